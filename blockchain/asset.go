@@ -0,0 +1,188 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// AssetIDSize is the size of the ID of an asset.
+const AssetIDSize = sha256.Size
+
+// Asset is a named token issued once with a fixed supply. Its ID is derived
+// from the first outpoint the issuance transaction spends, which no other
+// transaction can spend again, so nobody can issue more of it later.
+type Asset struct {
+	ID      []byte
+	Name    string
+	Supply  int
+	IssueTx []byte
+}
+
+// NewTokenOutput pays tokens of asset to address. Token outputs carry no
+// coins.
+func NewTokenOutput(asset []byte, tokens int, address string) *TxOutput {
+	out := NewTXOutput(0, address)
+	out.Asset = asset
+	out.Tokens = tokens
+
+	return out
+}
+
+// HasAsset reports whether out carries tokens rather than coins.
+func (out *TxOutput) HasAsset() bool {
+	return len(out.Asset) > 0 || out.Tokens != 0
+}
+
+// IssuedAsset is the ID of the asset tx issues, if it has outputs of it.
+func (tx *Transaction) IssuedAsset() []byte {
+	var data bytes.Buffer
+
+	if len(tx.Inputs) > 0 {
+		writeBytes(&data, tx.Inputs[0].ID)
+		writeInt(&data, tx.Inputs[0].Out)
+	}
+	hash := sha256.Sum256(data.Bytes())
+
+	return hash[:]
+}
+
+// Issuance describes the asset tx issues: its outputs of the asset make up
+// the supply and its first data output carries the name.
+func (tx *Transaction) Issuance() (*Asset, bool) {
+	if tx.IsCoinbase() {
+		return nil, false
+	}
+
+	asset := &Asset{ID: tx.IssuedAsset(), IssueTx: tx.ID}
+	named := false
+
+	for _, out := range tx.Outputs {
+		if bytes.Equal(out.Asset, asset.ID) {
+			asset.Supply += out.Tokens
+		}
+		if data, ok := out.Data(); ok && !named {
+			asset.Name, named = string(data), true
+		}
+	}
+
+	return asset, asset.Supply > 0
+}
+
+// checkTokens is the token half of Verify: every asset but the one tx
+// issues leaves it in exactly the amounts it came in.
+func (tx *Transaction) checkTokens(prevTXs map[string]Transaction) bool {
+	tokens := make(map[string]int)
+
+	for _, in := range tx.Inputs {
+		prevOut := prevTXs[hex.EncodeToString(in.ID)].Outputs[in.Out]
+		if prevOut.HasAsset() {
+			tokens[hex.EncodeToString(prevOut.Asset)] += prevOut.Tokens
+		}
+	}
+
+	for _, out := range tx.Outputs {
+		if out.HasAsset() {
+			tokens[hex.EncodeToString(out.Asset)] -= out.Tokens
+		}
+	}
+
+	issued := hex.EncodeToString(tx.IssuedAsset())
+	for asset, left := range tokens {
+		if left != 0 && !(asset == issued && left < 0) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewIssuanceTx issues supply tokens of a new asset called name to the
+// address of w, which also pays the fee.
+func NewIssuanceTx(w *wallet.Wallet, name string, supply int, UTXO *UTXOSet, opts SendOptions) (*Transaction, error) {
+	if name == "" {
+		return nil, errors.New("an asset needs a name")
+	}
+	if supply <= 0 {
+		return nil, errors.New("supply must be positive")
+	}
+
+	nameOut, err := NewDataOutput([]byte(name))
+	if err != nil {
+		return nil, err
+	}
+
+	// The asset ID is only known once the inputs are, see
+	// newUnsignedTransaction.
+	supplyOut := NewTXOutput(0, string(w.Address()))
+	supplyOut.Tokens = supply
+
+	return NewTransactionWith(w, []TxOutput{*supplyOut, *nameOut}, UTXO, opts)
+}
+
+// TokenBalance adds up the tokens of asset in outs, or their coins if asset
+// is nil.
+func TokenBalance(outs []TxOutput, asset []byte) int {
+	balance := 0
+
+	for _, out := range outs {
+		if asset == nil && !out.HasAsset() {
+			balance += out.Value
+		} else if asset != nil && bytes.Equal(out.Asset, asset) {
+			balance += out.Tokens
+		}
+	}
+
+	return balance
+}
+
+// FindAssets returns the assets issued on the chain, oldest first.
+func (chain *BlockChain) FindAssets() []*Asset {
+	var assets []*Asset
+
+	iter := chain.Iterator()
+	for {
+		block := iter.Next()
+
+		for i := len(block.Transactions) - 1; i >= 0; i-- {
+			if asset, ok := block.Transactions[i].Issuance(); ok {
+				assets = append([]*Asset{asset}, assets...)
+			}
+		}
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	return assets
+}
+
+// FindAsset looks an asset up by its hex encoded ID or by its name, which
+// has to be unique then.
+func (chain *BlockChain) FindAsset(idOrName string) (*Asset, error) {
+	var found *Asset
+
+	for _, asset := range chain.FindAssets() {
+		if hex.EncodeToString(asset.ID) == idOrName {
+			return asset, nil
+		}
+		if asset.Name != idOrName {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("several assets are called %s, give the asset ID", idOrName)
+		}
+		found = asset
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("unknown asset %s", idOrName)
+	}
+
+	return found, nil
+}
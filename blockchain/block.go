@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/gob"
 	"log"
-	"time"
 )
 
 type Block struct {
@@ -16,8 +15,8 @@ type Block struct {
 	Height       int
 }
 
-func CreateBlock(txs []*Transaction, prevHash []byte, height int) *Block {
-	block := &Block{time.Now().Unix(), []byte{}, txs, prevHash, 0, height}
+func CreateBlock(txs []*Transaction, prevHash []byte, height int, timestamp int64) *Block {
+	block := &Block{timestamp, []byte{}, txs, prevHash, 0, height}
 
 	pow := NewProof(block)
 
@@ -26,8 +25,8 @@ func CreateBlock(txs []*Transaction, prevHash []byte, height int) *Block {
 	return block
 }
 
-func Genesis(coinbase *Transaction) *Block {
-	return CreateBlock([]*Transaction{coinbase}, []byte{}, 0)
+func Genesis(coinbase *Transaction, timestamp int64) *Block {
+	return CreateBlock([]*Transaction{coinbase}, []byte{}, 0, timestamp)
 }
 
 func (b *Block) Serialize() []byte {
@@ -44,7 +43,7 @@ func (b *Block) HashTransactions() []byte {
 	var txHashes [][]byte
 
 	for _, tx := range b.Transactions {
-		txHashes = append(txHashes, tx.Serialize())
+		txHashes = append(txHashes, tx.hashData())
 	}
 	tree := NewMerkleTree(txHashes)
 
@@ -68,3 +67,29 @@ func HandleErr(err error) {
 		log.Panic(err)
 	}
 }
+
+// MerkleProof proves that the transaction txID is part of the block, or
+// returns false when it is not.
+func (b *Block) MerkleProof(txID []byte) (*MerkleProof, bool) {
+	var txHashes [][]byte
+	index := -1
+
+	for i, tx := range b.Transactions {
+		if bytes.Equal(tx.ID, txID) {
+			index = i
+		}
+		txHashes = append(txHashes, tx.hashData())
+	}
+
+	if index < 0 {
+		return nil, false
+	}
+
+	return NewMerkleProof(txHashes, index), true
+}
+
+// VerifyMerkleProof reports whether proof shows tx to be part of a block
+// whose transactions hash to root.
+func VerifyMerkleProof(tx *Transaction, proof *MerkleProof, root []byte) bool {
+	return bytes.Equal(proof.Root(tx.hashData()), root)
+}
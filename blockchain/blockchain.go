@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/dgraph-io/badger"
 )
@@ -23,6 +24,9 @@ const (
 type BlockChain struct {
 	LastHash []byte
 	Database *badger.DB
+	// Clock stamps new blocks. It is the wall clock unless a simulation
+	// sets a virtual one.
+	Clock func() time.Time
 }
 
 type BlockChainIterator struct {
@@ -54,6 +58,9 @@ func retry(dir string, originalOpts badger.Options) (*badger.DB, error) {
 
 func OpenDB(dir string, opts badger.Options) (*badger.DB, error) {
 	if db, err := badger.Open(opts); err != nil {
+		if strings.Contains(err.Error(), "Another process is using this Badger database") {
+			return nil, errors.New("the database is in use by a running node, talk to it with -rpc instead")
+		}
 		if strings.Contains(err.Error(), "LOCK") {
 			if db, err := retry(dir, opts); err == nil {
 				log.Println("database unlocked, value log truncated")
@@ -75,6 +82,17 @@ func InitBlockchain(address string, nodeId string) *BlockChain {
 		fmt.Println("Blockchain already exist")
 		runtime.Goexit()
 	}
+
+	return InitBlockchainAt(path, address)
+}
+
+func InitBlockchainAt(path, address string) *BlockChain {
+	return InitBlockchainWith(path, address, time.Now)
+}
+
+// InitBlockchainWith creates a chain whose blocks, the genesis block
+// included, are stamped with the time clock returns.
+func InitBlockchainWith(path, address string, clock func() time.Time) *BlockChain {
 	var lastHash []byte
 
 	opts := badger.DefaultOptions
@@ -85,8 +103,8 @@ func InitBlockchain(address string, nodeId string) *BlockChain {
 	HandleErr(err)
 
 	err = db.Update(func(txn *badger.Txn) error {
-		cbtx := CoinBaseTx(address, genesisData)
-		genesis := Genesis(cbtx)
+		cbtx := CoinBaseTx(address, genesisData, 0)
+		genesis := Genesis(cbtx, clock().Unix())
 		fmt.Println("Genesis created")
 		err := txn.Set(genesis.Hash, genesis.Serialize())
 		HandleErr(err)
@@ -99,7 +117,7 @@ func InitBlockchain(address string, nodeId string) *BlockChain {
 
 	HandleErr(err)
 
-	blockchain := BlockChain{lastHash, db}
+	blockchain := BlockChain{LastHash: lastHash, Database: db, Clock: clock}
 	return &blockchain
 }
 
@@ -110,6 +128,24 @@ func ContinueBlockchain(nodeId string) *BlockChain {
 		runtime.Goexit()
 	}
 
+	chain := ContinueBlockchainAt(path)
+
+	// Transaction IDs, signatures and the transaction hash of blocks are
+	// computed over hashData instead of gob since partially signed
+	// transactions were added. Blocks mined before fail proof of work, so
+	// chains created by earlier versions have to be created again.
+	tip, err := chain.GetBlock(chain.LastHash)
+	HandleErr(err)
+	if !NewProof(&tip).Validate() {
+		chain.Database.Close()
+		fmt.Printf("The blockchain in %s was created by a version hashing transactions differently, remove it and run createblockchain again\n", path)
+		runtime.Goexit()
+	}
+
+	return chain
+}
+
+func ContinueBlockchainAt(path string) *BlockChain {
 	var lastHash []byte
 
 	opts := badger.DefaultOptions
@@ -128,7 +164,7 @@ func ContinueBlockchain(nodeId string) *BlockChain {
 	})
 	HandleErr(err)
 
-	blockchain := BlockChain{lastHash, db}
+	blockchain := BlockChain{LastHash: lastHash, Database: db, Clock: time.Now}
 
 	return &blockchain
 }
@@ -142,11 +178,17 @@ func (chain *BlockChain) FindUnspentOutputs() map[string]TxOutputs {
 	for {
 		block := iter.Next()
 
-		for _, tx := range block.Transactions {
+		// Children come after their parents in a block, so walk it backwards
+		// too to see their inputs before the outputs they spend.
+		for i := len(block.Transactions) - 1; i >= 0; i-- {
+			tx := block.Transactions[i]
 			txID := hex.EncodeToString(tx.ID)
 
 		outputs:
 			for outIdx, out := range tx.Outputs {
+				if out.IsUnspendable() {
+					continue
+				}
 				if spentTXOs[txID] != nil {
 					for _, spentOut := range spentTXOs[txID] {
 						if spentOut == outIdx {
@@ -157,6 +199,7 @@ func (chain *BlockChain) FindUnspentOutputs() map[string]TxOutputs {
 
 				outs := UTXO[txID]
 				outs.Outputs = append(outs.Outputs, out)
+				outs.Indexes = append(outs.Indexes, outIdx)
 				UTXO[txID] = outs
 			}
 
@@ -180,10 +223,12 @@ func (chain *BlockChain) MineBlock(transactions []*Transaction) *Block {
 	var lastHash []byte
 	var lastHeight int
 
+	inBlock := make(map[string]Transaction)
 	for _, tx := range transactions {
-		if chain.VerifyTx(tx) != true {
+		if chain.VerifyTxWith(tx, inBlock) != true {
 			log.Panic("Invalid Transaction")
 		}
+		inBlock[hex.EncodeToString(tx.ID)] = *tx
 	}
 
 	err := chain.Database.View(func(txn *badger.Txn) error {
@@ -202,7 +247,7 @@ func (chain *BlockChain) MineBlock(transactions []*Transaction) *Block {
 		return err
 	})
 	HandleErr(err)
-	newBlock := CreateBlock(transactions, lastHash, lastHeight+1)
+	newBlock := CreateBlock(transactions, lastHash, lastHeight+1, chain.Clock().Unix())
 
 	err = chain.Database.Update(func(txn *badger.Txn) error {
 		err = txn.Set(newBlock.Hash, newBlock.Serialize())
@@ -241,7 +286,7 @@ func (chain *BlockChain) AddBlock(block *Block) {
 		if block.Height > lastBlock.Height {
 			err := txn.Set([]byte("lh"), block.Hash)
 			HandleErr(err)
-			chain.LastHash = lastHash
+			chain.LastHash = block.Hash
 		}
 
 		return nil
@@ -250,6 +295,25 @@ func (chain *BlockChain) AddBlock(block *Block) {
 	HandleErr(err)
 }
 
+// SetTip makes the stored block with the given hash the last block.
+func (chain *BlockChain) SetTip(hash []byte) {
+	err := chain.Database.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("lh"), hash)
+	})
+	HandleErr(err)
+
+	chain.LastHash = hash
+}
+
+// RemoveBlock forgets a block that turned out to be invalid. It must not
+// be the last block.
+func (chain *BlockChain) RemoveBlock(hash []byte) {
+	err := chain.Database.Update(func(txn *badger.Txn) error {
+		return txn.Delete(hash)
+	})
+	HandleErr(err)
+}
+
 func (chain *BlockChain) GetBlock(blockHash []byte) (Block, error) {
 	var block Block
 
@@ -270,6 +334,37 @@ func (chain *BlockChain) GetBlock(blockHash []byte) (Block, error) {
 	return block, nil
 }
 
+func (chain *BlockChain) FindFork(oldTip, newTip []byte) ([]*Block, []*Block, error) {
+	var disconnected, connected []*Block
+
+	oldBlock, err := chain.GetBlock(oldTip)
+	if err != nil {
+		return nil, nil, err
+	}
+	newBlock, err := chain.GetBlock(newTip)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for !bytes.Equal(oldBlock.Hash, newBlock.Hash) {
+		if oldBlock.Height >= newBlock.Height {
+			block := oldBlock
+			disconnected = append(disconnected, &block)
+			if oldBlock, err = chain.GetBlock(oldBlock.PrevHash); err != nil {
+				return nil, nil, err
+			}
+		} else {
+			block := newBlock
+			connected = append([]*Block{&block}, connected...)
+			if newBlock, err = chain.GetBlock(newBlock.PrevHash); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return disconnected, connected, nil
+}
+
 func (chain *BlockChain) GetBlockHashes() [][]byte {
 	var blockHashes [][]byte
 
@@ -357,11 +452,108 @@ func (bc *BlockChain) FindTx(ID []byte) (Transaction, error) {
 	return Transaction{}, errors.New("Transaction does not exist")
 }
 
+// UsedPubKeyHashes returns the hex encoded public key hashes that received an
+// output anywhere in the chain.
+func (bc *BlockChain) UsedPubKeyHashes() map[string]bool {
+	used := make(map[string]bool)
+	iter := bc.Iterator()
+
+	for {
+		block := iter.Next()
+
+		for _, tx := range block.Transactions {
+			for _, out := range tx.Outputs {
+				used[hex.EncodeToString(out.PubKeyHash)] = true
+			}
+		}
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	return used
+}
+
+// WalletTx is a confirmed transaction touching a set of public key hashes,
+// with the amounts it paid to and spent from them.
+type WalletTx struct {
+	Tx       *Transaction
+	Block    *Block
+	Received int
+	Sent     int
+	Matched  map[string]bool
+}
+
+// FindWalletTxs returns the transactions paying to or spending from any of
+// the hex encoded public key hashes, oldest first.
+func (bc *BlockChain) FindWalletTxs(pubKeyHashes map[string]bool) []WalletTx {
+	var blocks []*Block
+	outputs := make(map[string][]TxOutput)
+
+	iter := bc.Iterator()
+	for {
+		block := iter.Next()
+		blocks = append(blocks, block)
+
+		for _, tx := range block.Transactions {
+			outputs[hex.EncodeToString(tx.ID)] = tx.Outputs
+		}
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	var txs []WalletTx
+
+	for i := len(blocks) - 1; i >= 0; i-- {
+		for _, tx := range blocks[i].Transactions {
+			wtx := WalletTx{Tx: tx, Block: blocks[i], Matched: make(map[string]bool)}
+
+			for _, out := range tx.Outputs {
+				pubKeyHash := hex.EncodeToString(out.PubKeyHash)
+				if pubKeyHashes[pubKeyHash] {
+					wtx.Received += out.Value
+					wtx.Matched[pubKeyHash] = true
+				}
+			}
+
+			if !tx.IsCoinbase() {
+				for _, in := range tx.Inputs {
+					prevOuts := outputs[hex.EncodeToString(in.ID)]
+					if in.Out >= len(prevOuts) {
+						continue
+					}
+
+					pubKeyHash := hex.EncodeToString(prevOuts[in.Out].PubKeyHash)
+					if pubKeyHashes[pubKeyHash] {
+						wtx.Sent += prevOuts[in.Out].Value
+						wtx.Matched[pubKeyHash] = true
+					}
+				}
+			}
+
+			if len(wtx.Matched) > 0 {
+				txs = append(txs, wtx)
+			}
+		}
+	}
+
+	return txs
+}
+
 func (bc *BlockChain) SignTx(tx *Transaction, privKey ecdsa.PrivateKey) {
+	bc.SignTxWith(tx, privKey, nil)
+}
+
+// SignTxWith is SignTx for a transaction that may spend outputs of the
+// unconfirmed transactions in pending.
+func (bc *BlockChain) SignTxWith(tx *Transaction, privKey ecdsa.PrivateKey, pending map[string]Transaction) {
 	prevTxs := make(map[string]Transaction)
 
 	for _, in := range tx.Inputs {
-		prevTx, err := bc.FindTx(in.ID)
+		prevTx, err := bc.FindTxWith(in.ID, pending)
 		HandleErr(err)
 		prevTxs[hex.EncodeToString(in.ID)] = prevTx
 	}
@@ -369,16 +561,69 @@ func (bc *BlockChain) SignTx(tx *Transaction, privKey ecdsa.PrivateKey) {
 	tx.Sign(privKey, prevTxs)
 }
 
+// FindTxWith finds the transaction with the given ID among pending, keyed by
+// hex encoded ID, or in the chain.
+func (bc *BlockChain) FindTxWith(ID []byte, pending map[string]Transaction) (Transaction, error) {
+	if tx, ok := pending[hex.EncodeToString(ID)]; ok {
+		return tx, nil
+	}
+
+	return bc.FindTx(ID)
+}
+
+// Fee is the value of the outputs tx spends that it does not pay out again.
+func (bc *BlockChain) Fee(tx *Transaction) (int, error) {
+	return bc.FeeWith(tx, nil)
+}
+
+// FeeWith is Fee for a transaction that may spend outputs of the
+// unconfirmed transactions in pending.
+func (bc *BlockChain) FeeWith(tx *Transaction, pending map[string]Transaction) (int, error) {
+	if tx.IsCoinbase() {
+		return 0, nil
+	}
+
+	fee := 0
+	for _, in := range tx.Inputs {
+		prevTx, err := bc.FindTxWith(in.ID, pending)
+		if err != nil {
+			return 0, err
+		}
+		if in.Out < 0 || in.Out >= len(prevTx.Outputs) {
+			return 0, fmt.Errorf("transaction %x has no output %d", in.ID, in.Out)
+		}
+		fee += prevTx.Outputs[in.Out].Value
+	}
+
+	for _, out := range tx.Outputs {
+		fee -= out.Value
+	}
+
+	return fee, nil
+}
+
 func (bc *BlockChain) VerifyTx(tx *Transaction) bool {
+	return bc.VerifyTxWith(tx, nil)
+}
+
+// VerifyTxWith is VerifyTx for a transaction that may spend outputs of the
+// unconfirmed transactions in pending, keyed by hex encoded ID.
+func (bc *BlockChain) VerifyTxWith(tx *Transaction, pending map[string]Transaction) bool {
 	if tx.IsCoinbase() {
-		return true
+		return tx.Verify(nil)
+	}
+
+	if bc.CheckLockTimes(tx, bc.GetBestHeight()+1, bc.LastHash) != nil {
+		return false
 	}
 
 	prevTxs := make(map[string]Transaction)
 
 	for _, in := range tx.Inputs {
-		prevTx, err := bc.FindTx(in.ID)
-		HandleErr(err)
+		prevTx, err := bc.FindTxWith(in.ID, pending)
+		if err != nil {
+			return false
+		}
 		prevTxs[hex.EncodeToString(in.ID)] = prevTx
 	}
 
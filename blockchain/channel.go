@@ -0,0 +1,162 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// PaymentChannel is the funding contract of a unidirectional payment
+// channel. Spending it takes the signatures of both parties, so the payer
+// pays off-chain by signing commitments that pay the payee ever more and
+// the payee closes the channel with the latest one. If the payee does not,
+// the payer alone takes the funds back once LockTime has passed.
+type PaymentChannel struct {
+	PayerPubKey []byte
+	PayeePubKey []byte
+	LockTime    int64
+}
+
+// Script is the locking script of the funding output:
+//
+//	OP_IF
+//	    2 <payer key> <payee key> 2 OP_CHECKMULTISIG
+//	OP_ELSE
+//	    <lock time> OP_CHECKLOCKTIMEVERIFY OP_DROP
+//	    OP_DUP OP_HASH160 <payer> OP_EQUALVERIFY OP_CHECKSIG
+//	OP_ENDIF
+func (c *PaymentChannel) Script() []byte {
+	return NewScriptBuilder().
+		AddOp(OpIf).AddInt(2).AddData(c.PayerPubKey).AddData(c.PayeePubKey).AddInt(2).AddOp(OpCheckMultiSig).
+		AddOp(OpElse).AddInt(c.LockTime).AddOp(OpCheckLockTimeVerify, OpDrop).
+		AddOp(OpDup, OpHash160).AddData(wallet.PublicKeyHash(c.PayerPubKey)).AddOp(OpEqualVerify, OpCheckSig).
+		AddOp(OpEndIf).Script()
+}
+
+func (c *PaymentChannel) PayerAddress() string {
+	return string(wallet.PubKeyHashToAddress(wallet.PublicKeyHash(c.PayerPubKey)))
+}
+
+func (c *PaymentChannel) PayeeAddress() string {
+	return string(wallet.PubKeyHashToAddress(wallet.PublicKeyHash(c.PayeePubKey)))
+}
+
+// ParseChannelScript recovers the contract a funding output was locked
+// with.
+func ParseChannelScript(script []byte) (*PaymentChannel, error) {
+	instructions, err := parseScript(script)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(instructions) != 16 {
+		return nil, errors.New("not a payment channel")
+	}
+
+	c := &PaymentChannel{
+		PayerPubKey: instructions[2].data,
+		PayeePubKey: instructions[3].data,
+	}
+
+	if n, ok := smallInt(instructions[7]); ok {
+		c.LockTime = int64(n)
+	} else if lockTime, err := decodeNum(instructions[7].data, maxLockTimeSize); err == nil {
+		c.LockTime = lockTime
+	}
+
+	if !bytes.Equal(c.Script(), script) {
+		return nil, errors.New("not a payment channel")
+	}
+	for _, pubKey := range [][]byte{c.PayerPubKey, c.PayeePubKey} {
+		if _, err := wallet.ParsePublicKey(pubKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// NewCommitmentTx spends the funding output fundingTxID:out, paying paid to
+// the payee and the rest back to the payer, who pays the fee. Both parties
+// build the same transaction from the same state.
+func NewCommitmentTx(fundingTxID []byte, out int, funding TxOutput, paid int, rate FeeRate) (*Transaction, error) {
+	c, err := ParseChannelScript(funding.Script)
+	if err != nil {
+		return nil, err
+	}
+
+	fee := rate.Fee(EstimateSize(1, 2))
+	if paid < 0 || paid+fee > funding.Value {
+		return nil, fmt.Errorf("the channel can pay at most %d", funding.Value-fee)
+	}
+
+	tx := &Transaction{Inputs: []TxInput{{ID: fundingTxID, Out: out}}}
+	if paid > 0 {
+		tx.Outputs = append(tx.Outputs, *NewTXOutput(paid, c.PayeeAddress()))
+	}
+	if change := funding.Value - paid - fee; change >= rate.DustThreshold() {
+		tx.Outputs = append(tx.Outputs, *NewTXOutput(change, c.PayerAddress()))
+	}
+	tx.ID = tx.UnsignedHash()
+
+	return tx, nil
+}
+
+// CheckCommitmentSignature reports whether sig is a valid signature of the
+// commitment tx by pubKey.
+func CheckCommitmentSignature(tx *Transaction, funding TxOutput, sig, pubKey []byte) bool {
+	return txSigChecker{tx, 0, funding}.CheckSig(sig, pubKey)
+}
+
+// CloseCommitmentTx adds the payee's signature to a commitment the payer
+// signed, making it spend the funding output.
+func CloseCommitmentTx(tx *Transaction, funding TxOutput, payerSig []byte, payee *wallet.Wallet) (*Transaction, error) {
+	c, err := ParseChannelScript(funding.Script)
+	if err != nil {
+		return nil, err
+	}
+
+	if !CheckCommitmentSignature(tx, funding, payerSig, c.PayerPubKey) {
+		return nil, errors.New("the payer's signature of the commitment is not valid")
+	}
+	if !bytes.Equal(payee.PublicKey, c.PayeePubKey) {
+		return nil, errors.New("the wallet key is not the payee's")
+	}
+
+	payeeSig := tx.SignatureFor(0, payee.PrivateKey, funding)
+	tx.Inputs[0].Script = NewScriptBuilder().AddData(payerSig).AddData(payeeSig).AddInt(1).Script()
+
+	return tx, nil
+}
+
+// NewChannelRefundTx spends the funding output back to the payer. It cannot
+// be mined before the channel's lock time.
+func NewChannelRefundTx(fundingTxID []byte, out int, funding TxOutput, payer *wallet.Wallet, rate FeeRate) (*Transaction, error) {
+	c, err := ParseChannelScript(funding.Script)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(payer.PublicKey, c.PayerPubKey) {
+		return nil, errors.New("the wallet key is not the payer's")
+	}
+
+	value := funding.Value - rate.Fee(EstimateSize(1, 1))
+	if value < rate.DustThreshold() {
+		return nil, ErrInsufficientFunds
+	}
+
+	tx := &Transaction{
+		Inputs:   []TxInput{{ID: fundingTxID, Out: out}},
+		Outputs:  []TxOutput{*NewTXOutput(value, c.PayerAddress())},
+		LockTime: int(c.LockTime),
+	}
+	tx.ID = tx.UnsignedHash()
+
+	sig := tx.SignatureFor(0, payer.PrivateKey, funding)
+	tx.Inputs[0].Script = NewScriptBuilder().AddData(sig).AddData(payer.PublicKey).AddInt(0).Script()
+
+	return tx, nil
+}
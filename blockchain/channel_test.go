@@ -0,0 +1,108 @@
+package blockchain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// testChannel locks 1000 coins in a channel from payer to payee that the
+// payer can take back from height 100 on.
+func testChannel(payer, payee *wallet.Wallet) (*PaymentChannel, []byte, TxOutput) {
+	c := &PaymentChannel{PayerPubKey: payer.PublicKey, PayeePubKey: payee.PublicKey, LockTime: 100}
+
+	return c, []byte("funding transaction"), *NewScriptOutput(1000, c.Script())
+}
+
+func TestChannelCooperativeClose(t *testing.T) {
+	payer, payee := wallet.MakeWallet(), wallet.MakeWallet()
+	c, fundingID, funding := testChannel(payer, payee)
+
+	parsed, err := ParseChannelScript(funding.Script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.PayerAddress() != c.PayerAddress() || parsed.PayeeAddress() != c.PayeeAddress() || parsed.LockTime != c.LockTime {
+		t.Fatalf("parsed %+v", parsed)
+	}
+
+	commitment, err := NewCommitmentTx(fundingID, 0, funding, 300, DefaultFeeRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := TokenBalance(commitment.Outputs[:1], nil); got != 300 || commitment.Outputs[0].Address() != c.PayeeAddress() {
+		t.Fatalf("the commitment pays %d to %s", got, commitment.Outputs[0].Address())
+	}
+	payerSig := commitment.SignatureFor(0, payer.PrivateKey, funding)
+
+	closed, err := CloseCommitmentTx(commitment, funding, payerSig, payee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := closed.VerifyInputScript(0, funding); err != nil {
+		t.Fatalf("closing with both signatures: %s", err)
+	}
+
+	// The payer's signature commits to what the payee gets.
+	more, err := NewCommitmentTx(fundingID, 0, funding, 400, DefaultFeeRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CloseCommitmentTx(more, funding, payerSig, payee); err == nil {
+		t.Error("closed a commitment the payer did not sign")
+	}
+	if _, err := CloseCommitmentTx(commitment, funding, payerSig, payer); err == nil {
+		t.Error("the payer closed the channel as the payee")
+	}
+
+	alone := *closed
+	alone.Inputs = []TxInput{{ID: fundingID, Out: 0}}
+	payeeSig := alone.SignatureFor(0, payee.PrivateKey, funding)
+	alone.Inputs[0].Script = NewScriptBuilder().AddData(payeeSig).AddData(payeeSig).AddInt(1).Script()
+	if err := alone.VerifyInputScript(0, funding); err == nil {
+		t.Error("the payee closed the channel without the payer's signature")
+	}
+
+	if _, err := NewCommitmentTx(fundingID, 0, funding, 1000, DefaultFeeRate); err == nil || !strings.Contains(err.Error(), "at most") {
+		t.Errorf("paying the whole capacity and the fee got %v", err)
+	}
+}
+
+func TestChannelRefund(t *testing.T) {
+	payer, payee := wallet.MakeWallet(), wallet.MakeWallet()
+	c, fundingID, funding := testChannel(payer, payee)
+
+	refund, err := NewChannelRefundTx(fundingID, 0, funding, payer, DefaultFeeRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := refund.VerifyInputScript(0, funding); err != nil {
+		t.Fatalf("refunding: %s", err)
+	}
+	if refund.Outputs[0].Address() != c.PayerAddress() {
+		t.Errorf("the refund pays %s", refund.Outputs[0].Address())
+	}
+
+	chain := &BlockChain{}
+	if err := chain.CheckLockTimes(refund, 99, nil); err == nil {
+		t.Error("the refund can be mined before the lock time")
+	}
+	if err := chain.CheckLockTimes(refund, 100, nil); err != nil {
+		t.Errorf("the refund cannot be mined at the lock time: %s", err)
+	}
+
+	early := *refund
+	early.Inputs = []TxInput{{ID: fundingID, Out: 0}}
+	early.LockTime = 99
+	early.ID = early.UnsignedHash()
+	sig := early.SignatureFor(0, payer.PrivateKey, funding)
+	early.Inputs[0].Script = NewScriptBuilder().AddData(sig).AddData(payer.PublicKey).AddInt(0).Script()
+	if err := early.VerifyInputScript(0, funding); err == nil || !strings.Contains(err.Error(), "lock time is before 100") {
+		t.Fatalf("refunding before the lock time got %v", err)
+	}
+
+	if _, err := NewChannelRefundTx(fundingID, 0, funding, payee, DefaultFeeRate); err == nil {
+		t.Error("the payee took the refund")
+	}
+}
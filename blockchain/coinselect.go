@@ -0,0 +1,303 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Estimated encoded sizes in bytes, used to price transactions before they
+// are signed.
+const (
+	txOverheadSize = 10
+	inputSize      = 180
+	outputSize     = 34
+)
+
+// DefaultFeeRate is the fee rate used when none is given.
+const DefaultFeeRate FeeRate = 1
+
+var ErrInsufficientFunds = errors.New("not enough funds")
+
+// FeeRate is a fee in coins per 1000 bytes of transaction.
+type FeeRate int
+
+// Fee is the fee for size bytes, rounded up.
+func (r FeeRate) Fee(size int) int {
+	return (int(r)*size + 999) / 1000
+}
+
+// DustThreshold is the smallest output worth creating. Anything smaller
+// costs a good part of its value in fees to spend again.
+func (r FeeRate) DustThreshold() int {
+	threshold := 3 * r.Fee(inputSize)
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	return threshold
+}
+
+func EstimateSize(inputs, outputs int) int {
+	return txOverheadSize + inputs*inputSize + outputs*outputSize
+}
+
+// Selection is the result of coin selection. Change is zero when the
+// remainder was too small for a change output and went to the fee instead.
+type Selection struct {
+	Inputs []UTXO
+	Fee    int
+	Change int
+}
+
+// CoinSelector picks the outputs that pay for target coins spread over
+// outputs outputs, plus the fee at rate.
+type CoinSelector interface {
+	Select(utxos []UTXO, target, outputs int, rate FeeRate) (*Selection, error)
+}
+
+// CoinSelectorByName returns the built in strategy called name. The manual
+// strategy spends exactly the TXID:VOUT outpoints given.
+func CoinSelectorByName(name string, outpoints []string) (CoinSelector, error) {
+	switch name {
+	case "", "bnb":
+		return BranchAndBound{}, nil
+	case "largest":
+		return LargestFirst{}, nil
+	case "random":
+		return RandomImprove{}, nil
+	case "manual":
+		selector := ManualSelection{}
+		for _, outpoint := range outpoints {
+			utxo, err := ParseOutpoint(outpoint)
+			if err != nil {
+				return nil, err
+			}
+			selector.Outpoints = append(selector.Outpoints, utxo)
+		}
+
+		if len(selector.Outpoints) == 0 {
+			return nil, errors.New("manual coin selection needs at least one outpoint")
+		}
+
+		return selector, nil
+	}
+
+	return nil, fmt.Errorf("unknown coin selection strategy %q, use bnb, largest, random or manual", name)
+}
+
+// ParseOutpoint parses TXID:VOUT into a UTXO without an output.
+func ParseOutpoint(s string) (UTXO, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return UTXO{}, fmt.Errorf("outpoint %s is not TXID:VOUT", s)
+	}
+
+	txID, err := hex.DecodeString(parts[0])
+	if err != nil {
+		return UTXO{}, fmt.Errorf("outpoint %s is not TXID:VOUT", s)
+	}
+
+	out, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return UTXO{}, fmt.Errorf("outpoint %s is not TXID:VOUT", s)
+	}
+
+	return UTXO{TxID: txID, Out: out}, nil
+}
+
+// settle prices inputs paying target and decides whether the remainder is
+// worth a change output. It fails when the inputs do not cover target and fee.
+func settle(inputs []UTXO, target, outputs int, rate FeeRate) (*Selection, bool) {
+	total := 0
+	for _, utxo := range inputs {
+		total += utxo.Output.Value
+	}
+
+	fee := rate.Fee(EstimateSize(len(inputs), outputs+1))
+	if change := total - target - fee; change >= rate.DustThreshold() {
+		return &Selection{inputs, fee, change}, true
+	}
+
+	fee = rate.Fee(EstimateSize(len(inputs), outputs))
+	if total < target+fee {
+		return nil, false
+	}
+
+	return &Selection{inputs, total - target, 0}, true
+}
+
+// sortByValue orders utxos largest first, breaking ties by outpoint so the
+// result does not depend on database order.
+func sortByValue(utxos []UTXO) []UTXO {
+	sorted := append([]UTXO(nil), utxos...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Output.Value != sorted[j].Output.Value {
+			return sorted[i].Output.Value > sorted[j].Output.Value
+		}
+		if c := bytes.Compare(sorted[i].TxID, sorted[j].TxID); c != 0 {
+			return c < 0
+		}
+		return sorted[i].Out < sorted[j].Out
+	})
+
+	return sorted
+}
+
+// LargestFirst spends the biggest outputs first, which uses few inputs and
+// consolidates nothing.
+type LargestFirst struct{}
+
+func (LargestFirst) Select(utxos []UTXO, target, outputs int, rate FeeRate) (*Selection, error) {
+	var inputs []UTXO
+
+	for _, utxo := range sortByValue(utxos) {
+		inputs = append(inputs, utxo)
+		if selection, ok := settle(inputs, target, outputs, rate); ok {
+			return selection, nil
+		}
+	}
+
+	return nil, ErrInsufficientFunds
+}
+
+// maxBnBTries bounds the branch and bound search.
+const maxBnBTries = 100000
+
+// BranchAndBound searches for inputs that pay target and fee with less than
+// a dust threshold left over, so the transaction needs no change output.
+// Without such a match it falls back to largest first.
+type BranchAndBound struct{}
+
+func (BranchAndBound) Select(utxos []UTXO, target, outputs int, rate FeeRate) (*Selection, error) {
+	sorted := sortByValue(utxos)
+
+	remaining := make([]int, len(sorted)+1)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + sorted[i].Output.Value
+	}
+
+	var picked []UTXO
+	tries := 0
+
+	var search func(i, total int) bool
+	search = func(i, total int) bool {
+		tries++
+		low := target + rate.Fee(EstimateSize(len(picked), outputs))
+		if total >= low {
+			return total < low+rate.DustThreshold()
+		}
+		if i == len(sorted) || total+remaining[i] < low || tries > maxBnBTries {
+			return false
+		}
+
+		picked = append(picked, sorted[i])
+		if search(i+1, total+sorted[i].Output.Value) {
+			return true
+		}
+		picked = picked[:len(picked)-1]
+
+		return search(i+1, total)
+	}
+
+	if search(0, 0) {
+		if selection, ok := settle(picked, target, outputs, rate); ok {
+			return selection, nil
+		}
+	}
+
+	return LargestFirst{}.Select(utxos, target, outputs, rate)
+}
+
+// RandomImprove picks outputs at random until target is paid, then keeps
+// adding random outputs while that brings the change closer to target. The
+// change output then resembles the payment and the UTXO set stays healthy.
+type RandomImprove struct{}
+
+func (RandomImprove) Select(utxos []UTXO, target, outputs int, rate FeeRate) (*Selection, error) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	shuffled := append([]UTXO(nil), utxos...)
+	r.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	var inputs []UTXO
+	var selection *Selection
+	i := 0
+
+	for ; i < len(shuffled); i++ {
+		inputs = append(inputs, shuffled[i])
+		if s, ok := settle(inputs, target, outputs, rate); ok {
+			selection = s
+			i++
+			break
+		}
+	}
+
+	if selection == nil {
+		return nil, ErrInsufficientFunds
+	}
+
+	distance := func(s *Selection) int {
+		if s.Change > target {
+			return s.Change - target
+		}
+		return target - s.Change
+	}
+
+	for ; i < len(shuffled); i++ {
+		candidate, ok := settle(append(inputs, shuffled[i]), target, outputs, rate)
+		if !ok || candidate.Change > 2*target || distance(candidate) >= distance(selection) {
+			continue
+		}
+
+		inputs = candidate.Inputs
+		selection = candidate
+	}
+
+	return selection, nil
+}
+
+// ManualSelection spends exactly the given outpoints, all of which must be
+// unspent outputs of the sender.
+type ManualSelection struct {
+	Outpoints []UTXO
+}
+
+func (m ManualSelection) Select(utxos []UTXO, target, outputs int, rate FeeRate) (*Selection, error) {
+	var inputs []UTXO
+
+	for i, outpoint := range m.Outpoints {
+		for _, other := range m.Outpoints[:i] {
+			if bytes.Equal(other.TxID, outpoint.TxID) && other.Out == outpoint.Out {
+				return nil, fmt.Errorf("output %x:%d is given twice", outpoint.TxID, outpoint.Out)
+			}
+		}
+
+		found := false
+		for _, utxo := range utxos {
+			if bytes.Equal(utxo.TxID, outpoint.TxID) && utxo.Out == outpoint.Out {
+				inputs = append(inputs, utxo)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("output %x:%d is not an unspent output of the sender", outpoint.TxID, outpoint.Out)
+		}
+	}
+
+	selection, ok := settle(inputs, target, outputs, rate)
+	if !ok {
+		return nil, ErrInsufficientFunds
+	}
+
+	return selection, nil
+}
@@ -0,0 +1,188 @@
+package blockchain
+
+import (
+	"strings"
+	"testing"
+)
+
+// coins returns unspent outputs of the given values, each of its own
+// transaction.
+func coins(values ...int) []UTXO {
+	var utxos []UTXO
+	for i, value := range values {
+		utxos = append(utxos, UTXO{TxID: []byte{byte(i + 1)}, Out: 0, Output: TxOutput{Value: value}})
+	}
+
+	return utxos
+}
+
+func values(utxos []UTXO) []int {
+	var values []int
+	for _, utxo := range utxos {
+		values = append(values, utxo.Output.Value)
+	}
+
+	return values
+}
+
+func TestCoinSelection(t *testing.T) {
+	// At rate 10 an input costs 2 coins in fees and outputs below 6 coins
+	// are dust.
+	const rate FeeRate = 10
+	wallet := coins(100, 50, 30, 20, 5)
+	manual := func(indexes ...int) ManualSelection {
+		var outpoints []UTXO
+		for _, i := range indexes {
+			outpoints = append(outpoints, UTXO{TxID: wallet[i].TxID, Out: wallet[i].Out})
+		}
+		return ManualSelection{Outpoints: outpoints}
+	}
+
+	tests := []struct {
+		name     string
+		selector CoinSelector
+		utxos    []UTXO
+		target   int
+		inputs   []int
+		fee      int
+		change   int
+		err      string
+	}{
+		{"largest", LargestFirst{}, wallet, 60, []int{100}, 3, 37, ""},
+		{"largest two", LargestFirst{}, wallet, 120, []int{100, 50}, 5, 25, ""},
+		{"largest short", LargestFirst{}, wallet, 300, nil, 0, 0, ErrInsufficientFunds.Error()},
+		{"bnb exact match", BranchAndBound{}, wallet, 47, []int{50}, 3, 0, ""},
+		{"bnb two inputs", BranchAndBound{}, wallet, 75, []int{50, 30}, 5, 0, ""},
+		{"bnb falls back to largest", BranchAndBound{}, coins(100), 60, []int{100}, 3, 37, ""},
+		{"bnb short", BranchAndBound{}, wallet, 300, nil, 0, 0, ErrInsufficientFunds.Error()},
+		{"random short", RandomImprove{}, wallet, 300, nil, 0, 0, ErrInsufficientFunds.Error()},
+		{"manual", manual(2, 3), wallet, 30, []int{30, 20}, 5, 15, ""},
+		{"manual folds dust change", manual(2, 3), wallet, 40, []int{30, 20}, 10, 0, ""},
+		{"manual short", manual(4), wallet, 10, nil, 0, 0, ErrInsufficientFunds.Error()},
+		{"manual twice", manual(4, 4), wallet, 1, nil, 0, 0, "given twice"},
+		{"manual unknown", ManualSelection{Outpoints: []UTXO{{TxID: []byte{9}}}}, wallet, 1, nil, 0, 0, "not an unspent output"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selection, err := test.selector.Select(test.utxos, test.target, 1, rate)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := values(selection.Inputs)
+			if len(got) != len(test.inputs) {
+				t.Fatalf("spent %v, want %v", got, test.inputs)
+			}
+			for i := range got {
+				if got[i] != test.inputs[i] {
+					t.Fatalf("spent %v, want %v", got, test.inputs)
+				}
+			}
+			if selection.Fee != test.fee || selection.Change != test.change {
+				t.Fatalf("fee %d and change %d, want %d and %d", selection.Fee, selection.Change, test.fee, test.change)
+			}
+		})
+	}
+}
+
+func TestRandomImprove(t *testing.T) {
+	const rate FeeRate = 10
+	wallet := coins(100, 50, 30, 20, 5, 40, 60, 10)
+
+	for i := 0; i < 50; i++ {
+		selection, err := RandomImprove{}.Select(wallet, 45, 1, rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		total := 0
+		for _, value := range values(selection.Inputs) {
+			total += value
+		}
+		outputs := 1
+		if selection.Change > 0 {
+			outputs++
+		}
+
+		if total != 45+selection.Fee+selection.Change {
+			t.Fatalf("inputs of %d pay 45, a fee of %d and change of %d", total, selection.Fee, selection.Change)
+		}
+		if selection.Fee < rate.Fee(EstimateSize(len(selection.Inputs), outputs)) {
+			t.Fatalf("fee %d is below the rate", selection.Fee)
+		}
+		if selection.Change != 0 && selection.Change < rate.DustThreshold() {
+			t.Fatalf("change %d is dust", selection.Change)
+		}
+	}
+
+	// Any coin pays 45, but its change of 2 is dust. A second coin brings
+	// change of 50, close to the payment, and a third one change of 98, more
+	// than twice the payment, whatever the order they are picked in.
+	selection, err := RandomImprove{}.Select(coins(50, 50, 50, 50), 45, 1, rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selection.Inputs) != 2 || selection.Change != 50 || selection.Fee != 5 {
+		t.Fatalf("got %d inputs, change %d and fee %d, want 2, 50 and 5", len(selection.Inputs), selection.Change, selection.Fee)
+	}
+}
+
+func TestSettleFoldsDustIntoFee(t *testing.T) {
+	const rate FeeRate = 10
+	inputs := coins(30, 20)
+
+	tests := []struct {
+		target int
+		fee    int
+		change int
+		ok     bool
+	}{
+		{30, 5, 15, true},
+		{39, 5, 6, true},
+		{40, 10, 0, true},
+		{45, 5, 0, true},
+		{46, 0, 0, false},
+	}
+
+	for _, test := range tests {
+		selection, ok := settle(inputs, test.target, 1, rate)
+		if ok != test.ok {
+			t.Fatalf("target %d: settled %t, want %t", test.target, ok, test.ok)
+		}
+		if ok && (selection.Fee != test.fee || selection.Change != test.change) {
+			t.Errorf("target %d: fee %d and change %d, want %d and %d", test.target, selection.Fee, selection.Change, test.fee, test.change)
+		}
+	}
+}
+
+func TestCoinSelectorByName(t *testing.T) {
+	for _, name := range []string{"", "bnb", "largest", "random"} {
+		if _, err := CoinSelectorByName(name, nil); err != nil {
+			t.Errorf("%q: %s", name, err)
+		}
+	}
+
+	selector, err := CoinSelectorByName("manual", []string{"0a0b:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outpoints := selector.(ManualSelection).Outpoints; len(outpoints) != 1 || outpoints[0].Out != 1 || outpoints[0].TxID[1] != 0x0b {
+		t.Fatalf("parsed %v", outpoints)
+	}
+
+	for _, bad := range []struct {
+		name      string
+		outpoints []string
+	}{{"manual", nil}, {"manual", []string{"0a0b"}}, {"manual", []string{"xx:1"}}, {"smallest", nil}} {
+		if _, err := CoinSelectorByName(bad.name, bad.outpoints); err == nil {
+			t.Errorf("%s %v was accepted", bad.name, bad.outpoints)
+		}
+	}
+}
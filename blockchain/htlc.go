@@ -0,0 +1,168 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// SecretSize is the size of the secrets generated for hash time-locked
+// contracts.
+const SecretSize = 32
+
+// HTLC is a hash time-locked contract. The recipient can spend it with the
+// SHA-256 preimage of SecretHash and a signature, the refund key with a
+// signature once a transaction may have a lock time of LockTime.
+type HTLC struct {
+	SecretHash          []byte
+	RecipientPubKeyHash []byte
+	RefundPubKeyHash    []byte
+	LockTime            int64
+}
+
+// Script is the locking script of the contract:
+//
+//	OP_IF
+//	    OP_SHA256 <secret hash> OP_EQUALVERIFY OP_DUP OP_HASH160 <recipient>
+//	OP_ELSE
+//	    <lock time> OP_CHECKLOCKTIMEVERIFY OP_DROP OP_DUP OP_HASH160 <refund>
+//	OP_ENDIF
+//	OP_EQUALVERIFY OP_CHECKSIG
+func (h *HTLC) Script() []byte {
+	return NewScriptBuilder().
+		AddOp(OpIf, OpSha256).AddData(h.SecretHash).AddOp(OpEqualVerify, OpDup, OpHash160).AddData(h.RecipientPubKeyHash).
+		AddOp(OpElse).AddInt(h.LockTime).AddOp(OpCheckLockTimeVerify, OpDrop, OpDup, OpHash160).AddData(h.RefundPubKeyHash).
+		AddOp(OpEndIf, OpEqualVerify, OpCheckSig).Script()
+}
+
+// ParseHTLCScript recovers the contract a locking script was made from.
+func ParseHTLCScript(script []byte) (*HTLC, error) {
+	instructions, err := parseScript(script)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(instructions) != 17 {
+		return nil, errors.New("not a hash time-locked contract")
+	}
+
+	h := &HTLC{
+		SecretHash:          instructions[2].data,
+		RecipientPubKeyHash: instructions[6].data,
+		RefundPubKeyHash:    instructions[13].data,
+	}
+
+	if n, ok := smallInt(instructions[8]); ok {
+		h.LockTime = int64(n)
+	} else if lockTime, err := decodeNum(instructions[8].data, maxLockTimeSize); err == nil {
+		h.LockTime = lockTime
+	}
+
+	if !bytes.Equal(h.Script(), script) || len(h.SecretHash) != sha256.Size {
+		return nil, errors.New("not a hash time-locked contract")
+	}
+
+	return h, nil
+}
+
+// ExtractSecret returns the secret revealed by the unlocking script of an
+// input that redeemed a contract with secretHash.
+func ExtractSecret(unlocking, secretHash []byte) ([]byte, bool) {
+	instructions, err := parseScript(unlocking)
+	if err != nil || len(instructions) != 4 {
+		return nil, false
+	}
+
+	secret := instructions[2].data
+	hash := sha256.Sum256(secret)
+
+	return secret, bytes.Equal(hash[:], secretHash)
+}
+
+// NewHTLCRedeemTx spends output out of contractTx to the address to with
+// the recipient's wallet w, revealing secret.
+func NewHTLCRedeemTx(contractTx *Transaction, out int, w *wallet.Wallet, secret []byte, to string, rate FeeRate) (*Transaction, error) {
+	return newHTLCSpend(contractTx, out, w, secret, to, rate)
+}
+
+// NewHTLCRefundTx spends output out of contractTx back to the address to
+// with the refund wallet w. It cannot be mined before the contract's lock
+// time.
+func NewHTLCRefundTx(contractTx *Transaction, out int, w *wallet.Wallet, to string, rate FeeRate) (*Transaction, error) {
+	return newHTLCSpend(contractTx, out, w, nil, to, rate)
+}
+
+func newHTLCSpend(contractTx *Transaction, out int, w *wallet.Wallet, secret []byte, to string, rate FeeRate) (*Transaction, error) {
+	if out < 0 || out >= len(contractTx.Outputs) {
+		return nil, fmt.Errorf("transaction %x has no output %d", contractTx.ID, out)
+	}
+
+	prevOut := contractTx.Outputs[out]
+	contract, err := ParseHTLCScript(prevOut.Script)
+	if err != nil {
+		return nil, err
+	}
+
+	pubKeyHash := contract.RefundPubKeyHash
+	if secret != nil {
+		pubKeyHash = contract.RecipientPubKeyHash
+		if hash := sha256.Sum256(secret); !bytes.Equal(hash[:], contract.SecretHash) {
+			return nil, errors.New("the secret does not match the contract's secret hash")
+		}
+	}
+	if !bytes.Equal(wallet.PublicKeyHash(w.PublicKey), pubKeyHash) {
+		return nil, errors.New("the wallet key cannot sign for this contract")
+	}
+
+	value := prevOut.Value - rate.Fee(EstimateSize(1, 1))
+	if value < rate.DustThreshold() {
+		return nil, ErrInsufficientFunds
+	}
+
+	tx := &Transaction{
+		Inputs:  []TxInput{{ID: contractTx.ID, Out: out}},
+		Outputs: []TxOutput{*NewTXOutput(value, to)},
+	}
+	if secret == nil {
+		tx.LockTime = int(contract.LockTime)
+	}
+	tx.ID = tx.UnsignedHash()
+
+	sig := tx.SignatureFor(0, w.PrivateKey, prevOut)
+	b := NewScriptBuilder().AddData(sig).AddData(w.PublicKey)
+	if secret != nil {
+		b.AddData(secret).AddInt(1)
+	} else {
+		b.AddInt(0)
+	}
+	tx.Inputs[0].Script = b.Script()
+
+	return tx, nil
+}
+
+// FindSpendingTx returns the transaction of the best chain that spends
+// output out of the transaction txID, if any.
+func (chain *BlockChain) FindSpendingTx(txID []byte, out int) (*Transaction, bool) {
+	iter := chain.Iterator()
+
+	for {
+		block := iter.Next()
+
+		for _, tx := range block.Transactions {
+			for _, in := range tx.Inputs {
+				if bytes.Equal(in.ID, txID) && in.Out == out {
+					return tx, true
+				}
+			}
+		}
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,140 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// testContract locks 100 coins in a contract paying recipient for the
+// preimage of secret, or refund from height 100 on.
+func testContract(recipient, refund *wallet.Wallet, secret []byte) (*HTLC, *Transaction) {
+	secretHash := sha256.Sum256(secret)
+	contract := &HTLC{
+		SecretHash:          secretHash[:],
+		RecipientPubKeyHash: wallet.PublicKeyHash(recipient.PublicKey),
+		RefundPubKeyHash:    wallet.PublicKeyHash(refund.PublicKey),
+		LockTime:            100,
+	}
+
+	tx := &Transaction{
+		Inputs:  []TxInput{{ID: []byte("funding"), Out: 0}},
+		Outputs: []TxOutput{*NewScriptOutput(100, contract.Script())},
+	}
+	tx.ID = tx.UnsignedHash()
+
+	return contract, tx
+}
+
+func TestParseHTLCScript(t *testing.T) {
+	secretHash := sha256.Sum256([]byte("secret"))
+
+	for _, lockTime := range []int64{0, 5, 100, 1000000, 1600000000} {
+		contract := &HTLC{secretHash[:], []byte("recipient hash 20 b"), []byte("refund hash of 20 by"), lockTime}
+
+		parsed, err := ParseHTLCScript(contract.Script())
+		if err != nil {
+			t.Fatalf("lock time %d: %s", lockTime, err)
+		}
+		if !bytes.Equal(parsed.SecretHash, contract.SecretHash) || !bytes.Equal(parsed.RecipientPubKeyHash, contract.RecipientPubKeyHash) ||
+			!bytes.Equal(parsed.RefundPubKeyHash, contract.RefundPubKeyHash) || parsed.LockTime != lockTime {
+			t.Fatalf("lock time %d: parsed %+v", lockTime, parsed)
+		}
+	}
+
+	short := &HTLC{[]byte("short"), []byte("recipient"), []byte("refund"), 100}
+	for _, script := range [][]byte{short.Script(), P2PKHScript([]byte("key hash")), {0xff}} {
+		if _, err := ParseHTLCScript(script); err == nil {
+			t.Errorf("parsed %x as a contract", script)
+		}
+	}
+}
+
+func TestHTLCRedeem(t *testing.T) {
+	recipient, refund := wallet.MakeWallet(), wallet.MakeWallet()
+	secret := []byte("secret")
+	contract, contractTx := testContract(recipient, refund, secret)
+	prevOut := contractTx.Outputs[0]
+
+	redeem, err := NewHTLCRedeemTx(contractTx, 0, recipient, secret, string(recipient.Address()), DefaultFeeRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := redeem.VerifyInputScript(0, prevOut); err != nil {
+		t.Fatalf("redeeming with the secret: %s", err)
+	}
+	if redeem.LockTime != 0 {
+		t.Errorf("the redeem transaction is locked until %d", redeem.LockTime)
+	}
+
+	if revealed, ok := ExtractSecret(redeem.Inputs[0].Script, contract.SecretHash); !ok || !bytes.Equal(revealed, secret) {
+		t.Errorf("extracted %q, %t", revealed, ok)
+	}
+	if _, ok := ExtractSecret(redeem.Inputs[0].Script, make([]byte, sha256.Size)); ok {
+		t.Error("extracted a secret for another hash")
+	}
+
+	if _, err := NewHTLCRedeemTx(contractTx, 0, recipient, []byte("guess"), string(recipient.Address()), DefaultFeeRate); err == nil {
+		t.Error("redeemed with the wrong secret")
+	}
+	if _, err := NewHTLCRedeemTx(contractTx, 0, refund, secret, string(refund.Address()), DefaultFeeRate); err == nil {
+		t.Error("the refund key redeemed the contract")
+	}
+
+	// A redeem transaction built by hand, revealing the wrong secret.
+	guess := *redeem
+	guess.Inputs = []TxInput{{ID: contractTx.ID, Out: 0}}
+	sig := guess.SignatureFor(0, recipient.PrivateKey, prevOut)
+	guess.Inputs[0].Script = NewScriptBuilder().AddData(sig).AddData(recipient.PublicKey).AddData([]byte("guess")).AddInt(1).Script()
+	if err := guess.VerifyInputScript(0, prevOut); err == nil || !strings.Contains(err.Error(), "not equal") {
+		t.Fatalf("redeeming with the wrong secret got %v", err)
+	}
+	if _, ok := ExtractSecret(guess.Inputs[0].Script, contract.SecretHash); ok {
+		t.Error("extracted the wrong secret")
+	}
+}
+
+func TestHTLCRefund(t *testing.T) {
+	recipient, refund := wallet.MakeWallet(), wallet.MakeWallet()
+	contract, contractTx := testContract(recipient, refund, []byte("secret"))
+	prevOut := contractTx.Outputs[0]
+
+	tx, err := NewHTLCRefundTx(contractTx, 0, refund, string(refund.Address()), DefaultFeeRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.VerifyInputScript(0, prevOut); err != nil {
+		t.Fatalf("refunding: %s", err)
+	}
+	if _, ok := ExtractSecret(tx.Inputs[0].Script, contract.SecretHash); ok {
+		t.Error("a refund revealed a secret")
+	}
+
+	// The refund carries the contract's lock time, so it cannot be mined
+	// before then.
+	chain := &BlockChain{}
+	if err := chain.CheckLockTimes(tx, 99, nil); err == nil {
+		t.Error("the refund can be mined before the lock time")
+	}
+	if err := chain.CheckLockTimes(tx, 100, nil); err != nil {
+		t.Errorf("the refund cannot be mined at the lock time: %s", err)
+	}
+
+	// An earlier lock time fails the contract's script instead.
+	early := *tx
+	early.Inputs = []TxInput{{ID: contractTx.ID, Out: 0}}
+	early.LockTime = 99
+	early.ID = early.UnsignedHash()
+	sig := early.SignatureFor(0, refund.PrivateKey, prevOut)
+	early.Inputs[0].Script = NewScriptBuilder().AddData(sig).AddData(refund.PublicKey).AddInt(0).Script()
+	if err := early.VerifyInputScript(0, prevOut); err == nil || !strings.Contains(err.Error(), "lock time is before 100") {
+		t.Fatalf("refunding before the lock time got %v", err)
+	}
+
+	if _, err := NewHTLCRefundTx(contractTx, 0, recipient, string(recipient.Address()), DefaultFeeRate); err == nil {
+		t.Error("the recipient took the refund")
+	}
+}
@@ -0,0 +1,173 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"encoding/json"
+)
+
+type transactionJSON struct {
+	ID       string     `json:"txid"`
+	Inputs   []TxInput  `json:"vin"`
+	Outputs  []TxOutput `json:"vout"`
+	LockTime int        `json:"locktime,omitempty"`
+}
+
+type txInputJSON struct {
+	ID        string `json:"txid"`
+	Out       int    `json:"vout"`
+	Signature string `json:"signature"`
+	PubKey    string `json:"pubkey"`
+	Script    string `json:"script,omitempty"`
+	Asm       string `json:"asm,omitempty"`
+	Sequence  int    `json:"sequence,omitempty"`
+}
+
+type txOutputJSON struct {
+	Value      int    `json:"value"`
+	PubKeyHash string `json:"pubkeyhash,omitempty"`
+	Script     string `json:"script,omitempty"`
+	Asm        string `json:"asm,omitempty"`
+	Type       string `json:"type,omitempty"`
+	Asset      string `json:"asset,omitempty"`
+	Tokens     int    `json:"tokens,omitempty"`
+}
+
+func (tx Transaction) MarshalJSON() ([]byte, error) {
+	data := transactionJSON{hex.EncodeToString(tx.ID), tx.Inputs, tx.Outputs, tx.LockTime}
+	if data.Inputs == nil {
+		data.Inputs = []TxInput{}
+	}
+	if data.Outputs == nil {
+		data.Outputs = []TxOutput{}
+	}
+
+	return json.Marshal(data)
+}
+
+// UnmarshalJSON accepts transactions exactly as given, so hand written ones
+// may carry a wrong ID or invalid signatures.
+func (tx *Transaction) UnmarshalJSON(content []byte) error {
+	var data transactionJSON
+	if err := json.Unmarshal(content, &data); err != nil {
+		return err
+	}
+
+	id, err := hex.DecodeString(data.ID)
+	if err != nil {
+		return err
+	}
+
+	*tx = Transaction{id, data.Inputs, data.Outputs, data.LockTime}
+
+	return nil
+}
+
+func (in TxInput) MarshalJSON() ([]byte, error) {
+	data := txInputJSON{
+		ID:        hex.EncodeToString(in.ID),
+		Out:       in.Out,
+		Signature: hex.EncodeToString(in.Signature),
+		PubKey:    hex.EncodeToString(in.PubKey),
+		Script:    hex.EncodeToString(in.Script),
+		Sequence:  in.Sequence,
+	}
+	if len(in.Script) > 0 {
+		data.Asm = disasmOrHex(in.Script)
+	}
+
+	return json.Marshal(data)
+}
+
+// UnmarshalJSON ignores asm, which is only there for people to read.
+func (in *TxInput) UnmarshalJSON(content []byte) error {
+	var data txInputJSON
+	if err := json.Unmarshal(content, &data); err != nil {
+		return err
+	}
+
+	fields, err := decodeHex(data.ID, data.Signature, data.PubKey, data.Script)
+	if err != nil {
+		return err
+	}
+
+	*in = TxInput{fields[0], data.Out, fields[1], fields[2], fields[3], data.Sequence}
+
+	return nil
+}
+
+func (out TxOutput) MarshalJSON() ([]byte, error) {
+	data := txOutputJSON{
+		Value:      out.Value,
+		PubKeyHash: hex.EncodeToString(out.PubKeyHash),
+		Script:     hex.EncodeToString(out.Script),
+		Asset:      hex.EncodeToString(out.Asset),
+		Tokens:     out.Tokens,
+	}
+	if len(out.Script) > 0 {
+		data.Asm = disasmOrHex(out.Script)
+		data.Type = ScriptType(out.Script)
+	}
+
+	return json.Marshal(data)
+}
+
+func (out *TxOutput) UnmarshalJSON(content []byte) error {
+	var data txOutputJSON
+	if err := json.Unmarshal(content, &data); err != nil {
+		return err
+	}
+
+	fields, err := decodeHex(data.PubKeyHash, data.Script, data.Asset)
+	if err != nil {
+		return err
+	}
+
+	*out = TxOutput{data.Value, fields[0], fields[1], fields[2], data.Tokens}
+	if len(out.PubKeyHash) == 0 {
+		out.PubKeyHash = extractScriptHash(out.Script)
+	}
+
+	return nil
+}
+
+func decodeHex(fields ...string) ([][]byte, error) {
+	var decoded [][]byte
+
+	for _, field := range fields {
+		b, err := hex.DecodeString(field)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(b) == 0 {
+			b = nil
+		}
+		decoded = append(decoded, b)
+	}
+
+	return decoded, nil
+}
+
+type multiSigInputJSON struct {
+	RedeemScript string            `json:"redeemscript"`
+	Asm          string            `json:"asm"`
+	Signatures   map[string]string `json:"signatures"`
+}
+
+// MarshalJSON writes inputs that do not spend a multisig address as null.
+func (ms MultiSigInput) MarshalJSON() ([]byte, error) {
+	if ms.RedeemScript == nil {
+		return []byte("null"), nil
+	}
+
+	data := multiSigInputJSON{
+		RedeemScript: hex.EncodeToString(ms.RedeemScript),
+		Asm:          disasmOrHex(ms.RedeemScript),
+		Signatures:   make(map[string]string),
+	}
+	for pubKey, sig := range ms.Signatures {
+		data.Signatures[pubKey] = hex.EncodeToString(sig)
+	}
+
+	return json.Marshal(data)
+}
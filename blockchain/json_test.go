@@ -0,0 +1,98 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+func TestTransactionJSONRoundTrip(t *testing.T) {
+	w := wallet.MakeWallet()
+	redeemScript := MultiSigScript(1, [][]byte{w.PublicKey})
+
+	tx := Transaction{
+		Inputs: []TxInput{
+			{ID: []byte{1, 2}, Out: 0, Signature: []byte{3, 4}, PubKey: w.PublicKey},
+			{ID: []byte{5, 6}, Out: 3, Script: NewScriptBuilder().AddData([]byte{7}).AddData(redeemScript).Script(), Sequence: 10},
+		},
+		Outputs: []TxOutput{
+			*NewTXOutput(5, string(w.Address())),
+			*NewScriptOutput(6, ScriptHashScript(wallet.ScriptHash(redeemScript))),
+			*NewTokenOutput([]byte{9, 9}, 100, string(w.Address())),
+		},
+		LockTime: 42,
+	}
+	tx.ID = tx.UnsignedHash()
+
+	content, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{`"locktime":42`, `"sequence":10`, `"type":"scripthash"`, `"asm":"OP_HASH160 `, `"tokens":100`, `"asset":"0909"`} {
+		if !bytes.Contains(content, []byte(field)) {
+			t.Errorf("%s does not contain %s", content, field)
+		}
+	}
+
+	var decoded Transaction
+	if err := json.Unmarshal(content, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, tx) {
+		t.Fatalf("got %+v, want %+v", decoded, tx)
+	}
+	if !bytes.Equal(decoded.Hash(), tx.Hash()) {
+		t.Fatal("the decoded transaction hashes differently")
+	}
+}
+
+func TestTransactionJSONFields(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"input", TxInput{ID: []byte{0xab}, Out: 1, Signature: []byte{1}, PubKey: []byte{2}}, `{"txid":"ab","vout":1,"signature":"01","pubkey":"02"}`},
+		{"output", TxOutput{Value: 3, PubKeyHash: []byte{0xcd}}, `{"value":3,"pubkeyhash":"cd"}`},
+		{"empty transaction", Transaction{}, `{"txid":"","vin":[],"vout":[]}`},
+	}
+
+	for _, test := range tests {
+		content, err := json.Marshal(test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, content, test.want)
+		}
+	}
+}
+
+func TestTransactionJSONDecoding(t *testing.T) {
+	// Hand written transactions are taken as given, wrong ID and all.
+	var tx Transaction
+	if err := json.Unmarshal([]byte(`{"txid":"00ff","vin":[{"txid":"01","vout":2,"signature":"","pubkey":"","asm":"ignored"}],"vout":[{"value":1,"script":"a914`+strings.Repeat("11", 20)+`87"}]}`), &tx); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tx.ID, []byte{0, 0xff}) || tx.Inputs[0].Out != 2 || tx.Inputs[0].Signature != nil {
+		t.Fatalf("got %+v", tx)
+	}
+	if !bytes.Equal(tx.Outputs[0].PubKeyHash, bytes.Repeat([]byte{0x11}, 20)) {
+		t.Fatalf("the script hash of a pay to script hash output is %x", tx.Outputs[0].PubKeyHash)
+	}
+
+	for _, content := range []string{
+		`{"txid":"zz"}`,
+		`{"txid":"","vin":[{"txid":"01","signature":"0"}]}`,
+		`{"txid":"","vout":[{"value":1,"pubkeyhash":"xy"}]}`,
+		`{"txid":"","vout":"none"}`,
+	} {
+		if err := json.Unmarshal([]byte(content), &tx); err == nil {
+			t.Errorf("decoded %s", content)
+		}
+	}
+}
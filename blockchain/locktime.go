@@ -0,0 +1,155 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// LockTimeThreshold separates the two meanings of Transaction.LockTime:
+// below it is a block height, from it on a unix time.
+const LockTimeThreshold = 500000000
+
+// A non-zero TxInput.Sequence is a relative lock on the output the input
+// spends, in the BIP 68 layout: the low 16 bits count blocks, or units of
+// 512 seconds when SequenceTypeFlag is set, that have to pass after the
+// output was confirmed. SequenceDisableFlag turns the lock off.
+const (
+	SequenceDisableFlag = 1 << 31
+	SequenceTypeFlag    = 1 << 22
+	SequenceMask        = 0xffff
+	SequenceGranularity = 9
+)
+
+// medianTimeSpan is how many blocks the median time past is taken over.
+const medianTimeSpan = 11
+
+// BlockSequence encodes a relative lock of blocks blocks.
+func BlockSequence(blocks int) int {
+	return blocks & SequenceMask
+}
+
+// TimeSequence encodes a relative lock of at least seconds seconds.
+func TimeSequence(seconds int64) int {
+	units := (seconds + 1<<SequenceGranularity - 1) >> SequenceGranularity
+
+	return SequenceTypeFlag | int(units)&SequenceMask
+}
+
+// MedianTimePast is the median timestamp of the block with hash blockHash
+// and the ones before it. Unlike a single timestamp it only moves forward,
+// so time locks compare against it.
+func (chain *BlockChain) MedianTimePast(blockHash []byte) (int64, error) {
+	var timestamps []int64
+
+	for len(blockHash) > 0 && len(timestamps) < medianTimeSpan {
+		block, err := chain.GetBlock(blockHash)
+		if err != nil {
+			return 0, err
+		}
+
+		timestamps = append(timestamps, block.Timestamp)
+		blockHash = block.PrevHash
+	}
+
+	if len(timestamps) == 0 {
+		return 0, errors.New("no blocks to take the median time of")
+	}
+
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	return timestamps[len(timestamps)/2], nil
+}
+
+// FindTxBlock returns the block that contains the transaction with the
+// given ID among the block with hash blockHash and its ancestors, so that
+// blocks of a side branch are checked against their own branch.
+func (chain *BlockChain) FindTxBlock(ID, blockHash []byte) (*Block, error) {
+	for len(blockHash) > 0 {
+		block, err := chain.GetBlock(blockHash)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tx := range block.Transactions {
+			if bytes.Equal(tx.ID, ID) {
+				return &block, nil
+			}
+		}
+
+		blockHash = block.PrevHash
+	}
+
+	return nil, errors.New("Transaction does not exist")
+}
+
+// CheckBlockLockTimes checks the lock times of every transaction of a block
+// whose parent is known.
+func (chain *BlockChain) CheckBlockLockTimes(block *Block) error {
+	for _, tx := range block.Transactions {
+		if err := chain.CheckLockTimes(tx, block.Height, block.PrevHash); err != nil {
+			return fmt.Errorf("transaction %x: %s", tx.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// CheckLockTimes checks that tx may go into a block at height on top of the
+// block prevHash: its lock time has passed and so have the relative locks
+// of its inputs, counted from the blocks the spent outputs were confirmed
+// in. Times compare against the median time past of prevHash.
+func (chain *BlockChain) CheckLockTimes(tx *Transaction, height int, prevHash []byte) error {
+	if tx.IsCoinbase() {
+		return nil
+	}
+
+	var medianTime int64
+	if tx.LockTime >= LockTimeThreshold || tx.hasSequences() {
+		var err error
+		if medianTime, err = chain.MedianTimePast(prevHash); err != nil {
+			return err
+		}
+	}
+
+	if tx.LockTime >= LockTimeThreshold {
+		if int64(tx.LockTime) > medianTime {
+			return fmt.Errorf("transaction is locked until time %d, median time is %d", tx.LockTime, medianTime)
+		}
+	} else if tx.LockTime > height {
+		return fmt.Errorf("transaction is locked until height %d", tx.LockTime)
+	}
+
+	for i, in := range tx.Inputs {
+		if in.Sequence&^SequenceReplaceFlag == 0 || in.Sequence&SequenceDisableFlag != 0 {
+			continue
+		}
+
+		block, err := chain.FindTxBlock(in.ID, prevHash)
+		if err != nil {
+			return fmt.Errorf("input %d: relative lock on an unconfirmed output", i)
+		}
+
+		if in.Sequence&SequenceTypeFlag == 0 {
+			if unlock := block.Height + in.Sequence&SequenceMask; unlock > height {
+				return fmt.Errorf("input %d is locked until height %d", i, unlock)
+			}
+			continue
+		}
+
+		confirmedTime := block.Timestamp
+		if len(block.PrevHash) > 0 {
+			if confirmedTime, err = chain.MedianTimePast(block.PrevHash); err != nil {
+				return err
+			}
+		}
+
+		unlock := confirmedTime + int64(in.Sequence&SequenceMask)<<SequenceGranularity
+		if unlock > medianTime {
+			return fmt.Errorf("input %d is locked until time %d, median time is %d", i, unlock, medianTime)
+		}
+	}
+
+	return nil
+}
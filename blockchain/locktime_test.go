@@ -0,0 +1,75 @@
+package blockchain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+func TestRelativeLockOnSideBranch(t *testing.T) {
+	w := wallet.MakeWallet()
+	address := string(w.Address())
+
+	chain := InitBlockchainAt(t.TempDir(), address)
+	defer chain.Database.Close()
+
+	genesis, err := chain.GetBlock(chain.LastHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The best chain is three empty blocks long, the side branch holds
+	// the transaction the locked inputs spend.
+	var best []*Block
+	prevHash := genesis.Hash
+	for height := 1; height <= 3; height++ {
+		block := CreateBlock([]*Transaction{CoinBaseTx(address, "", 0)}, prevHash, height, 0)
+		chain.AddBlock(block)
+		best = append(best, block)
+		prevHash = block.Hash
+	}
+
+	confirmed := &Transaction{
+		Inputs:  []TxInput{{ID: genesis.Transactions[0].ID, Out: 0, PubKey: w.PublicKey}},
+		Outputs: []TxOutput{*NewTXOutput(Subsidy, address)},
+	}
+	confirmed.ID = confirmed.Hash()
+
+	side := CreateBlock([]*Transaction{confirmed, CoinBaseTx(address, "", 0)}, genesis.Hash, 1, 0)
+	chain.AddBlock(side)
+
+	spending := func(ID []byte, sequence int) *Transaction {
+		tx := &Transaction{
+			Inputs:  []TxInput{{ID: ID, Out: 0, PubKey: w.PublicKey, Sequence: sequence}},
+			Outputs: []TxOutput{*NewTXOutput(1, address)},
+		}
+		tx.ID = tx.Hash()
+
+		return tx
+	}
+
+	tests := []struct {
+		name string
+		tx   *Transaction
+		err  string
+	}{
+		{"output of the branch", spending(confirmed.ID, BlockSequence(1)), ""},
+		{"lock not reached", spending(confirmed.ID, BlockSequence(2)), "locked until height 3"},
+		{"output of the best chain", spending(best[0].Transactions[0].ID, BlockSequence(1)), "unconfirmed output"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := chain.CheckLockTimes(test.tx, 2, side.Hash)
+			switch {
+			case test.err == "" && err != nil:
+				t.Fatalf("got error %q", err)
+			case test.err != "" && err == nil:
+				t.Fatalf("got no error, want %q", test.err)
+			case test.err != "" && !strings.Contains(err.Error(), test.err):
+				t.Fatalf("got error %q, want %q", err, test.err)
+			}
+		})
+	}
+}
@@ -43,9 +43,13 @@ func NewMerkleTree(data [][]byte) *MerkleTree {
 		nodes = append(nodes, *node)
 	}
 
-	for i := 0; i < len(data)/2; i++ {
+	for len(nodes) > 1 {
 		var level []MerkleNode
 
+		if len(nodes)%2 != 0 {
+			nodes = append(nodes, nodes[len(nodes)-1])
+		}
+
 		for j := 0; j < len(nodes); j += 2 {
 			node := NewMerkleNode(&nodes[j], &nodes[j+1], nil)
 			level = append(level, *node)
@@ -58,3 +62,62 @@ func NewMerkleTree(data [][]byte) *MerkleTree {
 
 	return &tree
 }
+
+// MerkleProof proves that a leaf is part of a tree: Hashes are its
+// siblings from the bottom level up, and Index its position among the
+// leaves, whose bits tell on which side each sibling is.
+type MerkleProof struct {
+	Index  int
+	Hashes [][]byte
+}
+
+// NewMerkleProof builds the proof for leaf index of the tree NewMerkleTree
+// builds from data.
+func NewMerkleProof(data [][]byte, index int) *MerkleProof {
+	var level [][]byte
+
+	if len(data)%2 != 0 {
+		data = append(data, data[len(data)-1])
+	}
+
+	for _, leaf := range data {
+		level = append(level, NewMerkleNode(nil, nil, leaf).Data)
+	}
+
+	proof := &MerkleProof{Index: index}
+	for i := index; len(level) > 1; i /= 2 {
+		if len(level)%2 != 0 {
+			level = append(level, level[len(level)-1])
+		}
+
+		proof.Hashes = append(proof.Hashes, level[i^1])
+
+		var next [][]byte
+		for j := 0; j < len(level); j += 2 {
+			next = append(next, merkleParent(level[j], level[j+1]))
+		}
+		level = next
+	}
+
+	return proof
+}
+
+// Root computes the root of the tree the proof is for, starting from the
+// leaf data.
+func (p *MerkleProof) Root(leaf []byte) []byte {
+	hash := NewMerkleNode(nil, nil, leaf).Data
+
+	for i, sibling := range p.Hashes {
+		if p.Index>>uint(i)&1 == 0 {
+			hash = merkleParent(hash, sibling)
+		} else {
+			hash = merkleParent(sibling, hash)
+		}
+	}
+
+	return hash
+}
+
+func merkleParent(left, right []byte) []byte {
+	return NewMerkleNode(&MerkleNode{Data: left}, &MerkleNode{Data: right}, nil).Data
+}
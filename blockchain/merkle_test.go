@@ -0,0 +1,90 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+func leaves(n int) [][]byte {
+	var data [][]byte
+	for i := 0; i < n; i++ {
+		data = append(data, []byte(fmt.Sprintf("leaf %d", i)))
+	}
+
+	return data
+}
+
+func TestMerkleProof(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		data := leaves(n)
+		root := NewMerkleTree(data).RootNode.Data
+
+		for i := range data {
+			proof := NewMerkleProof(data, i)
+			if !bytes.Equal(proof.Root(data[i]), root) {
+				t.Fatalf("%d leaves: the proof of leaf %d does not lead to the root", n, i)
+			}
+			if bytes.Equal(proof.Root([]byte("other leaf")), root) {
+				t.Fatalf("%d leaves: the proof of leaf %d proves another leaf", n, i)
+			}
+		}
+	}
+}
+
+func TestTamperedMerkleProof(t *testing.T) {
+	data := leaves(6)
+	root := NewMerkleTree(data).RootNode.Data
+
+	for level := range NewMerkleProof(data, 2).Hashes {
+		proof := NewMerkleProof(data, 2)
+		proof.Hashes[level] = append([]byte(nil), proof.Hashes[level]...)
+		proof.Hashes[level][0] ^= 1
+		if bytes.Equal(proof.Root(data[2]), root) {
+			t.Errorf("a proof with sibling %d changed still leads to the root", level)
+		}
+	}
+
+	moved := NewMerkleProof(data, 2)
+	moved.Index = 3
+	if bytes.Equal(moved.Root(data[2]), root) {
+		t.Error("a proof at the wrong index still leads to the root")
+	}
+
+	short := NewMerkleProof(data, 2)
+	short.Hashes = short.Hashes[:len(short.Hashes)-1]
+	if bytes.Equal(short.Root(data[2]), root) {
+		t.Error("a proof missing a level still leads to the root")
+	}
+}
+
+func TestBlockMerkleProof(t *testing.T) {
+	address := string(wallet.MakeWallet().Address())
+	block := &Block{}
+	for i := 0; i < 3; i++ {
+		block.Transactions = append(block.Transactions, CoinBaseTx(address, fmt.Sprintf("coinbase %d", i), 0))
+	}
+	root := block.HashTransactions()
+
+	for _, tx := range block.Transactions {
+		proof, ok := block.MerkleProof(tx.ID)
+		if !ok {
+			t.Fatalf("no proof for transaction %x", tx.ID)
+		}
+		if !VerifyMerkleProof(tx, proof, root) {
+			t.Fatalf("the proof of transaction %x does not verify", tx.ID)
+		}
+
+		changed := *tx
+		changed.Outputs = []TxOutput{{Value: tx.Outputs[0].Value + 1, PubKeyHash: tx.Outputs[0].PubKeyHash}}
+		if VerifyMerkleProof(&changed, proof, root) {
+			t.Fatalf("the proof of transaction %x verifies a changed transaction", tx.ID)
+		}
+	}
+
+	if _, ok := block.MerkleProof([]byte("no such transaction")); ok {
+		t.Error("got a proof for a transaction not in the block")
+	}
+}
@@ -0,0 +1,155 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// SpendableOutputs lists the outputs of pubKeyHash a new transaction may
+// spend. Outputs the pending transactions of the set spend already are
+// left out, and the outputs those transactions pay back to pubKeyHash, like
+// change, are added when they spend outputs of pubKeyHash themselves.
+func (u UTXOSet) SpendableOutputs(pubKeyHash []byte) []UTXO {
+	spent := u.pendingSpends()
+
+	var spendable []UTXO
+	for _, utxo := range u.ListUnspent(pubKeyHash) {
+		if !spent[outPoint(utxo.TxID, utxo.Out)] {
+			spendable = append(spendable, utxo)
+		}
+	}
+
+	var ids []string
+	for id := range u.Pending {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		tx := u.Pending[id]
+		if !tx.spendsKey(pubKeyHash) {
+			continue
+		}
+
+		for outIdx, out := range tx.Outputs {
+			if out.IsUnspendable() || !out.isLockedWithKey(pubKeyHash) || spent[outPoint(tx.ID, outIdx)] {
+				continue
+			}
+			spendable = append(spendable, UTXO{tx.ID, outIdx, out})
+		}
+	}
+
+	return spendable
+}
+
+// PendingBalance is what the pending transactions of the set add to the
+// balance of pubKeyHash in coins, or tokens of asset, once they confirm:
+// the outputs they pay there less the ones they spend from there. Only the
+// transactions PendingTxs keeps count.
+func (u UTXOSet) PendingBalance(pubKeyHash, asset []byte) int {
+	var received, spent []TxOutput
+
+	var txs []Transaction
+	for _, tx := range u.Pending {
+		txs = append(txs, tx)
+	}
+
+	for _, tx := range u.PendingTxs(txs) {
+		for _, out := range tx.Outputs {
+			if !out.IsUnspendable() && out.isLockedWithKey(pubKeyHash) {
+				received = append(received, out)
+			}
+		}
+
+		for _, in := range tx.Inputs {
+			prevOut, ok := u.pendingPrevOutput(in)
+			if ok && prevOut.isLockedWithKey(pubKeyHash) {
+				spent = append(spent, prevOut)
+			}
+		}
+	}
+
+	return TokenBalance(received, asset) - TokenBalance(spent, asset)
+}
+
+// PendingTxs keeps the transactions of txs that can still be confirmed:
+// each of their inputs is an unspent output, or an output of another one
+// kept. Confirmed transactions and the ones that lost a double spend drop
+// out, and so do their descendants. The result is keyed by hex encoded ID.
+func (u UTXOSet) PendingTxs(txs []Transaction) map[string]Transaction {
+	pending := make(map[string]Transaction)
+	for _, tx := range txs {
+		pending[hex.EncodeToString(tx.ID)] = tx
+	}
+
+	for changed := true; changed; {
+		changed = false
+
+		for id, tx := range pending {
+			for _, in := range tx.Inputs {
+				if _, ok := pending[hex.EncodeToString(in.ID)]; ok {
+					continue
+				}
+				if _, ok := u.GetOutput(in.ID, in.Out); !ok {
+					delete(pending, id)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	return pending
+}
+
+// pendingSpends returns the outputs the pending transactions spend.
+func (u UTXOSet) pendingSpends() map[string]bool {
+	spent := make(map[string]bool)
+	for _, tx := range u.Pending {
+		for _, in := range tx.Inputs {
+			spent[outPoint(in.ID, in.Out)] = true
+		}
+	}
+
+	return spent
+}
+
+// pendingPrevOutput finds the output in spends among the pending
+// transactions or the unspent outputs.
+func (u UTXOSet) pendingPrevOutput(in TxInput) (TxOutput, bool) {
+	if prevTx, ok := u.Pending[hex.EncodeToString(in.ID)]; ok {
+		if in.Out < 0 || in.Out >= len(prevTx.Outputs) {
+			return TxOutput{}, false
+		}
+		return prevTx.Outputs[in.Out], true
+	}
+
+	return u.GetOutput(in.ID, in.Out)
+}
+
+// unspentOutput finds an output that neither the chain nor a pending
+// transaction has spent.
+func (u UTXOSet) unspentOutput(txID []byte, out int) (TxOutput, bool) {
+	if u.pendingSpends()[outPoint(txID, out)] {
+		return TxOutput{}, false
+	}
+
+	return u.pendingPrevOutput(TxInput{ID: txID, Out: out})
+}
+
+// spendsKey reports whether tx spends outputs of pubKeyHash, which makes
+// what it pays back there change the owner can trust.
+func (tx *Transaction) spendsKey(pubKeyHash []byte) bool {
+	for _, in := range tx.Inputs {
+		if len(in.Script) == 0 && in.UsesKey(pubKeyHash) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func outPoint(txID []byte, out int) string {
+	return fmt.Sprintf("%x:%d", txID, out)
+}
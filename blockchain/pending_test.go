@@ -0,0 +1,47 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+func TestPendingBalanceSkipsInvalidTransactions(t *testing.T) {
+	w, payee := wallet.MakeWallet(), wallet.MakeWallet()
+	payeeHash := wallet.PublicKeyHash(payee.PublicKey)
+
+	chain := InitBlockchainAt(t.TempDir(), string(w.Address()))
+	defer chain.Database.Close()
+
+	UTXOSet := UTXOSet{Blockchain: chain}
+	UTXOSet.Reindex()
+
+	genesis, err := chain.GetBlock(chain.LastHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment := func(ID []byte, value int) Transaction {
+		tx := Transaction{
+			Inputs:  []TxInput{{ID: ID, Out: 0, PubKey: w.PublicKey}},
+			Outputs: []TxOutput{*NewTXOutput(value, string(payee.Address()))},
+		}
+		tx.ID = tx.Hash()
+
+		return tx
+	}
+
+	valid := payment(genesis.Transactions[0].ID, 5)
+	missing := payment([]byte("no such transaction"), 7)
+	child := payment(missing.ID, 3)
+
+	UTXOSet.Pending = make(map[string]Transaction)
+	for _, tx := range []Transaction{valid, missing, child} {
+		UTXOSet.Pending[hex.EncodeToString(tx.ID)] = tx
+	}
+
+	if balance := UTXOSet.PendingBalance(payeeHash, nil); balance != 5 {
+		t.Fatalf("pending balance is %d, want 5", balance)
+	}
+}
@@ -0,0 +1,303 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// PartialTx is a transaction on its way through one or more signers. It
+// carries the outputs the transaction spends, Spent[i] being spent by input
+// i, so signing needs neither the chain database nor the other signers.
+// MultiSig[i], if there is one, collects the signatures for input i when it
+// spends a multisig address.
+type PartialTx struct {
+	Tx       Transaction     `json:"tx"`
+	Spent    []TxOutput      `json:"spent"`
+	MultiSig []MultiSigInput `json:"multisig,omitempty"`
+}
+
+// MultiSigInput holds the redeem script of a multisig input and the
+// signatures made for it so far, by hex encoded public key.
+type MultiSigInput struct {
+	RedeemScript []byte
+	Signatures   map[string][]byte
+}
+
+// NewPartialTx looks up the outputs spent by tx in the UTXO set.
+func NewPartialTx(tx *Transaction, UTXO *UTXOSet) (*PartialTx, error) {
+	ptx := &PartialTx{Tx: *tx}
+
+	for _, in := range tx.Inputs {
+		out, ok := UTXO.unspentOutput(in.ID, in.Out)
+		if !ok {
+			return nil, fmt.Errorf("output %x:%d is not unspent", in.ID, in.Out)
+		}
+
+		ptx.Spent = append(ptx.Spent, out)
+	}
+
+	return ptx, nil
+}
+
+// NewPartialTxFrom builds an unsigned transaction paying outputs from an
+// address of wallets, either one whose public key it knows or a multisig
+// address whose redeem script it holds.
+func NewPartialTxFrom(wallets *wallet.Wallets, from string, outputs []TxOutput, UTXO *UTXOSet, opts SendOptions) (*PartialTx, error) {
+	var tx *Transaction
+	var err error
+
+	redeemScript, isScript := wallets.RedeemScript(from)
+	if isScript {
+		tx, err = NewScriptHashTransactionWith(from, outputs, UTXO, opts)
+	} else {
+		pubKey, ok := wallets.PublicKey(from)
+		if !ok {
+			return nil, fmt.Errorf("the public key of %s is unknown, import it with importpubkey", from)
+		}
+		tx, err = NewUnsignedTransactionWith(pubKey, outputs, UTXO, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ptx, err := NewPartialTx(tx, UTXO)
+	if err != nil {
+		return nil, err
+	}
+
+	if isScript {
+		if _, err := ptx.AddRedeemScript(redeemScript); err != nil {
+			return nil, err
+		}
+	}
+
+	return ptx, nil
+}
+
+func (ptx *PartialTx) Serialize() []byte {
+	var encoded bytes.Buffer
+
+	err := gob.NewEncoder(&encoded).Encode(ptx)
+	HandleErr(err)
+
+	return encoded.Bytes()
+}
+
+func DeserializePartialTx(data []byte) (*PartialTx, error) {
+	var ptx PartialTx
+
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&ptx); err != nil {
+		return nil, err
+	}
+
+	if len(ptx.Spent) != len(ptx.Tx.Inputs) {
+		return nil, errors.New("partially signed transaction has no spent output for every input")
+	}
+	if ptx.MultiSig != nil && len(ptx.MultiSig) != len(ptx.Tx.Inputs) {
+		return nil, errors.New("partially signed transaction has multisig data for the wrong inputs")
+	}
+
+	for i := range ptx.MultiSig {
+		if ptx.MultiSig[i].Signatures == nil {
+			ptx.MultiSig[i].Signatures = make(map[string][]byte)
+		}
+	}
+
+	return &ptx, nil
+}
+
+// AddRedeemScript attaches a multisig redeem script to the inputs spending
+// outputs of its address and returns how many there are.
+func (ptx *PartialTx) AddRedeemScript(script []byte) (int, error) {
+	if _, _, err := ParseMultiSigScript(script); err != nil {
+		return 0, err
+	}
+
+	scriptHash := wallet.ScriptHash(script)
+	added := 0
+
+	for i, prevOut := range ptx.Spent {
+		if !bytes.Equal(extractScriptHash(prevOut.Script), scriptHash) {
+			continue
+		}
+
+		if ptx.MultiSig == nil {
+			ptx.MultiSig = make([]MultiSigInput, len(ptx.Tx.Inputs))
+		}
+		if ptx.MultiSig[i].RedeemScript == nil {
+			ptx.MultiSig[i] = MultiSigInput{RedeemScript: script, Signatures: make(map[string][]byte)}
+		}
+		added++
+	}
+
+	return added, nil
+}
+
+// Sign signs every input spending an output locked to the wallet's key and
+// adds the wallet's signature to multisig inputs it is a cosigner of. It
+// returns how many signatures it made. Inputs without a public key get the
+// wallet's.
+func (ptx *PartialTx) Sign(w *wallet.Wallet) int {
+	pubKeyHash := wallet.PublicKeyHash(w.PublicKey)
+	signed := 0
+
+	for i, prevOut := range ptx.Spent {
+		if !prevOut.isLockedWithKey(pubKeyHash) {
+			continue
+		}
+
+		if len(ptx.Tx.Inputs[i].PubKey) == 0 {
+			ptx.Tx.Inputs[i].PubKey = w.PublicKey
+		}
+
+		ptx.Tx.SignInput(i, w.PrivateKey, prevOut)
+		signed++
+	}
+
+	for i, ms := range ptx.MultiSig {
+		if ms.RedeemScript == nil {
+			continue
+		}
+
+		_, pubKeys, err := ParseMultiSigScript(ms.RedeemScript)
+		if err != nil {
+			continue
+		}
+
+		for _, pubKey := range pubKeys {
+			key := hex.EncodeToString(pubKey)
+			if _, ok := ms.Signatures[key]; ok {
+				continue
+			}
+			if raw, err := wallet.ParsePublicKey(pubKey); err != nil || !bytes.Equal(raw, w.PublicKey) {
+				continue
+			}
+
+			redeemOut := TxOutput{Value: ptx.Spent[i].Value, Script: ms.RedeemScript}
+			ms.Signatures[key] = ptx.Tx.SignatureFor(i, w.PrivateKey, redeemOut)
+			signed++
+		}
+
+		ptx.unlockMultiSig(i)
+	}
+
+	ptx.Tx.ID = ptx.Tx.UnsignedHash()
+
+	return signed
+}
+
+// unlockMultiSig sets the unlocking script of multisig input i once enough
+// signatures have been collected, taking them in the order of the keys.
+func (ptx *PartialTx) unlockMultiSig(i int) {
+	ms := ptx.MultiSig[i]
+
+	m, pubKeys, err := ParseMultiSigScript(ms.RedeemScript)
+	if err != nil {
+		return
+	}
+
+	b := NewScriptBuilder()
+	count := 0
+	for _, pubKey := range pubKeys {
+		if sig, ok := ms.Signatures[hex.EncodeToString(pubKey)]; ok && count < m {
+			b.AddData(sig)
+			count++
+		}
+	}
+
+	if count == m {
+		ptx.Tx.Inputs[i].Script = b.AddData(ms.RedeemScript).Script()
+	}
+}
+
+// Combine merges the signatures other collected for the same transaction,
+// so cosigners can sign copies in parallel. Public keys signers filled in
+// for inputs without one are merged too.
+func (ptx *PartialTx) Combine(other *PartialTx) error {
+	trimmed, otherTrimmed := ptx.Tx.TrimmedCopy(), other.Tx.TrimmedCopy()
+	if !bytes.Equal(trimmed.Hash(), otherTrimmed.Hash()) || len(other.Spent) != len(ptx.Spent) {
+		return errors.New("partially signed transactions are for different transactions")
+	}
+
+	for i, in := range other.Tx.Inputs {
+		pubKey := ptx.Tx.Inputs[i].PubKey
+		if len(pubKey) > 0 && len(in.PubKey) > 0 && !bytes.Equal(pubKey, in.PubKey) {
+			return fmt.Errorf("partially signed transactions have different public keys for input %d", i)
+		}
+	}
+
+	for i, in := range other.Tx.Inputs {
+		if len(ptx.Tx.Inputs[i].PubKey) == 0 {
+			ptx.Tx.Inputs[i].PubKey = in.PubKey
+		}
+		if len(ptx.Tx.Inputs[i].Signature) == 0 && len(in.Signature) > 0 {
+			ptx.Tx.Inputs[i].Signature = in.Signature
+		}
+		if len(ptx.Tx.Inputs[i].Script) == 0 && len(in.Script) > 0 {
+			ptx.Tx.Inputs[i].Script = in.Script
+		}
+	}
+
+	for i, ms := range other.MultiSig {
+		if ms.RedeemScript == nil {
+			continue
+		}
+		if _, err := ptx.AddRedeemScript(ms.RedeemScript); err != nil {
+			return err
+		}
+
+		for key, sig := range ms.Signatures {
+			if _, ok := ptx.MultiSig[i].Signatures[key]; !ok {
+				ptx.MultiSig[i].Signatures[key] = sig
+			}
+		}
+
+		ptx.unlockMultiSig(i)
+	}
+
+	ptx.Tx.ID = ptx.Tx.UnsignedHash()
+
+	return nil
+}
+
+// Complete reports whether every input carries a valid signature.
+func (ptx *PartialTx) Complete() bool {
+	for i, prevOut := range ptx.Spent {
+		if !ptx.Tx.VerifyInput(i, prevOut) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Finalize returns the fully signed transaction ready to be broadcast.
+func (ptx *PartialTx) Finalize() (*Transaction, error) {
+	if !ptx.Complete() {
+		return nil, errors.New("transaction is not fully signed")
+	}
+
+	tx := ptx.Tx
+	tx.ID = tx.UnsignedHash()
+
+	return &tx, nil
+}
+
+// Fee is the value of the spent outputs not paid out again.
+func (ptx *PartialTx) Fee() int {
+	fee := 0
+	for _, out := range ptx.Spent {
+		fee += out.Value
+	}
+
+	for _, out := range ptx.Tx.Outputs {
+		fee -= out.Value
+	}
+
+	return fee
+}
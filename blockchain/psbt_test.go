@@ -0,0 +1,252 @@
+package blockchain
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// multiSigSpend returns a transaction spending a pay to script hash output
+// locked to a 2 of 3 multisig script of wallets, partially signed by none of
+// them yet.
+func multiSigSpend(t *testing.T, wallets []*wallet.Wallet) (*PartialTx, []byte) {
+	var pubKeys [][]byte
+	for _, w := range wallets {
+		pubKeys = append(pubKeys, w.PublicKey)
+	}
+
+	redeemScript, err := NewMultiSigScript(2, pubKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prevOut := *NewScriptOutput(10, ScriptHashScript(wallet.ScriptHash(redeemScript)))
+	tx := Transaction{
+		Inputs:  []TxInput{{ID: []byte("previous transaction"), Out: 0}},
+		Outputs: []TxOutput{*NewTXOutput(9, string(wallets[0].Address()))},
+	}
+	tx.ID = tx.UnsignedHash()
+
+	ptx := &PartialTx{Tx: tx, Spent: []TxOutput{prevOut}}
+	if added, err := ptx.AddRedeemScript(redeemScript); err != nil || added != 1 {
+		t.Fatalf("AddRedeemScript = %d, %v", added, err)
+	}
+
+	return ptx, redeemScript
+}
+
+func TestScriptHashMultiSig(t *testing.T) {
+	wallets := []*wallet.Wallet{wallet.MakeWallet(), wallet.MakeWallet(), wallet.MakeWallet()}
+
+	t.Run("two of three", func(t *testing.T) {
+		ptx, _ := multiSigSpend(t, wallets)
+
+		if signed := ptx.Sign(wallets[2]); signed != 1 {
+			t.Fatalf("signed %d inputs, want 1", signed)
+		}
+		if ptx.Complete() {
+			t.Fatal("one signature completes a 2 of 3 multisig")
+		}
+
+		ptx.Sign(wallets[0])
+		if _, err := ptx.Finalize(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("combined", func(t *testing.T) {
+		ptx, _ := multiSigSpend(t, wallets)
+		other, err := DeserializePartialTx(ptx.Serialize())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ptx.Sign(wallets[1])
+		other.Sign(wallets[2])
+		if err := ptx.Combine(other); err != nil {
+			t.Fatal(err)
+		}
+		if !ptx.Complete() {
+			t.Fatal("combining two signatures did not complete the transaction")
+		}
+	})
+
+	t.Run("outsider", func(t *testing.T) {
+		ptx, _ := multiSigSpend(t, wallets)
+
+		if signed := ptx.Sign(wallet.MakeWallet()); signed != 0 {
+			t.Fatalf("a wallet outside the multisig made %d signatures", signed)
+		}
+	})
+
+	t.Run("other redeem script", func(t *testing.T) {
+		ptx, redeemScript := multiSigSpend(t, wallets)
+		ptx.Sign(wallets[0])
+		ptx.Sign(wallets[1])
+
+		// Reveal a 1 of 1 script of the first signer instead, with its
+		// valid signature.
+		other := MultiSigScript(1, [][]byte{wallets[0].PublicKey})
+		sig := ptx.Tx.SignatureFor(0, wallets[0].PrivateKey, TxOutput{Value: 10, Script: other})
+		ptx.Tx.Inputs[0].Script = NewScriptBuilder().AddData(sig).AddData(other).Script()
+
+		if err := ptx.Tx.VerifyInputScript(0, ptx.Spent[0]); err == nil {
+			t.Fatalf("spent an output of %x with another redeem script", wallet.ScriptHash(redeemScript))
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		ptx, _ := multiSigSpend(t, wallets)
+		ptx.Sign(wallets[0])
+		ptx.Sign(wallets[1])
+
+		ptx.Tx.Outputs[0].Value = 10
+		if ptx.Complete() {
+			t.Fatal("the signatures still hold after the outputs changed")
+		}
+	})
+}
+
+// twoOwnerSpend returns a transaction paying all but a fee of 1 of an output
+// of each of payer and cosigner to a third party, unsigned, and the chain
+// holding the outputs.
+func twoOwnerSpend(t *testing.T, payer, cosigner *wallet.Wallet) (*PartialTx, *BlockChain) {
+	chain := InitBlockchainAt(t.TempDir(), string(payer.Address()))
+	t.Cleanup(func() { chain.Database.Close() })
+	UTXO := UTXOSet{Blockchain: chain}
+	UTXO.Reindex()
+
+	funding, err := NewTransactionWith(payer, []TxOutput{*NewTXOutput(7, string(cosigner.Address()))}, &UTXO, DefaultSendOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fee, err := chain.Fee(funding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain.MineBlock([]*Transaction{funding, CoinBaseTx(string(payer.Address()), "", fee)})
+	UTXO.Reindex()
+
+	var inputs []TxInput
+	total := 0
+	for _, w := range []*wallet.Wallet{payer, cosigner} {
+		utxo := UTXO.ListUnspent(wallet.PublicKeyHash(w.PublicKey))[0]
+		inputs = append(inputs, TxInput{ID: utxo.TxID, Out: utxo.Out})
+		total += utxo.Output.Value
+	}
+
+	tx := Transaction{Inputs: inputs, Outputs: []TxOutput{*NewTXOutput(total-1, string(wallet.MakeWallet().Address()))}}
+	tx.ID = tx.UnsignedHash()
+
+	ptx, err := NewPartialTx(&tx, &UTXO)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ptx, chain
+}
+
+func TestPartialTxCombineAndFinalize(t *testing.T) {
+	payer, cosigner := wallet.MakeWallet(), wallet.MakeWallet()
+	ptx, chain := twoOwnerSpend(t, payer, cosigner)
+
+	if fee := ptx.Fee(); fee != 1 {
+		t.Fatalf("got fee %d, want 1", fee)
+	}
+
+	copies := make([]*PartialTx, 2)
+	for i := range copies {
+		var err error
+		if copies[i], err = DeserializePartialTx(ptx.Serialize()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if signed := copies[0].Sign(wallet.MakeWallet()); signed != 0 {
+		t.Fatalf("a stranger made %d signatures", signed)
+	}
+	if signed := copies[0].Sign(payer); signed != 1 {
+		t.Fatalf("the payer made %d signatures, want 1", signed)
+	}
+	if signed := copies[1].Sign(cosigner); signed != 1 {
+		t.Fatalf("the cosigner made %d signatures, want 1", signed)
+	}
+	if copies[0].Complete() || copies[1].Complete() {
+		t.Fatal("one signer completed the transaction")
+	}
+	if _, err := copies[0].Finalize(); err == nil {
+		t.Fatal("finalized a transaction missing a signature")
+	}
+
+	for _, signed := range copies {
+		if err := ptx.Combine(signed); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tx, err := ptx.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tx.ID, tx.UnsignedHash()) || !chain.VerifyTx(tx) {
+		t.Fatal("the finalized transaction is invalid")
+	}
+}
+
+func TestPartialTxCombineRejectsOtherTransaction(t *testing.T) {
+	payer, cosigner := wallet.MakeWallet(), wallet.MakeWallet()
+	ptx, _ := twoOwnerSpend(t, payer, cosigner)
+
+	other, err := DeserializePartialTx(ptx.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Tx.Outputs[0].Value--
+	other.Sign(payer)
+
+	if err := ptx.Combine(other); err == nil {
+		t.Fatal("combined the signatures of a different transaction")
+	}
+	if len(ptx.Tx.Inputs[0].Signature) > 0 {
+		t.Fatal("a signature of the different transaction was taken")
+	}
+}
+
+func TestPartialTxInputs(t *testing.T) {
+	payer, cosigner := wallet.MakeWallet(), wallet.MakeWallet()
+	ptx, chain := twoOwnerSpend(t, payer, cosigner)
+	UTXO := UTXOSet{Blockchain: chain}
+
+	unknown := ptx.Tx
+	unknown.Inputs = []TxInput{{ID: []byte("no such transaction"), Out: 0}}
+	if _, err := NewPartialTx(&unknown, &UTXO); err == nil {
+		t.Fatal("looked up an output that does not exist")
+	}
+
+	truncated := *ptx
+	truncated.Spent = truncated.Spent[:1]
+	if _, err := DeserializePartialTx(truncated.Serialize()); err == nil {
+		t.Fatal("decoded a transaction missing a spent output")
+	}
+	if _, err := DeserializePartialTx([]byte("garbage")); err == nil {
+		t.Fatal("decoded garbage")
+	}
+}
+
+func TestPartialTxCombineRejectsOtherPublicKey(t *testing.T) {
+	payer, cosigner := wallet.MakeWallet(), wallet.MakeWallet()
+	ptx, _ := twoOwnerSpend(t, payer, cosigner)
+
+	other, err := DeserializePartialTx(ptx.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ptx.Tx.Inputs[0].PubKey = payer.PublicKey
+	other.Tx.Inputs[0].PubKey = cosigner.PublicKey
+
+	if err := ptx.Combine(other); err == nil || !strings.Contains(err.Error(), "public keys") {
+		t.Fatalf("combining different public keys got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// SequenceReplaceFlag set on the sequence of any input opts a transaction
+// into replace-by-fee: until it is mined, a transaction spending some of the
+// same outputs for a higher fee and fee rate replaces it in the memory pool.
+// The flag lies outside the bits of a relative lock and leaves it alone.
+const SequenceReplaceFlag = 1 << 30
+
+// SignalsReplacement reports whether tx opted into replace-by-fee.
+func (tx *Transaction) SignalsReplacement() bool {
+	for _, in := range tx.Inputs {
+		if in.Sequence&SequenceReplaceFlag != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Size is the size tx pays fees for. Like the fee of a new transaction it
+// is estimated from the numbers of inputs and outputs, so it does not
+// depend on how the transaction happens to be encoded.
+func (tx *Transaction) Size() int {
+	return EstimateSize(len(tx.Inputs), len(tx.Outputs))
+}
+
+// FeeRateOf is the rate fee pays for size bytes, rounded down.
+func FeeRateOf(fee, size int) FeeRate {
+	return FeeRate(fee * 1000 / size)
+}
+
+// CheckReplacement checks that tx, paying fee, replaces the transactions
+// replaced, paying oldFees: it must not spend their outputs, which would
+// leave it without its inputs once they are gone, and it has to pay more
+// than all of them together and at a higher rate than each.
+func CheckReplacement(tx *Transaction, fee int, replaced []Transaction, oldFees []int) error {
+	for _, old := range replaced {
+		for _, in := range tx.Inputs {
+			if bytes.Equal(in.ID, old.ID) {
+				return fmt.Errorf("it spends outputs of the replaced transaction %x", old.ID)
+			}
+		}
+	}
+
+	size := tx.Size()
+	total := 0
+	for i, oldFee := range oldFees {
+		total += oldFee
+		oldSize := replaced[i].Size()
+		if fee*oldSize <= oldFee*size {
+			return fmt.Errorf("fee rate %d is not higher than the %d of a replaced transaction", FeeRateOf(fee, size), FeeRateOf(oldFee, oldSize))
+		}
+	}
+
+	if fee <= total {
+		return fmt.Errorf("fee %d is not higher than the %d of the replaced transactions", fee, total)
+	}
+
+	return nil
+}
+
+// NewFeeBumpTx replaces tx, which spends outputs of w, with a copy paying
+// fee instead. The difference is taken from output change, which is left
+// out if that leaves it below the dust threshold and it is not the only one.
+func NewFeeBumpTx(tx *Transaction, w *wallet.Wallet, change, fee int, UTXO *UTXOSet) (*Transaction, error) {
+	oldFee, err := UTXO.Blockchain.FeeWith(tx, UTXO.Pending)
+	if err != nil {
+		return nil, err
+	}
+
+	bump := &Transaction{LockTime: tx.LockTime}
+	for _, in := range tx.Inputs {
+		bump.Inputs = append(bump.Inputs, TxInput{ID: in.ID, Out: in.Out, PubKey: w.PublicKey, Sequence: in.Sequence})
+	}
+	bump.Outputs = append([]TxOutput(nil), tx.Outputs...)
+
+	value := bump.Outputs[change].Value - (fee - oldFee)
+	if value < 0 {
+		return nil, ErrInsufficientFunds
+	}
+
+	if value < FeeRateOf(fee, tx.Size()).DustThreshold() && len(bump.Outputs) > 1 {
+		bump.Outputs = append(bump.Outputs[:change], bump.Outputs[change+1:]...)
+	} else {
+		bump.Outputs[change].Value = value
+	}
+
+	bump.ID = bump.Hash()
+	UTXO.Blockchain.SignTxWith(bump, w.PrivateKey, UTXO.Pending)
+
+	return bump, nil
+}
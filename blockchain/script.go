@@ -0,0 +1,899 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// Opcodes understood by the script interpreter. Their values and names
+// follow Bitcoin so scripts read the same in both.
+const (
+	Op0                   byte = 0x00
+	OpPushData1           byte = 0x4c
+	OpPushData2           byte = 0x4d
+	Op1Negate             byte = 0x4f
+	Op1                   byte = 0x51
+	Op16                  byte = 0x60
+	OpNop                 byte = 0x61
+	OpIf                  byte = 0x63
+	OpNotIf               byte = 0x64
+	OpElse                byte = 0x67
+	OpEndIf               byte = 0x68
+	OpVerify              byte = 0x69
+	OpReturn              byte = 0x6a
+	OpDrop                byte = 0x75
+	OpDup                 byte = 0x76
+	OpOver                byte = 0x78
+	OpSwap                byte = 0x7c
+	OpSize                byte = 0x82
+	OpEqual               byte = 0x87
+	OpEqualVerify         byte = 0x88
+	OpNot                 byte = 0x91
+	OpAdd                 byte = 0x93
+	OpSub                 byte = 0x94
+	OpSha256              byte = 0xa8
+	OpHash160             byte = 0xa9
+	OpCheckSig            byte = 0xac
+	OpCheckSigVerify      byte = 0xad
+	OpCheckMultiSig       byte = 0xae
+	OpCheckMultiSigVerify byte = 0xaf
+	OpCheckLockTimeVerify byte = 0xb1
+	OpCheckSequenceVerify byte = 0xb2
+)
+
+// Limits that keep script evaluation cheap no matter what a transaction
+// contains.
+const (
+	MaxScriptSize   = 10000
+	MaxElementSize  = 520
+	MaxOps          = 201
+	MaxStackSize    = 1000
+	MaxMultiSigKeys = 20
+	maxNumSize      = 4
+	maxLockTimeSize = 5
+)
+
+var opNames = map[byte]string{
+	Op0:                   "OP_0",
+	OpPushData1:           "OP_PUSHDATA1",
+	OpPushData2:           "OP_PUSHDATA2",
+	Op1Negate:             "OP_1NEGATE",
+	OpNop:                 "OP_NOP",
+	OpIf:                  "OP_IF",
+	OpNotIf:               "OP_NOTIF",
+	OpElse:                "OP_ELSE",
+	OpEndIf:               "OP_ENDIF",
+	OpVerify:              "OP_VERIFY",
+	OpReturn:              "OP_RETURN",
+	OpDrop:                "OP_DROP",
+	OpDup:                 "OP_DUP",
+	OpOver:                "OP_OVER",
+	OpSwap:                "OP_SWAP",
+	OpSize:                "OP_SIZE",
+	OpEqual:               "OP_EQUAL",
+	OpEqualVerify:         "OP_EQUALVERIFY",
+	OpNot:                 "OP_NOT",
+	OpAdd:                 "OP_ADD",
+	OpSub:                 "OP_SUB",
+	OpSha256:              "OP_SHA256",
+	OpHash160:             "OP_HASH160",
+	OpCheckSig:            "OP_CHECKSIG",
+	OpCheckSigVerify:      "OP_CHECKSIGVERIFY",
+	OpCheckMultiSig:       "OP_CHECKMULTISIG",
+	OpCheckMultiSigVerify: "OP_CHECKMULTISIGVERIFY",
+	OpCheckLockTimeVerify: "OP_CHECKLOCKTIMEVERIFY",
+	OpCheckSequenceVerify: "OP_CHECKSEQUENCEVERIFY",
+}
+
+var opCodes = make(map[string]byte)
+
+func init() {
+	for n := 1; n <= 16; n++ {
+		opNames[Op1+byte(n-1)] = fmt.Sprintf("OP_%d", n)
+	}
+
+	for op, name := range opNames {
+		opCodes[name] = op
+	}
+}
+
+// SignatureChecker gives scripts access to the transaction they unlock.
+type SignatureChecker interface {
+	CheckSig(sig, pubKey []byte) bool
+	CheckLockTime(lockTime int64) bool
+	CheckSequence(sequence int64) bool
+}
+
+type instruction struct {
+	op   byte
+	data []byte
+}
+
+func (in instruction) isPush() bool {
+	return in.op <= Op16
+}
+
+// parseScript splits a script into instructions, rejecting truncated pushes
+// and unknown opcodes.
+func parseScript(script []byte) ([]instruction, error) {
+	if len(script) > MaxScriptSize {
+		return nil, fmt.Errorf("script is %d bytes, more than %d", len(script), MaxScriptSize)
+	}
+
+	var instructions []instruction
+
+	for i := 0; i < len(script); {
+		op := script[i]
+		i++
+
+		size := 0
+		switch {
+		case op > Op0 && op < OpPushData1:
+			size = int(op)
+		case op == OpPushData1:
+			if i+1 > len(script) {
+				return nil, errors.New("truncated OP_PUSHDATA1")
+			}
+			size = int(script[i])
+			i++
+		case op == OpPushData2:
+			if i+2 > len(script) {
+				return nil, errors.New("truncated OP_PUSHDATA2")
+			}
+			size = int(script[i]) | int(script[i+1])<<8
+			i += 2
+		default:
+			if _, ok := opNames[op]; !ok {
+				return nil, fmt.Errorf("unknown opcode 0x%02x", op)
+			}
+		}
+
+		if i+size > len(script) {
+			return nil, errors.New("push past the end of the script")
+		}
+		if size > MaxElementSize {
+			return nil, fmt.Errorf("push of %d bytes, more than %d", size, MaxElementSize)
+		}
+
+		var data []byte
+		if size > 0 {
+			data = script[i : i+size]
+		}
+		instructions = append(instructions, instruction{op, data})
+		i += size
+	}
+
+	return instructions, nil
+}
+
+// VerifyScript runs the unlocking script of an input and then the locking
+// script of the output it spends on the resulting stack. The input may spend
+// the output if the top of the stack is true afterwards.
+func VerifyScript(unlocking, locking []byte, checker SignatureChecker) error {
+	unlockInstructions, err := parseScript(unlocking)
+	if err != nil {
+		return fmt.Errorf("unlocking script: %s", err)
+	}
+
+	for _, in := range unlockInstructions {
+		if !in.isPush() {
+			return errors.New("unlocking script may only push data")
+		}
+	}
+
+	lockInstructions, err := parseScript(locking)
+	if err != nil {
+		return fmt.Errorf("locking script: %s", err)
+	}
+
+	e := &engine{checker: checker}
+	if err := e.execute(unlockInstructions); err != nil {
+		return fmt.Errorf("unlocking script: %s", err)
+	}
+
+	e.ops = 0
+	if err := e.execute(lockInstructions); err != nil {
+		return fmt.Errorf("locking script: %s", err)
+	}
+
+	if len(e.stack) == 0 || !asBool(e.stack[len(e.stack)-1]) {
+		return errors.New("script evaluated to false")
+	}
+
+	return nil
+}
+
+type engine struct {
+	stack   [][]byte
+	checker SignatureChecker
+	ops     int
+}
+
+func (e *engine) push(data []byte) {
+	e.stack = append(e.stack, data)
+}
+
+func (e *engine) pushBool(b bool) {
+	if b {
+		e.push([]byte{1})
+	} else {
+		e.push(nil)
+	}
+}
+
+func (e *engine) pop() ([]byte, error) {
+	if len(e.stack) == 0 {
+		return nil, errors.New("stack is empty")
+	}
+
+	top := e.stack[len(e.stack)-1]
+	e.stack = e.stack[:len(e.stack)-1]
+
+	return top, nil
+}
+
+func (e *engine) popNum(maxSize int) (int64, error) {
+	data, err := e.pop()
+	if err != nil {
+		return 0, err
+	}
+
+	return decodeNum(data, maxSize)
+}
+
+func (e *engine) peek(depth int) ([]byte, error) {
+	if depth >= len(e.stack) {
+		return nil, errors.New("stack is too small")
+	}
+
+	return e.stack[len(e.stack)-1-depth], nil
+}
+
+func (e *engine) execute(instructions []instruction) error {
+	var branches []bool
+
+	executing := func() bool {
+		for _, b := range branches {
+			if !b {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, in := range instructions {
+		if !in.isPush() {
+			e.ops++
+			if e.ops > MaxOps {
+				return fmt.Errorf("more than %d operations", MaxOps)
+			}
+		}
+
+		switch in.op {
+		case OpIf, OpNotIf:
+			b := false
+			if executing() {
+				top, err := e.pop()
+				if err != nil {
+					return err
+				}
+				b = asBool(top) == (in.op == OpIf)
+			}
+			branches = append(branches, b)
+			continue
+		case OpElse:
+			if len(branches) == 0 {
+				return errors.New("OP_ELSE without OP_IF")
+			}
+			branches[len(branches)-1] = !branches[len(branches)-1]
+			continue
+		case OpEndIf:
+			if len(branches) == 0 {
+				return errors.New("OP_ENDIF without OP_IF")
+			}
+			branches = branches[:len(branches)-1]
+			continue
+		}
+
+		if !executing() {
+			continue
+		}
+
+		if err := e.step(in); err != nil {
+			return fmt.Errorf("%s: %s", opName(in), err)
+		}
+
+		if len(e.stack) > MaxStackSize {
+			return fmt.Errorf("more than %d stack elements", MaxStackSize)
+		}
+	}
+
+	if len(branches) > 0 {
+		return errors.New("OP_IF without OP_ENDIF")
+	}
+
+	return nil
+}
+
+func (e *engine) step(in instruction) error {
+	switch {
+	case in.op == Op0 || (in.op > Op0 && in.op <= OpPushData2):
+		e.push(in.data)
+		return nil
+	case in.op == Op1Negate:
+		e.push(encodeNum(-1))
+		return nil
+	case in.op >= Op1 && in.op <= Op16:
+		e.push(encodeNum(int64(in.op-Op1) + 1))
+		return nil
+	}
+
+	switch in.op {
+	case OpNop:
+	case OpVerify:
+		top, err := e.pop()
+		if err != nil {
+			return err
+		}
+		if !asBool(top) {
+			return errors.New("verify failed")
+		}
+	case OpReturn:
+		return errors.New("output is unspendable")
+	case OpDrop:
+		_, err := e.pop()
+		return err
+	case OpDup, OpOver:
+		depth := 0
+		if in.op == OpOver {
+			depth = 1
+		}
+		data, err := e.peek(depth)
+		if err != nil {
+			return err
+		}
+		e.push(data)
+	case OpSwap:
+		if len(e.stack) < 2 {
+			return errors.New("stack is too small")
+		}
+		n := len(e.stack)
+		e.stack[n-1], e.stack[n-2] = e.stack[n-2], e.stack[n-1]
+	case OpSize:
+		top, err := e.peek(0)
+		if err != nil {
+			return err
+		}
+		e.push(encodeNum(int64(len(top))))
+	case OpEqual, OpEqualVerify:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+		equal := string(a) == string(b)
+		if in.op == OpEqualVerify {
+			if !equal {
+				return errors.New("values are not equal")
+			}
+			return nil
+		}
+		e.pushBool(equal)
+	case OpNot:
+		n, err := e.popNum(maxNumSize)
+		if err != nil {
+			return err
+		}
+		e.pushBool(n == 0)
+	case OpAdd, OpSub:
+		b, err := e.popNum(maxNumSize)
+		if err != nil {
+			return err
+		}
+		a, err := e.popNum(maxNumSize)
+		if err != nil {
+			return err
+		}
+		if in.op == OpAdd {
+			e.push(encodeNum(a + b))
+		} else {
+			e.push(encodeNum(a - b))
+		}
+	case OpSha256:
+		top, err := e.pop()
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(top)
+		e.push(hash[:])
+	case OpHash160:
+		top, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.push(wallet.PublicKeyHash(top))
+	case OpCheckSig, OpCheckSigVerify:
+		pubKey, err := e.pop()
+		if err != nil {
+			return err
+		}
+		sig, err := e.pop()
+		if err != nil {
+			return err
+		}
+		valid := e.checker.CheckSig(sig, pubKey)
+		if in.op == OpCheckSigVerify {
+			if !valid {
+				return errors.New("signature is not valid")
+			}
+			return nil
+		}
+		e.pushBool(valid)
+	case OpCheckMultiSig, OpCheckMultiSigVerify:
+		valid, err := e.checkMultiSig()
+		if err != nil {
+			return err
+		}
+		if in.op == OpCheckMultiSigVerify {
+			if !valid {
+				return errors.New("signatures are not valid")
+			}
+			return nil
+		}
+		e.pushBool(valid)
+	case OpCheckLockTimeVerify:
+		top, err := e.peek(0)
+		if err != nil {
+			return err
+		}
+		lockTime, err := decodeNum(top, maxLockTimeSize)
+		if err != nil {
+			return err
+		}
+		if lockTime < 0 {
+			return errors.New("negative lock time")
+		}
+		if !e.checker.CheckLockTime(lockTime) {
+			return fmt.Errorf("transaction lock time is before %d", lockTime)
+		}
+	case OpCheckSequenceVerify:
+		top, err := e.peek(0)
+		if err != nil {
+			return err
+		}
+		sequence, err := decodeNum(top, maxLockTimeSize)
+		if err != nil {
+			return err
+		}
+		if sequence < 0 {
+			return errors.New("negative sequence")
+		}
+		if sequence&SequenceDisableFlag == 0 && !e.checker.CheckSequence(sequence) {
+			return fmt.Errorf("input relative lock is shorter than %#x", sequence)
+		}
+	default:
+		return errors.New("unknown opcode")
+	}
+
+	return nil
+}
+
+// checkMultiSig pops N public keys and M signatures, both in the order they
+// were pushed, and checks that every signature matches a later key than the
+// one before it.
+func (e *engine) checkMultiSig() (bool, error) {
+	n, err := e.popNum(maxNumSize)
+	if err != nil {
+		return false, err
+	}
+	if n < 0 || n > MaxMultiSigKeys {
+		return false, fmt.Errorf("%d public keys, at most %d are allowed", n, MaxMultiSigKeys)
+	}
+
+	e.ops += int(n)
+	if e.ops > MaxOps {
+		return false, fmt.Errorf("more than %d operations", MaxOps)
+	}
+
+	pubKeys := make([][]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		if pubKeys[i], err = e.pop(); err != nil {
+			return false, err
+		}
+	}
+
+	m, err := e.popNum(maxNumSize)
+	if err != nil {
+		return false, err
+	}
+	if m < 0 || m > n {
+		return false, fmt.Errorf("%d signatures required from %d public keys", m, n)
+	}
+
+	sigs := make([][]byte, m)
+	for i := m - 1; i >= 0; i-- {
+		if sigs[i], err = e.pop(); err != nil {
+			return false, err
+		}
+	}
+
+	k := 0
+	for _, sig := range sigs {
+		for k < len(pubKeys) && !e.checker.CheckSig(sig, pubKeys[k]) {
+			k++
+		}
+		if k == len(pubKeys) {
+			return false, nil
+		}
+		k++
+	}
+
+	return true, nil
+}
+
+func asBool(data []byte) bool {
+	for i, b := range data {
+		if b != 0 {
+			// Negative zero is false too.
+			return !(i == len(data)-1 && b == 0x80)
+		}
+	}
+
+	return false
+}
+
+// encodeNum encodes n the way Bitcoin script does: little endian with the
+// sign in the top bit of the last byte.
+func encodeNum(n int64) []byte {
+	if n == 0 {
+		return nil
+	}
+
+	negative := n < 0
+	abs := uint64(n)
+	if negative {
+		abs = uint64(-n)
+	}
+
+	var data []byte
+	for abs > 0 {
+		data = append(data, byte(abs))
+		abs >>= 8
+	}
+
+	if data[len(data)-1]&0x80 != 0 {
+		if negative {
+			data = append(data, 0x80)
+		} else {
+			data = append(data, 0)
+		}
+	} else if negative {
+		data[len(data)-1] |= 0x80
+	}
+
+	return data
+}
+
+func decodeNum(data []byte, maxSize int) (int64, error) {
+	if len(data) > maxSize {
+		return 0, fmt.Errorf("number of %d bytes, more than %d", len(data), maxSize)
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	var n int64
+	for i, b := range data {
+		n |= int64(b) << (8 * uint(i))
+	}
+
+	if data[len(data)-1]&0x80 != 0 {
+		n &^= int64(0x80) << (8 * uint(len(data)-1))
+		return -n, nil
+	}
+
+	return n, nil
+}
+
+func opName(in instruction) string {
+	if in.op > Op0 && in.op <= OpPushData2 {
+		return "push"
+	}
+
+	return opNames[in.op]
+}
+
+// ScriptBuilder assembles scripts, choosing the push opcode for each piece
+// of data.
+type ScriptBuilder struct {
+	script []byte
+}
+
+func NewScriptBuilder() *ScriptBuilder {
+	return &ScriptBuilder{}
+}
+
+func (b *ScriptBuilder) AddOp(ops ...byte) *ScriptBuilder {
+	b.script = append(b.script, ops...)
+	return b
+}
+
+func (b *ScriptBuilder) AddData(data []byte) *ScriptBuilder {
+	switch {
+	case len(data) == 0:
+		b.script = append(b.script, Op0)
+	case len(data) < int(OpPushData1):
+		b.script = append(b.script, byte(len(data)))
+	case len(data) <= 0xff:
+		b.script = append(b.script, OpPushData1, byte(len(data)))
+	default:
+		b.script = append(b.script, OpPushData2, byte(len(data)), byte(len(data)>>8))
+	}
+
+	b.script = append(b.script, data...)
+
+	return b
+}
+
+func (b *ScriptBuilder) AddInt(n int64) *ScriptBuilder {
+	switch {
+	case n == 0:
+		return b.AddOp(Op0)
+	case n == -1:
+		return b.AddOp(Op1Negate)
+	case n >= 1 && n <= 16:
+		return b.AddOp(Op1 + byte(n-1))
+	}
+
+	return b.AddData(encodeNum(n))
+}
+
+func (b *ScriptBuilder) Script() []byte {
+	return b.script
+}
+
+// P2PKHScript is the locking script equivalent to a plain address output.
+func P2PKHScript(pubKeyHash []byte) []byte {
+	return NewScriptBuilder().AddOp(OpDup, OpHash160).AddData(pubKeyHash).AddOp(OpEqualVerify, OpCheckSig).Script()
+}
+
+// HashLockScript can be spent by anyone who knows the SHA-256 preimage of
+// hash.
+func HashLockScript(hash []byte) []byte {
+	return NewScriptBuilder().AddOp(OpSha256).AddData(hash).AddOp(OpEqual).Script()
+}
+
+// TimeLockScript pays to pubKeyHash once transactions may have a lock time
+// of lockTime.
+func TimeLockScript(lockTime int64, pubKeyHash []byte) []byte {
+	script := NewScriptBuilder().AddInt(lockTime).AddOp(OpCheckLockTimeVerify, OpDrop).Script()
+
+	return append(script, P2PKHScript(pubKeyHash)...)
+}
+
+// RelativeLockScript pays to pubKeyHash once the output has been confirmed
+// for as long as sequence says, see SequenceTypeFlag.
+func RelativeLockScript(sequence int64, pubKeyHash []byte) []byte {
+	script := NewScriptBuilder().AddInt(sequence).AddOp(OpCheckSequenceVerify, OpDrop).Script()
+
+	return append(script, P2PKHScript(pubKeyHash)...)
+}
+
+// MultiSigScript requires m signatures made with different keys of pubKeys,
+// given in the order of the keys.
+func MultiSigScript(m int, pubKeys [][]byte) []byte {
+	b := NewScriptBuilder().AddInt(int64(m))
+	for _, pubKey := range pubKeys {
+		b.AddData(pubKey)
+	}
+
+	return b.AddInt(int64(len(pubKeys))).AddOp(OpCheckMultiSig).Script()
+}
+
+// NewMultiSigScript is MultiSigScript checking that the script is standard
+// and small enough to be redeemed through a pay to script hash address.
+func NewMultiSigScript(m int, pubKeys [][]byte) ([]byte, error) {
+	if len(pubKeys) == 0 || len(pubKeys) > MaxMultiSigKeys {
+		return nil, fmt.Errorf("a multisig script needs 1 to %d keys", MaxMultiSigKeys)
+	}
+	if m < 1 || m > len(pubKeys) {
+		return nil, fmt.Errorf("cannot require %d of %d signatures", m, len(pubKeys))
+	}
+
+	for i, pubKey := range pubKeys {
+		if _, err := wallet.ParsePublicKey(pubKey); err != nil {
+			return nil, fmt.Errorf("key %d: %s", i+1, err)
+		}
+		for _, other := range pubKeys[:i] {
+			if bytes.Equal(other, pubKey) {
+				return nil, fmt.Errorf("key %d is given twice", i+1)
+			}
+		}
+	}
+
+	script := MultiSigScript(m, pubKeys)
+	if len(script) > MaxElementSize {
+		return nil, fmt.Errorf("a script of %d keys is too big to redeem, the limit is %d bytes", len(pubKeys), MaxElementSize)
+	}
+
+	return script, nil
+}
+
+// ParseMultiSigScript returns the number of signatures a multisig script
+// requires and its public keys.
+func ParseMultiSigScript(script []byte) (int, [][]byte, error) {
+	instructions, err := parseScript(script)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	n := len(instructions) - 3
+	if n < 1 || instructions[len(instructions)-1].op != OpCheckMultiSig {
+		return 0, nil, errors.New("not a multisig script")
+	}
+
+	m, ok := smallInt(instructions[0])
+	total, totalOk := smallInt(instructions[n+1])
+	if !ok || !totalOk || total != n || m < 1 || m > n {
+		return 0, nil, errors.New("not a multisig script")
+	}
+
+	var pubKeys [][]byte
+	for _, in := range instructions[1 : n+1] {
+		if in.op == Op0 || in.op > OpPushData2 {
+			return 0, nil, errors.New("not a multisig script")
+		}
+		pubKeys = append(pubKeys, in.data)
+	}
+
+	return m, pubKeys, nil
+}
+
+func smallInt(in instruction) (int, bool) {
+	if in.op < Op1 || in.op > Op16 {
+		return 0, false
+	}
+
+	return int(in.op-Op1) + 1, true
+}
+
+// ScriptHashScript pays to whoever reveals a script hashing to scriptHash
+// and satisfies it, like a pay to script hash address.
+func ScriptHashScript(scriptHash []byte) []byte {
+	return NewScriptBuilder().AddOp(OpHash160).AddData(scriptHash).AddOp(OpEqual).Script()
+}
+
+// extractScriptHash returns the hash a pay to script hash script commits to,
+// or nil for any other script.
+func extractScriptHash(script []byte) []byte {
+	if len(script) != 23 || script[0] != OpHash160 || script[1] != 20 || script[22] != OpEqual {
+		return nil
+	}
+
+	return script[2:22]
+}
+
+// splitRedeemScript separates the redeem script pushed last by the
+// unlocking script of a pay to script hash input from the pushes before it.
+func splitRedeemScript(unlocking []byte) ([]byte, []byte, error) {
+	instructions, err := parseScript(unlocking)
+	if err != nil || len(instructions) == 0 {
+		return nil, nil, errors.New("unlocking script does not push a redeem script")
+	}
+
+	last := instructions[len(instructions)-1]
+	header := 1
+	switch last.op {
+	case OpPushData1:
+		header = 2
+	case OpPushData2:
+		header = 3
+	}
+
+	return unlocking[:len(unlocking)-header-len(last.data)], last.data, nil
+}
+
+// DataScript carries data in an output nobody can spend.
+func DataScript(data []byte) []byte {
+	return NewScriptBuilder().AddOp(OpReturn).AddData(data).Script()
+}
+
+// DisasmScript renders a script as opcode names and hex encoded data.
+func DisasmScript(script []byte) (string, error) {
+	instructions, err := parseScript(script)
+	if err != nil {
+		return "", err
+	}
+
+	var parts []string
+	for _, in := range instructions {
+		if in.op > Op0 && in.op <= OpPushData2 {
+			parts = append(parts, hex.EncodeToString(in.data))
+		} else {
+			parts = append(parts, opNames[in.op])
+		}
+	}
+
+	return strings.Join(parts, " "), nil
+}
+
+// AssembleScript is the inverse of DisasmScript. Besides opcode names it
+// accepts hex data and plain numbers prefixed with #, such as #144.
+func AssembleScript(asm string) ([]byte, error) {
+	b := NewScriptBuilder()
+
+	for _, token := range strings.Fields(asm) {
+		if op, ok := opCodes[strings.ToUpper(token)]; ok {
+			b.AddOp(op)
+			continue
+		}
+
+		if strings.HasPrefix(token, "#") {
+			var n int64
+			if _, err := fmt.Sscanf(token[1:], "%d", &n); err != nil {
+				return nil, fmt.Errorf("%s is not a number", token)
+			}
+			b.AddInt(n)
+			continue
+		}
+
+		data, err := hex.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("%s is neither an opcode nor hex data", token)
+		}
+		b.AddData(data)
+	}
+
+	script := b.Script()
+	if _, err := parseScript(script); err != nil {
+		return nil, err
+	}
+
+	return script, nil
+}
+
+// ScriptType names the standard script templates.
+func ScriptType(script []byte) string {
+	instructions, err := parseScript(script)
+	if err != nil {
+		return "invalid"
+	}
+
+	ops := make([]byte, len(instructions))
+	for i, in := range instructions {
+		ops[i] = in.op
+		if in.op > Op0 && in.op <= OpPushData2 {
+			ops[i] = OpPushData1
+		}
+	}
+
+	switch {
+	case len(ops) > 0 && ops[0] == OpReturn:
+		return "nulldata"
+	case len(ops) == 17 && ops[0] == OpIf && ops[9] == OpCheckLockTimeVerify:
+		return "htlc"
+	case len(ops) == 16 && ops[0] == OpIf && ops[5] == OpCheckMultiSig:
+		return "channel"
+	case extractScriptHash(script) != nil:
+		return "scripthash"
+	case string(ops) == string([]byte{OpDup, OpHash160, OpPushData1, OpEqualVerify, OpCheckSig}):
+		return "pubkeyhash"
+	case string(ops) == string([]byte{OpSha256, OpPushData1, OpEqual}):
+		return "hashlock"
+	case len(ops) >= 3 && ops[len(ops)-1] == OpCheckMultiSig:
+		return "multisig"
+	case len(ops) > 2 && (ops[1] == OpCheckLockTimeVerify || ops[1] == OpCheckSequenceVerify):
+		return "timelock"
+	}
+
+	return "nonstandard"
+}
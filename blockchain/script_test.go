@@ -0,0 +1,164 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// fakeChecker accepts a signature equal to the public key, lock times up to
+// 100 and relative locks up to 10.
+type fakeChecker struct{}
+
+func (fakeChecker) CheckSig(sig, pubKey []byte) bool {
+	return len(sig) > 0 && bytes.Equal(sig, pubKey)
+}
+
+func (fakeChecker) CheckLockTime(lockTime int64) bool {
+	return lockTime <= 100
+}
+
+func (fakeChecker) CheckSequence(sequence int64) bool {
+	return sequence <= 10
+}
+
+func asm(t *testing.T, s string) []byte {
+	t.Helper()
+
+	script, err := AssembleScript(s)
+	if err != nil {
+		t.Fatalf("assembling %q: %s", s, err)
+	}
+
+	return script
+}
+
+func TestVerifyScript(t *testing.T) {
+	key1, key2, key3 := []byte{1, 1}, []byte{2, 2}, []byte{3, 3}
+	keys := [][]byte{key1, key2, key3}
+	secret := []byte("secret")
+	secretHash := sha256.Sum256(secret)
+
+	nops := strings.Repeat("OP_NOP ", MaxOps+1) + "#1"
+
+	tests := []struct {
+		name      string
+		unlocking []byte
+		locking   []byte
+		err       string
+	}{
+		{"add", asm(t, "#2 #3"), asm(t, "OP_ADD #5 OP_EQUAL"), ""},
+		{"sub below zero", asm(t, "#2 #3"), asm(t, "OP_SUB OP_1NEGATE OP_EQUAL"), ""},
+		{"big numbers", asm(t, "#1000 #24"), asm(t, "OP_ADD #1024 OP_EQUAL"), ""},
+		{"not", asm(t, "#0"), asm(t, "OP_NOT"), ""},
+		{"false", asm(t, "#1"), asm(t, "OP_NOT"), "evaluated to false"},
+		{"empty stack", nil, asm(t, "OP_NOP"), "evaluated to false"},
+		{"if", asm(t, "#1"), asm(t, "OP_IF #7 OP_ELSE #8 OP_ENDIF #7 OP_EQUAL"), ""},
+		{"else", asm(t, "#0"), asm(t, "OP_IF #7 OP_ELSE #8 OP_ENDIF #8 OP_EQUAL"), ""},
+		{"notif", asm(t, "#0"), asm(t, "OP_NOTIF #1 OP_ELSE OP_RETURN OP_ENDIF"), ""},
+		{"nested if", asm(t, "#1 #0"), asm(t, "OP_IF OP_RETURN OP_ELSE OP_IF #1 OP_ELSE OP_RETURN OP_ENDIF OP_ENDIF"), ""},
+		{"skipped branch", asm(t, "#0"), asm(t, "OP_IF OP_RETURN OP_ENDIF #1"), ""},
+		{"unterminated if", asm(t, "#1"), asm(t, "OP_IF #1"), "without OP_ENDIF"},
+		{"else without if", asm(t, "#1"), asm(t, "OP_ELSE"), "without OP_IF"},
+		{"endif without if", asm(t, "#1"), asm(t, "OP_ENDIF"), "without OP_IF"},
+		{"verify", asm(t, "#1"), asm(t, "OP_VERIFY #1"), ""},
+		{"verify fails", asm(t, "#0"), asm(t, "OP_VERIFY #1"), "verify failed"},
+		{"return", asm(t, "#1"), asm(t, "OP_RETURN"), "unspendable"},
+		{"drop", asm(t, "#1 #0"), asm(t, "OP_DROP"), ""},
+		{"drop empty", nil, asm(t, "OP_DROP #1"), "stack is empty"},
+		{"dup", asm(t, "#4"), asm(t, "OP_DUP OP_EQUAL"), ""},
+		{"over", asm(t, "#4 #5"), asm(t, "OP_OVER #4 OP_EQUALVERIFY #5 OP_EQUALVERIFY #4 OP_EQUAL"), ""},
+		{"swap", asm(t, "#4 #5"), asm(t, "OP_SWAP #4 OP_EQUALVERIFY #5 OP_EQUAL"), ""},
+		{"swap too small", asm(t, "#4"), asm(t, "OP_SWAP"), "too small"},
+		{"size", asm(t, "0a0b0c"), asm(t, "OP_SIZE #3 OP_EQUAL"), ""},
+		{"equalverify fails", asm(t, "#1 #2"), asm(t, "OP_EQUALVERIFY #1"), "not equal"},
+		{"hash lock", NewScriptBuilder().AddData(secret).Script(), HashLockScript(secretHash[:]), ""},
+		{"wrong preimage", asm(t, "00"), HashLockScript(secretHash[:]), "evaluated to false"},
+		{"pay to public key hash", NewScriptBuilder().AddData(key1).AddData(key1).Script(), P2PKHScript(wallet.PublicKeyHash(key1)), ""},
+		{"wrong public key", NewScriptBuilder().AddData(key2).AddData(key2).Script(), P2PKHScript(wallet.PublicKeyHash(key1)), "not equal"},
+		{"wrong signature", NewScriptBuilder().AddData(key2).AddData(key1).Script(), P2PKHScript(wallet.PublicKeyHash(key1)), "evaluated to false"},
+		{"checksigverify", NewScriptBuilder().AddData(key2).AddData(key1).Script(), asm(t, "OP_CHECKSIGVERIFY #1"), "not valid"},
+		{"lock time", asm(t, "#1"), asm(t, "#100 OP_CHECKLOCKTIMEVERIFY OP_DROP"), ""},
+		{"lock time not reached", asm(t, "#1"), asm(t, "#101 OP_CHECKLOCKTIMEVERIFY OP_DROP"), "lock time is before 101"},
+		{"negative lock time", asm(t, "#1"), asm(t, "OP_1NEGATE OP_CHECKLOCKTIMEVERIFY"), "negative lock time"},
+		{"relative lock", asm(t, "#1"), asm(t, "#10 OP_CHECKSEQUENCEVERIFY OP_DROP"), ""},
+		{"relative lock not reached", asm(t, "#1"), asm(t, "#11 OP_CHECKSEQUENCEVERIFY OP_DROP"), "shorter than"},
+		{"relative lock disabled", asm(t, "#1"), NewScriptBuilder().AddInt(SequenceDisableFlag|11).AddOp(OpCheckSequenceVerify, OpDrop).Script(), ""},
+		{"multisig", NewScriptBuilder().AddData(key1).AddData(key3).Script(), MultiSigScript(2, keys), ""},
+		{"multisig out of order", NewScriptBuilder().AddData(key3).AddData(key1).Script(), MultiSigScript(2, keys), "evaluated to false"},
+		{"multisig same key twice", NewScriptBuilder().AddData(key1).AddData(key1).Script(), MultiSigScript(2, keys), "evaluated to false"},
+		{"multisig too few signatures", NewScriptBuilder().AddData(key1).Script(), MultiSigScript(2, keys), "stack is empty"},
+		{"multisigverify", NewScriptBuilder().AddData(key2).Script(), asm(t, "#1 0101 0202 #2 OP_CHECKMULTISIGVERIFY #1"), ""},
+		{"multisig too many keys", asm(t, "#0"), NewScriptBuilder().AddInt(MaxMultiSigKeys + 1).AddOp(OpCheckMultiSig).Script(), "at most"},
+		{"unlocking runs opcodes", asm(t, "#1 OP_DUP"), asm(t, "OP_EQUAL"), "may only push data"},
+		{"unknown opcode", asm(t, "#1"), []byte{0xff}, "unknown opcode"},
+		{"truncated push", asm(t, "#1"), []byte{5, 1, 2}, "past the end"},
+		{"too many operations", nil, asm(t, nops), "more than 201 operations"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := VerifyScript(test.unlocking, test.locking, fakeChecker{})
+			switch {
+			case test.err == "" && err != nil:
+				t.Fatalf("got error %q", err)
+			case test.err != "" && err == nil:
+				t.Fatalf("got no error, want %q", test.err)
+			case test.err != "" && !strings.Contains(err.Error(), test.err):
+				t.Fatalf("got error %q, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestScriptNumbers(t *testing.T) {
+	tests := []struct {
+		n       int64
+		encoded []byte
+	}{
+		{0, nil},
+		{1, []byte{0x01}},
+		{-1, []byte{0x81}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x00}},
+		{-128, []byte{0x80, 0x80}},
+		{255, []byte{0xff, 0x00}},
+		{256, []byte{0x00, 0x01}},
+		{-32768, []byte{0x00, 0x80, 0x80}},
+		{1<<31 - 1, []byte{0xff, 0xff, 0xff, 0x7f}},
+	}
+
+	for _, test := range tests {
+		encoded := encodeNum(test.n)
+		if !bytes.Equal(encoded, test.encoded) {
+			t.Errorf("encodeNum(%d) = %x, want %x", test.n, encoded, test.encoded)
+		}
+
+		n, err := decodeNum(encoded, maxLockTimeSize)
+		if err != nil || n != test.n {
+			t.Errorf("decodeNum(%x) = %d, %v, want %d", encoded, n, err, test.n)
+		}
+	}
+
+	if _, err := decodeNum([]byte{1, 2, 3, 4, 5}, maxNumSize); err == nil {
+		t.Error("decodeNum accepted a number longer than its limit")
+	}
+	if asBool([]byte{0, 0x80}) {
+		t.Error("negative zero is true")
+	}
+}
+
+func TestDisasmScript(t *testing.T) {
+	script := asm(t, "OP_DUP OP_HASH160 0a0b #144 OP_CHECKSIG")
+
+	got, err := DisasmScript(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "OP_DUP OP_HASH160 0a0b 9000 OP_CHECKSIG"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
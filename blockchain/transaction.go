@@ -6,20 +6,27 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/gob"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
+	"sort"
 	"strings"
 
 	"github.com/gitferry/blockchain-go/wallet"
 )
 
+// Transaction may not be mined before the block at height LockTime, or
+// before the median time past reaches LockTime if it is a unix time, see
+// LockTimeThreshold.
 type Transaction struct {
-	ID      []byte
-	Inputs  []TxInput
-	Outputs []TxOutput
+	ID       []byte
+	Inputs   []TxInput
+	Outputs  []TxOutput
+	LockTime int
 }
 
 func (tx *Transaction) Serialize() []byte {
@@ -43,17 +50,146 @@ func DeserializeTransaction(data []byte) Transaction {
 	return transaction
 }
 
+// DecodeTransaction is DeserializeTransaction for untrusted input, returning
+// an error instead of panicking.
+func DecodeTransaction(data []byte) (*Transaction, error) {
+	var transaction Transaction
+
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&transaction); err != nil {
+		return nil, err
+	}
+
+	return &transaction, nil
+}
+
 func (tx *Transaction) Hash() []byte {
 	var hash [32]byte
 
 	TxCopy := *tx
 	TxCopy.ID = []byte{}
 
-	hash = sha256.Sum256(TxCopy.Serialize())
+	hash = sha256.Sum256(TxCopy.hashData())
 
 	return hash[:]
 }
 
+// hashData is the canonical encoding transactions are hashed over. Gob output
+// depends on the order a process first used its types, so two processes may
+// encode the same transaction differently. Switching from gob to it changed
+// every transaction ID, signature and block hash, see ContinueBlockchain.
+func (tx *Transaction) hashData() []byte {
+	var data bytes.Buffer
+
+	writeBytes(&data, tx.ID)
+
+	writeInt(&data, len(tx.Inputs))
+	for _, in := range tx.Inputs {
+		writeBytes(&data, in.ID)
+		writeInt(&data, in.Out)
+		writeBytes(&data, in.Signature)
+		writeBytes(&data, in.PubKey)
+	}
+
+	writeInt(&data, len(tx.Outputs))
+	for _, out := range tx.Outputs {
+		writeInt(&data, out.Value)
+		writeBytes(&data, out.PubKeyHash)
+	}
+
+	// Fields added after this encoding are only written when set, so
+	// transactions that do not use them hash as they did before the fields
+	// existed.
+	if tx.hasExtensions() {
+		writeInt(&data, tx.LockTime)
+		for _, in := range tx.Inputs {
+			writeBytes(&data, in.Script)
+		}
+		for _, out := range tx.Outputs {
+			writeBytes(&data, out.Script)
+		}
+
+		if tx.hasSequences() {
+			for _, in := range tx.Inputs {
+				writeInt(&data, in.Sequence)
+			}
+		}
+
+		if tx.hasAssets() {
+			for _, out := range tx.Outputs {
+				writeBytes(&data, out.Asset)
+				writeInt(&data, out.Tokens)
+			}
+		}
+	}
+
+	return data.Bytes()
+}
+
+func (tx *Transaction) hasExtensions() bool {
+	if tx.LockTime != 0 || tx.hasSequences() || tx.hasAssets() {
+		return true
+	}
+
+	for _, in := range tx.Inputs {
+		if len(in.Script) > 0 {
+			return true
+		}
+	}
+
+	for _, out := range tx.Outputs {
+		if len(out.Script) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (tx *Transaction) hasAssets() bool {
+	for _, out := range tx.Outputs {
+		if out.HasAsset() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (tx *Transaction) hasSequences() bool {
+	for _, in := range tx.Inputs {
+		if in.Sequence != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeInt(buf *bytes.Buffer, n int) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(int64(n)))
+	buf.Write(b[:])
+}
+
+func writeBytes(buf *bytes.Buffer, b []byte) {
+	writeInt(buf, len(b))
+	buf.Write(b)
+}
+
+// UnsignedHash is the hash a transaction ID is set to. Signatures and
+// unlocking scripts are added after the ID has been set, so they are left
+// out of it.
+func (tx *Transaction) UnsignedHash() []byte {
+	txCopy := *tx
+	txCopy.Inputs = make([]TxInput, len(tx.Inputs))
+
+	for i, in := range tx.Inputs {
+		txCopy.Inputs[i] = TxInput{ID: in.ID, Out: in.Out, PubKey: in.PubKey, Sequence: in.Sequence}
+	}
+
+	return txCopy.Hash()
+}
+
 func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) {
 	if tx.IsCoinbase() {
 		return
@@ -65,21 +201,38 @@ func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transac
 		}
 	}
 
-	txCopy := tx.TrimmedCopy()
-
-	for inIdx, in := range txCopy.Inputs {
+	for inIdx, in := range tx.Inputs {
 		prevTX := prevTXs[hex.EncodeToString(in.ID)]
-		in.Signature = nil
-		in.PubKey = prevTX.Outputs[in.Out].PubKeyHash
-		txCopy.ID = txCopy.Hash()
-		in.PubKey = nil
+		tx.SignInput(inIdx, privKey, prevTX.Outputs[in.Out])
+	}
+}
 
-		r, s, err := ecdsa.Sign(rand.Reader, &privKey, txCopy.ID)
-		HandleErr(err)
-		signature := append(r.Bytes(), s.Bytes()...)
+// SignInput signs a single input given the output it spends, which is all a
+// signer needs to know about the previous transaction.
+func (tx *Transaction) SignInput(inIdx int, privKey ecdsa.PrivateKey, prevOut TxOutput) {
+	tx.Inputs[inIdx].Signature = tx.SignatureFor(inIdx, privKey, prevOut)
+}
+
+// SignatureFor signs input inIdx for a script that checks signatures, such
+// as a multisig or hash lock script, without storing the signature.
+func (tx *Transaction) SignatureFor(inIdx int, privKey ecdsa.PrivateKey, prevOut TxOutput) []byte {
+	r, s, err := ecdsa.Sign(rand.Reader, &privKey, tx.sigHash(inIdx, prevOut))
+	HandleErr(err)
+
+	return append(paddedBytes(r, 32), paddedBytes(s, 32)...)
+}
 
-		tx.Inputs[inIdx].Signature = signature
+// sigHash commits to the transaction without any signatures and to the
+// locking script of the output the signed input spends.
+func (tx *Transaction) sigHash(inIdx int, prevOut TxOutput) []byte {
+	txCopy := tx.TrimmedCopy()
+	if len(prevOut.Script) > 0 {
+		txCopy.Inputs[inIdx].Script = prevOut.Script
+	} else {
+		txCopy.Inputs[inIdx].PubKey = prevOut.PubKeyHash
 	}
+
+	return txCopy.Hash()
 }
 
 func (tx *Transaction) TrimmedCopy() Transaction {
@@ -87,58 +240,137 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 	var outputs []TxOutput
 
 	for _, in := range tx.Inputs {
-		inputs = append(inputs, TxInput{in.ID, in.Out, nil, nil})
+		inputs = append(inputs, TxInput{ID: in.ID, Out: in.Out, Sequence: in.Sequence})
 	}
 
 	for _, out := range tx.Outputs {
-		outputs = append(outputs, TxOutput{out.Value, out.PubKeyHash})
+		outputs = append(outputs, out)
 	}
 
-	txCopy := Transaction{tx.ID, inputs, outputs}
+	txCopy := Transaction{ID: tx.ID, Inputs: inputs, Outputs: outputs, LockTime: tx.LockTime}
 
 	return txCopy
 }
 
 func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
 	if tx.IsCoinbase() {
-		return true
+		return !tx.hasAssets()
 	}
 
 	for _, in := range tx.Inputs {
-		if prevTXs[hex.EncodeToString(in.ID)].ID != nil {
+		if prevTXs[hex.EncodeToString(in.ID)].ID == nil {
 			log.Panic("ERROR: Previous transaction does not exit!")
 		}
 	}
 
-	txCopy := tx.TrimmedCopy()
-	curve := elliptic.P256()
-
-	for _, in := range txCopy.Inputs {
+	spent := 0
+	for inIdx, in := range tx.Inputs {
 		prevTX := prevTXs[hex.EncodeToString(in.ID)]
-		in.Signature = nil
-		in.PubKey = prevTX.Outputs[in.Out].PubKeyHash
-		txCopy.ID = txCopy.Hash()
-		in.PubKey = nil
-
-		r := big.Int{}
-		s := big.Int{}
-		sigLen := len(in.Signature)
-		r.SetBytes(in.Signature[:(sigLen / 2)])
-		s.SetBytes(in.Signature[(sigLen / 2):])
-
-		x := big.Int{}
-		y := big.Int{}
-		keyLen := len(in.PubKey)
-		x.SetBytes(in.PubKey[:(keyLen / 2)])
-		y.SetBytes(in.PubKey[(keyLen / 2):])
-
-		rawPubKey := ecdsa.PublicKey{curve, &x, &y}
-		if ecdsa.Verify(&rawPubKey, txCopy.ID, &r, &s) == false {
+		if in.Out < 0 || in.Out >= len(prevTX.Outputs) || !tx.VerifyInput(inIdx, prevTX.Outputs[in.Out]) {
 			return false
 		}
+
+		for _, other := range tx.Inputs[:inIdx] {
+			if bytes.Equal(other.ID, in.ID) && other.Out == in.Out {
+				return false
+			}
+		}
+
+		spent += prevTX.Outputs[in.Out].Value
 	}
 
-	return true
+	for _, out := range tx.Outputs {
+		if out.Value < 0 || !out.wellFormed() {
+			return false
+		}
+		spent -= out.Value
+	}
+
+	return spent >= 0 && tx.checkTokens(prevTXs)
+}
+
+// VerifyInput checks that an input satisfies the locking script of the
+// output it spends.
+func (tx *Transaction) VerifyInput(inIdx int, prevOut TxOutput) bool {
+	return tx.VerifyInputScript(inIdx, prevOut) == nil
+}
+
+// VerifyInputScript is VerifyInput telling why an input is invalid. Inputs
+// spending a pay to script hash output also have to satisfy the redeem
+// script they reveal, which signatures commit to instead of the output.
+func (tx *Transaction) VerifyInputScript(inIdx int, prevOut TxOutput) error {
+	unlocking := tx.Inputs[inIdx].UnlockingScript()
+
+	if err := VerifyScript(unlocking, prevOut.LockingScript(), txSigChecker{tx, inIdx, prevOut}); err != nil {
+		return err
+	}
+
+	if extractScriptHash(prevOut.Script) == nil {
+		return nil
+	}
+
+	args, redeemScript, err := splitRedeemScript(unlocking)
+	if err != nil {
+		return err
+	}
+
+	redeemOut := TxOutput{Value: prevOut.Value, Script: redeemScript}
+	if err := VerifyScript(args, redeemScript, txSigChecker{tx, inIdx, redeemOut}); err != nil {
+		return fmt.Errorf("redeem script: %s", err)
+	}
+
+	return nil
+}
+
+type txSigChecker struct {
+	tx      *Transaction
+	inIdx   int
+	prevOut TxOutput
+}
+
+func (c txSigChecker) CheckSig(sig, pubKey []byte) bool {
+	rawPubKey, err := wallet.ParsePublicKey(pubKey)
+	if err != nil || len(sig) != 64 {
+		return false
+	}
+
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	x := new(big.Int).SetBytes(rawPubKey[:32])
+	y := new(big.Int).SetBytes(rawPubKey[32:])
+
+	key := ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+
+	return ecdsa.Verify(&key, c.tx.sigHash(c.inIdx, c.prevOut), r, s)
+}
+
+// CheckLockTime accepts lock times the transaction's own lock time has
+// passed, both being heights or both times.
+func (c txSigChecker) CheckLockTime(lockTime int64) bool {
+	if (lockTime < LockTimeThreshold) != (c.tx.LockTime < LockTimeThreshold) {
+		return false
+	}
+
+	return lockTime <= int64(c.tx.LockTime)
+}
+
+// CheckSequence accepts relative locks the input's own relative lock is at
+// least as long as, both counting blocks or both time.
+func (c txSigChecker) CheckSequence(sequence int64) bool {
+	own := int64(c.tx.Inputs[c.inIdx].Sequence)
+	if own&SequenceDisableFlag != 0 || own&SequenceTypeFlag != sequence&SequenceTypeFlag {
+		return false
+	}
+
+	return sequence&SequenceMask <= own&SequenceMask
+}
+
+func paddedBytes(n *big.Int, size int) []byte {
+	buf := make([]byte, size)
+	b := n.Bytes()
+	copy(buf[size-len(b):], b)
+
+	return buf
 }
 
 func (tx *Transaction) String() string {
@@ -151,18 +383,47 @@ func (tx *Transaction) String() string {
 		lines = append(lines, fmt.Sprintf("        Out: %d", in.Out))
 		lines = append(lines, fmt.Sprintf("        Signature: %x", in.Signature))
 		lines = append(lines, fmt.Sprintf("        PubKey: %x", in.PubKey))
+		if len(in.Script) > 0 {
+			lines = append(lines, fmt.Sprintf("        Unlocking script: %s", disasmOrHex(in.Script)))
+		}
+		if in.Sequence != 0 {
+			lines = append(lines, fmt.Sprintf("        Sequence: %#x", in.Sequence))
+		}
 	}
 
 	for i, out := range tx.Outputs {
 		lines = append(lines, fmt.Sprintf("    Output: %d", i))
 		lines = append(lines, fmt.Sprintf("        Value: %d", out.Value))
-		lines = append(lines, fmt.Sprintf("        Script: %x", out.PubKeyHash))
+		if out.HasAsset() {
+			lines = append(lines, fmt.Sprintf("        Tokens: %d of asset %x", out.Tokens, out.Asset))
+		}
+		if len(out.Script) > 0 {
+			lines = append(lines, fmt.Sprintf("        Locking script: %s", disasmOrHex(out.Script)))
+		} else {
+			lines = append(lines, fmt.Sprintf("        Script: %x", out.PubKeyHash))
+		}
+	}
+
+	if tx.LockTime != 0 {
+		lines = append(lines, fmt.Sprintf("    Lock time: %d", tx.LockTime))
 	}
 
 	return strings.Join(lines, "\n")
 }
 
-func CoinBaseTx(to, data string) *Transaction {
+func disasmOrHex(script []byte) string {
+	if asm, err := DisasmScript(script); err == nil {
+		return asm
+	}
+
+	return fmt.Sprintf("%x", script)
+}
+
+// Subsidy is the newly created part of the block reward.
+const Subsidy = 20
+
+// CoinBaseTx pays the subsidy and the fees of the block's transactions to to.
+func CoinBaseTx(to, data string, fees int) *Transaction {
 	if data == "" {
 		randData := make([]byte, 24)
 		_, err := rand.Read(randData)
@@ -170,10 +431,10 @@ func CoinBaseTx(to, data string) *Transaction {
 		data = fmt.Sprintf("%x", randData)
 	}
 
-	txInput := TxInput{[]byte{}, -1, nil, []byte(data)}
-	txOutput := NewTXOutput(20, to)
+	txInput := TxInput{ID: []byte{}, Out: -1, PubKey: []byte(data)}
+	txOutput := NewTXOutput(Subsidy+fees, to)
 
-	tx := Transaction{nil, []TxInput{txInput}, []TxOutput{*txOutput}}
+	tx := Transaction{Inputs: []TxInput{txInput}, Outputs: []TxOutput{*txOutput}}
 	tx.ID = tx.Hash()
 
 	return &tx
@@ -183,39 +444,180 @@ func (tx *Transaction) IsCoinbase() bool {
 	return len(tx.Inputs) == 1 && len(tx.Inputs[0].ID) == 0 && tx.Inputs[0].Out == -1
 }
 
+// SendOptions control how a new transaction picks its inputs and what fee
+// rate it pays. Replaceable opts it into replace-by-fee.
+type SendOptions struct {
+	Selector    CoinSelector
+	FeeRate     FeeRate
+	Replaceable bool
+}
+
+var DefaultSendOptions = SendOptions{BranchAndBound{}, DefaultFeeRate, false}
+
 func NewTransaction(w *wallet.Wallet, to string, value int, UTXO *UTXOSet) *Transaction {
+	tx, err := NewTransactionWith(w, []TxOutput{*NewTXOutput(value, to)}, UTXO, DefaultSendOptions)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return tx
+}
+
+// NewTransactionWith pays outputs from the address of w, choosing inputs and
+// fee as opts says.
+func NewTransactionWith(w *wallet.Wallet, outputs []TxOutput, UTXO *UTXOSet, opts SendOptions) (*Transaction, error) {
+	tx, err := NewUnsignedTransactionWith(w.PublicKey, outputs, UTXO, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	UTXO.Blockchain.SignTxWith(tx, w.PrivateKey, UTXO.Pending)
+
+	return tx, nil
+}
+
+// NewUnsignedTransaction spends outputs of the address of pubKey without
+// signing them, for keys that are held outside of the wallet.
+func NewUnsignedTransaction(pubKey []byte, to string, value int, UTXO *UTXOSet) (*Transaction, error) {
+	return NewUnsignedTransactionWith(pubKey, []TxOutput{*NewTXOutput(value, to)}, UTXO, DefaultSendOptions)
+}
+
+func NewUnsignedTransactionWith(pubKey []byte, outputs []TxOutput, UTXO *UTXOSet, opts SendOptions) (*Transaction, error) {
+	from := string(wallet.PubKeyHashToAddress(wallet.PublicKeyHash(pubKey)))
+
+	return newUnsignedTransaction(from, pubKey, outputs, UTXO, opts)
+}
+
+// NewScriptHashTransactionWith spends outputs of a pay to script hash
+// address, such as a multisig address. Its inputs are unlocked later, once
+// the signatures the redeem script asks for have been collected.
+func NewScriptHashTransactionWith(from string, outputs []TxOutput, UTXO *UTXOSet, opts SendOptions) (*Transaction, error) {
+	if !wallet.IsScriptHashAddress(from) {
+		return nil, fmt.Errorf("%s is not a script hash address", from)
+	}
+
+	return newUnsignedTransaction(from, nil, outputs, UTXO, opts)
+}
+
+// newUnsignedTransaction pays outputs from the address from, returning
+// change there too. The inputs carry pubKey, if any. Token outputs are paid
+// from outputs of their asset and coin outputs only from coins. Outputs with
+// Tokens but no Asset issue a new asset, whose ID is only known once the
+// inputs have been picked.
+func newUnsignedTransaction(from string, pubKey []byte, outputs []TxOutput, UTXO *UTXOSet, opts SendOptions) (*Transaction, error) {
 	var inputs []TxInput
-	var outputs []TxOutput
 
-	pubKeyHash := wallet.PublicKeyHash(w.PublicKey)
+	pubKeyHash := wallet.AddressToPubKeyHash(from)
+
+	target := 0
+	tokens := make(map[string]int)
+	for _, out := range outputs {
+		if out.Value <= 0 && out.Tokens <= 0 && !out.IsUnspendable() {
+			return nil, errors.New("output values must be positive")
+		}
+		target += out.Value
+		if len(out.Asset) > 0 {
+			tokens[hex.EncodeToString(out.Asset)] += out.Tokens
+		}
+	}
 
-	acc, validOutputs := UTXO.FindSpendableOutputs(pubKeyHash, value)
+	coins, holdings := splitHoldings(UTXO.SpendableOutputs(pubKeyHash))
 
-	if acc < value {
-		log.Panic("Error: not enough funds")
+	var assets []string
+	for asset := range tokens {
+		assets = append(assets, asset)
 	}
+	sort.Strings(assets)
 
-	for txid, outputs := range validOutputs {
-		txID, err := hex.DecodeString(txid)
-		HandleErr(err)
-		for _, out := range outputs {
-			input := TxInput{txID, out, nil, w.PublicKey}
-			inputs = append(inputs, input)
+	var tokenChange []TxOutput
+	for _, asset := range assets {
+		selected, total := selectTokens(holdings[asset], tokens[asset])
+		if total < tokens[asset] {
+			return nil, fmt.Errorf("not enough tokens of asset %s", asset)
+		}
+
+		for _, utxo := range selected {
+			inputs = append(inputs, TxInput{ID: utxo.TxID, Out: utxo.Out, PubKey: pubKey})
+		}
+		if total > tokens[asset] {
+			tokenChange = append(tokenChange, *NewTokenOutput(selected[0].Output.Asset, total-tokens[asset], from))
 		}
 	}
 
-	from := fmt.Sprintf("%s", w.Address)
+	// The coins pay for the token inputs too.
+	target += opts.FeeRate.Fee(len(inputs) * inputSize)
 
-	outputs = append(outputs, *NewTXOutput(value, to))
+	selection, err := opts.Selector.Select(coins, target, len(outputs)+len(tokenChange), opts.FeeRate)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, utxo := range selection.Inputs {
+		inputs = append(inputs, TxInput{ID: utxo.TxID, Out: utxo.Out, PubKey: pubKey})
+	}
+	if len(inputs) == 0 {
+		return nil, ErrInsufficientFunds
+	}
 
-	if acc > value {
-		outputs = append(outputs, *NewTXOutput(acc-value, from))
+	if opts.Replaceable {
+		for i := range inputs {
+			inputs[i].Sequence = SequenceReplaceFlag
+		}
 	}
 
-	tx := Transaction{nil, inputs, outputs}
+	outputs = append(append([]TxOutput(nil), outputs...), tokenChange...)
+	if selection.Change > 0 {
+		outputs = append(outputs, *NewTXOutput(selection.Change, from))
+	}
+
+	tx := Transaction{Inputs: inputs, Outputs: outputs}
+
+	for i, out := range tx.Outputs {
+		if out.Tokens > 0 && len(out.Asset) == 0 {
+			tx.Outputs[i].Asset = tx.IssuedAsset()
+		}
+	}
 
 	tx.ID = tx.Hash()
-	UTXO.Blockchain.SignTx(&tx, w.PrivateKey)
 
-	return &tx
+	return &tx, nil
+}
+
+// splitHoldings separates coins from token outputs, which it groups by
+// hex encoded asset ID.
+func splitHoldings(unspent []UTXO) ([]UTXO, map[string][]UTXO) {
+	var coins []UTXO
+	holdings := make(map[string][]UTXO)
+
+	for _, utxo := range unspent {
+		if utxo.Output.HasAsset() {
+			asset := hex.EncodeToString(utxo.Output.Asset)
+			holdings[asset] = append(holdings[asset], utxo)
+		} else {
+			coins = append(coins, utxo)
+		}
+	}
+
+	return coins, holdings
+}
+
+// selectTokens picks the largest outputs of an asset until they carry
+// amount tokens.
+func selectTokens(utxos []UTXO, amount int) ([]UTXO, int) {
+	sorted := append([]UTXO(nil), utxos...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Output.Tokens > sorted[j].Output.Tokens
+	})
+
+	var selected []UTXO
+	total := 0
+	for _, utxo := range sorted {
+		if total >= amount {
+			break
+		}
+		selected = append(selected, utxo)
+		total += utxo.Output.Tokens
+	}
+
+	return selected, total
 }
@@ -0,0 +1,35 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// TestTransactionHash pins the canonical encoding transactions are hashed
+// over, which IDs, signatures and blocks depend on.
+func TestTransactionHash(t *testing.T) {
+	plain := Transaction{
+		Inputs:  []TxInput{{ID: []byte{1, 2, 3}, Out: 1, Signature: []byte{4}, PubKey: []byte{5, 6}}},
+		Outputs: []TxOutput{{Value: 10, PubKeyHash: []byte{7, 8}}},
+	}
+
+	locked := plain
+	locked.LockTime = 100
+
+	tests := []struct {
+		name string
+		tx   Transaction
+		hash string
+	}{
+		{"plain", plain, "96517deb6bf80ce49946ddf8a247efdf9d2d310c7a4ef70f3ffa8367e393cc12"},
+		{"lock time", locked, "ad48e14a0961a04a389c89102c342fefee409d1b15bc07c6ebbdbb78619d15b9"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if hash := hex.EncodeToString(test.tx.Hash()); hash != test.hash {
+				t.Fatalf("hash is %s, want %s", hash, test.hash)
+			}
+		})
+	}
+}
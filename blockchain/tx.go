@@ -3,28 +3,45 @@ package blockchain
 import (
 	"bytes"
 	"encoding/gob"
+	"fmt"
 
 	"github.com/gitferry/blockchain-go/wallet"
 )
 
+// MaxDataSize is the most data a data output can carry.
+const MaxDataSize = 80
+
+// TxOutput is locked either to PubKeyHash, like an address, or by Script
+// when that is set. Pay to script hash outputs keep the script hash in
+// PubKeyHash as well, so they are found by address like key outputs. Token
+// outputs carry Tokens of Asset instead of a Value.
 type TxOutput struct {
 	Value      int
 	PubKeyHash []byte
+	Script     []byte
+	Asset      []byte
+	Tokens     int
 }
 
 type TxOutputs struct {
 	Outputs []TxOutput
+	Indexes []int
 }
 
+// TxInput unlocks the output it spends with Signature and PubKey, or with
+// Script when that is set. A non-zero Sequence is a relative lock, see
+// SequenceTypeFlag.
 type TxInput struct {
 	ID        []byte
 	Out       int
 	Signature []byte
 	PubKey    []byte
+	Script    []byte
+	Sequence  int
 }
 
 func NewTXOutput(value int, address string) *TxOutput {
-	txOutput := &TxOutput{value, nil}
+	txOutput := &TxOutput{Value: value}
 	txOutput.Lock([]byte(address))
 
 	return txOutput
@@ -40,12 +57,109 @@ func (out *TxOutput) Lock(address []byte) {
 	pubKeyHash := wallet.Base58Decode(address)
 	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
 	out.PubKeyHash = pubKeyHash
+
+	if wallet.IsScriptHashAddress(string(address)) {
+		out.Script = ScriptHashScript(pubKeyHash)
+	}
+}
+
+// NewScriptOutput locks value with an arbitrary script.
+func NewScriptOutput(value int, script []byte) *TxOutput {
+	return &TxOutput{Value: value, PubKeyHash: extractScriptHash(script), Script: script}
+}
+
+// NewDataOutput carries data in an output nobody can spend. It holds no
+// value and never enters the UTXO set.
+func NewDataOutput(data []byte) (*TxOutput, error) {
+	if len(data) > MaxDataSize {
+		return nil, fmt.Errorf("data outputs carry at most %d bytes", MaxDataSize)
+	}
+
+	return &TxOutput{Script: DataScript(data)}, nil
+}
+
+// IsUnspendable reports whether out is a data output, which no unlocking
+// script can spend.
+func (out *TxOutput) IsUnspendable() bool {
+	return len(out.Script) > 0 && out.Script[0] == OpReturn
+}
+
+// Data returns what a data output carries.
+func (out *TxOutput) Data() ([]byte, bool) {
+	if !out.IsUnspendable() {
+		return nil, false
+	}
+
+	instructions, err := parseScript(out.Script)
+	if err != nil || len(instructions) != 2 || instructions[1].op > OpPushData2 {
+		return nil, false
+	}
+
+	return instructions[1].data, true
+}
+
+// Address is the address out pays to, or empty if it is locked by a script
+// without one.
+func (out *TxOutput) Address() string {
+	if scriptHash := extractScriptHash(out.Script); scriptHash != nil {
+		return string(wallet.ScriptHashToAddress(scriptHash))
+	}
+	if len(out.Script) > 0 {
+		return ""
+	}
+
+	return string(wallet.PubKeyHashToAddress(out.PubKeyHash))
+}
+
+// LockingScript is the script an input spending out has to satisfy.
+func (out *TxOutput) LockingScript() []byte {
+	if len(out.Script) > 0 {
+		return out.Script
+	}
+
+	return P2PKHScript(out.PubKeyHash)
+}
+
+// UnlockingScript is the script run before the locking script of the output
+// in spends.
+func (in *TxInput) UnlockingScript() []byte {
+	if len(in.Script) > 0 {
+		return in.Script
+	}
+
+	return NewScriptBuilder().AddData(in.Signature).AddData(in.PubKey).Script()
+}
+
+// wellFormed checks that PubKeyHash is what lookups by address expect, that
+// data outputs carry no value and at most MaxDataSize bytes and that token
+// outputs carry tokens of one asset only.
+func (out *TxOutput) wellFormed() bool {
+	if out.HasAsset() && (len(out.Asset) != AssetIDSize || out.Tokens <= 0 || out.Value != 0 || out.IsUnspendable()) {
+		return false
+	}
+	if out.IsUnspendable() {
+		data, ok := out.Data()
+		return ok && len(data) <= MaxDataSize && out.Value == 0 && len(out.PubKeyHash) == 0
+	}
+	if len(out.Script) == 0 {
+		return len(out.PubKeyHash) > 0
+	}
+
+	return bytes.Equal(out.PubKeyHash, extractScriptHash(out.Script))
 }
 
 func (out *TxOutput) isLockedWithKey(pubKeyHash []byte) bool {
 	return bytes.Compare(out.PubKeyHash, pubKeyHash) == 0
 }
 
+func (outs TxOutputs) Index(i int) int {
+	if i < len(outs.Indexes) {
+		return outs.Indexes[i]
+	}
+
+	return i
+}
+
 func (outs TxOutputs) Serialize() []byte {
 	var buffer bytes.Buffer
 	encode := gob.NewEncoder(&buffer)
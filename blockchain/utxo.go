@@ -13,8 +13,18 @@ var (
 	prefixLength = len(utxoPrefix)
 )
 
+// UTXOSet is the set of unspent outputs of Blockchain. Pending, if set,
+// holds unconfirmed transactions keyed by hex encoded ID, which wallets see
+// the set through when they pick outputs to spend, see SpendableOutputs.
 type UTXOSet struct {
 	Blockchain *BlockChain
+	Pending    map[string]Transaction
+}
+
+type UTXO struct {
+	TxID   []byte
+	Out    int
+	Output TxOutput
 }
 
 func (u *UTXOSet) DeleteByPrefix(prefix []byte) {
@@ -109,8 +119,9 @@ func (u *UTXOSet) Update(block *Block) {
 					outs := DeserializeOutputs(v)
 
 					for idx, out := range outs.Outputs {
-						if idx != in.Out {
+						if outs.Index(idx) != in.Out {
 							updatedOuts.Outputs = append(updatedOuts.Outputs, out)
+							updatedOuts.Indexes = append(updatedOuts.Indexes, outs.Index(idx))
 						}
 					}
 
@@ -127,8 +138,16 @@ func (u *UTXOSet) Update(block *Block) {
 			}
 
 			newOutputs := TxOutputs{}
-			for _, out := range tx.Outputs {
+			for outIdx, out := range tx.Outputs {
+				if out.IsUnspendable() {
+					continue
+				}
 				newOutputs.Outputs = append(newOutputs.Outputs, out)
+				newOutputs.Indexes = append(newOutputs.Indexes, outIdx)
+			}
+
+			if len(newOutputs.Outputs) == 0 {
+				continue
 			}
 
 			txId := append(utxoPrefix, tx.ID...)
@@ -197,9 +216,41 @@ func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TxOutput {
 	return txOutputs
 }
 
-func (u UTXOSet) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[string][]int) {
-	var unspentOutputs = make(map[string][]int)
-	accumulated := 0
+func (u UTXOSet) GetOutput(txID []byte, out int) (TxOutput, bool) {
+	var output TxOutput
+	found := false
+
+	db := u.Blockchain.Database
+
+	err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(append(utxoPrefix, txID...))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		HandleErr(err)
+
+		v, err := item.Value()
+		HandleErr(err)
+
+		outs := DeserializeOutputs(v)
+		for idx := range outs.Outputs {
+			if outs.Index(idx) == out {
+				output = outs.Outputs[idx]
+				found = true
+			}
+		}
+
+		return nil
+	})
+
+	HandleErr(err)
+
+	return output, found
+}
+
+func (u UTXOSet) ListUnspent(pubKeyHash []byte) []UTXO {
+	var UTXOs []UTXO
+
 	db := u.Blockchain.Database
 
 	err := db.View(func(txn *badger.Txn) error {
@@ -210,22 +261,14 @@ func (u UTXOSet) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[s
 		for it.Seek(utxoPrefix); it.ValidForPrefix(utxoPrefix); it.Next() {
 			item := it.Item()
 			v, err := item.Value()
-			k := item.Key()
 			HandleErr(err)
+			txID := bytes.TrimPrefix(item.KeyCopy(nil), utxoPrefix)
 
-			k = bytes.TrimPrefix(k, utxoPrefix)
-			txID := hex.EncodeToString(k)
 			outs := DeserializeOutputs(v)
 
-		Work:
 			for outIdx, out := range outs.Outputs {
-				if out.isLockedWithKey(pubKeyHash) && accumulated < amount {
-					accumulated += out.Value
-					unspentOutputs[txID] = append(unspentOutputs[txID], outIdx)
-
-					if accumulated >= amount {
-						break Work
-					}
+				if out.isLockedWithKey(pubKeyHash) {
+					UTXOs = append(UTXOs, UTXO{txID, outs.Index(outIdx), out})
 				}
 			}
 		}
@@ -235,5 +278,5 @@ func (u UTXOSet) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[s
 
 	HandleErr(err)
 
-	return accumulated, unspentOutputs
+	return UTXOs
 }
@@ -0,0 +1,129 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// CheckBlock checks a block whose ancestors are known before it joins the
+// chain: its proof of work, that every transaction is valid, satisfies the
+// scripts of the outputs it spends, keeps its tokens and only spends
+// outputs still unspent on the branch it extends, that its one coinbase
+// claims no more than the subsidy and the fees and carries no tokens, and
+// its lock times.
+func (chain *BlockChain) CheckBlock(block *Block) error {
+	if !NewProof(block).Validate() {
+		return errors.New("proof of work is invalid")
+	}
+
+	txs, spent, err := chain.branchOutputs(block.PrevHash)
+	if err != nil {
+		return err
+	}
+
+	var coinbase *Transaction
+	fees := 0
+
+	for _, tx := range block.Transactions {
+		ID := hex.EncodeToString(tx.ID)
+		if _, ok := txs[ID]; ok {
+			return fmt.Errorf("transaction %x is already in the chain", tx.ID)
+		}
+
+		if tx.IsCoinbase() {
+			if coinbase != nil {
+				return errors.New("block has more than one coinbase")
+			}
+			coinbase = tx
+			txs[ID] = *tx
+			continue
+		}
+
+		prevTxs := make(map[string]Transaction)
+		fee := 0
+
+		for i, in := range tx.Inputs {
+			prevTx, ok := txs[hex.EncodeToString(in.ID)]
+			if !ok || in.Out < 0 || in.Out >= len(prevTx.Outputs) {
+				return fmt.Errorf("transaction %x: input %d spends unknown output %x:%d", tx.ID, i, in.ID, in.Out)
+			}
+
+			key := outPoint(in.ID, in.Out)
+			if spent[key] {
+				return fmt.Errorf("transaction %x: input %d spends output %x:%d, which is spent", tx.ID, i, in.ID, in.Out)
+			}
+			spent[key] = true
+
+			if err := tx.VerifyInputScript(i, prevTx.Outputs[in.Out]); err != nil {
+				return fmt.Errorf("transaction %x: input %d: %s", tx.ID, i, err)
+			}
+
+			prevTxs[hex.EncodeToString(in.ID)] = prevTx
+			fee += prevTx.Outputs[in.Out].Value
+		}
+
+		if !tx.checkTokens(prevTxs) {
+			return fmt.Errorf("transaction %x creates or destroys tokens", tx.ID)
+		}
+		if !tx.Verify(prevTxs) {
+			return fmt.Errorf("transaction %x is invalid", tx.ID)
+		}
+
+		for _, out := range tx.Outputs {
+			fee -= out.Value
+		}
+		fees += fee
+
+		txs[ID] = *tx
+	}
+
+	if coinbase == nil {
+		return errors.New("block has no coinbase")
+	}
+	if coinbase.hasAssets() {
+		return fmt.Errorf("coinbase %x carries tokens", coinbase.ID)
+	}
+
+	reward := 0
+	for _, out := range coinbase.Outputs {
+		if out.Value < 0 {
+			return fmt.Errorf("coinbase %x has a negative output", coinbase.ID)
+		}
+		reward += out.Value
+	}
+	if reward > Subsidy+fees {
+		return fmt.Errorf("coinbase claims %d, the subsidy and fees are %d", reward, Subsidy+fees)
+	}
+
+	return chain.CheckBlockLockTimes(block)
+}
+
+// branchOutputs collects the transactions of the block with hash blockHash
+// and its ancestors, keyed by hex encoded ID, and the outputs they spend.
+func (chain *BlockChain) branchOutputs(blockHash []byte) (map[string]Transaction, map[string]bool, error) {
+	txs := make(map[string]Transaction)
+	spent := make(map[string]bool)
+
+	for len(blockHash) > 0 {
+		block, err := chain.GetBlock(blockHash)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, tx := range block.Transactions {
+			txs[hex.EncodeToString(tx.ID)] = *tx
+
+			if tx.IsCoinbase() {
+				continue
+			}
+			for _, in := range tx.Inputs {
+				spent[outPoint(in.ID, in.Out)] = true
+			}
+		}
+
+		blockHash = block.PrevHash
+	}
+
+	return txs, spent, nil
+}
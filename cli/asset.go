@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/rpc"
+)
+
+// IssueAsset issues supply tokens of a new asset called name to the wallet
+// address from.
+func (cli *CommandLine) IssueAsset(from, name string, supply int, opts rpc.SendOptions, nodeId string, mineNow bool) {
+	var result rpc.AssetResult
+
+	if cli.client != nil {
+		cli.call("issueasset", &result, from, name, supply, opts)
+		cli.remoteMine(from, mineNow)
+	} else {
+		chain := blockchain.ContinueBlockchain(nodeId)
+		defer chain.Database.Close()
+		UTXOSet := pendingUTXOSet(chain, nodeId)
+
+		sendOpts, err := opts.Build()
+		if err != nil {
+			log.Panic(err)
+		}
+
+		var tx *blockchain.Transaction
+		tx, result, err = rpc.IssueAsset(loadWallets(nodeId, true), from, name, supply, &UTXOSet, sendOpts)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		submitTx(chain, tx, from, nodeId, mineNow)
+	}
+
+	fmt.Printf("Asset: %s\n", result.ID)
+	fmt.Printf("Name: %s\n", result.Name)
+	fmt.Printf("Supply: %d\n", result.Supply)
+	fmt.Printf("Issued in transaction: %s\n", result.IssueTxID)
+}
+
+func (cli *CommandLine) ListAssets(nodeId string) {
+	var assets []rpc.AssetResult
+
+	if cli.client != nil {
+		cli.call("listassets", &assets)
+	} else {
+		chain := blockchain.ContinueBlockchain(nodeId)
+		defer chain.Database.Close()
+		UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+
+		assets = rpc.ListAssets(loadWallets(nodeId, false), &UTXOSet)
+	}
+
+	for idx, asset := range assets {
+		fmt.Printf("%d. %s %s supply %d, the wallet holds %d\n", idx, asset.ID, asset.Name, asset.Supply, asset.Balance)
+	}
+}
+
+// findAssetID resolves an asset given by ID or name on the local chain.
+func findAssetID(asset, nodeId string) []byte {
+	chain := blockchain.ContinueBlockchain(nodeId)
+	defer chain.Database.Close()
+
+	id, err := rpc.FindAssetID(chain, asset)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return id
+}
+
+func assetSuffix(asset string) string {
+	if asset == "" {
+		return ""
+	}
+
+	return " " + asset
+}
@@ -0,0 +1,22 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/gitferry/blockchain-go/rpc"
+)
+
+// BumpFee replaces a wallet transaction waiting in the memory pool of the
+// running node with one paying a higher fee.
+func (cli *CommandLine) BumpFee(txID string, feeRate int) {
+	if cli.client == nil {
+		log.Panic("bumpfee needs the memory pool of a running node")
+	}
+
+	var result rpc.BumpResult
+	cli.call("bumpfee", &result, txID, feeRate)
+
+	fmt.Printf("Replaced %s paying %d\n", result.OrigTxID, result.OrigFee)
+	fmt.Printf("Transaction: %s paying %d\n", result.TxID, result.Fee)
+}
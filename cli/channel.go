@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/rpc"
+)
+
+// OpenChannel funds a payment channel from one address of the wallet to an
+// address whose public key the wallet knows.
+func (cli *CommandLine) OpenChannel(from, to string, amount int, timeout time.Duration, opts rpc.SendOptions, nodeId string, mineNow bool) {
+	if timeout == 0 {
+		timeout = rpc.ChannelTimeout
+	}
+
+	var result rpc.ChannelResult
+
+	if cli.client != nil {
+		cli.call("openchannel", &result, from, to, amount, int64(timeout/time.Second), opts)
+		cli.remoteMine(from, mineNow)
+	} else {
+		chain := blockchain.ContinueBlockchain(nodeId)
+		defer chain.Database.Close()
+		UTXOSet := pendingUTXOSet(chain, nodeId)
+
+		wallets := loadWallets(nodeId, true)
+
+		sendOpts, err := opts.Build()
+		if err != nil {
+			log.Panic(err)
+		}
+
+		var tx *blockchain.Transaction
+		tx, result, err = rpc.OpenChannel(wallets, from, to, amount, timeout, &UTXOSet, sendOpts)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		wallets.SaveFile(nodeId)
+		submitTx(chain, tx, from, nodeId, mineNow)
+	}
+
+	printChannel(result)
+}
+
+// PayChannel pays amount over a channel without touching the chain. The
+// printed payment is what the payee accepts.
+func (cli *CommandLine) PayChannel(id string, amount int, nodeId string) {
+	var payment rpc.ChannelPayment
+
+	if cli.client != nil {
+		cli.call("paychannel", &payment, id, amount)
+	} else {
+		wallets := loadWallets(nodeId, true)
+
+		var err error
+		if payment, err = rpc.PayChannel(wallets, id, amount); err != nil {
+			log.Panic(err)
+		}
+		wallets.SaveFile(nodeId)
+	}
+
+	printChannel(payment.ChannelResult)
+	fmt.Printf("Payment: %s\n", payment.Payment)
+}
+
+// AcceptChannelPayment checks and keeps a payment received from a payer.
+func (cli *CommandLine) AcceptChannelPayment(payment, nodeId string) {
+	var result rpc.ChannelResult
+
+	if cli.client != nil {
+		cli.call("acceptchannelpayment", &result, payment)
+	} else {
+		data, err := hex.DecodeString(payment)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		chain := blockchain.ContinueBlockchain(nodeId)
+		defer chain.Database.Close()
+		UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+
+		wallets := loadWallets(nodeId, false)
+		if result, err = rpc.AcceptChannelPayment(wallets, data, &UTXOSet); err != nil {
+			log.Panic(err)
+		}
+		wallets.SaveFile(nodeId)
+	}
+
+	printChannel(result)
+}
+
+// CloseChannel pays out a channel with its latest payment as the payee, or
+// with refund takes it back as the payer after its timeout.
+func (cli *CommandLine) CloseChannel(id string, refund bool, nodeId string, mineNow bool) {
+	method, spend := "closechannel", rpc.CloseChannel
+	if refund {
+		method, spend = "refundchannel", rpc.RefundChannel
+	}
+
+	if cli.client != nil {
+		var channels []rpc.ChannelResult
+		cli.call("listchannels", &channels)
+
+		rewardAddress := ""
+		for _, c := range channels {
+			if c.ID == id && refund {
+				rewardAddress = c.Payer
+			} else if c.ID == id {
+				rewardAddress = c.Payee
+			}
+		}
+
+		var txID string
+		cli.call(method, &txID, id)
+		fmt.Printf("Closed the channel in transaction %s\n", txID)
+
+		cli.remoteMine(rewardAddress, mineNow)
+		return
+	}
+
+	chain := blockchain.ContinueBlockchain(nodeId)
+	defer chain.Database.Close()
+
+	tx, err := spend(loadWallets(nodeId, true), id)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if err := chain.CheckLockTimes(tx, chain.GetBestHeight()+1, chain.LastHash); err != nil {
+		log.Panicf("The channel cannot be refunded yet: %s", err)
+	}
+
+	submitTx(chain, tx, tx.Outputs[0].Address(), nodeId, mineNow)
+	fmt.Printf("Closed the channel in transaction %x\n", tx.ID)
+}
+
+func (cli *CommandLine) ListChannels(nodeId string) {
+	var channels []rpc.ChannelResult
+
+	if cli.client != nil {
+		cli.call("listchannels", &channels)
+	} else {
+		chain := blockchain.ContinueBlockchain(nodeId)
+		defer chain.Database.Close()
+		UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+
+		var err error
+		if channels, err = rpc.ListChannels(loadWallets(nodeId, false), &UTXOSet); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	for idx, c := range channels {
+		fmt.Printf("%d. %s %s -> %s paid %d of %d, refundable from %s, %s\n", idx, c.ID, c.Payer, c.Payee, c.Paid, c.Capacity, time.Unix(c.LockTime, 0).Format(time.RFC3339), c.Status)
+	}
+}
+
+func printChannel(c rpc.ChannelResult) {
+	fmt.Printf("Channel: %s\n", c.ID)
+	fmt.Printf("Payer: %s\n", c.Payer)
+	fmt.Printf("Payee: %s\n", c.Payee)
+	fmt.Printf("Paid: %d of %d\n", c.Paid, c.Capacity)
+	fmt.Printf("Refundable from: %d (%s)\n", c.LockTime, time.Unix(c.LockTime, 0).Format(time.RFC3339))
+}
@@ -11,21 +11,76 @@ import (
 	"github.com/gitferry/blockchain-go/network"
 
 	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/explorer"
+	"github.com/gitferry/blockchain-go/rpc"
 	"github.com/gitferry/blockchain-go/wallet"
 )
 
-type CommandLine struct{}
+type CommandLine struct {
+	client *rpc.Client
+}
 
 func (cli *CommandLine) PrintUsage() {
 	fmt.Println("Usage:")
-	fmt.Println(" getbalance -address ADRESS - get the balance for that address")
+	fmt.Println(" getbalance -address ADRESS - get the balance for that address, or of the whole wallet without -address")
+	fmt.Println("   -asset ASSET - Count tokens of the asset, given by ID or name, instead of coins")
 	fmt.Println(" createblockchain -address ADRESS creates a blockchain and that address mines the genessis block")
 	fmt.Println(" print - Prints the blocks in the chain")
 	fmt.Println(" sent -from FROM -to To -amount AMOUNT -mine - send amount of tokens. Then -mine flag is set")
-	fmt.Println(" createwallet - Create a new wallet")
+	fmt.Println("   -strategy bnb|largest|random|manual -in TXID:VOUT -feerate RATE - Coin selection, manual spends exactly the -in outputs, fee in coins per 1000 bytes")
+	fmt.Println("   -asset ASSET - Send tokens of the asset, given by ID or name, instead of coins")
+	fmt.Println("   -blocks N - Without -feerate, pay the fee rate a running node estimates for confirming within N blocks, also for sendmany")
+	fmt.Println("   -replaceable - Allow replacing the transaction with one paying a higher fee while unconfirmed, also for sendmany")
+	fmt.Println(" sendmany -from FROM -file FILE -mine - Pay every address/amount pair of a JSON or CSV file in one transaction, accepts the send coin selection flags")
+	fmt.Println(" createwallet -path PATH - Derive the next address of the HD wallet below PATH")
+	fmt.Println("   -mnemonic -passphrase PASS - Create the HD seed from a new mnemonic phrase and print it for backup")
+	fmt.Println(" restorewallet -mnemonic WORDS -passphrase PASS -path PATH -gap N - Restore an HD wallet and rescan the chain for its outputs")
+	fmt.Println(" discoverwallet -path PATH -gap N - Recover used HD addresses below PATH, stopping after N unused ones")
+	fmt.Println(" encryptwallet -passphrase PASS - Encrypt the private keys of the wallet file")
+	fmt.Println(" walletpassphrase -passphrase PASS -timeout SECONDS - Unlock the wallet of a running node for sending")
+	fmt.Println(" walletlock - Lock the wallet of a running node again")
+	fmt.Println(" importaddress -address ADDRESS - Watch an address without its private key")
+	fmt.Println(" importpubkey -pubkey HEX - Watch the address of a public key, sending from it prints an unsigned transaction")
+	fmt.Println(" getaddressinfo -address ADDRESS - Show the public key and ownership of an address")
+	fmt.Println(" addmultisigaddress -m M -key KEY - Add an address needing M signatures of the keys, each a hex public key or known address, repeat -key in a fixed order")
+	fmt.Println(" listtransactions -watchonly - List the confirmed transactions of the wallet")
+	fmt.Println(" createrawtx -from FROM -to TO -amount AMOUNT -strategy S -feerate RATE - Build an unsigned, partially signed transaction")
+	fmt.Println("   -in TXID:VOUT -out ADDRESS:AMOUNT - Spend exactly these inputs into these outputs, both may be repeated")
+	fmt.Println("   -json JSON - Encode a transaction written as JSON to raw hex without checking it")
+	fmt.Println("   -locktime HEIGHT|TIME - With -in and -out, the first block height or unix time the transaction may be mined at")
+	fmt.Println("   -in TXID:VOUT:BLOCKS or TXID:VOUT:SECONDSs - Only spend the input after its output has been confirmed that long")
+	fmt.Println(" script -asm ASM | -hex HEX - Assemble or disassemble a script, numbers in ASM are written #144")
+	fmt.Println(" decoderawtx -hex HEX | -psbt HEX - Print a raw or partially signed transaction as JSON")
+	fmt.Println(" signrawtx -psbt HEX - Sign a partially signed transaction with the local wallet, offline")
+	fmt.Println(" combinerawtx -psbt HEX -psbt HEX - Merge the signatures of copies of a partially signed transaction, offline")
+	fmt.Println(" sendrawtx -psbt HEX | -hex HEX - Broadcast a fully signed partially signed transaction or a raw transaction")
+	fmt.Println(" swap initiate -from FROM -to TO -amount AMOUNT -timeout 48h - Lock coins to TO behind the hash of a new secret, refundable after the timeout")
+	fmt.Println(" swap participate -from FROM -to TO -amount AMOUNT -secrethash HASH -timeout 24h - Lock coins to the initiator behind its secret hash")
+	fmt.Println(" swap redeem -txid TXID -secret SECRET - Claim a swap contract, revealing the secret")
+	fmt.Println(" swap refund -txid TXID - Take back a swap contract after its timeout")
+	fmt.Println(" swap audit -txid TXID - Show a swap contract and the secret it was redeemed with")
+	fmt.Println("   -mine -feerate RATE - Mine the transaction immediately, fee of the contract in coins per 1000 bytes")
+	fmt.Println(" channel open -from FROM -to TO -amount AMOUNT -timeout 24h - Fund a payment channel to an address whose public key the wallet knows")
+	fmt.Println(" channel pay -id ID -amount AMOUNT - Pay over a channel off-chain and print the payment for the payee")
+	fmt.Println(" channel accept -payment HEX - Check and keep a payment as the payee")
+	fmt.Println(" channel close -id ID -refund - Pay out the latest payment as the payee, or take the channel back as the payer after its timeout")
+	fmt.Println(" channel list - List the payment channels of the wallet")
+	fmt.Println("   -mine -feerate RATE - Mine the transaction immediately, fee of the funding transaction in coins per 1000 bytes")
+	fmt.Println(" timestamp -from FROM -file PATH -mine -feerate RATE - Commit the SHA-256 hash of a file to the chain in a data output")
+	fmt.Println(" verifystamp -file PATH - Prove a file was timestamped with its block height and a Merkle proof")
+	fmt.Println(" issueasset -from FROM -name NAME -supply N -mine -feerate RATE - Issue a new asset with a fixed supply of tokens to FROM")
+	fmt.Println(" listassets - List the assets issued on the chain and the wallet's tokens of each")
+	fmt.Println(" estimatefee -blocks N - Print the fee rate a running node estimates for confirming within N blocks")
+	fmt.Println(" bumpfee -txid TXID -feerate RATE - Replace a replaceable wallet transaction in the memory pool of a running node with one paying a higher fee")
 	fmt.Println(" listaddresses - Lists the addresses in our wallet file")
 	fmt.Println(" reindexutxo - Rebuild the UTXO set")
 	fmt.Println(" startnode -miner ADDRESS - Start a node with ID specified in NODE_ID env. var. -mine enables mining")
+	fmt.Println("   -rpcaddr HOST:PORT -rpcuser USER -rpcpassword PASSWORD - JSON-RPC endpoint, defaults to localhost:NODE_ID+1000 with cookie auth")
+	fmt.Println("   -http HOST:PORT - Serve the REST API and block explorer")
+	fmt.Println(" explorer -http HOST:PORT - Serve the REST API and block explorer from the local database")
+	fmt.Println("All other commands accept -rpc HOST:PORT -rpcuser USER -rpcpassword PASSWORD to talk to a running node.")
+	fmt.Println("Without -rpc they use the local node if it is running and open the database directly otherwise.")
+	fmt.Println("SEED_NODE env. var. replaces the seed node localhost:3000, to run a second network next to the first.")
 }
 
 func (cli *CommandLine) ValidateArgs() {
@@ -36,6 +91,11 @@ func (cli *CommandLine) ValidateArgs() {
 }
 
 func (cli *CommandLine) PrintChain(nodeId string) {
+	if cli.client != nil {
+		cli.remotePrintChain()
+		return
+	}
+
 	chain := blockchain.ContinueBlockchain(nodeId)
 	defer chain.Database.Close()
 	iter := chain.Iterator()
@@ -43,14 +103,7 @@ func (cli *CommandLine) PrintChain(nodeId string) {
 	for {
 		block := iter.Next()
 
-		fmt.Printf("Previous block hash: %x\n", block.PrevHash)
-		fmt.Printf("Block hash: %x\n", block.Hash)
-		pow := blockchain.NewProof(block)
-		fmt.Printf("PoW: %s\n", strconv.FormatBool(pow.Validate()))
-		for _, tx := range block.Transactions {
-			fmt.Println(tx)
-		}
-		fmt.Println()
+		printBlock(block)
 
 		if len(block.PrevHash) == 0 {
 			break
@@ -58,109 +111,378 @@ func (cli *CommandLine) PrintChain(nodeId string) {
 	}
 }
 
+func printBlock(block *blockchain.Block) {
+	fmt.Printf("Previous block hash: %x\n", block.PrevHash)
+	fmt.Printf("Block hash: %x\n", block.Hash)
+	pow := blockchain.NewProof(block)
+	fmt.Printf("PoW: %s\n", strconv.FormatBool(pow.Validate()))
+	for _, tx := range block.Transactions {
+		fmt.Println(tx)
+	}
+	fmt.Println()
+}
+
 func (cli *CommandLine) CreateBlockChain(address, nodeId string) {
 	if !wallet.ValidateAddress(address) {
 		log.Panic("Address is not valid")
 	}
+	if cli.client != nil {
+		log.Panic("A node is running on this blockchain, it already exists")
+	}
 	chain := blockchain.InitBlockchain(address, nodeId)
 	defer chain.Database.Close()
 
-	UTXOSet := blockchain.UTXOSet{chain}
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
 	UTXOSet.Reindex()
 	fmt.Println("A blockchain created!")
 }
 
-func (cli *CommandLine) StartNode(nodeId, minerAddress string) {
+func (cli *CommandLine) StartNode(nodeId, minerAddress, rpcAddress, rpcUser, rpcPassword, httpAddress string) {
 	fmt.Printf("Starting Node %s\n", nodeId)
 
 	if len(minerAddress) > 0 {
 		if wallet.ValidateAddress(minerAddress) {
-			fmt.Printf("Mining is on. Address to receive rewards: ", minerAddress)
+			fmt.Printf("Mining is on. Address to receive rewards: %s\n", minerAddress)
 		} else {
 			log.Panic("Wrong miner address!")
 		}
 	}
 
-	network.StartServer(nodeId, minerAddress)
+	var services []network.Service
+
+	if rpcAddress != "" {
+		services = append(services, rpc.NewServer(nodeId, rpcAddress, rpcUser, rpcPassword))
+	}
+
+	if httpAddress != "" {
+		services = append(services, explorer.NewServer(httpAddress))
+	}
+
+	network.StartServer(nodeId, minerAddress, services...)
+}
+
+func (cli *CommandLine) Explorer(nodeId, httpAddress string) {
+	chain := blockchain.ContinueBlockchain(nodeId)
+	defer chain.Database.Close()
+
+	server := explorer.NewServer(httpAddress)
+	if err := server.Serve(explorer.NewChainBackend(chain)); err != nil {
+		log.Panic(err)
+	}
+
+	network.CloseDB(chain, server)
 }
 
-func (cli *CommandLine) GetBalance(address, nodeId string) {
+// GetBalance prints the coins of address, or its tokens of asset when that
+// is given by ID or name.
+func (cli *CommandLine) GetBalance(address, asset, nodeId string) {
+	if address == "" {
+		cli.GetWalletBalance(asset, nodeId)
+		return
+	}
 	if !wallet.ValidateAddress(address) {
 		log.Panic("Address is not valid")
 	}
+	if cli.client != nil {
+		cli.remoteGetBalance(address, asset)
+		return
+	}
 	chain := blockchain.ContinueBlockchain(nodeId)
-	utxoSet := blockchain.UTXOSet{chain}
+	utxoSet := pendingUTXOSet(chain, nodeId)
 	defer chain.Database.Close()
 
-	balance := 0
+	assetID, err := rpc.FindAssetID(chain, asset)
+	if err != nil {
+		log.Panic(err)
+	}
+
 	pubKeyHash := wallet.Base58Decode([]byte(address))
 	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
 	UTXOs := utxoSet.FindUTXO(pubKeyHash)
 
-	for _, out := range UTXOs {
-		balance += out.Value
-	}
+	balance := blockchain.TokenBalance(UTXOs, assetID)
 
-	fmt.Printf("Balance of %s is: %d\n", address, balance)
+	fmt.Printf("Balance of %s is: %d%s\n", address, balance, assetSuffix(asset))
+	printPending(utxoSet.PendingBalance(pubKeyHash, assetID), asset)
 }
 
-func (cli *CommandLine) Send(from, to string, amount int, nodeId string, mineNow bool) {
+// printPending shows what unconfirmed transactions add to a balance.
+func printPending(balance int, asset string) {
+	if balance != 0 {
+		fmt.Printf("Pending: %+d%s\n", balance, assetSuffix(asset))
+	}
+}
+
+// Send pays amount coins, or tokens of asset when that is given by ID or
+// name.
+func (cli *CommandLine) Send(from, to string, amount int, asset string, opts rpc.SendOptions, nodeId string, mineNow bool) {
 	if !wallet.ValidateAddress(from) {
 		log.Panic("Address is not valid")
 	}
 	if !wallet.ValidateAddress(to) {
 		log.Panic("Address is not valid")
 	}
+	if cli.isWatchOnly(from, nodeId) {
+		if asset != "" {
+			log.Panic("Tokens can only be sent from addresses with their keys in the wallet")
+		}
+		cli.sendWatchOnly(from, to, amount, opts, nodeId)
+		return
+	}
+	if cli.client != nil {
+		cli.remoteSend(from, to, amount, asset, opts, mineNow)
+		return
+	}
+
+	output := blockchain.NewTXOutput(amount, to)
+	if asset != "" {
+		output = blockchain.NewTokenOutput(findAssetID(asset, nodeId), amount, to)
+	}
+
+	cli.sendOutputs(from, []blockchain.TxOutput{*output}, opts, nodeId, mineNow)
+}
+
+func (cli *CommandLine) sendOutputs(from string, outputs []blockchain.TxOutput, opts rpc.SendOptions, nodeId string, mineNow bool) {
 	chain := blockchain.ContinueBlockchain(nodeId)
-	utxoSet := blockchain.UTXOSet{chain}
+	utxoSet := pendingUTXOSet(chain, nodeId)
 	defer chain.Database.Close()
 
-	wallets, err := wallet.CreateWalltes(nodeId)
+	wallets := loadWallets(nodeId, true)
+	wallet := wallets.GetWallet(from)
+
+	sendOpts, err := opts.Build()
 	if err != nil {
-		log.Panic()
+		log.Panic(err)
+	}
+
+	tx, err := blockchain.NewTransactionWith(&wallet, outputs, &utxoSet, sendOpts)
+	if err != nil {
+		log.Panic(err)
 	}
-	wallet := wallets.GetWallet(from)
 
-	tx := blockchain.NewTransaction(&wallet, to, amount, &utxoSet)
+	submitTx(chain, tx, from, nodeId, mineNow)
+
+	fmt.Println("Success!")
+}
+
+// submitTx mines tx, after the pending wallet transactions it spends, into a
+// block whose reward goes to rewardAddress, or sends it to the seed node and
+// keeps it in the wallet until it is mined.
+func submitTx(chain *blockchain.BlockChain, tx *blockchain.Transaction, rewardAddress, nodeId string, mineNow bool) {
 	if mineNow {
-		cbTx := blockchain.CoinBaseTx(from, "")
-		txs := []*blockchain.Transaction{cbTx, tx}
-		block := chain.MineBlock(txs)
+		utxoSet := pendingUTXOSet(chain, nodeId)
+		txs := append(pendingAncestors(tx, utxoSet.Pending), tx)
+
+		fees := 0
+		for _, tx := range txs {
+			fee, err := chain.FeeWith(tx, utxoSet.Pending)
+			blockchain.HandleErr(err)
+			fees += fee
+		}
+
+		cbTx := blockchain.CoinBaseTx(rewardAddress, "", fees)
+		block := chain.MineBlock(append([]*blockchain.Transaction{cbTx}, txs...))
 		utxoSet.Update(block)
 	} else {
-		network.SendTx(network.KnownNodes[0], tx)
+		recordPending(tx, nodeId)
+		if err := network.SendTx(network.KnownNodes[0], tx); err != nil {
+			fmt.Printf("Could not reach %s, transaction %x is kept pending in the wallet: %v\n", network.KnownNodes[0], tx.ID, err)
+			return
+		}
 		fmt.Println("send tx")
 	}
-
-	fmt.Println("Success!")
 }
 
 func (cli *CommandLine) reindexUTXO(nodeId string) {
+	if cli.client != nil {
+		cli.remoteReindexUTXO()
+		return
+	}
+
 	chain := blockchain.ContinueBlockchain(nodeId)
 	defer chain.Database.Close()
-	UTXOSet := blockchain.UTXOSet{chain}
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
 	UTXOSet.Reindex()
 
 	count := UTXOSet.CountTransactions()
 	fmt.Printf("Done! There are %d transactions in the UTXO set.\n", count)
 }
 
-func (cli *CommandLine) NewWallet(nodeId string) {
-	wallets, _ := wallet.CreateWalltes(nodeId)
-	address := wallets.AddWallet()
+func (cli *CommandLine) NewWallet(path, nodeId string) {
+	if cli.client != nil {
+		cli.remoteNewWallet(path)
+		return
+	}
+
+	wallets := loadWallets(nodeId, true)
+	address, err := wallets.AddWalletAt(path)
+	if err != nil {
+		log.Panic(err)
+	}
 	wallets.SaveFile(nodeId)
 
 	fmt.Printf("New wallet address is :%s", address)
 }
 
+func (cli *CommandLine) NewMnemonicWallet(passphrase, path, nodeId string) {
+	if cli.client != nil {
+		cli.remoteNewMnemonicWallet(passphrase, path)
+		return
+	}
+
+	wallets := loadWallets(nodeId, true)
+	if wallets.Seed != nil {
+		log.Panic("The wallet already has an HD seed")
+	}
+
+	mnemonic, err := wallet.NewMnemonic(wallet.DefaultEntropyBits)
+	blockchain.HandleErr(err)
+	seed, err := wallet.MnemonicToSeed(mnemonic, passphrase)
+	blockchain.HandleErr(err)
+	blockchain.HandleErr(wallets.SetSeed(seed))
+
+	address, err := wallets.AddWalletAt(path)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveFile(nodeId)
+
+	printMnemonic(mnemonic, address)
+}
+
+func printMnemonic(mnemonic, address string) {
+	fmt.Println("Write down this mnemonic, it restores every address of the wallet:")
+	fmt.Printf("\n  %s\n\n", mnemonic)
+	fmt.Printf("New wallet address is :%s\n", address)
+}
+
+func (cli *CommandLine) RestoreWallet(mnemonic, passphrase, path string, gap int, nodeId string) {
+	if cli.client != nil {
+		cli.remoteRestoreWallet(mnemonic, passphrase, path, gap)
+		return
+	}
+
+	seed, err := wallet.MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	wallets := loadWallets(nodeId, true)
+	if err := wallets.SetSeed(seed); err != nil {
+		log.Panic(err)
+	}
+
+	chain := blockchain.ContinueBlockchain(nodeId)
+	defer chain.Database.Close()
+
+	found, err := wallets.Discover(path, gap, wallet.UsedIn(chain.UsedPubKeyHashes()))
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveFile(nodeId)
+
+	total := 0
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+	for idx, address := range found {
+		balance := 0
+		for _, out := range UTXOSet.FindUTXO(wallet.AddressToPubKeyHash(address)) {
+			balance += out.Value
+		}
+		total += balance
+
+		fmt.Printf("%d. %s %d\n", idx, address, balance)
+	}
+
+	fmt.Printf("Restored %d used addresses with a balance of %d\n", len(found), total)
+}
+
+func (cli *CommandLine) DiscoverWallet(path string, gap int, nodeId string) {
+	var found []string
+
+	if cli.client != nil {
+		found = cli.remoteDiscoverWallet(path, gap)
+	} else {
+		chain := blockchain.ContinueBlockchain(nodeId)
+		used := chain.UsedPubKeyHashes()
+		chain.Database.Close()
+
+		wallets := loadWallets(nodeId, true)
+		var err error
+		found, err = wallets.Discover(path, gap, wallet.UsedIn(used))
+		if err != nil {
+			log.Panic(err)
+		}
+		wallets.SaveFile(nodeId)
+	}
+
+	for idx, address := range found {
+		fmt.Printf("%d. %s\n", idx, address)
+	}
+	fmt.Printf("Found %d used addresses below %s\n", len(found), path)
+}
+
+func (cli *CommandLine) EncryptWallet(passphrase, nodeId string) {
+	if passphrase == "" {
+		passphrase = readPassphrase("New wallet passphrase: ")
+		if readPassphrase("Repeat the passphrase: ") != passphrase {
+			log.Panic("The passphrases do not match")
+		}
+	}
+
+	if cli.client != nil {
+		cli.call("encryptwallet", nil, passphrase)
+	} else {
+		wallets := loadWallets(nodeId, false)
+		if err := wallets.Encrypt(passphrase); err != nil {
+			log.Panic(err)
+		}
+		wallets.SaveFile(nodeId)
+	}
+
+	fmt.Println("Wallet encrypted. Sending now asks for the passphrase, or needs walletpassphrase on a running node.")
+}
+
+func (cli *CommandLine) WalletPassphrase(passphrase string, timeout int) {
+	if cli.client == nil {
+		log.Panic("walletpassphrase unlocks a running node, local commands ask for the passphrase when they need it")
+	}
+
+	if passphrase == "" {
+		passphrase = readPassphrase("Wallet passphrase: ")
+	}
+
+	cli.call("walletpassphrase", nil, passphrase, timeout)
+	fmt.Printf("Wallet unlocked for %d seconds\n", timeout)
+}
+
+func (cli *CommandLine) WalletLock() {
+	if cli.client == nil {
+		log.Panic("walletlock locks a running node, local wallets are never left unlocked")
+	}
+
+	cli.call("walletlock", nil)
+	fmt.Println("Wallet locked")
+}
+
 func (cli *CommandLine) ListAddresses(nodeId string) {
-	wallets, _ := wallet.CreateWalltes(nodeId)
+	var addresses, watched []string
 
-	addresses := wallets.GetAllAddresses()
+	if cli.client != nil {
+		addresses = cli.remoteListAddresses()
+		cli.call("listwatchonly", &watched)
+	} else {
+		wallets := loadWallets(nodeId, false)
+		addresses = wallets.GetAllAddresses()
+		watched = wallets.GetWatchedAddresses()
+	}
 
 	for idx, address := range addresses {
 		fmt.Printf("%d. %s\n", idx, address)
 	}
+
+	for idx, address := range watched {
+		fmt.Printf("%d. %s (watch-only)\n", len(addresses)+idx, address)
+	}
 }
 
 func (cli *CommandLine) Run() {
@@ -172,22 +494,148 @@ func (cli *CommandLine) Run() {
 		runtime.Goexit()
 	}
 
+	if seed := os.Getenv("SEED_NODE"); seed != "" {
+		network.KnownNodes = []string{seed}
+	}
+
 	getBalancecmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
 	createBlockchaincmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
 	sendcmd := flag.NewFlagSet("send", flag.ExitOnError)
+	sendManycmd := flag.NewFlagSet("sendmany", flag.ExitOnError)
 	printChaincmd := flag.NewFlagSet("print", flag.ExitOnError)
 	createWalletcmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
+	discoverWalletcmd := flag.NewFlagSet("discoverwallet", flag.ExitOnError)
+	restoreWalletcmd := flag.NewFlagSet("restorewallet", flag.ExitOnError)
+	encryptWalletcmd := flag.NewFlagSet("encryptwallet", flag.ExitOnError)
+	walletPassphrasecmd := flag.NewFlagSet("walletpassphrase", flag.ExitOnError)
+	walletLockcmd := flag.NewFlagSet("walletlock", flag.ExitOnError)
+	importAddresscmd := flag.NewFlagSet("importaddress", flag.ExitOnError)
+	importPubKeycmd := flag.NewFlagSet("importpubkey", flag.ExitOnError)
+	getAddressInfocmd := flag.NewFlagSet("getaddressinfo", flag.ExitOnError)
+	addMultiSigAddresscmd := flag.NewFlagSet("addmultisigaddress", flag.ExitOnError)
+	listTransactionscmd := flag.NewFlagSet("listtransactions", flag.ExitOnError)
+	createRawTxcmd := flag.NewFlagSet("createrawtx", flag.ExitOnError)
+	decodeRawTxcmd := flag.NewFlagSet("decoderawtx", flag.ExitOnError)
+	scriptcmd := flag.NewFlagSet("script", flag.ExitOnError)
+	signRawTxcmd := flag.NewFlagSet("signrawtx", flag.ExitOnError)
+	combineRawTxcmd := flag.NewFlagSet("combinerawtx", flag.ExitOnError)
+	sendRawTxcmd := flag.NewFlagSet("sendrawtx", flag.ExitOnError)
+	swapcmd := flag.NewFlagSet("swap", flag.ExitOnError)
+	channelcmd := flag.NewFlagSet("channel", flag.ExitOnError)
+	timestampcmd := flag.NewFlagSet("timestamp", flag.ExitOnError)
+	verifyStampcmd := flag.NewFlagSet("verifystamp", flag.ExitOnError)
+	issueAssetcmd := flag.NewFlagSet("issueasset", flag.ExitOnError)
+	listAssetscmd := flag.NewFlagSet("listassets", flag.ExitOnError)
+	estimateFeecmd := flag.NewFlagSet("estimatefee", flag.ExitOnError)
+	bumpFeecmd := flag.NewFlagSet("bumpfee", flag.ExitOnError)
 	listAddressescmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
 	reindexUTXOcmd := flag.NewFlagSet("reindexutxo", flag.ExitOnError)
 	startNodecmd := flag.NewFlagSet("startnode", flag.ExitOnError)
+	explorercmd := flag.NewFlagSet("explorer", flag.ExitOnError)
+
+	rpcFlags := make(map[string]rpcOptions)
+	for _, cmd := range []*flag.FlagSet{getBalancecmd, createBlockchaincmd, sendcmd, sendManycmd, printChaincmd, createWalletcmd, discoverWalletcmd, restoreWalletcmd, encryptWalletcmd, walletPassphrasecmd, walletLockcmd, importAddresscmd, importPubKeycmd, getAddressInfocmd, addMultiSigAddresscmd, listTransactionscmd, createRawTxcmd, sendRawTxcmd, swapcmd, channelcmd, timestampcmd, verifyStampcmd, issueAssetcmd, listAssetscmd, estimateFeecmd, bumpFeecmd, listAddressescmd, reindexUTXOcmd} {
+		rpcFlags[cmd.Name()] = addRPCFlags(cmd)
+	}
 
 	getBalanceAddress := getBalancecmd.String("address", "", "The address")
+	getBalanceAsset := getBalancecmd.String("asset", "", "ID or name of the asset to count tokens of")
 	createBlockchainAddress := createBlockchaincmd.String("address", "", "The address")
 	sendFrom := sendcmd.String("from", "", "address sent from")
 	sendTo := sendcmd.String("to", "", "address sent to")
 	sendAmount := sendcmd.Int("amount", 0, "amount sent to")
 	sendMine := sendcmd.Bool("mine", false, "Mine immediately on the same node")
+	sendStrategy := sendcmd.String("strategy", "", "Coin selection strategy: bnb, largest, random or manual")
+	sendFeeRate := sendcmd.Int("feerate", int(blockchain.DefaultFeeRate), "Fee in coins per 1000 bytes, estimated by a running node if not given")
+	sendAsset := sendcmd.String("asset", "", "ID or name of the asset to send tokens of")
+	sendBlocks := sendcmd.Int("blocks", network.DefaultConfTarget, "Number of blocks to confirm within at the estimated fee rate")
+	sendReplaceable := sendcmd.Bool("replaceable", false, "Signal that the transaction may be replaced by one paying a higher fee")
+	var sendIns listFlag
+	sendcmd.Var(&sendIns, "in", "TXID:VOUT of an output to spend with the manual strategy, may be repeated")
+	sendManyFrom := sendManycmd.String("from", "", "address sent from")
+	sendManyFile := sendManycmd.String("file", "", "JSON or CSV file of address/amount pairs")
+	sendManyMine := sendManycmd.Bool("mine", false, "Mine immediately on the same node")
+	sendManyStrategy := sendManycmd.String("strategy", "", "Coin selection strategy: bnb, largest, random or manual")
+	sendManyFeeRate := sendManycmd.Int("feerate", int(blockchain.DefaultFeeRate), "Fee in coins per 1000 bytes, estimated by a running node if not given")
+	sendManyBlocks := sendManycmd.Int("blocks", network.DefaultConfTarget, "Number of blocks to confirm within at the estimated fee rate")
+	sendManyReplaceable := sendManycmd.Bool("replaceable", false, "Signal that the transaction may be replaced by one paying a higher fee")
+	var sendManyIns listFlag
+	sendManycmd.Var(&sendManyIns, "in", "TXID:VOUT of an output to spend with the manual strategy, may be repeated")
+	createWalletPath := createWalletcmd.String("path", wallet.DefaultPath, "HD derivation path the address is derived below")
+	createWalletMnemonic := createWalletcmd.Bool("mnemonic", false, "Create the HD seed from a new mnemonic phrase")
+	createWalletPassphrase := createWalletcmd.String("passphrase", "", "Optional passphrase protecting the mnemonic")
+	restoreWalletMnemonic := restoreWalletcmd.String("mnemonic", "", "Mnemonic phrase of the wallet")
+	restoreWalletPassphrase := restoreWalletcmd.String("passphrase", "", "Passphrase the mnemonic was created with")
+	restoreWalletPath := restoreWalletcmd.String("path", wallet.DefaultPath, "HD derivation path to scan")
+	restoreWalletGap := restoreWalletcmd.Int("gap", wallet.DefaultGap, "Number of consecutive unused addresses that ends the scan")
+	encryptWalletPassphrase := encryptWalletcmd.String("passphrase", "", "New wallet passphrase, asked for if empty")
+	walletPassphrasePassphrase := walletPassphrasecmd.String("passphrase", "", "Wallet passphrase, asked for if empty")
+	walletPassphraseTimeout := walletPassphrasecmd.Int("timeout", 60, "Seconds until the wallet locks again")
+	importAddressAddress := importAddresscmd.String("address", "", "Address to watch")
+	importPubKeyPubKey := importPubKeycmd.String("pubkey", "", "Hex encoded public key to watch")
+	getAddressInfoAddress := getAddressInfocmd.String("address", "", "The address")
+	addMultiSigAddressM := addMultiSigAddresscmd.Int("m", 0, "Number of signatures required")
+	var addMultiSigAddressKeys listFlag
+	addMultiSigAddresscmd.Var(&addMultiSigAddressKeys, "key", "Hex encoded public key or address of a cosigner, may be repeated")
+	listTransactionsWatchOnly := listTransactionscmd.Bool("watchonly", true, "Include watch-only addresses")
+	createRawTxFrom := createRawTxcmd.String("from", "", "address sent from")
+	createRawTxTo := createRawTxcmd.String("to", "", "address sent to")
+	createRawTxAmount := createRawTxcmd.Int("amount", 0, "amount sent to")
+	var createRawTxIns, createRawTxOuts listFlag
+	createRawTxcmd.Var(&createRawTxIns, "in", "TXID:VOUT of an output to spend, may be repeated")
+	createRawTxcmd.Var(&createRawTxOuts, "out", "ADDRESS:AMOUNT of an output to create, may be repeated")
+	createRawTxLockTime := createRawTxcmd.Int("locktime", 0, "First block height, or unix time from 500000000 on, the transaction may be mined at")
+	createRawTxJSON := createRawTxcmd.String("json", "", "Transaction written as JSON")
+	createRawTxStrategy := createRawTxcmd.String("strategy", "", "Coin selection strategy: bnb, largest, random or manual")
+	createRawTxFeeRate := createRawTxcmd.Int("feerate", int(blockchain.DefaultFeeRate), "Fee in coins per 1000 bytes")
+	scriptAsm := scriptcmd.String("asm", "", "Script in assembly")
+	scriptHex := scriptcmd.String("hex", "", "Hex encoded script")
+	decodeRawTxHex := decodeRawTxcmd.String("hex", "", "Hex encoded raw transaction")
+	decodeRawTxPSBT := decodeRawTxcmd.String("psbt", "", "Hex encoded partially signed transaction")
+	signRawTxPSBT := signRawTxcmd.String("psbt", "", "Hex encoded partially signed transaction")
+	var combineRawTxPSBTs listFlag
+	combineRawTxcmd.Var(&combineRawTxPSBTs, "psbt", "Hex encoded partially signed transaction, may be repeated")
+	sendRawTxPSBT := sendRawTxcmd.String("psbt", "", "Hex encoded partially signed transaction")
+	sendRawTxHex := sendRawTxcmd.String("hex", "", "Hex encoded raw transaction")
+	swapFrom := swapcmd.String("from", "", "address the contract is paid from")
+	swapTo := swapcmd.String("to", "", "address of the other party")
+	swapAmount := swapcmd.Int("amount", 0, "amount locked in the contract")
+	swapTimeout := swapcmd.Duration("timeout", 0, "Time until the contract can be refunded, 48h to initiate and 24h to participate if 0")
+	swapSecretHash := swapcmd.String("secrethash", "", "Hex encoded secret hash of the initiator's contract")
+	swapSecret := swapcmd.String("secret", "", "Hex encoded secret")
+	swapTxID := swapcmd.String("txid", "", "Transaction of the contract")
+	swapMine := swapcmd.Bool("mine", false, "Mine immediately on the same node")
+	swapFeeRate := swapcmd.Int("feerate", int(blockchain.DefaultFeeRate), "Fee in coins per 1000 bytes")
+	channelFrom := channelcmd.String("from", "", "address of the payer")
+	channelTo := channelcmd.String("to", "", "address of the payee")
+	channelAmount := channelcmd.Int("amount", 0, "amount to fund the channel with or to pay")
+	channelTimeout := channelcmd.Duration("timeout", 0, "Time until the payer can take the channel back, 24h if 0")
+	channelID := channelcmd.String("id", "", "Channel ID, the funding transaction")
+	channelPayment := channelcmd.String("payment", "", "Hex encoded payment")
+	channelRefund := channelcmd.Bool("refund", false, "Take the channel back as the payer")
+	channelMine := channelcmd.Bool("mine", false, "Mine immediately on the same node")
+	channelFeeRate := channelcmd.Int("feerate", int(blockchain.DefaultFeeRate), "Fee in coins per 1000 bytes")
+	timestampFrom := timestampcmd.String("from", "", "address paying the fee")
+	timestampFile := timestampcmd.String("file", "", "File to timestamp")
+	timestampMine := timestampcmd.Bool("mine", false, "Mine immediately on the same node")
+	timestampFeeRate := timestampcmd.Int("feerate", int(blockchain.DefaultFeeRate), "Fee in coins per 1000 bytes")
+	verifyStampFile := verifyStampcmd.String("file", "", "File to verify")
+	issueAssetFrom := issueAssetcmd.String("from", "", "address the tokens are issued to")
+	issueAssetName := issueAssetcmd.String("name", "", "Name of the asset")
+	issueAssetSupply := issueAssetcmd.Int("supply", 0, "Number of tokens issued")
+	issueAssetMine := issueAssetcmd.Bool("mine", false, "Mine immediately on the same node")
+	issueAssetFeeRate := issueAssetcmd.Int("feerate", int(blockchain.DefaultFeeRate), "Fee in coins per 1000 bytes")
+	estimateFeeBlocks := estimateFeecmd.Int("blocks", network.DefaultConfTarget, "Number of blocks to confirm within")
+	bumpFeeTxID := bumpFeecmd.String("txid", "", "ID of the transaction to replace")
+	bumpFeeFeeRate := bumpFeecmd.Int("feerate", 0, "Fee in coins per 1000 bytes, 0 for the old rate plus the default rate")
+	discoverWalletPath := discoverWalletcmd.String("path", wallet.DefaultPath, "HD derivation path to scan")
+	discoverWalletGap := discoverWalletcmd.Int("gap", wallet.DefaultGap, "Number of consecutive unused addresses that ends the scan")
 	startNodeMiner := startNodecmd.String("miner", "", "Enable mining mode and send reward to the miner.")
+	startNodeRPCAddr := startNodecmd.String("rpcaddr", rpc.DefaultAddress(nodeId), "Address of the JSON-RPC server, empty to disable")
+	startNodeRPCUser := startNodecmd.String("rpcuser", "", "Username for JSON-RPC basic auth")
+	startNodeRPCPassword := startNodecmd.String("rpcpassword", "", "Password for JSON-RPC basic auth, a cookie file is written if empty")
+	startNodeHTTP := startNodecmd.String("http", "", "Address to serve the REST API and block explorer on")
+	explorerHTTP := explorercmd.String("http", "localhost:8080", "Address to serve the REST API and block explorer on")
 
 	switch os.Args[1] {
 	case "getbalance":
@@ -199,12 +647,95 @@ func (cli *CommandLine) Run() {
 	case "send":
 		err := sendcmd.Parse(os.Args[2:])
 		blockchain.HandleErr(err)
+	case "sendmany":
+		err := sendManycmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
 	case "print":
 		err := printChaincmd.Parse(os.Args[2:])
 		blockchain.HandleErr(err)
 	case "createwallet":
 		err := createWalletcmd.Parse(os.Args[2:])
 		blockchain.HandleErr(err)
+	case "restorewallet":
+		err := restoreWalletcmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "encryptwallet":
+		err := encryptWalletcmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "walletpassphrase":
+		err := walletPassphrasecmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "walletlock":
+		err := walletLockcmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "importaddress":
+		err := importAddresscmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "importpubkey":
+		err := importPubKeycmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "addmultisigaddress":
+		err := addMultiSigAddresscmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "combinerawtx":
+		err := combineRawTxcmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "getaddressinfo":
+		err := getAddressInfocmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "listtransactions":
+		err := listTransactionscmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "createrawtx":
+		err := createRawTxcmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "script":
+		err := scriptcmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "decoderawtx":
+		err := decodeRawTxcmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "signrawtx":
+		err := signRawTxcmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "sendrawtx":
+		err := sendRawTxcmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "swap":
+		if len(os.Args) < 3 {
+			cli.PrintUsage()
+			runtime.Goexit()
+		}
+		err := swapcmd.Parse(os.Args[3:])
+		blockchain.HandleErr(err)
+	case "channel":
+		if len(os.Args) < 3 {
+			cli.PrintUsage()
+			runtime.Goexit()
+		}
+		err := channelcmd.Parse(os.Args[3:])
+		blockchain.HandleErr(err)
+	case "timestamp":
+		err := timestampcmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "verifystamp":
+		err := verifyStampcmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "issueasset":
+		err := issueAssetcmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "listassets":
+		err := listAssetscmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "estimatefee":
+		err := estimateFeecmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "bumpfee":
+		err := bumpFeecmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
+	case "discoverwallet":
+		err := discoverWalletcmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
 	case "listaddresses":
 		err := listAddressescmd.Parse(os.Args[2:])
 		blockchain.HandleErr(err)
@@ -214,17 +745,20 @@ func (cli *CommandLine) Run() {
 	case "startnode":
 		err := startNodecmd.Parse(os.Args[2:])
 		blockchain.HandleErr(err)
+	case "explorer":
+		err := explorercmd.Parse(os.Args[2:])
+		blockchain.HandleErr(err)
 	default:
 		cli.PrintUsage()
 		runtime.Goexit()
 	}
 
+	if opts, ok := rpcFlags[os.Args[1]]; ok {
+		cli.connect(nodeId, opts)
+	}
+
 	if getBalancecmd.Parsed() {
-		if *getBalanceAddress == "" {
-			getBalancecmd.Usage()
-			runtime.Goexit()
-		}
-		cli.GetBalance(*getBalanceAddress, nodeId)
+		cli.GetBalance(*getBalanceAddress, *getBalanceAsset, nodeId)
 	}
 
 	if createBlockchaincmd.Parsed() {
@@ -240,7 +774,25 @@ func (cli *CommandLine) Run() {
 			sendcmd.Usage()
 			runtime.Goexit()
 		}
-		cli.Send(*sendFrom, *sendTo, *sendAmount, nodeId, *sendMine)
+		opts := sendOptions(*sendStrategy, *sendFeeRate, sendIns)
+		opts.Replaceable = *sendReplaceable
+		if !isFlagSet(sendcmd, "feerate") {
+			opts.Blocks = *sendBlocks
+		}
+		cli.Send(*sendFrom, *sendTo, *sendAmount, *sendAsset, opts, nodeId, *sendMine)
+	}
+
+	if sendManycmd.Parsed() {
+		if *sendManyFrom == "" || *sendManyFile == "" {
+			sendManycmd.Usage()
+			runtime.Goexit()
+		}
+		opts := sendOptions(*sendManyStrategy, *sendManyFeeRate, sendManyIns)
+		opts.Replaceable = *sendManyReplaceable
+		if !isFlagSet(sendManycmd, "feerate") {
+			opts.Blocks = *sendManyBlocks
+		}
+		cli.SendMany(*sendManyFrom, *sendManyFile, opts, nodeId, *sendManyMine)
 	}
 
 	if printChaincmd.Parsed() {
@@ -248,7 +800,257 @@ func (cli *CommandLine) Run() {
 	}
 
 	if createWalletcmd.Parsed() {
-		cli.NewWallet(nodeId)
+		if *createWalletMnemonic {
+			cli.NewMnemonicWallet(*createWalletPassphrase, *createWalletPath, nodeId)
+		} else {
+			cli.NewWallet(*createWalletPath, nodeId)
+		}
+	}
+
+	if restoreWalletcmd.Parsed() {
+		if *restoreWalletMnemonic == "" || *restoreWalletGap <= 0 {
+			restoreWalletcmd.Usage()
+			runtime.Goexit()
+		}
+		cli.RestoreWallet(*restoreWalletMnemonic, *restoreWalletPassphrase, *restoreWalletPath, *restoreWalletGap, nodeId)
+	}
+
+	if encryptWalletcmd.Parsed() {
+		cli.EncryptWallet(*encryptWalletPassphrase, nodeId)
+	}
+
+	if walletPassphrasecmd.Parsed() {
+		if *walletPassphraseTimeout <= 0 {
+			walletPassphrasecmd.Usage()
+			runtime.Goexit()
+		}
+		cli.WalletPassphrase(*walletPassphrasePassphrase, *walletPassphraseTimeout)
+	}
+
+	if walletLockcmd.Parsed() {
+		cli.WalletLock()
+	}
+
+	if importAddresscmd.Parsed() {
+		if *importAddressAddress == "" {
+			importAddresscmd.Usage()
+			runtime.Goexit()
+		}
+		cli.ImportAddress(*importAddressAddress, nodeId)
+	}
+
+	if importPubKeycmd.Parsed() {
+		if *importPubKeyPubKey == "" {
+			importPubKeycmd.Usage()
+			runtime.Goexit()
+		}
+		cli.ImportPubKey(*importPubKeyPubKey, nodeId)
+	}
+
+	if getAddressInfocmd.Parsed() {
+		if *getAddressInfoAddress == "" {
+			getAddressInfocmd.Usage()
+			runtime.Goexit()
+		}
+		cli.GetAddressInfo(*getAddressInfoAddress, nodeId)
+	}
+
+	if addMultiSigAddresscmd.Parsed() {
+		if *addMultiSigAddressM <= 0 || len(addMultiSigAddressKeys) == 0 {
+			addMultiSigAddresscmd.Usage()
+			runtime.Goexit()
+		}
+		cli.AddMultiSigAddress(*addMultiSigAddressM, addMultiSigAddressKeys, nodeId)
+	}
+
+	if listTransactionscmd.Parsed() {
+		cli.ListTransactions(*listTransactionsWatchOnly, nodeId)
+	}
+
+	if createRawTxcmd.Parsed() && *createRawTxJSON != "" {
+		cli.CreateRawTxFromJSON(*createRawTxJSON)
+	} else if createRawTxcmd.Parsed() && len(createRawTxOuts) > 0 {
+		if len(createRawTxIns) == 0 {
+			createRawTxcmd.Usage()
+			runtime.Goexit()
+		}
+		cli.CreateRawTxFromParts(createRawTxIns, createRawTxOuts, *createRawTxLockTime, nodeId)
+	} else if createRawTxcmd.Parsed() {
+		if *createRawTxFrom == "" || *createRawTxTo == "" || *createRawTxAmount <= 0 {
+			createRawTxcmd.Usage()
+			runtime.Goexit()
+		}
+		cli.CreateRawTx(*createRawTxFrom, *createRawTxTo, *createRawTxAmount, sendOptions(*createRawTxStrategy, *createRawTxFeeRate, createRawTxIns), nodeId)
+	}
+
+	if scriptcmd.Parsed() {
+		if *scriptAsm == "" && *scriptHex == "" {
+			scriptcmd.Usage()
+			runtime.Goexit()
+		}
+		cli.Script(*scriptAsm, *scriptHex)
+	}
+
+	if decodeRawTxcmd.Parsed() {
+		if *decodeRawTxHex == "" && *decodeRawTxPSBT == "" {
+			decodeRawTxcmd.Usage()
+			runtime.Goexit()
+		}
+		cli.DecodeRawTx(*decodeRawTxHex, *decodeRawTxPSBT)
+	}
+
+	if combineRawTxcmd.Parsed() {
+		if len(combineRawTxPSBTs) < 2 {
+			combineRawTxcmd.Usage()
+			runtime.Goexit()
+		}
+		cli.CombineRawTx(combineRawTxPSBTs)
+	}
+
+	if signRawTxcmd.Parsed() {
+		if *signRawTxPSBT == "" {
+			signRawTxcmd.Usage()
+			runtime.Goexit()
+		}
+		cli.SignRawTx(*signRawTxPSBT, nodeId)
+	}
+
+	if sendRawTxcmd.Parsed() {
+		if *sendRawTxPSBT == "" && *sendRawTxHex == "" {
+			sendRawTxcmd.Usage()
+			runtime.Goexit()
+		}
+		if *sendRawTxHex != "" {
+			cli.SendRawHexTx(*sendRawTxHex)
+		} else {
+			cli.SendRawTx(*sendRawTxPSBT)
+		}
+	}
+
+	if swapcmd.Parsed() {
+		opts := sendOptions("", *swapFeeRate, nil)
+
+		switch os.Args[2] {
+		case "initiate":
+			if *swapFrom == "" || *swapTo == "" || *swapAmount <= 0 || *swapTimeout < 0 {
+				swapcmd.Usage()
+				runtime.Goexit()
+			}
+			cli.InitiateSwap(*swapFrom, *swapTo, *swapAmount, *swapTimeout, opts, nodeId, *swapMine)
+		case "participate":
+			if *swapFrom == "" || *swapTo == "" || *swapAmount <= 0 || *swapSecretHash == "" || *swapTimeout < 0 {
+				swapcmd.Usage()
+				runtime.Goexit()
+			}
+			cli.ParticipateSwap(*swapFrom, *swapTo, *swapAmount, *swapSecretHash, *swapTimeout, opts, nodeId, *swapMine)
+		case "redeem":
+			if *swapTxID == "" || *swapSecret == "" {
+				swapcmd.Usage()
+				runtime.Goexit()
+			}
+			cli.RedeemSwap(*swapTxID, *swapSecret, nodeId, *swapMine)
+		case "refund":
+			if *swapTxID == "" {
+				swapcmd.Usage()
+				runtime.Goexit()
+			}
+			cli.RefundSwap(*swapTxID, nodeId, *swapMine)
+		case "audit":
+			if *swapTxID == "" {
+				swapcmd.Usage()
+				runtime.Goexit()
+			}
+			cli.AuditSwap(*swapTxID, nodeId)
+		default:
+			cli.PrintUsage()
+			runtime.Goexit()
+		}
+	}
+
+	if channelcmd.Parsed() {
+		switch os.Args[2] {
+		case "open":
+			if *channelFrom == "" || *channelTo == "" || *channelAmount <= 0 || *channelTimeout < 0 {
+				channelcmd.Usage()
+				runtime.Goexit()
+			}
+			cli.OpenChannel(*channelFrom, *channelTo, *channelAmount, *channelTimeout, sendOptions("", *channelFeeRate, nil), nodeId, *channelMine)
+		case "pay":
+			if *channelID == "" || *channelAmount <= 0 {
+				channelcmd.Usage()
+				runtime.Goexit()
+			}
+			cli.PayChannel(*channelID, *channelAmount, nodeId)
+		case "accept":
+			if *channelPayment == "" {
+				channelcmd.Usage()
+				runtime.Goexit()
+			}
+			cli.AcceptChannelPayment(*channelPayment, nodeId)
+		case "close":
+			if *channelID == "" {
+				channelcmd.Usage()
+				runtime.Goexit()
+			}
+			cli.CloseChannel(*channelID, *channelRefund, nodeId, *channelMine)
+		case "list":
+			cli.ListChannels(nodeId)
+		default:
+			cli.PrintUsage()
+			runtime.Goexit()
+		}
+	}
+
+	if timestampcmd.Parsed() {
+		if *timestampFrom == "" || *timestampFile == "" {
+			timestampcmd.Usage()
+			runtime.Goexit()
+		}
+		cli.Timestamp(*timestampFrom, *timestampFile, sendOptions("", *timestampFeeRate, nil), nodeId, *timestampMine)
+	}
+
+	if verifyStampcmd.Parsed() {
+		if *verifyStampFile == "" {
+			verifyStampcmd.Usage()
+			runtime.Goexit()
+		}
+		cli.VerifyStamp(*verifyStampFile, nodeId)
+	}
+
+	if issueAssetcmd.Parsed() {
+		if *issueAssetFrom == "" || *issueAssetName == "" || *issueAssetSupply <= 0 {
+			issueAssetcmd.Usage()
+			runtime.Goexit()
+		}
+		cli.IssueAsset(*issueAssetFrom, *issueAssetName, *issueAssetSupply, sendOptions("", *issueAssetFeeRate, nil), nodeId, *issueAssetMine)
+	}
+
+	if listAssetscmd.Parsed() {
+		cli.ListAssets(nodeId)
+	}
+
+	if estimateFeecmd.Parsed() {
+		if *estimateFeeBlocks <= 0 {
+			estimateFeecmd.Usage()
+			runtime.Goexit()
+		}
+		cli.EstimateFee(*estimateFeeBlocks)
+	}
+
+	if bumpFeecmd.Parsed() {
+		if *bumpFeeTxID == "" || *bumpFeeFeeRate < 0 {
+			bumpFeecmd.Usage()
+			runtime.Goexit()
+		}
+		cli.BumpFee(*bumpFeeTxID, *bumpFeeFeeRate)
+	}
+
+	if discoverWalletcmd.Parsed() {
+		if *discoverWalletGap <= 0 {
+			discoverWalletcmd.Usage()
+			runtime.Goexit()
+		}
+		cli.DiscoverWallet(*discoverWalletPath, *discoverWalletGap, nodeId)
 	}
 
 	if listAddressescmd.Parsed() {
@@ -265,6 +1067,10 @@ func (cli *CommandLine) Run() {
 			startNodecmd.Usage()
 			runtime.Goexit()
 		}
-		cli.StartNode(nodeId, *startNodeMiner)
+		cli.StartNode(nodeId, *startNodeMiner, *startNodeRPCAddr, *startNodeRPCUser, *startNodeRPCPassword, *startNodeHTTP)
+	}
+
+	if explorercmd.Parsed() {
+		cli.Explorer(nodeId, *explorerHTTP)
 	}
 }
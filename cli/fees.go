@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/gitferry/blockchain-go/rpc"
+)
+
+// EstimateFee prints the fee rate the running node estimates for confirming
+// within blocks blocks.
+func (cli *CommandLine) EstimateFee(blocks int) {
+	if cli.client == nil {
+		log.Panic("estimatefee needs the memory pool of a running node")
+	}
+
+	var estimate rpc.FeeEstimate
+	cli.call("estimatefee", &estimate, blocks)
+
+	fmt.Printf("Fee rate to confirm within %d blocks: %d coins per 1000 bytes\n", estimate.Blocks, estimate.FeeRate)
+}
+
+// isFlagSet reports whether flag name was given on the command line of cmd.
+func isFlagSet(cmd *flag.FlagSet, name string) bool {
+	set := false
+	cmd.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+
+	return set
+}
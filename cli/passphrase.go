@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/gitferry/blockchain-go/wallet"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// readPassphrase reads a passphrase from the terminal without echoing it, or
+// a single line from stdin when it is not a terminal.
+func readPassphrase(prompt string) string {
+	fmt.Fprint(os.Stderr, prompt)
+
+	fd := int(os.Stdin.Fd())
+	if terminal.IsTerminal(fd) {
+		passphrase, err := terminal.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		return string(passphrase)
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		log.Panic(err)
+	}
+
+	return strings.TrimRight(line, "\r\n")
+}
+
+// loadWallets reads the wallet file of the node, asking for the passphrase
+// when the keys are needed and the file is encrypted.
+func loadWallets(nodeId string, unlock bool) *wallet.Wallets {
+	wallets, err := wallet.CreateWalltes(nodeId)
+	if err != nil && !os.IsNotExist(err) {
+		log.Panicf("Cannot read the wallet: %s", err)
+	}
+
+	if unlock && wallets.Locked() {
+		if err := wallets.Unlock(readPassphrase("Wallet passphrase: ")); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	return wallets
+}
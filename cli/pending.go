@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"encoding/hex"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+)
+
+// pendingUTXOSet returns the unspent outputs of chain together with the
+// transactions the wallet sent that are not mined yet, so new transactions
+// neither spend the same outputs again nor miss their change. Transactions
+// that were mined or lost a double spend are forgotten.
+func pendingUTXOSet(chain *blockchain.BlockChain, nodeId string) blockchain.UTXOSet {
+	wallets := loadWallets(nodeId, false)
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+
+	var txs []blockchain.Transaction
+	for _, data := range wallets.PendingTxs() {
+		txs = append(txs, blockchain.DeserializeTransaction(data))
+	}
+	UTXOSet.Pending = UTXOSet.PendingTxs(txs)
+
+	if len(UTXOSet.Pending) < len(txs) {
+		for _, tx := range txs {
+			id := hex.EncodeToString(tx.ID)
+			if _, ok := UTXOSet.Pending[id]; !ok {
+				wallets.RemovePending(id)
+			}
+		}
+		wallets.SaveFile(nodeId)
+	}
+
+	return UTXOSet
+}
+
+// recordPending remembers tx, sent to the seed node, until it is mined.
+func recordPending(tx *blockchain.Transaction, nodeId string) {
+	wallets := loadWallets(nodeId, false)
+	wallets.AddPending(hex.EncodeToString(tx.ID), tx.Serialize())
+	wallets.SaveFile(nodeId)
+}
+
+// pendingAncestors returns the pending transactions tx spends outputs of,
+// directly or through others, with every parent before its children.
+func pendingAncestors(tx *blockchain.Transaction, pending map[string]blockchain.Transaction) []*blockchain.Transaction {
+	var ancestors []*blockchain.Transaction
+	seen := make(map[string]bool)
+
+	var visit func(tx *blockchain.Transaction)
+	visit = func(tx *blockchain.Transaction) {
+		for _, in := range tx.Inputs {
+			id := hex.EncodeToString(in.ID)
+			parent, ok := pending[id]
+			if !ok || seen[id] {
+				continue
+			}
+
+			seen[id] = true
+			visit(&parent)
+			ancestors = append(ancestors, &parent)
+		}
+	}
+	visit(tx)
+
+	return ancestors
+}
@@ -0,0 +1,319 @@
+package cli
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/network"
+	"github.com/gitferry/blockchain-go/rpc"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+func (cli *CommandLine) CreateRawTx(from, to string, amount int, opts rpc.SendOptions, nodeId string) {
+	if !wallet.ValidateAddress(from) || !wallet.ValidateAddress(to) {
+		log.Panic("Address is not valid")
+	}
+
+	fmt.Println(cli.createRawTx(from, to, amount, opts, nodeId))
+}
+
+func (cli *CommandLine) createRawTx(from, to string, amount int, opts rpc.SendOptions, nodeId string) string {
+	if cli.client != nil {
+		var psbt string
+		cli.call("createrawtransaction", &psbt, from, to, amount, opts)
+
+		return psbt
+	}
+
+	wallets := loadWallets(nodeId, false)
+
+	chain := blockchain.ContinueBlockchain(nodeId)
+	defer chain.Database.Close()
+	UTXOSet := pendingUTXOSet(chain, nodeId)
+
+	sendOpts, err := opts.Build()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	ptx, err := blockchain.NewPartialTxFrom(wallets, from, []blockchain.TxOutput{*blockchain.NewTXOutput(amount, to)}, &UTXOSet, sendOpts)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return hex.EncodeToString(ptx.Serialize())
+}
+
+// sendOptions picks the manual strategy when inputs are given without one.
+func sendOptions(strategy string, feeRate int, inputs []string) rpc.SendOptions {
+	if strategy == "" && len(inputs) > 0 {
+		strategy = "manual"
+	}
+
+	return rpc.SendOptions{Strategy: strategy, FeeRate: feeRate, Inputs: inputs}
+}
+
+// listFlag collects the values of a flag given several times.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// CreateRawTxFromParts spends exactly the given TXID:VOUT inputs into the
+// given ADDRESS:AMOUNT outputs, leaving any difference as fee.
+func (cli *CommandLine) CreateRawTxFromParts(ins, outs []string, lockTime int, nodeId string) {
+	var inputs []rpc.RawInput
+	for _, in := range ins {
+		parts := strings.Split(in, ":")
+		if len(parts) != 2 && len(parts) != 3 {
+			log.Panicf("Input %s is not TXID:VOUT", in)
+		}
+
+		out, err := strconv.Atoi(parts[1])
+		if err != nil {
+			log.Panicf("Input %s is not TXID:VOUT", in)
+		}
+
+		input := rpc.RawInput{TxID: parts[0], Out: out}
+		if len(parts) == 3 {
+			if input.Sequence, err = parseRelativeLock(parts[2]); err != nil {
+				log.Panicf("Input %s: %s", in, err)
+			}
+		}
+
+		inputs = append(inputs, input)
+	}
+
+	var outputs []rpc.RawOutput
+	for _, out := range outs {
+		parts := strings.Split(out, ":")
+		if len(parts) != 2 {
+			log.Panicf("Output %s is not ADDRESS:AMOUNT", out)
+		}
+
+		amount, err := strconv.Atoi(parts[1])
+		if err != nil {
+			log.Panicf("Output %s is not ADDRESS:AMOUNT", out)
+		}
+
+		// Anything that is not an address is taken as a hex locking script.
+		output := rpc.RawOutput{Address: parts[0], Amount: amount}
+		if !wallet.ValidateAddress(parts[0]) {
+			output = rpc.RawOutput{Script: parts[0], Amount: amount}
+		}
+
+		outputs = append(outputs, output)
+	}
+
+	tx, err := rpc.NewRawTransaction(inputs, outputs, lockTime)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if cli.client != nil {
+		var psbt string
+		cli.call("createrawtransaction", &psbt, inputs, outputs, lockTime)
+		fmt.Println(psbt)
+		return
+	}
+
+	chain := blockchain.ContinueBlockchain(nodeId)
+	defer chain.Database.Close()
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+
+	ptx, err := blockchain.NewPartialTx(tx, &UTXOSet)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(hex.EncodeToString(ptx.Serialize()))
+}
+
+// parseRelativeLock reads a relative lock given in blocks, such as 10, or in
+// seconds, such as 3600s.
+func parseRelativeLock(s string) (int, error) {
+	if strings.HasSuffix(s, "s") {
+		seconds, err := strconv.ParseInt(strings.TrimSuffix(s, "s"), 10, 64)
+		if err != nil || seconds <= 0 || seconds>>blockchain.SequenceGranularity > blockchain.SequenceMask {
+			return 0, fmt.Errorf("relative lock %s is not a valid number of seconds", s)
+		}
+		return blockchain.TimeSequence(seconds), nil
+	}
+
+	blocks, err := strconv.Atoi(s)
+	if err != nil || blocks <= 0 || blocks > blockchain.SequenceMask {
+		return 0, fmt.Errorf("relative lock %s is not a valid number of blocks", s)
+	}
+
+	return blockchain.BlockSequence(blocks), nil
+}
+
+// CreateRawTxFromJSON encodes a transaction written as JSON without checking
+// it. An empty txid is filled in, any other is kept even if it is wrong.
+func (cli *CommandLine) CreateRawTxFromJSON(data string) {
+	var tx blockchain.Transaction
+	if err := json.Unmarshal([]byte(data), &tx); err != nil {
+		log.Panic(err)
+	}
+
+	if len(tx.ID) == 0 {
+		tx.ID = tx.UnsignedHash()
+	}
+
+	fmt.Println(hex.EncodeToString(tx.Serialize()))
+}
+
+// DecodeRawTx prints a raw or partially signed transaction as JSON.
+func (cli *CommandLine) DecodeRawTx(rawTx, psbt string) {
+	var v interface{}
+
+	if psbt != "" {
+		ptx := decodePartialTx(psbt)
+		v = struct {
+			*blockchain.PartialTx
+			Fee      int  `json:"fee"`
+			Complete bool `json:"complete"`
+		}{ptx, ptx.Fee(), ptx.Complete()}
+	} else {
+		data, err := hex.DecodeString(rawTx)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		tx, err := blockchain.DecodeTransaction(data)
+		if err != nil {
+			log.Panic(err)
+		}
+		v = tx
+	}
+
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(string(out))
+}
+
+// Script converts a script between its assembly and hex forms.
+func (cli *CommandLine) Script(asm, scriptHex string) {
+	var script []byte
+	var err error
+
+	if asm != "" {
+		script, err = blockchain.AssembleScript(asm)
+	} else {
+		script, err = hex.DecodeString(scriptHex)
+	}
+	if err != nil {
+		log.Panic(err)
+	}
+
+	result, err := rpc.NewScriptResult(script)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("asm: %s\nhex: %s\ntype: %s\n", result.Asm, result.Hex, result.Type)
+}
+
+// SignRawTx adds the signatures the local wallet can make. It only reads the
+// wallet file, so it works on a machine without the chain or network.
+func (cli *CommandLine) SignRawTx(psbt, nodeId string) {
+	ptx := decodePartialTx(psbt)
+
+	wallets := loadWallets(nodeId, true)
+	for _, address := range wallets.GetWatchedAddresses() {
+		if script, ok := wallets.RedeemScript(address); ok {
+			if _, err := ptx.AddRedeemScript(script); err != nil {
+				log.Panic(err)
+			}
+		}
+	}
+
+	signed := 0
+	for _, address := range wallets.GetAllAddresses() {
+		w := wallets.GetWallet(address)
+		signed += ptx.Sign(&w)
+	}
+
+	fmt.Printf("Added %d signatures to %d inputs, complete: %t\n", signed, len(ptx.Tx.Inputs), ptx.Complete())
+	fmt.Println(hex.EncodeToString(ptx.Serialize()))
+}
+
+// CombineRawTx merges the signatures of copies of a partially signed
+// transaction that cosigners signed in parallel.
+func (cli *CommandLine) CombineRawTx(psbts []string) {
+	ptx := decodePartialTx(psbts[0])
+	for _, psbt := range psbts[1:] {
+		if err := ptx.Combine(decodePartialTx(psbt)); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	fmt.Printf("Complete: %t\n", ptx.Complete())
+	fmt.Println(hex.EncodeToString(ptx.Serialize()))
+}
+
+func (cli *CommandLine) SendRawTx(psbt string) {
+	ptx := decodePartialTx(psbt)
+
+	tx, err := ptx.Finalize()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	cli.sendTx(tx)
+}
+
+// SendRawHexTx broadcasts a raw transaction as is, leaving all validation to
+// the receiving node.
+func (cli *CommandLine) SendRawHexTx(rawTx string) {
+	data, err := hex.DecodeString(rawTx)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	tx, err := blockchain.DecodeTransaction(data)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	cli.sendTx(tx)
+}
+
+func (cli *CommandLine) sendTx(tx *blockchain.Transaction) {
+	if cli.client != nil {
+		var txID string
+		cli.call("sendrawtransaction", &txID, hex.EncodeToString(tx.Serialize()))
+	} else if err := network.SendTx(network.KnownNodes[0], tx); err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("send tx %x\n", tx.ID)
+}
+
+func decodePartialTx(psbt string) *blockchain.PartialTx {
+	data, err := hex.DecodeString(psbt)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	ptx, err := blockchain.DeserializePartialTx(data)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return ptx
+}
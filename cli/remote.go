@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/rpc"
+)
+
+type rpcOptions struct {
+	address  *string
+	user     *string
+	password *string
+}
+
+func addRPCFlags(cmd *flag.FlagSet) rpcOptions {
+	return rpcOptions{
+		address:  cmd.String("rpc", "", "Address of a running node's JSON-RPC server"),
+		user:     cmd.String("rpcuser", "", "Username for JSON-RPC basic auth"),
+		password: cmd.String("rpcpassword", "", "Password for JSON-RPC basic auth"),
+	}
+}
+
+func (cli *CommandLine) connect(nodeId string, opts rpcOptions) {
+	address := *opts.address
+	explicit := address != ""
+	if !explicit {
+		address = rpc.DefaultAddress(nodeId)
+	}
+
+	if address == "" {
+		return
+	}
+
+	user, password := *opts.user, *opts.password
+	if password == "" {
+		if cookieUser, cookiePassword, err := rpc.ReadCookie(nodeId); err == nil {
+			user, password = cookieUser, cookiePassword
+		}
+	}
+
+	client := rpc.NewClient(address, user, password)
+	if !explicit && !client.Ping() {
+		return
+	}
+
+	cli.client = client
+}
+
+func (cli *CommandLine) call(method string, result interface{}, params ...interface{}) {
+	if err := cli.client.Call(method, result, params...); err != nil {
+		log.Panic(err)
+	}
+}
+
+func (cli *CommandLine) remotePrintChain() {
+	var hash string
+	cli.call("getbestblockhash", &hash)
+
+	for hash != "" {
+		var blockHex string
+		cli.call("getblock", &blockHex, hash, false)
+
+		data, err := hex.DecodeString(blockHex)
+		blockchain.HandleErr(err)
+		block := blockchain.Deserialize(data)
+
+		printBlock(block)
+
+		hash = hex.EncodeToString(block.PrevHash)
+	}
+}
+
+func (cli *CommandLine) remoteGetBalance(address, asset string) {
+	var balance int
+	cli.call("getbalance", &balance, address, asset)
+
+	var pending int
+	cli.call("getpendingbalance", &pending, address, asset)
+
+	fmt.Printf("Balance of %s is: %d%s\n", address, balance, assetSuffix(asset))
+	printPending(pending, asset)
+}
+
+func (cli *CommandLine) remoteSend(from, to string, amount int, asset string, opts rpc.SendOptions, mineNow bool) {
+	var txID string
+	cli.call("sendtoaddress", &txID, from, to, amount, opts, asset)
+	fmt.Printf("send tx %s\n", txID)
+
+	if mineNow {
+		var blockHash string
+		cli.call("generate", &blockHash, from)
+		fmt.Printf("Mined block %s\n", blockHash)
+	}
+
+	fmt.Println("Success!")
+}
+
+func (cli *CommandLine) remoteSendMany(from string, payments []rpc.Payment, opts rpc.SendOptions, mineNow bool) {
+	var txID string
+	cli.call("sendmany", &txID, from, payments, opts)
+	fmt.Printf("send tx %s\n", txID)
+
+	if mineNow {
+		var blockHash string
+		cli.call("generate", &blockHash, from)
+		fmt.Printf("Mined block %s\n", blockHash)
+	}
+
+	fmt.Println("Success!")
+}
+
+func (cli *CommandLine) remoteReindexUTXO() {
+	var count int
+	cli.call("reindexutxo", &count)
+
+	fmt.Printf("Done! There are %d transactions in the UTXO set.\n", count)
+}
+
+func (cli *CommandLine) remoteNewWallet(path string) {
+	var address string
+	cli.call("getnewaddress", &address, path)
+
+	fmt.Printf("New wallet address is :%s", address)
+}
+
+func (cli *CommandLine) remoteNewMnemonicWallet(passphrase, path string) {
+	var result rpc.MnemonicResult
+	cli.call("createmnemonic", &result, passphrase, path)
+
+	printMnemonic(result.Mnemonic, result.Address)
+}
+
+func (cli *CommandLine) remoteRestoreWallet(mnemonic, passphrase, path string, gap int) {
+	var result rpc.RestoreResult
+	cli.call("restorewallet", &result, mnemonic, passphrase, path, gap)
+
+	for idx, restored := range result.Addresses {
+		fmt.Printf("%d. %s %d\n", idx, restored.Address, restored.Balance)
+	}
+
+	fmt.Printf("Restored %d used addresses with a balance of %d\n", len(result.Addresses), result.Balance)
+}
+
+func (cli *CommandLine) remoteDiscoverWallet(path string, gap int) []string {
+	var found []string
+	cli.call("discoveraddresses", &found, path, gap)
+
+	return found
+}
+
+func (cli *CommandLine) remoteListAddresses() []string {
+	var addresses []string
+	cli.call("listaddresses", &addresses)
+
+	return addresses
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gitferry/blockchain-go/rpc"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// SendMany pays every address listed in file from one address in a single
+// transaction. All payments are checked before anything is sent.
+func (cli *CommandLine) SendMany(from, file string, opts rpc.SendOptions, nodeId string, mineNow bool) {
+	if !wallet.ValidateAddress(from) {
+		log.Panic("Address is not valid")
+	}
+
+	payments, err := readPayments(file)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	outputs, err := rpc.PaymentOutputs(payments)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	total := 0
+	for _, payment := range payments {
+		total += payment.Amount
+	}
+	fmt.Printf("Paying %d to %d addresses\n", total, len(payments))
+
+	if cli.isWatchOnly(from, nodeId) {
+		log.Panicf("%s is watch-only, build the transaction with createrawtx -in -out instead", from)
+	}
+
+	if cli.client != nil {
+		cli.remoteSendMany(from, payments, opts, mineNow)
+		return
+	}
+
+	cli.sendOutputs(from, outputs, opts, nodeId, mineNow)
+}
+
+// readPayments reads address/amount pairs from a JSON array of objects, a
+// JSON object mapping addresses to amounts, or CSV lines of address,amount
+// with an optional header.
+func readPayments(file string) ([]rpc.Payment, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%s has no payments", file)
+	}
+
+	switch data[0] {
+	case '[':
+		var payments []rpc.Payment
+		if err := json.Unmarshal(data, &payments); err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+		return payments, nil
+	case '{':
+		var amounts map[string]int
+		if err := json.Unmarshal(data, &amounts); err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+
+		var payments []rpc.Payment
+		for address, amount := range amounts {
+			payments = append(payments, rpc.Payment{Address: address, Amount: amount})
+		}
+		sort.Slice(payments, func(i, j int) bool {
+			return payments[i].Address < payments[j].Address
+		})
+		return payments, nil
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+
+	var payments []rpc.Payment
+	for i, record := range records {
+		amount, err := strconv.Atoi(strings.TrimSpace(record[1]))
+		if err != nil {
+			if i == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("%s line %d: amount %q is not a number", file, i+1, record[1])
+		}
+
+		payments = append(payments, rpc.Payment{Address: strings.TrimSpace(record[0]), Amount: amount})
+	}
+
+	return payments, nil
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gitferry/blockchain-go/rpc"
+)
+
+func TestReadPayments(t *testing.T) {
+	want := []rpc.Payment{{Address: "a", Amount: 5}, {Address: "b", Amount: 7}}
+
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"json array", `[{"address":"a","amount":5},{"address":"b","amount":7}]`, ""},
+		{"json object", `{"b":7,"a":5}`, ""},
+		{"csv", "a,5\nb,7\n", ""},
+		{"csv with header and spaces", "address, amount\n a , 5\nb, 7\n", ""},
+		{"empty", " \n", "no payments"},
+		{"bad amount", "address,amount\na,5\nb,seven\n", "line 3"},
+		{"missing amount", "a,5\nb\n", "wrong number of fields"},
+		{"bad json", `[{"address":"a","amount":"5"}]`, "cannot unmarshal"},
+	}
+
+	dir := t.TempDir()
+	for i, test := range tests {
+		file := filepath.Join(dir, strings.Repeat("p", i+1))
+		if err := ioutil.WriteFile(file, []byte(test.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		payments, err := readPayments(file)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %s", test.name, err)
+		case test.err == "" && !reflect.DeepEqual(payments, want):
+			t.Errorf("%s: got %+v, want %+v", test.name, payments, want)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: got %v, want an error containing %q", test.name, err, test.err)
+		}
+	}
+
+	if _, err := readPayments(filepath.Join(dir, "missing")); err == nil {
+		t.Error("read a missing file")
+	}
+}
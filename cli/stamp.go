@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/rpc"
+)
+
+// Timestamp commits the SHA-256 hash of a file to the chain. Only the hash
+// leaves the machine.
+func (cli *CommandLine) Timestamp(from, file string, opts rpc.SendOptions, nodeId string, mineNow bool) {
+	hash := hashFile(file)
+
+	if cli.client != nil {
+		var txID string
+		cli.call("timestamp", &txID, from, hex.EncodeToString(hash), opts)
+		fmt.Printf("Timestamped %s (%x) in transaction %s\n", file, hash, txID)
+
+		cli.remoteMine(from, mineNow)
+		return
+	}
+
+	chain := blockchain.ContinueBlockchain(nodeId)
+	defer chain.Database.Close()
+	UTXOSet := pendingUTXOSet(chain, nodeId)
+
+	sendOpts, err := opts.Build()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	tx, err := rpc.NewStamp(loadWallets(nodeId, true), from, hash, &UTXOSet, sendOpts)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	submitTx(chain, tx, from, nodeId, mineNow)
+	fmt.Printf("Timestamped %s (%x) in transaction %x\n", file, hash, tx.ID)
+}
+
+// VerifyStamp proves that a file was timestamped, printing the block it was
+// mined in and the Merkle proof of the stamping transaction.
+func (cli *CommandLine) VerifyStamp(file, nodeId string) {
+	hash := hashFile(file)
+
+	var stamp rpc.StampResult
+
+	if cli.client != nil {
+		cli.call("verifystamp", &stamp, hex.EncodeToString(hash))
+	} else {
+		chain := blockchain.ContinueBlockchain(nodeId)
+		defer chain.Database.Close()
+
+		var err error
+		if stamp, err = rpc.FindStamp(chain, hash); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	fmt.Printf("File: %s\n", file)
+	fmt.Printf("SHA-256: %s\n", stamp.Hash)
+	fmt.Printf("Transaction: %s output %d\n", stamp.TxID, stamp.Out)
+	fmt.Printf("Block: %s\n", stamp.BlockHash)
+	fmt.Printf("Height: %d (%d confirmations)\n", stamp.Height, stamp.Confirmations)
+	fmt.Printf("Time: %s\n", time.Unix(stamp.Time, 0).Format(time.RFC3339))
+	fmt.Printf("Merkle root: %s\n", stamp.MerkleRoot)
+	fmt.Printf("Merkle proof of transaction %d:\n", stamp.Index)
+	for _, hash := range stamp.Proof {
+		fmt.Printf("  %s\n", hash)
+	}
+	fmt.Printf("Verified: %t\n", stamp.Verified)
+}
+
+func hashFile(file string) []byte {
+	f, err := os.Open(file)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		log.Panic(err)
+	}
+
+	return hash.Sum(nil)
+}
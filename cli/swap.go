@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/rpc"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// InitiateSwap creates a new secret and locks amount to the other party
+// behind its hash. The secret is printed and must be kept until the other
+// party has locked its side of the swap.
+func (cli *CommandLine) InitiateSwap(from, to string, amount int, timeout time.Duration, opts rpc.SendOptions, nodeId string, mineNow bool) {
+	if timeout == 0 {
+		timeout = rpc.InitiatorTimeout
+	}
+
+	var contract rpc.SwapContract
+
+	if cli.client != nil {
+		cli.call("initiateswap", &contract, from, to, amount, int64(timeout/time.Second), opts)
+		cli.remoteMine(from, mineNow)
+	} else {
+		secret, secretHash, err := rpc.NewSecret()
+		blockchain.HandleErr(err)
+
+		contract = cli.newSwap(from, to, amount, secretHash, timeout, opts, nodeId, mineNow)
+		contract.Secret = hex.EncodeToString(secret)
+	}
+
+	printSwapContract(contract)
+}
+
+// ParticipateSwap locks amount to the initiator behind the secret hash of
+// the initiator's contract, for a shorter time than the initiator did.
+func (cli *CommandLine) ParticipateSwap(from, to string, amount int, secretHash string, timeout time.Duration, opts rpc.SendOptions, nodeId string, mineNow bool) {
+	if timeout == 0 {
+		timeout = rpc.ParticipantTimeout
+	}
+
+	hash, err := hex.DecodeString(secretHash)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	var contract rpc.SwapContract
+
+	if cli.client != nil {
+		cli.call("participateswap", &contract, from, to, amount, secretHash, int64(timeout/time.Second), opts)
+		cli.remoteMine(from, mineNow)
+	} else {
+		contract = cli.newSwap(from, to, amount, hash, timeout, opts, nodeId, mineNow)
+	}
+
+	printSwapContract(contract)
+}
+
+func (cli *CommandLine) newSwap(from, to string, amount int, secretHash []byte, timeout time.Duration, opts rpc.SendOptions, nodeId string, mineNow bool) rpc.SwapContract {
+	chain := blockchain.ContinueBlockchain(nodeId)
+	defer chain.Database.Close()
+	UTXOSet := pendingUTXOSet(chain, nodeId)
+
+	wallets := loadWallets(nodeId, true)
+
+	sendOpts, err := opts.Build()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	tx, contract, err := rpc.NewSwapContract(wallets, from, to, amount, secretHash, timeout, &UTXOSet, sendOpts)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	submitTx(chain, tx, from, nodeId, mineNow)
+
+	return contract
+}
+
+// RedeemSwap claims the contract of txID with its secret, revealing the
+// secret to the other party.
+func (cli *CommandLine) RedeemSwap(txID, secret, nodeId string, mineNow bool) {
+	if cli.client != nil {
+		audit := cli.remoteSpendSwap("redeemswap", txID, secret)
+		cli.remoteMine(audit.Recipient, mineNow)
+		return
+	}
+
+	preimage, err := hex.DecodeString(secret)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	cli.spendSwap(txID, nodeId, mineNow, func(wallets *wallet.Wallets, contractTx *blockchain.Transaction) (*blockchain.Transaction, error) {
+		return rpc.RedeemSwap(wallets, contractTx, preimage)
+	})
+}
+
+// RefundSwap takes back the funds of the contract of txID once its lock
+// time has passed.
+func (cli *CommandLine) RefundSwap(txID, nodeId string, mineNow bool) {
+	if cli.client != nil {
+		audit := cli.remoteSpendSwap("refundswap", txID)
+		cli.remoteMine(audit.Refund, mineNow)
+		return
+	}
+
+	cli.spendSwap(txID, nodeId, mineNow, rpc.RefundSwap)
+}
+
+func (cli *CommandLine) spendSwap(txID, nodeId string, mineNow bool, spend func(*wallet.Wallets, *blockchain.Transaction) (*blockchain.Transaction, error)) {
+	id, err := hex.DecodeString(txID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	chain := blockchain.ContinueBlockchain(nodeId)
+	defer chain.Database.Close()
+
+	contractTx, err := chain.FindTx(id)
+	if err != nil {
+		log.Panicf("Contract transaction %s is not confirmed", txID)
+	}
+
+	tx, err := spend(loadWallets(nodeId, true), &contractTx)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if err := chain.CheckLockTimes(tx, chain.GetBestHeight()+1, chain.LastHash); err != nil {
+		log.Panicf("The contract cannot be spent yet: %s", err)
+	}
+
+	submitTx(chain, tx, tx.Outputs[0].Address(), nodeId, mineNow)
+	fmt.Printf("Spent the contract in transaction %x\n", tx.ID)
+}
+
+func (cli *CommandLine) remoteSpendSwap(method, txID string, params ...interface{}) rpc.SwapAudit {
+	var audit rpc.SwapAudit
+	cli.call("auditswap", &audit, txID)
+
+	var spendTxID string
+	cli.call(method, &spendTxID, append([]interface{}{txID}, params...)...)
+	fmt.Printf("Spent the contract in transaction %s\n", spendTxID)
+
+	return audit
+}
+
+// AuditSwap prints a contract and whether it has been redeemed, with the
+// secret that was revealed, or refunded.
+func (cli *CommandLine) AuditSwap(txID, nodeId string) {
+	var audit rpc.SwapAudit
+
+	if cli.client != nil {
+		cli.call("auditswap", &audit, txID)
+	} else {
+		id, err := hex.DecodeString(txID)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		chain := blockchain.ContinueBlockchain(nodeId)
+		defer chain.Database.Close()
+
+		if audit, err = rpc.AuditSwap(chain, id, nil); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	printSwapContract(audit.SwapContract)
+	fmt.Printf("Confirmed: %t\n", audit.Confirmed)
+	fmt.Printf("Status: %s\n", audit.Status)
+	if audit.SpendTxID != "" {
+		fmt.Printf("Spent by: %s\n", audit.SpendTxID)
+	}
+}
+
+func (cli *CommandLine) remoteMine(rewardAddress string, mineNow bool) {
+	if mineNow {
+		var blockHash string
+		cli.call("generate", &blockHash, rewardAddress)
+		fmt.Printf("Mined block %s\n", blockHash)
+	}
+}
+
+func printSwapContract(contract rpc.SwapContract) {
+	fmt.Printf("Contract transaction: %s\n", contract.TxID)
+	fmt.Printf("Contract output: %d\n", contract.Out)
+	fmt.Printf("Amount: %d\n", contract.Amount)
+	fmt.Printf("Recipient: %s\n", contract.Recipient)
+	fmt.Printf("Refund: %s\n", contract.Refund)
+	fmt.Printf("Lock time: %d (%s)\n", contract.LockTime, time.Unix(contract.LockTime, 0).Format(time.RFC3339))
+	fmt.Printf("Secret hash: %s\n", contract.SecretHash)
+	if contract.Secret != "" {
+		fmt.Printf("Secret: %s\n", contract.Secret)
+	}
+}
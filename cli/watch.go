@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/rpc"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+func (cli *CommandLine) ImportAddress(address, nodeId string) {
+	if !wallet.ValidateAddress(address) {
+		log.Panic("Address is not valid")
+	}
+
+	if cli.client != nil {
+		cli.call("importaddress", nil, address)
+	} else {
+		wallets := loadWallets(nodeId, false)
+		if err := wallets.ImportAddress(address); err != nil {
+			log.Panic(err)
+		}
+		wallets.SaveFile(nodeId)
+	}
+
+	fmt.Printf("Watching %s\n", address)
+}
+
+func (cli *CommandLine) ImportPubKey(pubKeyHex, nodeId string) {
+	pubKey, err := hex.DecodeString(pubKeyHex)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	var address string
+
+	if cli.client != nil {
+		cli.call("importpubkey", &address, pubKeyHex)
+	} else {
+		wallets := loadWallets(nodeId, false)
+		if address, err = wallets.ImportPublicKey(pubKey); err != nil {
+			log.Panic(err)
+		}
+		wallets.SaveFile(nodeId)
+	}
+
+	fmt.Printf("Watching %s\n", address)
+}
+
+func (cli *CommandLine) GetAddressInfo(address, nodeId string) {
+	if !wallet.ValidateAddress(address) {
+		log.Panic("Address is not valid")
+	}
+
+	var info rpc.AddressInfo
+
+	if cli.client != nil {
+		cli.call("getaddressinfo", &info, address)
+	} else {
+		wallets := loadWallets(nodeId, false)
+		_, info.IsMine = wallets.Wallets[address]
+		info.IsWatchOnly = wallets.IsWatchOnly(address)
+		info.HDPath = wallets.Paths[address]
+		if pubKey, ok := wallets.PublicKey(address); ok {
+			info.PubKey = hex.EncodeToString(pubKey)
+		}
+		if script, ok := wallets.RedeemScript(address); ok {
+			info.Script = hex.EncodeToString(script)
+		}
+	}
+
+	fmt.Printf("Address: %s\n", address)
+	fmt.Printf("Public key: %s\n", info.PubKey)
+	fmt.Printf("Mine: %t\n", info.IsMine)
+	fmt.Printf("Watch-only: %t\n", info.IsWatchOnly)
+	if info.HDPath != "" {
+		fmt.Printf("HD path: %s\n", info.HDPath)
+	}
+	if info.Script != "" {
+		script, _ := hex.DecodeString(info.Script)
+		asm, _ := blockchain.DisasmScript(script)
+		fmt.Printf("Redeem script: %s\n", asm)
+	}
+}
+
+// AddMultiSigAddress adds an address requiring m signatures of keys to the
+// wallet. Every cosigner adds the same keys in the same order to get the
+// same address.
+func (cli *CommandLine) AddMultiSigAddress(m int, keys []string, nodeId string) {
+	var result rpc.MultiSigResult
+	var err error
+
+	if cli.client != nil {
+		cli.call("addmultisigaddress", &result, m, keys)
+	} else {
+		wallets := loadWallets(nodeId, false)
+		if result, err = rpc.AddMultiSigAddress(wallets, m, keys); err != nil {
+			log.Panic(err)
+		}
+		wallets.SaveFile(nodeId)
+	}
+
+	fmt.Printf("Multisig address: %s\n", result.Address)
+	fmt.Printf("Redeem script: %s\n", result.RedeemScript)
+}
+
+func (cli *CommandLine) GetWalletBalance(asset, nodeId string) {
+	var balance rpc.WalletBalance
+
+	if cli.client != nil {
+		cli.call("getwalletbalance", &balance, asset)
+	} else {
+		wallets := loadWallets(nodeId, false)
+		chain := blockchain.ContinueBlockchain(nodeId)
+		defer chain.Database.Close()
+		UTXOSet := pendingUTXOSet(chain, nodeId)
+
+		assetID, err := rpc.FindAssetID(chain, asset)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		balance = rpc.WalletBalanceOf(wallets, &UTXOSet, assetID)
+	}
+
+	fmt.Printf("Balance of the wallet is: %d%s\n", balance.Mine, assetSuffix(asset))
+	fmt.Printf("Watch-only balance is: %d%s\n", balance.WatchOnly, assetSuffix(asset))
+	printPending(balance.Pending, asset)
+}
+
+func (cli *CommandLine) ListTransactions(watchOnly bool, nodeId string) {
+	var txs []rpc.WalletTxResult
+
+	if cli.client != nil {
+		cli.call("listtransactions", &txs, watchOnly)
+	} else {
+		wallets := loadWallets(nodeId, false)
+		chain := blockchain.ContinueBlockchain(nodeId)
+		defer chain.Database.Close()
+
+		mine := wallets.PubKeyHashes(false)
+		for _, wtx := range chain.FindWalletTxs(wallets.PubKeyHashes(watchOnly)) {
+			result := rpc.WalletTxResult{
+				TxID:   hex.EncodeToString(wtx.Tx.ID),
+				Height: wtx.Block.Height,
+				Amount: wtx.Received - wtx.Sent,
+			}
+
+			for pubKeyHash := range wtx.Matched {
+				if !mine[pubKeyHash] {
+					result.InvolvesWatchOnly = true
+				}
+			}
+
+			txs = append(txs, result)
+		}
+	}
+
+	for _, tx := range txs {
+		watch := ""
+		if tx.InvolvesWatchOnly {
+			watch = " (watch-only)"
+		}
+
+		fmt.Printf("%d %s %+d%s\n", tx.Height, tx.TxID, tx.Amount, watch)
+	}
+}
+
+// sendWatchOnly prints an unsigned transaction spending from a watch-only
+// address, to be signed where its private key lives.
+func (cli *CommandLine) sendWatchOnly(from, to string, amount int, opts rpc.SendOptions, nodeId string) {
+	psbt := cli.createRawTx(from, to, amount, opts, nodeId)
+
+	fmt.Printf("%s is watch-only, sign this transaction with signrawtx where its key is kept:\n%s\n", from, psbt)
+}
+
+func (cli *CommandLine) isWatchOnly(address, nodeId string) bool {
+	if cli.client != nil {
+		var info rpc.AddressInfo
+		cli.call("getaddressinfo", &info, address)
+
+		return info.IsWatchOnly
+	}
+
+	return loadWallets(nodeId, false).IsWatchOnly(address)
+}
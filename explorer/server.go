@@ -0,0 +1,324 @@
+package explorer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/network"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+const recentBlocksLimit = 20
+
+type Backend interface {
+	View(fn func(chain *blockchain.BlockChain))
+	MempoolTxs() []blockchain.Transaction
+}
+
+type chainBackend struct {
+	mu    sync.Mutex
+	chain *blockchain.BlockChain
+}
+
+func NewChainBackend(chain *blockchain.BlockChain) Backend {
+	return &chainBackend{chain: chain}
+}
+
+func (b *chainBackend) View(fn func(chain *blockchain.BlockChain)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	fn(b.chain)
+}
+
+func (b *chainBackend) MempoolTxs() []blockchain.Transaction {
+	return nil
+}
+
+type Server struct {
+	Address string
+
+	backend    Backend
+	httpServer *http.Server
+	mux        *http.ServeMux
+}
+
+func NewServer(address string) *Server {
+	s := &Server{Address: address, mux: http.NewServeMux()}
+
+	s.mux.HandleFunc("/blocks", s.handleBlocks)
+	s.mux.HandleFunc("/blocks/", s.handleBlock)
+	s.mux.HandleFunc("/tx/", s.handleTx)
+	s.mux.HandleFunc("/address/", s.handleAddress)
+	s.mux.HandleFunc("/mempool", s.handleMempool)
+	s.mux.HandleFunc("/explorer/", s.handlePage)
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, "/explorer/", http.StatusFound)
+	})
+
+	return s
+}
+
+func (s *Server) Start(node *network.Node) error {
+	return s.Serve(node)
+}
+
+func (s *Server) Serve(backend Backend) error {
+	s.backend = backend
+
+	ln, err := net.Listen("tcp", s.Address)
+	if err != nil {
+		return err
+	}
+
+	s.httpServer = &http.Server{Handler: s.mux}
+	go func() {
+		if err := s.httpServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Println(err)
+		}
+	}()
+
+	fmt.Printf("Block explorer listening on http://%s/\n", s.Address)
+
+	return nil
+}
+
+func (s *Server) Stop() error {
+	if s.httpServer == nil {
+		return nil
+	}
+
+	return s.httpServer.Close()
+}
+
+func (s *Server) view(fn func(chain *blockchain.BlockChain) (interface{}, error)) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = nil
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	s.backend.View(func(chain *blockchain.BlockChain) {
+		result, err = fn(chain)
+	})
+
+	return result, err
+}
+
+func writeJSON(w http.ResponseWriter, result interface{}, err error) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		result = map[string]string{"error": err.Error()}
+	}
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Println(err)
+	}
+}
+
+func (s *Server) handleBlocks(w http.ResponseWriter, r *http.Request) {
+	limit := recentBlocksLimit
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+
+	result, err := s.view(func(chain *blockchain.BlockChain) (interface{}, error) {
+		return recentBlocks(chain, limit), nil
+	})
+
+	writeJSON(w, result, err)
+}
+
+func (s *Server) handleBlock(w http.ResponseWriter, r *http.Request) {
+	result, err := s.view(func(chain *blockchain.BlockChain) (interface{}, error) {
+		return lookupBlock(chain, strings.TrimPrefix(r.URL.Path, "/blocks/"))
+	})
+
+	writeJSON(w, result, err)
+}
+
+func (s *Server) handleTx(w http.ResponseWriter, r *http.Request) {
+	pool := s.backend.MempoolTxs()
+
+	result, err := s.view(func(chain *blockchain.BlockChain) (interface{}, error) {
+		return lookupTx(chain, pool, strings.TrimPrefix(r.URL.Path, "/tx/"))
+	})
+
+	writeJSON(w, result, err)
+}
+
+func (s *Server) handleAddress(w http.ResponseWriter, r *http.Request) {
+	result, err := s.view(func(chain *blockchain.BlockChain) (interface{}, error) {
+		return lookupAddress(chain, strings.TrimPrefix(r.URL.Path, "/address/"))
+	})
+
+	writeJSON(w, result, err)
+}
+
+func (s *Server) handleMempool(w http.ResponseWriter, r *http.Request) {
+	pool := s.backend.MempoolTxs()
+
+	result, err := s.view(func(chain *blockchain.BlockChain) (interface{}, error) {
+		return mempoolViews(chain, pool), nil
+	})
+
+	writeJSON(w, result, err)
+}
+
+func (s *Server) handlePage(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/explorer/"), "/", 2)
+	page, arg := parts[0], ""
+	if len(parts) > 1 {
+		arg = parts[1]
+	}
+
+	if page == "search" {
+		target, _ := s.view(func(chain *blockchain.BlockChain) (interface{}, error) {
+			return searchTarget(chain, r.URL.Query().Get("q")), nil
+		})
+		if target == nil {
+			target = "/explorer/"
+		}
+
+		http.Redirect(w, r, target.(string), http.StatusFound)
+		return
+	}
+
+	var tmpl *template.Template
+	pool := s.backend.MempoolTxs()
+
+	result, err := s.view(func(chain *blockchain.BlockChain) (interface{}, error) {
+		switch page {
+		case "":
+			tmpl = indexTemplate
+			return struct {
+				Blocks  []BlockView
+				Mempool []TxView
+			}{recentBlocks(chain, recentBlocksLimit), mempoolViews(chain, pool)}, nil
+		case "block":
+			tmpl = blockTemplate
+			return lookupBlock(chain, arg)
+		case "tx":
+			tmpl = txTemplate
+			return lookupTx(chain, pool, arg)
+		case "address":
+			tmpl = addressTemplate
+			return lookupAddress(chain, arg)
+		}
+
+		return nil, fmt.Errorf("page %s not found", page)
+	})
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		tmpl, result = errorTemplate, err.Error()
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout", result); err != nil {
+		log.Println(err)
+	}
+}
+
+func searchTarget(chain *blockchain.BlockChain, query string) string {
+	query = strings.TrimSpace(query)
+
+	if _, err := strconv.Atoi(query); err == nil {
+		return "/explorer/block/height/" + query
+	}
+
+	if wallet.ValidateAddress(query) {
+		return "/explorer/address/" + query
+	}
+
+	if hash, err := hex.DecodeString(query); err == nil {
+		if _, err := chain.GetBlock(hash); err == nil {
+			return "/explorer/block/" + query
+		}
+	}
+
+	return "/explorer/tx/" + query
+}
+
+func lookupBlock(chain *blockchain.BlockChain, arg string) (BlockView, error) {
+	if strings.HasPrefix(arg, "height/") {
+		height, err := strconv.Atoi(strings.TrimPrefix(arg, "height/"))
+		if err != nil {
+			return BlockView{}, err
+		}
+
+		block, err := blockByHeight(chain, height)
+		if err != nil {
+			return BlockView{}, err
+		}
+
+		return newBlockView(chain, block, true), nil
+	}
+
+	hash, err := hex.DecodeString(arg)
+	if err != nil {
+		return BlockView{}, err
+	}
+
+	block, err := chain.GetBlock(hash)
+	if err != nil {
+		return BlockView{}, err
+	}
+
+	return newBlockView(chain, &block, true), nil
+}
+
+func lookupTx(chain *blockchain.BlockChain, pool []blockchain.Transaction, arg string) (TxView, error) {
+	id, err := hex.DecodeString(arg)
+	if err != nil {
+		return TxView{}, err
+	}
+
+	for _, tx := range pool {
+		if hex.EncodeToString(tx.ID) == arg {
+			return newTxView(chain, &tx, nil), nil
+		}
+	}
+
+	tx, block, err := findTx(chain, id)
+	if err != nil {
+		return TxView{}, err
+	}
+
+	return newTxView(chain, tx, block), nil
+}
+
+func lookupAddress(chain *blockchain.BlockChain, address string) (AddressView, error) {
+	if !wallet.ValidateAddress(address) {
+		return AddressView{}, fmt.Errorf("invalid address %s", address)
+	}
+
+	return newAddressView(chain, address), nil
+}
+
+func mempoolViews(chain *blockchain.BlockChain, pool []blockchain.Transaction) []TxView {
+	views := []TxView{}
+
+	for _, tx := range pool {
+		views = append(views, newTxView(chain, &tx, nil))
+	}
+
+	return views
+}
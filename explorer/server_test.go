@@ -0,0 +1,188 @@
+package explorer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+type testBackend struct {
+	Backend
+	pool []blockchain.Transaction
+}
+
+func (b testBackend) MempoolTxs() []blockchain.Transaction {
+	return b.pool
+}
+
+// testExplorer serves a chain in which the genesis reward of payer paid 5
+// to payee in block 1, with a payment of 2 back from payee in the pool.
+func testExplorer(t *testing.T) (*httptest.Server, *wallet.Wallet, *wallet.Wallet, *blockchain.Transaction, *blockchain.Transaction) {
+	payer, payee := wallet.MakeWallet(), wallet.MakeWallet()
+	payerAddress, payeeAddress := string(payer.Address()), string(payee.Address())
+
+	chain := blockchain.InitBlockchainAt(t.TempDir(), payerAddress)
+	t.Cleanup(func() { chain.Database.Close() })
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+	UTXOSet.Reindex()
+
+	payment, err := blockchain.NewTransactionWith(payer, []blockchain.TxOutput{*blockchain.NewTXOutput(5, payeeAddress)}, &UTXOSet, blockchain.DefaultSendOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fee, err := chain.Fee(payment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain.MineBlock([]*blockchain.Transaction{payment, blockchain.CoinBaseTx(payerAddress, "", fee)})
+	UTXOSet.Reindex()
+
+	refund, err := blockchain.NewTransactionWith(payee, []blockchain.TxOutput{*blockchain.NewTXOutput(2, payerAddress)}, &UTXOSet, blockchain.SendOptions{Selector: blockchain.BranchAndBound{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer("")
+	s.backend = testBackend{NewChainBackend(chain), []blockchain.Transaction{*refund}}
+
+	httpServer := httptest.NewServer(s.mux)
+	t.Cleanup(httpServer.Close)
+
+	return httpServer, payer, payee, payment, refund
+}
+
+func get(t *testing.T, httpServer *httptest.Server, path string, status int, result interface{}) string {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	resp, err := client.Get(httpServer.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != status {
+		t.Fatalf("GET %s: got status %d, want %d: %s", path, resp.StatusCode, status, body)
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(body, result); err != nil {
+			t.Fatalf("GET %s: %s", path, err)
+		}
+	}
+
+	if location := resp.Header.Get("Location"); location != "" {
+		return location
+	}
+
+	return string(body)
+}
+
+func TestBlocksAPI(t *testing.T) {
+	httpServer, payer, payee, payment, _ := testExplorer(t)
+
+	var blocks []BlockView
+	get(t, httpServer, "/blocks", http.StatusOK, &blocks)
+	if len(blocks) != 2 || blocks[0].Height != 1 || blocks[0].PrevHash != blocks[1].Hash {
+		t.Fatalf("got blocks %+v", blocks)
+	}
+
+	var block BlockView
+	get(t, httpServer, "/blocks/height/1", http.StatusOK, &block)
+	if block.Hash != blocks[0].Hash || len(block.Transactions) != 2 {
+		t.Fatalf("got block %+v at height 1", block)
+	}
+
+	get(t, httpServer, "/blocks/"+blocks[1].Hash, http.StatusOK, &block)
+	genesisTx := block.Transactions[0]
+	if !genesisTx.Coinbase || !genesisTx.Outputs[0].Spent {
+		t.Fatalf("the genesis coinbase %+v is not shown spent", genesisTx)
+	}
+
+	var tx TxView
+	get(t, httpServer, "/tx/"+hex.EncodeToString(payment.ID), http.StatusOK, &tx)
+	in := tx.Inputs[0]
+	if tx.Height != 1 || in.TxID != genesisTx.ID || in.Address != string(payer.Address()) || in.Value != blockchain.Subsidy {
+		t.Fatalf("got transaction %+v", tx)
+	}
+	if out := tx.Outputs[0]; out.Address != string(payee.Address()) || out.Value != 5 || out.Spent {
+		t.Fatalf("got output %+v", out)
+	}
+
+	var failure map[string]string
+	get(t, httpServer, "/blocks/height/7", http.StatusNotFound, &failure)
+	get(t, httpServer, "/blocks/nothex", http.StatusNotFound, &failure)
+	get(t, httpServer, "/tx/00", http.StatusNotFound, &failure)
+	if failure["error"] == "" {
+		t.Fatal("a missing transaction got no error")
+	}
+}
+
+func TestAddressAndMempoolAPI(t *testing.T) {
+	httpServer, _, payee, payment, refund := testExplorer(t)
+
+	var address AddressView
+	get(t, httpServer, "/address/"+string(payee.Address()), http.StatusOK, &address)
+	if address.Balance != 5 || len(address.Unspent) != 1 || len(address.Transactions) != 1 || address.Transactions[0].ID != hex.EncodeToString(payment.ID) {
+		t.Fatalf("got address %+v", address)
+	}
+
+	var failure map[string]string
+	get(t, httpServer, "/address/bogus", http.StatusNotFound, &failure)
+
+	var pool []TxView
+	get(t, httpServer, "/mempool", http.StatusOK, &pool)
+	if len(pool) != 1 || pool[0].ID != hex.EncodeToString(refund.ID) || pool[0].Height != -1 || pool[0].BlockHash != "" {
+		t.Fatalf("got memory pool %+v", pool)
+	}
+
+	var tx TxView
+	get(t, httpServer, "/tx/"+hex.EncodeToString(refund.ID), http.StatusOK, &tx)
+	if tx.Height != -1 || tx.Inputs[0].Value != 5 {
+		t.Fatalf("got pool transaction %+v", tx)
+	}
+}
+
+func TestExplorerPages(t *testing.T) {
+	httpServer, _, payee, payment, _ := testExplorer(t)
+	payeeAddress := string(payee.Address())
+
+	if page := get(t, httpServer, "/explorer/", http.StatusOK, nil); !strings.Contains(page, "</html>") {
+		t.Fatalf("the index page is not HTML: %s", page)
+	}
+	if page := get(t, httpServer, "/explorer/tx/"+hex.EncodeToString(payment.ID), http.StatusOK, nil); !strings.Contains(page, payeeAddress) {
+		t.Fatal("the transaction page does not show the payee")
+	}
+	get(t, httpServer, "/explorer/nosuchpage", http.StatusNotFound, nil)
+
+	tests := []struct {
+		query, target string
+	}{
+		{"1", "/explorer/block/height/1"},
+		{payeeAddress, "/explorer/address/" + payeeAddress},
+		{hex.EncodeToString(payment.ID), "/explorer/tx/" + hex.EncodeToString(payment.ID)},
+	}
+	for _, test := range tests {
+		if target := get(t, httpServer, "/explorer/search?q="+test.query, http.StatusFound, nil); target != test.target {
+			t.Errorf("searching %s leads to %s, want %s", test.query, target, test.target)
+		}
+	}
+
+	if target := get(t, httpServer, "/", http.StatusFound, nil); target != "/explorer/" {
+		t.Errorf("the root leads to %s", target)
+	}
+}
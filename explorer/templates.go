@@ -0,0 +1,120 @@
+package explorer
+
+import "html/template"
+
+const layout = `{{define "layout"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>blockchain-go explorer</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 64em; color: #222; }
+a { color: #0645ad; text-decoration: none; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5em; }
+th, td { text-align: left; padding: 0.3em 0.6em; border-bottom: 1px solid #ddd; }
+code { font-size: 0.9em; word-break: break-all; }
+.spent { color: #999; }
+form { margin-bottom: 1.5em; }
+input[type=text] { width: 40em; }
+</style>
+</head>
+<body>
+<h1><a href="/explorer/">blockchain-go explorer</a></h1>
+<form action="/explorer/search"><input type="text" name="q" placeholder="block height, block hash, transaction id or address"> <input type="submit" value="Search"></form>
+{{template "content" .}}
+</body>
+</html>{{end}}
+
+{{define "txtable"}}
+<table>
+<tr><th>Inputs</th><th>Outputs</th></tr>
+<tr>
+<td>
+{{if .Coinbase}}Coinbase{{else}}{{range .Inputs}}
+<div>{{if .Address}}<a href="/explorer/address/{{.Address}}">{{.Address}}</a>{{else}}Script{{end}} {{.Value}}<br><small><a href="/explorer/tx/{{.TxID}}"><code>{{.TxID}}</code></a>:{{.Out}}</small></div>
+{{end}}{{end}}
+</td>
+<td>
+{{range .Outputs}}
+<div{{if .Spent}} class="spent"{{end}}>#{{.Index}} {{if .Address}}<a href="/explorer/address/{{.Address}}">{{.Address}}</a>{{else}}<code>{{.Script}}</code>{{end}} {{.Value}}{{if .Spent}} (spent){{end}}</div>
+{{end}}
+</td>
+</tr>
+</table>
+{{end}}`
+
+const indexContent = `{{define "content"}}
+<h2>Latest blocks</h2>
+<table>
+<tr><th>Height</th><th>Hash</th><th>Time</th><th>Transactions</th></tr>
+{{range .Blocks}}
+<tr><td><a href="/explorer/block/{{.Hash}}">{{.Height}}</a></td><td><a href="/explorer/block/{{.Hash}}"><code>{{.Hash}}</code></a></td><td>{{.Time}}</td><td>{{.TxCount}}</td></tr>
+{{end}}
+</table>
+<h2>Memory pool</h2>
+{{if .Mempool}}
+<table>
+<tr><th>Transaction</th><th>Total output</th></tr>
+{{range .Mempool}}
+<tr><td><a href="/explorer/tx/{{.ID}}"><code>{{.ID}}</code></a></td><td>{{.Total}}</td></tr>
+{{end}}
+</table>
+{{else}}
+<p>No unconfirmed transactions.</p>
+{{end}}
+{{end}}`
+
+const blockContent = `{{define "content"}}
+<h2>Block {{.Height}}</h2>
+<table>
+<tr><th>Hash</th><td><code>{{.Hash}}</code></td></tr>
+<tr><th>Previous block</th><td>{{if .PrevHash}}<a href="/explorer/block/{{.PrevHash}}"><code>{{.PrevHash}}</code></a>{{else}}Genesis{{end}}</td></tr>
+<tr><th>Time</th><td>{{.Time}}</td></tr>
+<tr><th>Nonce</th><td>{{.Nonce}}</td></tr>
+<tr><th>Transactions</th><td>{{.TxCount}}</td></tr>
+</table>
+{{range .Transactions}}
+<h3>Transaction <a href="/explorer/tx/{{.ID}}"><code>{{.ID}}</code></a></h3>
+{{template "txtable" .}}
+{{end}}
+{{end}}`
+
+const txContent = `{{define "content"}}
+<h2>Transaction</h2>
+<table>
+<tr><th>ID</th><td><code>{{.ID}}</code></td></tr>
+<tr><th>Block</th><td>{{if .BlockHash}}<a href="/explorer/block/{{.BlockHash}}">{{.Height}}</a>{{else}}Unconfirmed{{end}}</td></tr>
+<tr><th>Total output</th><td>{{.Total}}</td></tr>
+</table>
+{{template "txtable" .}}
+{{end}}`
+
+const addressContent = `{{define "content"}}
+<h2>Address {{.Address}}</h2>
+<table>
+<tr><th>Balance</th><td>{{.Balance}}</td></tr>
+<tr><th>Unspent outputs</th><td>{{len .Unspent}}</td></tr>
+<tr><th>Transactions</th><td>{{len .Transactions}}</td></tr>
+</table>
+{{range .Transactions}}
+<h3>Transaction <a href="/explorer/tx/{{.ID}}"><code>{{.ID}}</code></a> in block <a href="/explorer/block/{{.BlockHash}}">{{.Height}}</a></h3>
+{{template "txtable" .}}
+{{end}}
+{{end}}`
+
+const errorContent = `{{define "content"}}
+<h2>Not found</h2>
+<p>{{.}}</p>
+{{end}}`
+
+var (
+	indexTemplate   = newTemplate(indexContent)
+	blockTemplate   = newTemplate(blockContent)
+	txTemplate      = newTemplate(txContent)
+	addressTemplate = newTemplate(addressContent)
+	errorTemplate   = newTemplate(errorContent)
+)
+
+func newTemplate(content string) *template.Template {
+	return template.Must(template.Must(template.New("layout").Parse(layout)).Parse(content))
+}
@@ -0,0 +1,246 @@
+package explorer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+type BlockView struct {
+	Hash         string   `json:"hash"`
+	PrevHash     string   `json:"previousblockhash"`
+	Height       int      `json:"height"`
+	Timestamp    int64    `json:"time"`
+	Nonce        int      `json:"nonce"`
+	TxCount      int      `json:"txcount"`
+	Transactions []TxView `json:"tx,omitempty"`
+}
+
+type TxView struct {
+	ID        string       `json:"txid"`
+	BlockHash string       `json:"blockhash,omitempty"`
+	Height    int          `json:"height"`
+	Coinbase  bool         `json:"coinbase"`
+	Inputs    []InputView  `json:"vin"`
+	Outputs   []OutputView `json:"vout"`
+}
+
+type InputView struct {
+	TxID    string `json:"txid,omitempty"`
+	Out     int    `json:"vout"`
+	Address string `json:"address,omitempty"`
+	Value   int    `json:"value"`
+}
+
+type OutputView struct {
+	Index   int    `json:"n"`
+	Address string `json:"address,omitempty"`
+	Script  string `json:"script,omitempty"`
+	Value   int    `json:"value"`
+	Asset   string `json:"asset,omitempty"`
+	Tokens  int    `json:"tokens,omitempty"`
+	Spent   bool   `json:"spent"`
+}
+
+type UnspentView struct {
+	TxID  string `json:"txid"`
+	Out   int    `json:"vout"`
+	Value int    `json:"value"`
+}
+
+type AddressView struct {
+	Address      string        `json:"address"`
+	Balance      int           `json:"balance"`
+	Unspent      []UnspentView `json:"unspent"`
+	Transactions []TxView      `json:"transactions"`
+}
+
+func (b BlockView) Time() string {
+	return time.Unix(b.Timestamp, 0).UTC().Format(time.RFC3339)
+}
+
+func (tx TxView) Total() int {
+	total := 0
+	for _, out := range tx.Outputs {
+		total += out.Value
+	}
+
+	return total
+}
+
+func newBlockView(chain *blockchain.BlockChain, block *blockchain.Block, withTxs bool) BlockView {
+	view := BlockView{
+		Hash:      hex.EncodeToString(block.Hash),
+		PrevHash:  hex.EncodeToString(block.PrevHash),
+		Height:    block.Height,
+		Timestamp: block.Timestamp,
+		Nonce:     block.Nonce,
+		TxCount:   len(block.Transactions),
+	}
+
+	if withTxs {
+		for _, tx := range block.Transactions {
+			view.Transactions = append(view.Transactions, newTxView(chain, tx, block))
+		}
+	}
+
+	return view
+}
+
+func newTxView(chain *blockchain.BlockChain, tx *blockchain.Transaction, block *blockchain.Block) TxView {
+	view := TxView{
+		ID:       hex.EncodeToString(tx.ID),
+		Height:   -1,
+		Coinbase: tx.IsCoinbase(),
+	}
+
+	if block != nil {
+		view.BlockHash = hex.EncodeToString(block.Hash)
+		view.Height = block.Height
+	}
+
+	if !tx.IsCoinbase() {
+		for _, in := range tx.Inputs {
+			input := InputView{TxID: hex.EncodeToString(in.ID), Out: in.Out}
+			if len(in.Script) == 0 {
+				input.Address = string(wallet.PubKeyHashToAddress(wallet.PublicKeyHash(in.PubKey)))
+			}
+
+			if prevTx, err := chain.FindTx(in.ID); err == nil && in.Out < len(prevTx.Outputs) {
+				input.Value = prevTx.Outputs[in.Out].Value
+			}
+
+			view.Inputs = append(view.Inputs, input)
+		}
+	}
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+
+	for idx, out := range tx.Outputs {
+		output := OutputView{
+			Index:   idx,
+			Address: out.Address(),
+			Value:   out.Value,
+			Asset:   hex.EncodeToString(out.Asset),
+			Tokens:  out.Tokens,
+		}
+
+		if len(out.Script) > 0 {
+			output.Script, _ = blockchain.DisasmScript(out.Script)
+		}
+
+		if block != nil {
+			_, unspent := UTXOSet.GetOutput(tx.ID, idx)
+			output.Spent = !unspent
+		}
+
+		view.Outputs = append(view.Outputs, output)
+	}
+
+	return view
+}
+
+func recentBlocks(chain *blockchain.BlockChain, limit int) []BlockView {
+	var views []BlockView
+
+	iter := chain.Iterator()
+
+	for len(views) < limit {
+		block := iter.Next()
+
+		views = append(views, newBlockView(chain, block, false))
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	return views
+}
+
+func blockByHeight(chain *blockchain.BlockChain, height int) (*blockchain.Block, error) {
+	iter := chain.Iterator()
+
+	for {
+		block := iter.Next()
+
+		if block.Height == height {
+			return block, nil
+		}
+
+		if len(block.PrevHash) == 0 || block.Height < height {
+			return nil, errors.New("Block is not found")
+		}
+	}
+}
+
+func findTx(chain *blockchain.BlockChain, id []byte) (*blockchain.Transaction, *blockchain.Block, error) {
+	iter := chain.Iterator()
+
+	for {
+		block := iter.Next()
+
+		for _, tx := range block.Transactions {
+			if bytes.Equal(tx.ID, id) {
+				return tx, block, nil
+			}
+		}
+
+		if len(block.PrevHash) == 0 {
+			return nil, nil, errors.New("Transaction does not exist")
+		}
+	}
+}
+
+func newAddressView(chain *blockchain.BlockChain, address string) AddressView {
+	pubKeyHash := wallet.AddressToPubKeyHash(address)
+	view := AddressView{Address: address, Unspent: []UnspentView{}, Transactions: []TxView{}}
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+	for _, utxo := range UTXOSet.ListUnspent(pubKeyHash) {
+		view.Balance += utxo.Output.Value
+		view.Unspent = append(view.Unspent, UnspentView{hex.EncodeToString(utxo.TxID), utxo.Out, utxo.Output.Value})
+	}
+
+	iter := chain.Iterator()
+
+	for {
+		block := iter.Next()
+
+		for _, tx := range block.Transactions {
+			if touchesAddress(tx, pubKeyHash) {
+				view.Transactions = append(view.Transactions, newTxView(chain, tx, block))
+			}
+		}
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	return view
+}
+
+func touchesAddress(tx *blockchain.Transaction, pubKeyHash []byte) bool {
+	for _, out := range tx.Outputs {
+		if bytes.Equal(out.PubKeyHash, pubKeyHash) {
+			return true
+		}
+	}
+
+	if tx.IsCoinbase() {
+		return false
+	}
+
+	for _, in := range tx.Inputs {
+		if in.UsesKey(pubKeyHash) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,221 @@
+package network
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+type EventType string
+
+const (
+	BlockConnected    EventType = "blockconnected"
+	BlockDisconnected EventType = "blockdisconnected"
+	TxAccepted        EventType = "txaccepted"
+	TxRemoved         EventType = "txremoved"
+	PeerConnected     EventType = "peerconnected"
+)
+
+type Event struct {
+	Type   EventType
+	Block  *blockchain.Block
+	Tx     *blockchain.Transaction
+	Peer   string
+	Reason string
+}
+
+type EventFilter struct {
+	Types     []EventType
+	Addresses []string
+}
+
+func (f EventFilter) Matches(e Event) bool {
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if t == e.Type {
+				found = true
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if len(f.Addresses) == 0 {
+		return true
+	}
+
+	var txs []*blockchain.Transaction
+	if e.Tx != nil {
+		txs = append(txs, e.Tx)
+	}
+	if e.Block != nil {
+		txs = append(txs, e.Block.Transactions...)
+	}
+
+	for _, address := range f.Addresses {
+		if !wallet.ValidateAddress(address) {
+			continue
+		}
+		pubKeyHash := wallet.AddressToPubKeyHash(address)
+
+		for _, tx := range txs {
+			if paysTo(tx, pubKeyHash) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func paysTo(tx *blockchain.Transaction, pubKeyHash []byte) bool {
+	for _, out := range tx.Outputs {
+		if bytes.Equal(out.PubKeyHash, pubKeyHash) {
+			return true
+		}
+	}
+
+	if tx.IsCoinbase() {
+		return false
+	}
+
+	for _, in := range tx.Inputs {
+		if in.UsesKey(pubKeyHash) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// maxQueuedEvents is how many events a subscriber may fall behind before it
+// is disconnected.
+const maxQueuedEvents = 1024
+
+type EventBus struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe returns a subscription whose channel receives every published
+// event matching filter. Events are queued per subscriber, so a slow reader
+// never blocks the node. A reader maxQueuedEvents behind is disconnected and
+// its channel closed.
+func (b *EventBus) Subscribe(filter EventFilter) *Subscription {
+	sub := &Subscription{
+		bus:    b,
+		filter: filter,
+		events: make(chan Event),
+		done:   make(chan struct{}),
+	}
+	sub.cond = sync.NewCond(&sub.mu)
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	go sub.deliver()
+
+	return sub
+}
+
+func (b *EventBus) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		if sub.filter.Matches(e) && !sub.push(e) {
+			delete(b.subs, sub)
+		}
+	}
+}
+
+type Subscription struct {
+	bus    *EventBus
+	filter EventFilter
+	events chan Event
+	done   chan struct{}
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []Event
+	closed bool
+}
+
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+func (s *Subscription) Unsubscribe() {
+	s.bus.mu.Lock()
+	delete(s.bus.subs, s)
+	s.bus.mu.Unlock()
+
+	s.mu.Lock()
+	s.close()
+	s.mu.Unlock()
+}
+
+// close stops the delivery of events. s.mu must be held.
+func (s *Subscription) close() {
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+		s.cond.Signal()
+	}
+}
+
+// push queues e, or closes the subscription and returns false if it is
+// closed or its queue is full.
+func (s *Subscription) push(e Event) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	if len(s.queue) >= maxQueuedEvents {
+		s.close()
+		return false
+	}
+
+	s.queue = append(s.queue, e)
+	s.cond.Signal()
+
+	return true
+}
+
+func (s *Subscription) deliver() {
+	defer close(s.events)
+
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+
+		e := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		select {
+		case s.events <- e:
+		case <-s.done:
+			return
+		}
+	}
+}
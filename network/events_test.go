@@ -0,0 +1,172 @@
+package network
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+func TestSlowSubscriberIsDisconnected(t *testing.T) {
+	bus := NewEventBus()
+	fast := bus.Subscribe(EventFilter{})
+	defer fast.Unsubscribe()
+	slow := bus.Subscribe(EventFilter{})
+
+	for i := 0; i < maxQueuedEvents+2; i++ {
+		bus.Publish(Event{Type: PeerConnected})
+		if _, ok := <-fast.Events(); !ok {
+			t.Fatalf("a subscriber reading every event was disconnected after %d", i)
+		}
+	}
+
+	received := 0
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-slow.Events():
+			if !ok {
+				if received > maxQueuedEvents+1 {
+					t.Fatalf("the slow subscriber received all %d events", received)
+				}
+				if _, subscribed := bus.subs[slow]; subscribed {
+					t.Fatal("the slow subscriber is still subscribed")
+				}
+				if _, subscribed := bus.subs[fast]; !subscribed {
+					t.Fatal("the fast subscriber was dropped")
+				}
+				return
+			}
+			received++
+		case <-timeout:
+			t.Fatal("the slow subscriber was not disconnected")
+		}
+	}
+}
+
+// nextEvent returns the next event of sub, failing if none arrives in time.
+func nextEvent(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+
+	select {
+	case e, ok := <-sub.Events():
+		if !ok {
+			t.Fatal("the subscription was closed")
+		}
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event was published")
+	}
+
+	return Event{}
+}
+
+func TestEventFilter(t *testing.T) {
+	payer, payee, other := wallet.MakeWallet(), wallet.MakeWallet(), wallet.MakeWallet()
+
+	payment := &blockchain.Transaction{
+		ID:      []byte{1},
+		Inputs:  []blockchain.TxInput{{ID: []byte{2}, Out: 0, PubKey: payer.PublicKey}},
+		Outputs: []blockchain.TxOutput{*blockchain.NewTXOutput(5, string(payee.Address()))},
+	}
+	reward := blockchain.CoinBaseTx(string(other.Address()), "reward", 0)
+	block := &blockchain.Block{Transactions: []*blockchain.Transaction{payment, reward}}
+
+	tests := []struct {
+		name   string
+		filter EventFilter
+		event  Event
+		match  bool
+	}{
+		{"everything", EventFilter{}, Event{Type: PeerConnected}, true},
+		{"type", EventFilter{Types: []EventType{TxAccepted, BlockConnected}}, Event{Type: BlockConnected}, true},
+		{"other type", EventFilter{Types: []EventType{TxAccepted}}, Event{Type: TxRemoved, Tx: payment}, false},
+		{"payee", EventFilter{Addresses: []string{string(payee.Address())}}, Event{Type: TxAccepted, Tx: payment}, true},
+		{"payer", EventFilter{Addresses: []string{string(payer.Address())}}, Event{Type: TxAccepted, Tx: payment}, true},
+		{"unrelated address", EventFilter{Addresses: []string{string(other.Address())}}, Event{Type: TxAccepted, Tx: payment}, false},
+		{"coinbase in a block", EventFilter{Addresses: []string{string(other.Address())}}, Event{Type: BlockConnected, Block: block}, true},
+		{"invalid address", EventFilter{Addresses: []string{"bogus"}}, Event{Type: TxAccepted, Tx: payment}, false},
+		{"peer with addresses", EventFilter{Addresses: []string{string(payee.Address())}}, Event{Type: PeerConnected, Peer: "localhost:3000"}, false},
+		{"type and address", EventFilter{Types: []EventType{TxRemoved}, Addresses: []string{string(payee.Address())}}, Event{Type: TxAccepted, Tx: payment}, false},
+	}
+
+	for _, test := range tests {
+		if got := test.filter.Matches(test.event); got != test.match {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.match)
+		}
+	}
+}
+
+func TestUnsubscribeClosesEvents(t *testing.T) {
+	bus := NewEventBus()
+	sub := bus.Subscribe(EventFilter{Types: []EventType{PeerConnected}})
+
+	bus.Publish(Event{Type: TxAccepted})
+	bus.Publish(Event{Type: PeerConnected, Peer: "a"})
+	bus.Publish(Event{Type: PeerConnected, Peer: "b"})
+
+	if e := nextEvent(t, sub); e.Peer != "a" {
+		t.Fatalf("got %+v first", e)
+	}
+	if e := nextEvent(t, sub); e.Peer != "b" {
+		t.Fatalf("got %+v second", e)
+	}
+
+	sub.Unsubscribe()
+	bus.Publish(Event{Type: PeerConnected, Peer: "c"})
+
+	select {
+	case e, ok := <-sub.Events():
+		if ok {
+			t.Fatalf("got %+v after unsubscribing", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the subscription was not closed")
+	}
+}
+
+func TestNodePublishesEvents(t *testing.T) {
+	sim := newTestSimulator(t, 3, 1)
+	node := sim.Nodes[0]
+	sim.Mine(0)
+	sim.RunUntilIdle()
+
+	sub := node.Events.Subscribe(EventFilter{Types: []EventType{BlockConnected, BlockDisconnected, TxAccepted, TxRemoved}})
+	defer sub.Unsubscribe()
+
+	sim.Partition([]int{0, 1}, []int{2})
+
+	tx, err := sim.Send(0, 1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, sub); e.Type != TxAccepted || !bytes.Equal(e.Tx.ID, tx.ID) {
+		t.Fatalf("got %s, want %s of the payment", e.Type, TxAccepted)
+	}
+
+	mined := sim.Mine(0)
+	if e := nextEvent(t, sub); e.Type != TxRemoved || e.Reason != "block" || !bytes.Equal(e.Tx.ID, tx.ID) {
+		t.Fatalf("got %s %s, want the payment %s as it was mined", e.Type, e.Reason, TxRemoved)
+	}
+	if e := nextEvent(t, sub); e.Type != BlockConnected || !bytes.Equal(e.Block.Hash, mined.Hash) {
+		t.Fatalf("got %s, want %s of the mined block", e.Type, BlockConnected)
+	}
+
+	sim.RunUntilIdle()
+	sim.Mine(2)
+	sim.Mine(2)
+	sim.Heal()
+	sim.Sync()
+	sim.RunUntilIdle()
+
+	if e := nextEvent(t, sub); e.Type != BlockDisconnected || !bytes.Equal(e.Block.Hash, mined.Hash) {
+		t.Fatalf("got %s, want %s of the block on the shorter branch", e.Type, BlockDisconnected)
+	}
+	for i := 0; i < 2; i++ {
+		if e := nextEvent(t, sub); e.Type != BlockConnected || e.Block.Height != 2+i {
+			t.Fatalf("got %s, want %s at height %d", e.Type, BlockConnected, 2+i)
+		}
+	}
+}
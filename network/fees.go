@@ -0,0 +1,163 @@
+package network
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+)
+
+// DefaultConfTarget is the number of blocks a transaction paying the
+// estimated fee rate is meant to confirm within.
+const DefaultConfTarget = 6
+
+const (
+	// feeHistory is the number of blocks confirmations are remembered for,
+	// and the longest confirmation target that can be estimated.
+	feeHistory = 100
+	// feeMinSamples is the number of transactions needed for an estimate.
+	feeMinSamples = 3
+	// feeSuccessPercent is the share of transactions paying at least the
+	// estimated rate that have to confirm within the target.
+	feeSuccessPercent = 85
+)
+
+// ErrNoFeeEstimate is returned while too few transactions have confirmed to
+// estimate a fee rate.
+var ErrNoFeeEstimate = errors.New("not enough transactions confirmed to estimate a fee rate")
+
+// feeSample is a transaction that entered the memory pool at height seen
+// paying rate, and confirmed blocks blocks later at height confirmed.
+type feeSample struct {
+	rate      blockchain.FeeRate
+	seen      int
+	confirmed int
+	blocks    int
+}
+
+// feeEstimator learns from the transactions passing through the memory pool
+// how long each fee rate takes to confirm. It is guarded by the mutex of its
+// node.
+type feeEstimator struct {
+	height    int
+	pending   map[string]feeSample
+	confirmed []feeSample
+}
+
+func newFeeEstimator(height int) *feeEstimator {
+	return &feeEstimator{height: height, pending: make(map[string]feeSample)}
+}
+
+// trackTx starts timing tx, accepted into the memory pool paying fee.
+func (e *feeEstimator) trackTx(tx *blockchain.Transaction, fee int) {
+	e.pending[hex.EncodeToString(tx.ID)] = feeSample{rate: blockchain.FeeRateOf(fee, tx.Size()), seen: e.height}
+}
+
+// untrackTx forgets tx, which left the memory pool without being mined.
+func (e *feeEstimator) untrackTx(tx *blockchain.Transaction) {
+	delete(e.pending, hex.EncodeToString(tx.ID))
+}
+
+// connectBlock records the confirmation of the tracked transactions in
+// block and forgets confirmations older than feeHistory blocks.
+func (e *feeEstimator) connectBlock(block *blockchain.Block) {
+	e.height = block.Height
+
+	for _, tx := range block.Transactions {
+		id := hex.EncodeToString(tx.ID)
+		if sample, ok := e.pending[id]; ok {
+			delete(e.pending, id)
+			sample.confirmed = block.Height
+			sample.blocks = block.Height - sample.seen
+			e.confirmed = append(e.confirmed, sample)
+		}
+	}
+
+	kept := e.confirmed[:0]
+	for _, sample := range e.confirmed {
+		if sample.confirmed > e.height-feeHistory {
+			kept = append(kept, sample)
+		}
+	}
+	e.confirmed = kept
+}
+
+// disconnectBlock forgets the confirmations block recorded.
+func (e *feeEstimator) disconnectBlock(block *blockchain.Block) {
+	e.height = block.Height - 1
+
+	kept := e.confirmed[:0]
+	for _, sample := range e.confirmed {
+		if sample.confirmed < block.Height {
+			kept = append(kept, sample)
+		}
+	}
+	e.confirmed = kept
+}
+
+// estimate returns the lowest fee rate at which feeSuccessPercent of the
+// transactions paying at least as much confirmed within blocks blocks.
+// Transactions still in the memory pool count as failures once they waited
+// that long.
+func (e *feeEstimator) estimate(blocks int) (blockchain.FeeRate, error) {
+	if blocks < 1 || blocks > feeHistory {
+		return 0, fmt.Errorf("number of blocks must be between 1 and %d", feeHistory)
+	}
+
+	type outcome struct {
+		rate blockchain.FeeRate
+		ok   bool
+	}
+
+	var outcomes []outcome
+	for _, sample := range e.confirmed {
+		outcomes = append(outcomes, outcome{sample.rate, sample.blocks <= blocks})
+	}
+	for _, sample := range e.pending {
+		if e.height-sample.seen >= blocks {
+			outcomes = append(outcomes, outcome{sample.rate, false})
+		}
+	}
+
+	sort.Slice(outcomes, func(i, j int) bool {
+		return outcomes[i].rate > outcomes[j].rate
+	})
+
+	rate, found := blockchain.FeeRate(0), false
+	total, ok := 0, 0
+	for i, o := range outcomes {
+		total++
+		if o.ok {
+			ok++
+		}
+
+		if i+1 < len(outcomes) && outcomes[i+1].rate == o.rate {
+			continue
+		}
+		if total < feeMinSamples {
+			continue
+		}
+		if ok*100 < total*feeSuccessPercent {
+			break
+		}
+
+		rate, found = o.rate, true
+	}
+
+	if !found {
+		return 0, ErrNoFeeEstimate
+	}
+
+	return rate, nil
+}
+
+// EstimateFee returns the fee rate a transaction should pay to confirm
+// within blocks blocks, judging by the transactions this node has seen.
+func (n *Node) EstimateFee(blocks int) (blockchain.FeeRate, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.fees.estimate(blocks)
+}
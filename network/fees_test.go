@@ -0,0 +1,88 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+)
+
+func feeTestTx(id byte) *blockchain.Transaction {
+	return &blockchain.Transaction{
+		ID:      []byte{id},
+		Inputs:  []blockchain.TxInput{{ID: []byte{id}}},
+		Outputs: []blockchain.TxOutput{{Value: 1}},
+	}
+}
+
+func TestFeeEstimator(t *testing.T) {
+	e := newFeeEstimator(0)
+
+	var fast, slow []*blockchain.Transaction
+	for i := byte(0); i < 3; i++ {
+		fast = append(fast, feeTestTx(i))
+		e.trackTx(fast[i], 20)
+		slow = append(slow, feeTestTx(10+i))
+		e.trackTx(slow[i], 2)
+	}
+	fastRate := blockchain.FeeRateOf(20, fast[0].Size())
+
+	if _, err := e.estimate(1); err != ErrNoFeeEstimate {
+		t.Fatalf("estimated a fee rate before anything confirmed: %v", err)
+	}
+
+	first := &blockchain.Block{Height: 1, Transactions: fast}
+	e.connectBlock(first)
+	for height := 2; height <= 3; height++ {
+		e.connectBlock(&blockchain.Block{Height: height})
+	}
+
+	// The slow transactions have waited 3 blocks, so they count against
+	// their rate for targets up to 3 but not for longer ones.
+	for _, blocks := range []int{1, 3, 4} {
+		rate, err := e.estimate(blocks)
+		if err != nil || rate != fastRate {
+			t.Errorf("estimate(%d) = %d, %v, want %d", blocks, rate, err, fastRate)
+		}
+	}
+
+	e.connectBlock(&blockchain.Block{Height: 4, Transactions: slow})
+	if rate, err := e.estimate(4); err != nil || rate != blockchain.FeeRateOf(2, slow[0].Size()) {
+		t.Errorf("estimate(4) = %d, %v once the slow transactions confirmed within 4 blocks", rate, err)
+	}
+
+	for height := 4; height >= 1; height-- {
+		e.disconnectBlock(&blockchain.Block{Height: height})
+	}
+	if _, err := e.estimate(1); err != ErrNoFeeEstimate {
+		t.Fatalf("estimated a fee rate after every confirmation was disconnected: %v", err)
+	}
+
+	if _, err := e.estimate(0); err == nil {
+		t.Fatal("estimated a fee rate for 0 blocks")
+	}
+	if _, err := e.estimate(feeHistory + 1); err == nil {
+		t.Fatalf("estimated a fee rate for %d blocks", feeHistory+1)
+	}
+}
+
+func TestSimulatorEstimateFee(t *testing.T) {
+	sim := newTestSimulator(t, 2, 1)
+	node, w := sim.Nodes[0], sim.Wallets[0]
+	sim.Mine(0)
+	sim.Mine(0)
+
+	var txs []*blockchain.Transaction
+	for height := 0; height <= 2; height++ {
+		txs = append(txs, pay(t, node, w, spending(10, false, coinbase(t, node, height)), *blockchain.NewTXOutput(5, sim.Address(1))))
+	}
+	want := blockchain.FeeRateOf(fee(t, node, txs[0]), txs[0].Size())
+	sim.Mine(0)
+
+	rate, err := node.EstimateFee(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate != want {
+		t.Fatalf("estimated %d, want %d", rate, want)
+	}
+}
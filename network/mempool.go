@@ -0,0 +1,279 @@
+package network
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+)
+
+const mempoolFile = "./tmp/mempool_%s.data"
+
+// admit makes room for tx in the memory pool. Its chain of unconfirmed
+// transactions has to stay within the package limits, and a transaction
+// spending an output a pool transaction already spends is only let in as a
+// replacement of it, evicting it and its descendants, see replacements.
+func (n *Node) admit(tx *blockchain.Transaction) error {
+	if err := n.checkPackageLimits(tx); err != nil {
+		return err
+	}
+
+	replaced, err := n.replacements(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, old := range replaced {
+		evicted := old
+		delete(n.memoryPool, hex.EncodeToString(evicted.ID))
+		n.fees.untrackTx(&evicted)
+		fmt.Printf("Replaced transaction %x with %x\n", evicted.ID, tx.ID)
+		n.Events.Publish(Event{Type: TxRemoved, Tx: &evicted, Reason: "replaced"})
+	}
+
+	return nil
+}
+
+// replacements returns the pool transactions tx conflicts with and their
+// descendants. All transactions it conflicts with have to signal
+// replacement, and tx has to pay more than all of them together at a higher
+// fee rate than each.
+func (n *Node) replacements(tx *blockchain.Transaction) ([]blockchain.Transaction, error) {
+	conflicts := n.conflicts(tx)
+	if len(conflicts) == 0 {
+		return nil, nil
+	}
+
+	for _, old := range conflicts {
+		if !old.SignalsReplacement() {
+			return nil, fmt.Errorf("transaction %x spends the outputs of %x, which cannot be replaced", tx.ID, old.ID)
+		}
+	}
+
+	replaced := n.withDescendants(conflicts)
+
+	fee, err := n.Chain.FeeWith(tx, n.memoryPool)
+	if err != nil {
+		return nil, err
+	}
+
+	var oldFees []int
+	for _, old := range replaced {
+		oldFee, err := n.Chain.FeeWith(&old, n.memoryPool)
+		if err != nil {
+			return nil, err
+		}
+		oldFees = append(oldFees, oldFee)
+	}
+
+	if err := blockchain.CheckReplacement(tx, fee, replaced, oldFees); err != nil {
+		return nil, fmt.Errorf("transaction %x does not replace %x: %s", tx.ID, conflicts[0].ID, err)
+	}
+
+	return replaced, nil
+}
+
+// conflicts returns the other pool transactions spending any output tx
+// spends.
+func (n *Node) conflicts(tx *blockchain.Transaction) []blockchain.Transaction {
+	var conflicts []blockchain.Transaction
+
+	for _, pooled := range n.memoryPool {
+		if bytes.Equal(pooled.ID, tx.ID) {
+			continue
+		}
+
+	inputs:
+		for _, in := range pooled.Inputs {
+			for _, other := range tx.Inputs {
+				if bytes.Equal(in.ID, other.ID) && in.Out == other.Out {
+					conflicts = append(conflicts, pooled)
+					break inputs
+				}
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// withDescendants adds the pool transactions spending outputs of txs, and
+// theirs in turn, to txs.
+func (n *Node) withDescendants(txs []blockchain.Transaction) []blockchain.Transaction {
+	found := make(map[string]bool)
+	for _, tx := range txs {
+		found[hex.EncodeToString(tx.ID)] = true
+	}
+
+	for i := 0; i < len(txs); i++ {
+		for id, pooled := range n.memoryPool {
+			if found[id] {
+				continue
+			}
+
+			for _, in := range pooled.Inputs {
+				if bytes.Equal(in.ID, txs[i].ID) {
+					found[id] = true
+					txs = append(txs, pooled)
+					break
+				}
+			}
+		}
+	}
+
+	return txs
+}
+
+// mempoolStore is a Service keeping the memory pool of a node on disk while
+// the node is stopped.
+type mempoolStore struct {
+	path string
+	node *Node
+}
+
+func newMempoolStore(nodeID string) *mempoolStore {
+	return &mempoolStore{path: fmt.Sprintf(mempoolFile, nodeID)}
+}
+
+func (s *mempoolStore) Start(node *Node) error {
+	s.node = node
+
+	return node.LoadMempool(s.path)
+}
+
+func (s *mempoolStore) Stop() error {
+	return s.node.SaveMempool(s.path)
+}
+
+// SaveMempool writes the transactions of the memory pool to path, parents
+// before their children.
+func (n *Node) SaveMempool(path string) error {
+	n.mu.Lock()
+	var txs [][]byte
+	for _, tx := range n.orderedMempool() {
+		txs = append(txs, tx.Serialize())
+	}
+	n.mu.Unlock()
+
+	var content bytes.Buffer
+	if err := gob.NewEncoder(&content).Encode(txs); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path, content.Bytes(), 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("Saved %d transactions of the memory pool\n", len(txs))
+
+	return nil
+}
+
+// LoadMempool adds the transactions saved to path back to the memory pool,
+// without relaying or mining them, and removes the file. The chain may have
+// moved on since they were saved, so each one is checked again and dropped
+// if it is invalid or spends outputs that are spent now.
+func (n *Node) LoadMempool(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var txs [][]byte
+	if err := gob.NewDecoder(bytes.NewReader(content)).Decode(&txs); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	loaded := 0
+	for _, data := range txs {
+		tx, err := blockchain.DecodeTransaction(data)
+		if err != nil {
+			fmt.Printf("Dropped a saved transaction that does not decode: %s\n", err)
+			continue
+		}
+
+		if err := n.revalidate(tx); err != nil {
+			fmt.Printf("Dropped saved transaction %x: %s\n", tx.ID, err)
+			continue
+		}
+
+		n.insertTx(*tx)
+		loaded++
+	}
+
+	fmt.Printf("Loaded %d of %d saved transactions into the memory pool\n", loaded, len(txs))
+
+	return nil
+}
+
+// revalidate checks a saved transaction against the current chain and UTXO
+// set, or the pool transactions loaded before it, before it goes back into
+// the memory pool.
+func (n *Node) revalidate(tx *blockchain.Transaction) error {
+	if tx.IsCoinbase() || !n.Chain.VerifyTxWith(tx, n.memoryPool) {
+		return fmt.Errorf("transaction is invalid")
+	}
+
+	if err := n.checkInputs(tx); err != nil {
+		return err
+	}
+
+	return n.admit(tx)
+}
+
+// checkInputs checks that every output tx spends is in the UTXO set or
+// belongs to a pool transaction.
+func (n *Node) checkInputs(tx *blockchain.Transaction) error {
+	UTXOSet := blockchain.UTXOSet{Blockchain: n.Chain}
+	for _, in := range tx.Inputs {
+		if _, ok := n.memoryPool[hex.EncodeToString(in.ID)]; ok {
+			continue
+		}
+		if _, ok := UTXOSet.GetOutput(in.ID, in.Out); !ok {
+			return fmt.Errorf("output %x:%d is spent", in.ID, in.Out)
+		}
+	}
+
+	return nil
+}
+
+// PendingView runs fn with the UTXO set seen through the memory pool, so
+// wallets neither spend outputs twice nor miss their unconfirmed change.
+func (n *Node) PendingView(fn func(UTXO *blockchain.UTXOSet)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	fn(&blockchain.UTXOSet{Blockchain: n.Chain, Pending: n.memoryPool})
+}
+
+// AddNewTx builds a transaction from the pending UTXO set and adds it to the
+// memory pool without releasing the lock in between, so concurrent callers
+// never pick the same outputs.
+func (n *Node) AddNewTx(build func(UTXO *blockchain.UTXOSet) (*blockchain.Transaction, error)) (*blockchain.Transaction, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	tx, err := build(&blockchain.UTXOSet{Blockchain: n.Chain, Pending: n.memoryPool})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := n.addTx(tx); err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
@@ -0,0 +1,249 @@
+package network
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// spending returns options spending exactly the outputs given as
+// transaction and output index pairs.
+func spending(rate blockchain.FeeRate, replaceable bool, outpoints ...blockchain.UTXO) blockchain.SendOptions {
+	return blockchain.SendOptions{Selector: blockchain.ManualSelection{Outpoints: outpoints}, FeeRate: rate, Replaceable: replaceable}
+}
+
+// change returns the outpoint of the output of tx paying back to w.
+func change(t *testing.T, tx *blockchain.Transaction, w *wallet.Wallet) blockchain.UTXO {
+	t.Helper()
+
+	for i, out := range tx.Outputs {
+		if bytes.Equal(out.PubKeyHash, wallet.PublicKeyHash(w.PublicKey)) {
+			return blockchain.UTXO{TxID: tx.ID, Out: i, Output: out}
+		}
+	}
+	t.Fatalf("transaction %x has no change", tx.ID)
+
+	return blockchain.UTXO{}
+}
+
+// coinbase returns the outpoint of the reward of the block at height of
+// node's chain.
+func coinbase(t *testing.T, node *Node, height int) blockchain.UTXO {
+	t.Helper()
+
+	for _, block := range blocksOf(t, node) {
+		if block.Height == height {
+			cb := block.Transactions[len(block.Transactions)-1]
+			return blockchain.UTXO{TxID: cb.ID, Out: 0, Output: cb.Outputs[0]}
+		}
+	}
+	t.Fatalf("no block at height %d", height)
+
+	return blockchain.UTXO{}
+}
+
+// deliverTx hands tx to node as if from relayed it.
+func deliverTx(node *Node, from string, tx *blockchain.Transaction) {
+	node.HandleMessage(append(CmdToBytes("tx"), GobEncoder(Tx{from, tx.Serialize()})...))
+}
+
+// bump returns a signed copy of tx paying fee, taken from its change to w.
+func bump(t *testing.T, node *Node, tx *blockchain.Transaction, w *wallet.Wallet, fee int) *blockchain.Transaction {
+	t.Helper()
+
+	var bumped *blockchain.Transaction
+	var err error
+	node.PendingView(func(UTXO *blockchain.UTXOSet) {
+		bumped, err = blockchain.NewFeeBumpTx(tx, w, change(t, tx, w).Out, fee, UTXO)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return bumped
+}
+
+func fee(t *testing.T, node *Node, tx *blockchain.Transaction) int {
+	t.Helper()
+
+	entry, ok := node.MempoolEntry(tx.ID)
+	if !ok {
+		t.Fatalf("transaction %x is not in the memory pool", tx.ID)
+	}
+
+	return entry.Fee
+}
+
+func inPool(node *Node, tx *blockchain.Transaction) bool {
+	_, ok := node.MempoolTx(tx.ID)
+	return ok
+}
+
+func TestLoadMempoolNeitherRelaysNorMines(t *testing.T) {
+	sim := newTestSimulator(t, 2, 1)
+	path := filepath.Join(t.TempDir(), "mempool.data")
+
+	for i := 0; i < 3; i++ {
+		_, err := sim.Nodes[0].AddNewTx(func(UTXO *blockchain.UTXOSet) (*blockchain.Transaction, error) {
+			outputs := []blockchain.TxOutput{*blockchain.NewTXOutput(1, sim.Address(1))}
+			return blockchain.NewTransactionWith(sim.Wallets[0], outputs, UTXO, blockchain.DefaultSendOptions)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sim.Nodes[0].SaveMempool(path); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("the memory pool is saved with mode %o, want 600", mode)
+	}
+
+	// Drop the announcements of node 0. Node 1 mines once it holds more than
+	// two transactions, but not the ones it loads.
+	sim.Partition([]int{0}, []int{1})
+	sim.RunUntilIdle()
+
+	node := sim.Nodes[1]
+	if err := node.LoadMempool(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if txs := node.MempoolTxs(); len(txs) != 3 {
+		t.Fatalf("loaded %d transactions, want 3", len(txs))
+	}
+	if pending := sim.Network.Pending(); pending != 0 {
+		t.Errorf("loading the memory pool sent %d messages", pending)
+	}
+	if height := node.Chain.GetBestHeight(); height != 0 {
+		t.Errorf("loading the memory pool mined a block, height %d", height)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the memory pool file was kept after loading: %v", err)
+	}
+}
+
+func TestLoadMempoolSkipsCorruptEntries(t *testing.T) {
+	sim := newTestSimulator(t, 2, 1)
+	path := filepath.Join(t.TempDir(), "mempool.data")
+
+	tx := pay(t, sim.Nodes[0], sim.Wallets[0], blockchain.DefaultSendOptions, *blockchain.NewTXOutput(1, sim.Address(1)))
+	sim.Partition([]int{0}, []int{1})
+	sim.RunUntilIdle()
+
+	saved := GobEncoder([][]byte{[]byte("not a transaction"), tx.Serialize()})
+	if err := ioutil.WriteFile(path, saved, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	node := sim.Nodes[1]
+	if err := node.LoadMempool(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if txs := node.MempoolTxs(); len(txs) != 1 || !inPool(node, tx) {
+		t.Fatalf("loaded %d transactions, want only %x", len(txs), tx.ID)
+	}
+}
+
+func TestReplaceByFee(t *testing.T) {
+	sim := newTestSimulator(t, 2, 1)
+	node, w := sim.Nodes[0], sim.Wallets[0]
+	genesis := coinbase(t, node, 0)
+
+	tx := pay(t, node, w, spending(1, true, genesis), *blockchain.NewTXOutput(5, sim.Address(1)))
+	child := pay(t, node, w, spending(1, false, change(t, tx, w)), *blockchain.NewTXOutput(5, sim.Address(1)))
+
+	// The replacement pays more than the transaction, but not more than it
+	// and the child it evicts together.
+	underpaying := bump(t, node, tx, w, fee(t, node, tx)+fee(t, node, child))
+	if err := node.AddTx(underpaying); err == nil || !strings.Contains(err.Error(), "of the replaced transactions") {
+		t.Fatalf("a replacement paying no more than the transactions it evicts got %v", err)
+	}
+
+	replacement := bump(t, node, tx, w, fee(t, node, tx)+fee(t, node, child)+2)
+	if err := node.AddTx(replacement); err != nil {
+		t.Fatal(err)
+	}
+	if inPool(node, tx) || inPool(node, child) {
+		t.Fatal("the replaced transaction or its child stayed in the memory pool")
+	}
+	if !inPool(node, replacement) {
+		t.Fatal("the replacement is not in the memory pool")
+	}
+}
+
+func TestReplaceByFeeRequiresSignal(t *testing.T) {
+	sim := newTestSimulator(t, 2, 1)
+	node, w := sim.Nodes[0], sim.Wallets[0]
+
+	tx := pay(t, node, w, spending(1, false, coinbase(t, node, 0)), *blockchain.NewTXOutput(5, sim.Address(1)))
+
+	if err := node.AddTx(bump(t, node, tx, w, 10)); err == nil || !strings.Contains(err.Error(), "cannot be replaced") {
+		t.Fatalf("replacing a transaction not signalling replacement got %v", err)
+	}
+	if !inPool(node, tx) {
+		t.Fatal("the transaction was evicted")
+	}
+}
+
+func TestReplacementSpendingEvictedOutputs(t *testing.T) {
+	sim := newTestSimulator(t, 2, 1)
+	node, w := sim.Nodes[0], sim.Wallets[0]
+	genesis := coinbase(t, node, 0)
+
+	tx := pay(t, node, w, spending(1, true, genesis), *blockchain.NewTXOutput(5, sim.Address(1)))
+	child := pay(t, node, w, spending(1, false, change(t, tx, w)), *blockchain.NewTXOutput(5, sim.Address(1)))
+
+	// It conflicts with tx and spends the change of its child, both of
+	// which it would evict.
+	replacement := &blockchain.Transaction{
+		Inputs: []blockchain.TxInput{
+			{ID: genesis.TxID, Out: genesis.Out, PubKey: w.PublicKey},
+			{ID: child.ID, Out: change(t, child, w).Out, PubKey: w.PublicKey},
+		},
+		Outputs: []blockchain.TxOutput{*blockchain.NewTXOutput(10, sim.Address(1))},
+	}
+	replacement.ID = replacement.Hash()
+	node.PendingView(func(UTXO *blockchain.UTXOSet) {
+		node.Chain.SignTxWith(replacement, w.PrivateKey, UTXO.Pending)
+	})
+
+	if err := node.AddTx(replacement); err == nil || !strings.Contains(err.Error(), "spends outputs of the replaced transaction") {
+		t.Fatalf("a replacement spending outputs it evicts got %v", err)
+	}
+	if !inPool(node, tx) || !inPool(node, child) {
+		t.Fatal("the rejected replacement evicted transactions")
+	}
+}
+
+func TestRelayedReplacementMustBeValid(t *testing.T) {
+	sim := newTestSimulator(t, 2, 1)
+	node, w := sim.Nodes[0], sim.Wallets[0]
+
+	tx := pay(t, node, w, spending(1, true, coinbase(t, node, 0)), *blockchain.NewTXOutput(5, sim.Address(1)))
+
+	forged := bump(t, node, tx, w, 10)
+	forged.Inputs[0].Signature[0] ^= 1
+	deliverTx(node, "sim:1", forged)
+
+	if inPool(node, forged) || !inPool(node, tx) {
+		t.Fatal("a replacement with an invalid signature evicted the transaction")
+	}
+
+	deliverTx(node, "sim:1", bump(t, node, tx, w, 10))
+	if inPool(node, tx) {
+		t.Fatal("a valid relayed replacement was rejected")
+	}
+}
@@ -0,0 +1,276 @@
+package network
+
+import (
+	"container/heap"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type VirtualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewVirtualClock(start time.Time) *VirtualClock {
+	return &VirtualClock{now: start}
+}
+
+func (c *VirtualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *VirtualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func (c *VirtualClock) set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if t.After(c.now) {
+		c.now = t
+	}
+}
+
+type message struct {
+	from      string
+	to        string
+	data      []byte
+	deliverAt time.Time
+	seq       uint64
+}
+
+type messageQueue []*message
+
+func (q messageQueue) Len() int { return len(q) }
+
+func (q messageQueue) Less(i, j int) bool {
+	if q[i].deliverAt.Equal(q[j].deliverAt) {
+		return q[i].seq < q[j].seq
+	}
+
+	return q[i].deliverAt.Before(q[j].deliverAt)
+}
+
+func (q messageQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *messageQueue) Push(x interface{}) { *q = append(*q, x.(*message)) }
+
+func (q *messageQueue) Pop() interface{} {
+	old := *q
+	msg := old[len(old)-1]
+	*q = old[:len(old)-1]
+
+	return msg
+}
+
+// MemoryNetwork delivers messages between in-process transports on a virtual
+// clock. Messages are only delivered when Step or Run is called, so for a
+// given seed the delivery order, latencies and drops are reproducible.
+type MemoryNetwork struct {
+	Clock *VirtualClock
+
+	mu         sync.Mutex
+	rand       *rand.Rand
+	minLatency time.Duration
+	maxLatency time.Duration
+	dropRate   float64
+	handlers   map[string]Handler
+	groups     map[string]int
+	queue      messageQueue
+	seq        uint64
+}
+
+func NewMemoryNetwork(seed int64) *MemoryNetwork {
+	return &MemoryNetwork{
+		Clock:      NewVirtualClock(time.Unix(0, 0)),
+		rand:       rand.New(rand.NewSource(seed)),
+		minLatency: 10 * time.Millisecond,
+		maxLatency: 10 * time.Millisecond,
+		handlers:   make(map[string]Handler),
+	}
+}
+
+func (mn *MemoryNetwork) SetLatency(min, max time.Duration) {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	if max < min {
+		max = min
+	}
+	mn.minLatency = min
+	mn.maxLatency = max
+}
+
+func (mn *MemoryNetwork) SetDropRate(rate float64) {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	mn.dropRate = rate
+}
+
+// Partition splits the network so that only addresses in the same group can
+// reach each other. Addresses not listed in any group are isolated.
+func (mn *MemoryNetwork) Partition(groups ...[]string) {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	mn.groups = make(map[string]int)
+	for i, group := range groups {
+		for _, address := range group {
+			mn.groups[address] = i + 1
+		}
+	}
+}
+
+func (mn *MemoryNetwork) Heal() {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	mn.groups = nil
+}
+
+func (mn *MemoryNetwork) Transport() Transport {
+	return &memTransport{network: mn}
+}
+
+func (mn *MemoryNetwork) Pending() int {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	return len(mn.queue)
+}
+
+// Step delivers the next queued message, advancing the clock to its delivery
+// time. It returns false when there is nothing left to deliver.
+func (mn *MemoryNetwork) Step() bool {
+	mn.mu.Lock()
+	if len(mn.queue) == 0 {
+		mn.mu.Unlock()
+		return false
+	}
+
+	msg := heap.Pop(&mn.queue).(*message)
+	mn.Clock.set(msg.deliverAt)
+
+	handler, ok := mn.handlers[msg.to]
+	if !ok || !mn.reachable(msg.from, msg.to) {
+		mn.mu.Unlock()
+		return true
+	}
+	mn.mu.Unlock()
+
+	handler(msg.data)
+
+	return true
+}
+
+// RunFor delivers every message due within d and then moves the clock to the
+// end of the interval.
+func (mn *MemoryNetwork) RunFor(d time.Duration) int {
+	deadline := mn.Clock.Now().Add(d)
+	steps := 0
+
+	for {
+		mn.mu.Lock()
+		due := len(mn.queue) > 0 && !mn.queue[0].deliverAt.After(deadline)
+		mn.mu.Unlock()
+
+		if !due {
+			break
+		}
+
+		mn.Step()
+		steps++
+	}
+
+	mn.Clock.set(deadline)
+
+	return steps
+}
+
+// RunUntilIdle delivers messages until the queue is empty or maxSteps
+// messages have been delivered.
+func (mn *MemoryNetwork) RunUntilIdle(maxSteps int) int {
+	steps := 0
+
+	for steps < maxSteps && mn.Step() {
+		steps++
+	}
+
+	return steps
+}
+
+func (mn *MemoryNetwork) reachable(from, to string) bool {
+	if mn.groups == nil || from == "" {
+		return true
+	}
+
+	return mn.groups[from] != 0 && mn.groups[from] == mn.groups[to]
+}
+
+func (mn *MemoryNetwork) send(from, to string, data []byte) error {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	if _, ok := mn.handlers[to]; !ok {
+		return fmt.Errorf("no listener on %s", to)
+	}
+
+	if mn.dropRate > 0 && mn.rand.Float64() < mn.dropRate {
+		return nil
+	}
+
+	latency := mn.minLatency
+	if mn.maxLatency > mn.minLatency {
+		latency += time.Duration(mn.rand.Int63n(int64(mn.maxLatency - mn.minLatency)))
+	}
+
+	payload := make([]byte, len(data))
+	copy(payload, data)
+
+	mn.seq++
+	heap.Push(&mn.queue, &message{from, to, payload, mn.Clock.Now().Add(latency), mn.seq})
+
+	return nil
+}
+
+type memTransport struct {
+	network *MemoryNetwork
+	address string
+}
+
+func (t *memTransport) Listen(address string, handler Handler) error {
+	t.network.mu.Lock()
+	defer t.network.mu.Unlock()
+
+	if _, ok := t.network.handlers[address]; ok {
+		return fmt.Errorf("address %s already in use", address)
+	}
+
+	t.address = address
+	t.network.handlers[address] = handler
+
+	return nil
+}
+
+func (t *memTransport) Send(address string, data []byte) error {
+	return t.network.send(t.address, address, data)
+}
+
+func (t *memTransport) Close() error {
+	t.network.mu.Lock()
+	defer t.network.mu.Unlock()
+
+	delete(t.network.handlers, t.address)
+
+	return nil
+}
@@ -5,12 +5,11 @@ import (
 	"encoding/gob"
 	"encoding/hex"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"log"
-	"net"
 	"os"
 	"runtime"
+	"sort"
+	"sync"
 	"syscall"
 
 	"github.com/gitferry/blockchain-go/blockchain"
@@ -23,13 +22,27 @@ const (
 	commandLineLength = 12
 )
 
-var (
-	nodeAddress     string
-	minerAddress    string
-	KnownNodes      = []string{"localhost:3000"}
-	blocksInTransit = [][]byte{}
-	memoryPool      = make(map[string]blockchain.Transaction)
-)
+var KnownNodes = []string{"localhost:3000"}
+
+type Node struct {
+	Address      string
+	MinerAddress string
+	KnownNodes   []string
+	Chain        *blockchain.BlockChain
+	Transport    Transport
+	Events       *EventBus
+
+	mu              sync.Mutex
+	tip             []byte
+	blocksInTransit [][]byte
+	memoryPool      map[string]blockchain.Transaction
+	fees            *feeEstimator
+}
+
+type Service interface {
+	Start(node *Node) error
+	Stop() error
+}
 
 type Addr struct {
 	AddrList []string
@@ -67,6 +80,23 @@ type Version struct {
 	AddrFrom   string
 }
 
+func NewNode(address, minerAddress string, chain *blockchain.BlockChain, transport Transport) *Node {
+	knownNodes := make([]string, len(KnownNodes))
+	copy(knownNodes, KnownNodes)
+
+	return &Node{
+		Address:      address,
+		MinerAddress: minerAddress,
+		KnownNodes:   knownNodes,
+		Chain:        chain,
+		Transport:    transport,
+		Events:       NewEventBus(),
+		tip:          chain.LastHash,
+		memoryPool:   make(map[string]blockchain.Transaction),
+		fees:         newFeeEstimator(chain.GetBestHeight()),
+	}
+}
+
 func CmdToBytes(cmd string) []byte {
 	var bytes [commandLineLength]byte
 
@@ -82,19 +112,24 @@ func BytesToCmd(bytes []byte) string {
 
 	for _, b := range bytes {
 		if b != 0x0 {
-			cmd = append(bytes, b)
+			cmd = append(cmd, b)
 		}
 	}
 
 	return fmt.Sprintf("%s", cmd)
 }
 
-func CloseDB(chain *blockchain.BlockChain) {
+func CloseDB(chain *blockchain.BlockChain, services ...Service) {
 	d := death.NewDeath(syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
 
 	d.WaitForDeathWithFunc(func() {
 		defer os.Exit(1)
 		defer runtime.Goexit()
+		for _, service := range services {
+			if err := service.Stop(); err != nil {
+				log.Println(err)
+			}
+		}
 		chain.Database.Close()
 	})
 }
@@ -111,127 +146,160 @@ func GobEncoder(data interface{}) []byte {
 	return buff.Bytes()
 }
 
-func HandleConnection(conn net.Conn, chain *blockchain.BlockChain) {
-	req, err := ioutil.ReadAll(conn)
-	defer conn.Close()
+func (n *Node) Start() error {
+	if err := n.Transport.Listen(n.Address, n.HandleMessage); err != nil {
+		return err
+	}
 
-	if err != nil {
-		log.Panic(err)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if len(n.KnownNodes) > 0 && !n.isSeed() {
+		n.SendVersion(n.KnownNodes[0])
 	}
+
+	return nil
+}
+
+// isSeed reports whether the node is the first known node, which relays
+// every transaction instead of mining. Unreachable nodes are dropped from
+// KnownNodes, so the list may be empty.
+func (n *Node) isSeed() bool {
+	return len(n.KnownNodes) > 0 && n.Address == n.KnownNodes[0]
+}
+
+func (n *Node) Stop() error {
+	return n.Transport.Close()
+}
+
+func (n *Node) HandleMessage(req []byte) {
+	if len(req) < commandLineLength {
+		fmt.Println("Malformed message")
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
 	command := BytesToCmd(req[:commandLineLength])
-	fmt.Printf("Received %s command", command)
+	fmt.Printf("Received %s command\n", command)
 
 	switch command {
 	case "addr":
-		HandleAddr(req)
+		n.HandleAddr(req)
 	case "block":
-		HandleBlock(req, chain)
+		n.HandleBlock(req)
 	case "tx":
-		HandleTx(req, chain)
+		n.HandleTx(req)
 	case "inv":
-		HandleInv(req, chain)
+		n.HandleInv(req)
 	case "getblocks":
-		HandleGetBlocks(req, chain)
-	case "getData":
-		HandleGetData(req, chain)
+		n.HandleGetBlocks(req)
+	case "getdata":
+		n.HandleGetData(req)
 	case "version":
-		HandleVersion(req, chain)
+		n.HandleVersion(req)
 	default:
 		fmt.Println("Unknown command")
 
 	}
 }
 
-func SendData(address string, data []byte) {
-	conn, err := net.Dial(protocol, address)
-	defer conn.Close()
-
-	if err != nil {
-		fmt.Println("%s is not available")
+func (n *Node) SendData(address string, data []byte) {
+	if err := n.Transport.Send(address, data); err != nil {
+		fmt.Printf("%s is not available\n", address)
 
 		var updatedKnownNodes []string
 
-		for _, knownAddress := range KnownNodes {
+		for _, knownAddress := range n.KnownNodes {
 			if knownAddress != address {
 				updatedKnownNodes = append(updatedKnownNodes, knownAddress)
 			}
 		}
 
-		return
-	}
-
-	_, err = io.Copy(conn, bytes.NewReader(data))
-	if err != nil {
-		log.Panic(err)
+		n.KnownNodes = updatedKnownNodes
 	}
 }
 
-func SendAddr(address string) {
-	nodes := Addr{KnownNodes}
-	nodes.AddrList = append(nodes.AddrList, nodeAddress)
+func (n *Node) SendAddr(address string) {
+	nodes := Addr{n.KnownNodes}
+	nodes.AddrList = append(nodes.AddrList, n.Address)
 	payload := GobEncoder(nodes)
 	request := append(CmdToBytes("addr"), payload...)
 
-	SendData(address, request)
+	n.SendData(address, request)
 }
 
-func SendBlock(address string, b *blockchain.Block) {
-	data := Block{nodeAddress, b.Serialize()}
+func (n *Node) SendBlock(address string, b *blockchain.Block) {
+	data := Block{n.Address, b.Serialize()}
 	payload := GobEncoder(data)
 	request := append(CmdToBytes("block"), payload...)
 
-	SendData(address, request)
+	n.SendData(address, request)
 }
 
-func SendInv(address, kind string, items [][]byte) {
-	inventory := Inv{nodeAddress, kind, items}
+func (n *Node) SendInv(address, kind string, items [][]byte) {
+	inventory := Inv{n.Address, kind, items}
 	payload := GobEncoder(inventory)
 	request := append(CmdToBytes("inv"), payload...)
 
-	SendData(address, request)
+	n.SendData(address, request)
 }
 
-func SendTx(address string, tx *blockchain.Transaction) {
-	transaction := Tx{nodeAddress, tx.Serialize()}
+func (n *Node) SendTx(address string, tx *blockchain.Transaction) {
+	transaction := Tx{n.Address, tx.Serialize()}
 	payload := GobEncoder(transaction)
 	request := append(CmdToBytes("tx"), payload...)
 
-	SendData(address, request)
+	n.SendData(address, request)
 }
 
-func SendVersion(address string, chain *blockchain.BlockChain) {
-	bestHeight := chain.GetBestHeight()
+func (n *Node) SendVersion(address string) {
+	bestHeight := n.Chain.GetBestHeight()
 
-	v := Version{version, bestHeight, nodeAddress}
+	v := Version{version, bestHeight, n.Address}
 	payload := GobEncoder(v)
 	request := append(CmdToBytes("version"), payload...)
 
-	SendData(address, request)
+	n.SendData(address, request)
 }
 
-func SendGetBlocks(address string) {
-	payload := GobEncoder(GetBlocks{nodeAddress})
+func (n *Node) SendGetBlocks(address string) {
+	payload := GobEncoder(GetBlocks{n.Address})
 
 	request := append(CmdToBytes("getblocks"), payload...)
 
-	SendData(address, request)
+	n.SendData(address, request)
 }
 
-func SendGetData(address, kind string, id []byte) {
-	payload := GobEncoder(GetData{nodeAddress, kind, id})
+func (n *Node) SendGetData(address, kind string, id []byte) {
+	payload := GobEncoder(GetData{n.Address, kind, id})
 
 	request := append(CmdToBytes("getdata"), payload...)
 
-	SendData(address, request)
+	n.SendData(address, request)
+}
+
+func (n *Node) RequestBlocks() {
+	for _, node := range n.KnownNodes {
+		if node != n.Address {
+			n.SendGetBlocks(node)
+		}
+	}
 }
 
-func RequestBlocks() {
-	for _, node := range KnownNodes {
-		SendGetBlocks(node)
+func (n *Node) Sync() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, node := range n.KnownNodes {
+		if node != n.Address {
+			n.SendVersion(node)
+		}
 	}
 }
 
-func HandleAddr(request []byte) {
+func (n *Node) HandleAddr(request []byte) {
 	var buffer bytes.Buffer
 	var payload Addr
 
@@ -242,12 +310,16 @@ func HandleAddr(request []byte) {
 		log.Panic(err)
 	}
 
-	KnownNodes = append(KnownNodes, payload.AddrList...)
-	fmt.Printf("There are %d known nodes", len(KnownNodes))
-	RequestBlocks()
+	for _, address := range payload.AddrList {
+		if address != n.Address && !n.NodeIsKnown(address) {
+			n.KnownNodes = append(n.KnownNodes, address)
+		}
+	}
+	fmt.Printf("There are %d known nodes\n", len(n.KnownNodes))
+	n.RequestBlocks()
 }
 
-func HandleBlock(request []byte, chain *blockchain.BlockChain) {
+func (n *Node) HandleBlock(request []byte) {
 	var buffer bytes.Buffer
 	var payload Block
 
@@ -261,21 +333,38 @@ func HandleBlock(request []byte, chain *blockchain.BlockChain) {
 	block := blockchain.Deserialize(payload.Block)
 
 	fmt.Println("Received a new block!")
-	chain.AddBlock(block)
+
+	n.Chain.AddBlock(block)
 	fmt.Printf("Added block %x\n", block.Hash)
 
-	if len(blocksInTransit) > 0 {
-		blockHash := blocksInTransit[0]
-		SendGetData(payload.AddrFrom, "block", blockHash)
-		blockHash = blockHash[1:]
-	} else {
-		UTXOSet := blockchain.UTXOSet{chain}
-		UTXOSet.Reindex()
+	if len(n.blocksInTransit) > 0 {
+		blockHash := n.blocksInTransit[0]
+		n.SendGetData(payload.AddrFrom, "block", blockHash)
+		n.blocksInTransit = n.blocksInTransit[1:]
+		return
 	}
 
+	if len(block.PrevHash) > 0 {
+		if _, err := n.Chain.GetBlock(block.PrevHash); err != nil {
+			n.SendGetBlocks(payload.AddrFrom)
+			return
+		}
+	}
+
+	n.checkConnected()
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: n.Chain}
+	UTXOSet.Reindex()
+	n.chainUpdated()
+
+	for _, node := range n.KnownNodes {
+		if node != n.Address && node != payload.AddrFrom {
+			n.SendInv(node, "block", [][]byte{n.Chain.LastHash})
+		}
+	}
 }
 
-func HandleGetBlocks(request []byte, chain *blockchain.BlockChain) {
+func (n *Node) HandleGetBlocks(request []byte) {
 	var buffer bytes.Buffer
 	var payload GetBlocks
 
@@ -286,11 +375,11 @@ func HandleGetBlocks(request []byte, chain *blockchain.BlockChain) {
 		log.Panic(err)
 	}
 
-	blocks := chain.GetBlockHashes()
-	SendInv(payload.AddrFrom, "block", blocks)
+	blocks := n.Chain.GetBlockHashes()
+	n.SendInv(payload.AddrFrom, "block", blocks)
 }
 
-func HandleGetData(request []byte, chain *blockchain.BlockChain) {
+func (n *Node) HandleGetData(request []byte) {
 	var buffer bytes.Buffer
 	var payload GetData
 
@@ -302,23 +391,29 @@ func HandleGetData(request []byte, chain *blockchain.BlockChain) {
 	}
 
 	if payload.Type == "block" {
-		block, err := chain.GetBlock([]byte(payload.ID))
+		block, err := n.Chain.GetBlock([]byte(payload.ID))
 		if err != nil {
-			log.Panic(err)
+			fmt.Printf("Block %x is not found\n", payload.ID)
+			return
 		}
 
-		SendBlock(payload.AddrFrom, &block)
+		n.SendBlock(payload.AddrFrom, &block)
 	}
 
 	if payload.Type == "tx" {
 		txID := hex.EncodeToString(payload.ID)
-		tx := memoryPool[txID]
-		SendTx(payload.AddrFrom, &tx)
+		tx, ok := n.memoryPool[txID]
+		if !ok {
+			fmt.Printf("Transaction %s is not in the memory pool\n", txID)
+			return
+		}
+
+		n.SendTx(payload.AddrFrom, &tx)
 	}
 }
 
-func NodeIsKnown(address string) bool {
-	for _, node := range KnownNodes {
+func (n *Node) NodeIsKnown(address string) bool {
+	for _, node := range n.KnownNodes {
 		if node == address {
 			return true
 		}
@@ -327,7 +422,7 @@ func NodeIsKnown(address string) bool {
 	return false
 }
 
-func HandleVersion(request []byte, chain *blockchain.BlockChain) {
+func (n *Node) HandleVersion(request []byte) {
 	var buffer bytes.Buffer
 	var payload Version
 
@@ -338,28 +433,25 @@ func HandleVersion(request []byte, chain *blockchain.BlockChain) {
 		log.Panic(err)
 	}
 
-	bestHeight := chain.GetBestHeight()
+	bestHeight := n.Chain.GetBestHeight()
 	otherBestHeight := payload.BestHeight
 
 	if bestHeight < otherBestHeight {
-		SendGetBlocks(payload.AddrFrom)
+		n.SendGetBlocks(payload.AddrFrom)
 	} else if bestHeight > otherBestHeight {
-		SendVersion(payload.AddrFrom, chain)
+		n.SendVersion(payload.AddrFrom)
 	}
 
-	if !NodeIsKnown(payload.AddrFrom) {
-		KnownNodes = append(KnownNodes, payload.AddrFrom)
+	if !n.NodeIsKnown(payload.AddrFrom) {
+		n.KnownNodes = append(n.KnownNodes, payload.AddrFrom)
+		n.Events.Publish(Event{Type: PeerConnected, Peer: payload.AddrFrom})
 	}
 }
 
-func MineTx(chain *blockchain.BlockChain) {
-	var txs []*blockchain.Transaction
-
-	for id, tx := range memoryPool {
-		fmt.Printf("tx: %s\n", id)
-		if chain.VerifyTx(&tx) == true {
-			txs = append(txs, &tx)
-		}
+func (n *Node) MineTx() {
+	txs := n.blockTemplate()
+	for _, tx := range txs {
+		fmt.Printf("tx: %x\n", tx.ID)
 	}
 
 	if len(txs) == 0 {
@@ -367,32 +459,236 @@ func MineTx(chain *blockchain.BlockChain) {
 		return
 	}
 
-	cbTx := blockchain.CoinBaseTx(nodeAddress, "")
+	n.mineBlock(txs, n.MinerAddress)
+
+	if len(n.memoryPool) > 0 {
+		n.MineTx()
+	}
+}
+
+func (n *Node) MineBlock(rewardAddress string) *blockchain.Block {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.mineBlock(n.blockTemplate(), rewardAddress)
+}
+
+// mineBlock mines txs, ordered parents first, into a new block.
+func (n *Node) mineBlock(txs []*blockchain.Transaction, rewardAddress string) *blockchain.Block {
+	fees := 0
+	for _, tx := range txs {
+		fee, err := n.Chain.FeeWith(tx, n.memoryPool)
+		blockchain.HandleErr(err)
+		fees += fee
+	}
+
+	cbTx := blockchain.CoinBaseTx(rewardAddress, "", fees)
 	txs = append(txs, cbTx)
 
-	newBlock := chain.MineBlock(txs)
-	UTXOSet := blockchain.UTXOSet{chain}
+	newBlock := n.Chain.MineBlock(txs)
+	UTXOSet := blockchain.UTXOSet{Blockchain: n.Chain}
 	UTXOSet.Reindex()
+	n.chainUpdated()
 
 	fmt.Println("New block mined")
 
-	for _, tx := range txs {
-		txID := hex.EncodeToString(tx.ID)
-		delete(memoryPool, txID)
+	for _, node := range n.KnownNodes {
+		if node != n.Address {
+			n.SendInv(node, "block", [][]byte{newBlock.Hash})
+		}
+	}
+
+	return newBlock
+}
+
+// checkConnected validates the blocks about to join the chain since the
+// last tip, parents first. Blocks may arrive in any order
+// while syncing, but by now every one of them has its parent. The first
+// invalid block and the ones after it are dropped, and the chain goes back
+// to the longer of the old tip and the valid part of the new branch.
+func (n *Node) checkConnected() {
+	if bytes.Equal(n.tip, n.Chain.LastHash) {
+		return
+	}
+
+	_, connected, err := n.Chain.FindFork(n.tip, n.Chain.LastHash)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for i, block := range connected {
+		err := n.Chain.CheckBlock(block)
+		if err == nil {
+			continue
+		}
+
+		fmt.Printf("Rejected block %x: %s\n", block.Hash, err)
+
+		oldTip, tipErr := n.Chain.GetBlock(n.tip)
+		blockchain.HandleErr(tipErr)
+
+		tip := n.tip
+		if i > 0 && connected[i-1].Height > oldTip.Height {
+			tip = connected[i-1].Hash
+		}
+		n.Chain.SetTip(tip)
+
+		for _, invalid := range connected[i:] {
+			n.Chain.RemoveBlock(invalid.Hash)
+		}
+
+		return
+	}
+}
+
+func (n *Node) chainUpdated() {
+	if bytes.Equal(n.tip, n.Chain.LastHash) {
+		return
+	}
+
+	disconnected, connected, err := n.Chain.FindFork(n.tip, n.Chain.LastHash)
+	n.tip = n.Chain.LastHash
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, block := range disconnected {
+		n.fees.disconnectBlock(block)
+		n.Events.Publish(Event{Type: BlockDisconnected, Block: block})
 	}
 
-	for _, node := range KnownNodes {
-		if node != nodeAddress {
-			SendInv(node, "block", [][]byte{newBlock.Hash})
+	for _, block := range connected {
+		for _, tx := range block.Transactions {
+			txID := hex.EncodeToString(tx.ID)
+			if pooled, ok := n.memoryPool[txID]; ok {
+				delete(n.memoryPool, txID)
+				n.Events.Publish(Event{Type: TxRemoved, Tx: &pooled, Reason: "block"})
+			}
 		}
+
+		// Pool transactions spending the same outputs as the block can never
+		// be mined any more, and neither can their descendants.
+		for _, tx := range block.Transactions {
+			if tx.IsCoinbase() {
+				continue
+			}
+
+			for _, conflict := range n.withDescendants(n.conflicts(tx)) {
+				evicted := conflict
+				delete(n.memoryPool, hex.EncodeToString(evicted.ID))
+				n.fees.untrackTx(&evicted)
+				n.Events.Publish(Event{Type: TxRemoved, Tx: &evicted, Reason: "conflict"})
+			}
+		}
+
+		n.fees.connectBlock(block)
+		n.Events.Publish(Event{Type: BlockConnected, Block: block})
+	}
+}
+
+func (n *Node) AddTx(tx *blockchain.Transaction) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.addTx(tx)
+}
+
+func (n *Node) addTx(tx *blockchain.Transaction) error {
+	if tx.IsCoinbase() || !n.Chain.VerifyTxWith(tx, n.memoryPool) {
+		return fmt.Errorf("transaction %x is invalid", tx.ID)
+	}
+
+	if err := n.checkInputs(tx); err != nil {
+		return err
+	}
+
+	if err := n.admit(tx); err != nil {
+		return err
+	}
+
+	n.acceptTx(*tx, "")
+
+	return nil
+}
+
+func (n *Node) acceptTx(transaction blockchain.Transaction, from string) {
+	n.insertTx(transaction)
+
+	if n.isSeed() || from == "" {
+		for _, node := range n.KnownNodes {
+			if node != n.Address && node != from {
+				n.SendInv(node, "tx", [][]byte{transaction.ID})
+			}
+		}
+	}
+
+	if !n.isSeed() {
+		if len(n.memoryPool) > 2 && len(n.MinerAddress) > 0 {
+			n.MineTx()
+		}
+	}
+}
+
+// insertTx puts transaction into the memory pool without relaying or mining
+// it.
+func (n *Node) insertTx(transaction blockchain.Transaction) {
+	n.memoryPool[hex.EncodeToString(transaction.ID)] = transaction
+	fmt.Printf("Added transaction %x, there are %d transactions in the memory pool\n", transaction.ID, len(n.memoryPool))
+	if fee, err := n.Chain.FeeWith(&transaction, n.memoryPool); err == nil {
+		n.fees.trackTx(&transaction, fee)
+	}
+	n.Events.Publish(Event{Type: TxAccepted, Tx: &transaction})
+}
+
+func (n *Node) View(fn func(chain *blockchain.BlockChain)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	fn(n.Chain)
+}
+
+func (n *Node) MempoolTxs() []blockchain.Transaction {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var txs []blockchain.Transaction
+	for _, tx := range n.memoryPool {
+		txs = append(txs, tx)
 	}
 
-	if len(memoryPool) > 0 {
-		MineTx(chain)
+	sort.Slice(txs, func(i, j int) bool {
+		return bytes.Compare(txs[i].ID, txs[j].ID) < 0
+	})
+
+	return txs
+}
+
+func (n *Node) MempoolTx(id []byte) (blockchain.Transaction, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	tx, ok := n.memoryPool[hex.EncodeToString(id)]
+
+	return tx, ok
+}
+
+func (n *Node) Peers() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var peers []string
+	for _, node := range n.KnownNodes {
+		if node != n.Address {
+			peers = append(peers, node)
+		}
 	}
+
+	return peers
 }
 
-func HandleTx(request []byte, chain *blockchain.BlockChain) {
+func (n *Node) HandleTx(request []byte) {
 	var buffer bytes.Buffer
 	var payload Tx
 
@@ -400,28 +696,36 @@ func HandleTx(request []byte, chain *blockchain.BlockChain) {
 	decode := gob.NewDecoder(&buffer)
 	err := decode.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		fmt.Printf("Malformed transaction message: %s\n", err)
+		return
 	}
 
-	transaction := blockchain.DeserializeTransaction(payload.Transaction)
+	transaction, err := blockchain.DecodeTransaction(payload.Transaction)
+	if err != nil {
+		fmt.Printf("Malformed transaction from %s: %s\n", payload.AddrFrom, err)
+		return
+	}
 	fmt.Println("Received a new transaction!")
-	memoryPool[hex.EncodeToString(transaction.ID)] = transaction
-	fmt.Printf("Added transaction %x, there are %d transactions in the memory pool\n", transaction.ID, len(memoryPool))
 
-	if nodeAddress == KnownNodes[0] {
-		for _, node := range KnownNodes {
-			if node != nodeAddress && node != payload.AddrFrom {
-				SendInv(node, "tx", [][]byte{transaction.ID})
-			}
-		}
-	} else {
-		if len(memoryPool) > 2 && len(minerAddress) > 0 {
-			MineTx(chain)
-		}
+	if transaction.IsCoinbase() || !n.Chain.VerifyTxWith(transaction, n.memoryPool) {
+		fmt.Printf("Rejected transaction %x: it is invalid\n", transaction.ID)
+		return
+	}
+
+	if err := n.checkInputs(transaction); err != nil {
+		fmt.Printf("Rejected transaction %x: %s\n", transaction.ID, err)
+		return
 	}
+
+	if err := n.admit(transaction); err != nil {
+		fmt.Printf("Rejected transaction %x: %s\n", transaction.ID, err)
+		return
+	}
+
+	n.acceptTx(*transaction, payload.AddrFrom)
 }
 
-func HandleInv(request []byte, chain *blockchain.BlockChain) {
+func (n *Node) HandleInv(request []byte) {
 	var buffer bytes.Buffer
 	var payload Inv
 
@@ -432,48 +736,61 @@ func HandleInv(request []byte, chain *blockchain.BlockChain) {
 		log.Panic(err)
 	}
 
-	fmt.Printf("Received inventory %d %s\n", payload.Items, payload.Type)
+	fmt.Printf("Received inventory with %d %s\n", len(payload.Items), payload.Type)
 
 	if payload.Type == "block" {
-		blocksInTransit = payload.Items
+		n.blocksInTransit = [][]byte{}
+		for _, blockHash := range payload.Items {
+			if _, err := n.Chain.GetBlock(blockHash); err != nil {
+				n.blocksInTransit = append(n.blocksInTransit, blockHash)
+			}
+		}
 
-		blockHash := payload.Items[0]
-		SendGetData(payload.AddrFrom, "block", blockHash)
+		if len(n.blocksInTransit) == 0 {
+			return
+		}
+
+		blockHash := n.blocksInTransit[0]
+		n.SendGetData(payload.AddrFrom, "block", blockHash)
 
-		blocksInTransit = blocksInTransit[1:]
+		n.blocksInTransit = n.blocksInTransit[1:]
 	}
 
 	if payload.Type == "tx" {
 		txID := payload.Items[0]
 
-		if memoryPool[hex.EncodeToString(txID)].ID == nil {
-			SendGetData(payload.AddrFrom, "tx", txID)
+		if n.memoryPool[hex.EncodeToString(txID)].ID == nil {
+			n.SendGetData(payload.AddrFrom, "tx", txID)
 		}
 	}
 }
 
-func StartServer(nodeID, minerAddress string) {
+func SendTx(address string, tx *blockchain.Transaction) error {
+	transaction := Tx{"", tx.Serialize()}
+	payload := GobEncoder(transaction)
+	request := append(CmdToBytes("tx"), payload...)
+
+	return NewTCPTransport().Send(address, request)
+}
+
+func StartServer(nodeID, minerAddress string, services ...Service) {
 	nodeAddress := fmt.Sprintf("localhost:%s", nodeID)
-	minerAddress = minerAddress
-	ln, err := net.Listen(protocol, nodeAddress)
-	if err != nil {
-		log.Panic(err)
-	}
-	defer ln.Close()
 
 	chain := blockchain.ContinueBlockchain(nodeID)
 	defer chain.Database.Close()
-	go CloseDB(chain)
 
-	if nodeAddress != KnownNodes[0] {
-		SendVersion(nodeAddress, chain)
+	node := NewNode(nodeAddress, minerAddress, chain, NewTCPTransport())
+	if err := node.Start(); err != nil {
+		log.Panic(err)
 	}
-	for {
-		conn, err := ln.Accept()
-		if err != nil {
-			log.Panic()
-		}
+	defer node.Stop()
 
-		go HandleConnection(conn, chain)
+	services = append(services, newMempoolStore(nodeID))
+	for _, service := range services {
+		if err := service.Start(node); err != nil {
+			log.Panic(err)
+		}
 	}
+
+	CloseDB(chain, services...)
 }
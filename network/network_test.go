@@ -0,0 +1,302 @@
+package network
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// deliverBlock hands block to node as if from sent it.
+func deliverBlock(node *Node, from string, block *blockchain.Block) {
+	node.HandleMessage(append(CmdToBytes("block"), GobEncoder(Block{from, block.Serialize()})...))
+}
+
+func TestOutOfOrderBlocksCheckLockTimes(t *testing.T) {
+	sim := newTestSimulator(t, 2, 1)
+	node := sim.Nodes[0]
+	genesis := node.Chain.LastHash
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: node.Chain}
+	locked := blockchain.NewTransaction(sim.Wallets[0], sim.Address(1), 5, &UTXOSet)
+	locked.LockTime = 50
+	locked.ID = locked.Hash()
+	node.Chain.SignTx(locked, sim.Wallets[0].PrivateKey)
+
+	fee, err := node.Chain.Fee(locked)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := blockchain.CreateBlock([]*blockchain.Transaction{locked, blockchain.CoinBaseTx(sim.Address(1), "", fee)}, genesis, 1, 0)
+	child := blockchain.CreateBlock([]*blockchain.Transaction{blockchain.CoinBaseTx(sim.Address(1), "", 0)}, invalid.Hash, 2, 0)
+
+	// The child arrives first, as blocks do while syncing, when the lock
+	// times of its parent cannot be checked yet.
+	deliverBlock(node, "sim:1", child)
+	deliverBlock(node, "sim:1", invalid)
+
+	if !bytes.Equal(node.Chain.LastHash, genesis) {
+		t.Fatalf("the chain moved to %x on a block violating a lock time", node.Chain.LastHash)
+	}
+	for _, block := range []*blockchain.Block{invalid, child} {
+		if _, err := node.Chain.GetBlock(block.Hash); err == nil {
+			t.Errorf("block %x is still stored", block.Hash)
+		}
+	}
+}
+
+func TestConcurrentAddNewTx(t *testing.T) {
+	sim := newTestSimulator(t, 2, 1)
+	node := sim.Nodes[0]
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = node.AddNewTx(func(UTXO *blockchain.UTXOSet) (*blockchain.Transaction, error) {
+				outputs := []blockchain.TxOutput{*blockchain.NewTXOutput(1, sim.Address(1))}
+				return blockchain.NewTransactionWith(sim.Wallets[0], outputs, UTXO, blockchain.DefaultSendOptions)
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("send %d: %v", i, err)
+		}
+	}
+	if txs := node.MempoolTxs(); len(txs) != len(errs) {
+		t.Fatalf("the memory pool holds %d transactions, want %d", len(txs), len(errs))
+	}
+}
+
+func TestInvalidBlocksAreRejected(t *testing.T) {
+	sim := newTestSimulator(t, 2, 1)
+	node := sim.Nodes[0]
+	genesis := node.Chain.LastHash
+
+	genesisBlock, err := node.Chain.GetBlock(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reward := genesisBlock.Transactions[0]
+
+	thief := wallet.MakeWallet()
+	stolen := &blockchain.Transaction{
+		Inputs:  []blockchain.TxInput{{ID: reward.ID, Out: 0, PubKey: thief.PublicKey}},
+		Outputs: []blockchain.TxOutput{*blockchain.NewTXOutput(reward.Outputs[0].Value, string(thief.Address()))},
+	}
+	stolen.ID = stolen.Hash()
+	node.Chain.SignTx(stolen, thief.PrivateKey)
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: node.Chain}
+	a := blockchain.NewTransaction(sim.Wallets[0], sim.Address(1), 5, &UTXOSet)
+	b := blockchain.NewTransaction(sim.Wallets[0], sim.Address(1), 6, &UTXOSet)
+	missing := blockchain.NewTransaction(sim.Wallets[0], sim.Address(1), 7, &UTXOSet)
+	missing.Inputs[0].ID = bytes.Repeat([]byte{1}, 32)
+
+	fee, err := node.Chain.Fee(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coinbase := func(fees int) *blockchain.Transaction {
+		return blockchain.CoinBaseTx(sim.Address(1), "", fees)
+	}
+
+	tests := []struct {
+		name string
+		txs  []*blockchain.Transaction
+		err  string
+	}{
+		{"stolen input", []*blockchain.Transaction{stolen, blockchain.CoinBaseTx(string(thief.Address()), "", 1000000)}, "input 0"},
+		{"inflated coinbase", []*blockchain.Transaction{a, coinbase(fee + 1)}, "coinbase claims"},
+		{"double spend", []*blockchain.Transaction{a, b, coinbase(0)}, "which is spent"},
+		{"unknown output", []*blockchain.Transaction{missing, coinbase(0)}, "unknown output"},
+		{"no coinbase", []*blockchain.Transaction{a}, "no coinbase"},
+		{"two coinbases", []*blockchain.Transaction{coinbase(0), coinbase(0)}, "more than one coinbase"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			block := blockchain.CreateBlock(test.txs, genesis, 1, 0)
+
+			if err := node.Chain.CheckBlock(block); err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("got error %v, want %q", err, test.err)
+			}
+
+			deliverBlock(node, "sim:1", block)
+			if !bytes.Equal(node.Chain.LastHash, genesis) {
+				t.Fatalf("the chain moved to %x on an invalid block", node.Chain.LastHash)
+			}
+			if _, err := node.Chain.GetBlock(block.Hash); err == nil {
+				t.Errorf("block %x is still stored", block.Hash)
+			}
+		})
+	}
+
+	mined := blockchain.CreateBlock([]*blockchain.Transaction{a, coinbase(fee)}, genesis, 1, 0)
+	deliverBlock(node, "sim:1", mined)
+	if !bytes.Equal(node.Chain.LastHash, mined.Hash) {
+		t.Fatalf("the chain did not move to the valid block %x", mined.Hash)
+	}
+
+	respent := blockchain.CreateBlock([]*blockchain.Transaction{b, coinbase(0)}, mined.Hash, 2, 0)
+	deliverBlock(node, "sim:1", respent)
+	if !bytes.Equal(node.Chain.LastHash, mined.Hash) {
+		t.Fatalf("the chain moved to %x on a block spending a spent output", node.Chain.LastHash)
+	}
+}
+
+func TestBlocksMintingTokensAreRejected(t *testing.T) {
+	sim := newTestSimulator(t, 2, 1)
+	node := sim.Nodes[0]
+	w := sim.Wallets[0]
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: node.Chain}
+	issue, err := blockchain.NewIssuanceTx(w, "gold", 100, &UTXOSet, blockchain.DefaultSendOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := node.AddTx(issue); err != nil {
+		t.Fatal(err)
+	}
+	sim.Mine(0)
+	asset := issue.IssuedAsset()
+	tip := node.Chain.LastHash
+
+	// resign gives tx a new ID and signs it again after it was changed.
+	resign := func(tx *blockchain.Transaction) *blockchain.Transaction {
+		tx.ID = tx.Hash()
+		node.Chain.SignTx(tx, w.PrivateKey)
+		return tx
+	}
+
+	minted := blockchain.NewTransaction(w, sim.Address(1), 1, &UTXOSet)
+	minted.Outputs = append(minted.Outputs, *blockchain.NewTokenOutput(asset, 50, sim.Address(0)))
+
+	inflated, err := blockchain.NewTransactionWith(w, []blockchain.TxOutput{*blockchain.NewTokenOutput(asset, 10, sim.Address(1))}, &UTXOSet, blockchain.DefaultSendOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inflated.Outputs[0].Tokens = 1000
+
+	coinbase := blockchain.CoinBaseTx(sim.Address(1), "", 0)
+	coinbase.Outputs = append(coinbase.Outputs, *blockchain.NewTokenOutput(asset, 50, sim.Address(1)))
+	coinbase.ID = coinbase.Hash()
+
+	tests := []struct {
+		name string
+		txs  []*blockchain.Transaction
+		err  string
+	}{
+		{"more of an issued asset", []*blockchain.Transaction{resign(minted), blockchain.CoinBaseTx(sim.Address(1), "", 0)}, "creates or destroys tokens"},
+		{"more tokens than spent", []*blockchain.Transaction{resign(inflated), blockchain.CoinBaseTx(sim.Address(1), "", 0)}, "creates or destroys tokens"},
+		{"tokens in the coinbase", []*blockchain.Transaction{coinbase}, "carries tokens"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			block := blockchain.CreateBlock(test.txs, tip, node.Chain.GetBestHeight()+1, 0)
+
+			if err := node.Chain.CheckBlock(block); err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("got error %v, want %q", err, test.err)
+			}
+
+			deliverBlock(node, "sim:1", block)
+			if !bytes.Equal(node.Chain.LastHash, tip) {
+				t.Fatalf("the chain moved to %x on a block minting tokens", node.Chain.LastHash)
+			}
+		})
+	}
+}
+
+func TestBlocksFailingScriptsAreRejected(t *testing.T) {
+	sim := newTestSimulator(t, 2, 1)
+	node := sim.Nodes[0]
+	w := sim.Wallets[0]
+
+	secret := []byte("secret")
+	secretHash := sha256.Sum256(secret)
+	redeemScript := blockchain.HashLockScript(secretHash[:])
+
+	funding := pay(t, node, w, blockchain.DefaultSendOptions,
+		*blockchain.NewScriptOutput(5, blockchain.ScriptHashScript(wallet.ScriptHash(redeemScript))),
+		*blockchain.NewScriptOutput(5, blockchain.TimeLockScript(100, wallet.PublicKeyHash(w.PublicKey))))
+	sim.Mine(0)
+	tip := node.Chain.LastHash
+
+	spend := func(out int, unlocking []byte) *blockchain.Transaction {
+		tx := &blockchain.Transaction{
+			Inputs:  []blockchain.TxInput{{ID: funding.ID, Out: out, Script: unlocking}},
+			Outputs: []blockchain.TxOutput{*blockchain.NewTXOutput(4, sim.Address(1))},
+		}
+		tx.ID = tx.Hash()
+
+		return tx
+	}
+	block := func(tx *blockchain.Transaction) *blockchain.Block {
+		txs := []*blockchain.Transaction{tx, blockchain.CoinBaseTx(sim.Address(1), "", 1)}
+		return blockchain.CreateBlock(txs, tip, node.Chain.GetBestHeight()+1, 0)
+	}
+
+	tests := []struct {
+		name string
+		tx   *blockchain.Transaction
+		err  string
+	}{
+		{"wrong secret", spend(0, blockchain.NewScriptBuilder().AddData([]byte("guess")).AddData(redeemScript).Script()), "evaluated to false"},
+		{"wrong redeem script", spend(0, blockchain.NewScriptBuilder().AddData(secret).AddData(blockchain.HashLockScript(secret)).Script()), "input 0"},
+		{"lock time not reached", spend(1, blockchain.NewScriptBuilder().AddData([]byte("signature")).AddData(w.PublicKey).Script()), "lock time is before 100"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			invalid := block(test.tx)
+
+			if err := node.Chain.CheckBlock(invalid); err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("got error %v, want %q", err, test.err)
+			}
+
+			deliverBlock(node, "sim:1", invalid)
+			if !bytes.Equal(node.Chain.LastHash, tip) {
+				t.Fatalf("the chain moved to %x on a block failing a script", node.Chain.LastHash)
+			}
+		})
+	}
+
+	valid := block(spend(0, blockchain.NewScriptBuilder().AddData(secret).AddData(redeemScript).Script()))
+	deliverBlock(node, "sim:1", valid)
+	if !bytes.Equal(node.Chain.LastHash, valid.Hash) {
+		t.Fatalf("the chain did not move to the block revealing the secret %x", valid.Hash)
+	}
+}
+
+func TestMalformedTxMessagesAreDropped(t *testing.T) {
+	sim := newTestSimulator(t, 2, 1)
+	node := sim.Nodes[0]
+
+	messages := [][]byte{
+		append(CmdToBytes("tx"), "not a gob"...),
+		append(CmdToBytes("tx"), GobEncoder(Tx{"sim:1", []byte("not a transaction")})...),
+	}
+	for _, message := range messages {
+		node.HandleMessage(message)
+	}
+
+	if txs := node.MempoolTxs(); len(txs) != 0 {
+		t.Fatalf("the memory pool holds %d transactions", len(txs))
+	}
+
+	tx := pay(t, node, sim.Wallets[0], blockchain.DefaultSendOptions, *blockchain.NewTXOutput(1, sim.Address(1)))
+	if !inPool(node, tx) {
+		t.Fatal("the node stopped accepting transactions")
+	}
+}
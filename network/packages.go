@@ -0,0 +1,274 @@
+package network
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+)
+
+const (
+	// maxAncestors and maxDescendants limit the chains of unconfirmed
+	// transactions in the memory pool, counting the transaction itself.
+	maxAncestors   = 25
+	maxDescendants = 25
+	// maxBlockSize is the number of transaction bytes a mined block is
+	// filled with at most.
+	maxBlockSize = 100000
+)
+
+// MempoolEntry describes a memory pool transaction together with the pool
+// transactions it depends on and the ones depending on it.
+type MempoolEntry struct {
+	Tx              blockchain.Transaction
+	Fee             int
+	AncestorCount   int
+	AncestorSize    int
+	AncestorFees    int
+	DescendantCount int
+	DescendantSize  int
+	DescendantFees  int
+	Depends         [][]byte
+	SpentBy         [][]byte
+}
+
+// MempoolEntry returns the memory pool transaction with the given ID and
+// its package. Counts, sizes and fees include the transaction itself.
+func (n *Node) MempoolEntry(id []byte) (MempoolEntry, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	tx, ok := n.memoryPool[hex.EncodeToString(id)]
+	if !ok {
+		return MempoolEntry{}, false
+	}
+
+	entry := MempoolEntry{Tx: tx}
+	entry.Fee, _ = n.Chain.FeeWith(&tx, n.memoryPool)
+
+	for _, ancestor := range n.ancestors(&tx) {
+		entry.AncestorCount++
+		entry.AncestorSize += ancestor.Size()
+		fee, _ := n.Chain.FeeWith(&ancestor, n.memoryPool)
+		entry.AncestorFees += fee
+	}
+
+	for _, descendant := range n.withDescendants([]blockchain.Transaction{tx}) {
+		entry.DescendantCount++
+		entry.DescendantSize += descendant.Size()
+		fee, _ := n.Chain.FeeWith(&descendant, n.memoryPool)
+		entry.DescendantFees += fee
+	}
+
+	for _, parent := range n.parents(&tx) {
+		entry.Depends = append(entry.Depends, parent.ID)
+	}
+	for _, child := range n.children(&tx) {
+		entry.SpentBy = append(entry.SpentBy, child.ID)
+	}
+
+	return entry, true
+}
+
+// parents returns the pool transactions tx spends outputs of.
+func (n *Node) parents(tx *blockchain.Transaction) []blockchain.Transaction {
+	var parents []blockchain.Transaction
+
+	seen := make(map[string]bool)
+	for _, in := range tx.Inputs {
+		id := hex.EncodeToString(in.ID)
+		if parent, ok := n.memoryPool[id]; ok && !seen[id] {
+			seen[id] = true
+			parents = append(parents, parent)
+		}
+	}
+
+	return sortTxs(parents)
+}
+
+// children returns the pool transactions spending outputs of tx.
+func (n *Node) children(tx *blockchain.Transaction) []blockchain.Transaction {
+	var children []blockchain.Transaction
+
+	for _, pooled := range n.memoryPool {
+		for _, in := range pooled.Inputs {
+			if bytes.Equal(in.ID, tx.ID) {
+				children = append(children, pooled)
+				break
+			}
+		}
+	}
+
+	return sortTxs(children)
+}
+
+// ancestors returns tx and the pool transactions it depends on, directly or
+// through others, keyed by hex encoded ID.
+func (n *Node) ancestors(tx *blockchain.Transaction) map[string]blockchain.Transaction {
+	ancestors := map[string]blockchain.Transaction{hex.EncodeToString(tx.ID): *tx}
+
+	queue := []blockchain.Transaction{*tx}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+
+		for _, parent := range n.parents(&next) {
+			id := hex.EncodeToString(parent.ID)
+			if _, ok := ancestors[id]; !ok {
+				ancestors[id] = parent
+				queue = append(queue, parent)
+			}
+		}
+	}
+
+	return ancestors
+}
+
+// checkPackageLimits checks that adding tx keeps its ancestors, and the
+// descendants of each of them, within maxAncestors and maxDescendants.
+func (n *Node) checkPackageLimits(tx *blockchain.Transaction) error {
+	ancestors := n.ancestors(tx)
+	if len(ancestors) > maxAncestors {
+		return fmt.Errorf("transaction %x has more than %d unconfirmed ancestors", tx.ID, maxAncestors-1)
+	}
+
+	for id, ancestor := range ancestors {
+		if _, ok := n.memoryPool[id]; !ok {
+			continue
+		}
+
+		if len(n.withDescendants([]blockchain.Transaction{ancestor}))+1 > maxDescendants {
+			return fmt.Errorf("transaction %x would give %x more than %d unconfirmed descendants", tx.ID, ancestor.ID, maxDescendants-1)
+		}
+	}
+
+	return nil
+}
+
+// blockTemplate picks the pool transactions for a new block. It repeatedly
+// takes the transaction whose package, itself and its ancestors not taken
+// yet, pays the best combined fee rate, so a child paying a high fee pulls
+// its parents in with it. Parents always come before their children.
+// Transactions that are invalid or spend outputs spent already are left
+// out, and so are their descendants.
+func (n *Node) blockTemplate() []*blockchain.Transaction {
+	valid := make(map[string]bool)
+	fees := make(map[string]int)
+	for id, tx := range n.memoryPool {
+		pooled := tx
+		fee, err := n.Chain.FeeWith(&pooled, n.memoryPool)
+		valid[id] = err == nil && n.Chain.VerifyTxWith(&pooled, n.memoryPool) && n.checkInputs(&pooled) == nil
+		fees[id] = fee
+	}
+
+	ancestors := make(map[string]map[string]blockchain.Transaction)
+	for id, tx := range n.memoryPool {
+		pooled := tx
+		ancestors[id] = n.ancestors(&pooled)
+	}
+
+	// A transaction is only as valid as the ones it spends.
+	for id := range n.memoryPool {
+		for ancestor := range ancestors[id] {
+			if !valid[ancestor] {
+				valid[id] = false
+			}
+		}
+	}
+
+	var ids []string
+	for id := range n.memoryPool {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var txs []*blockchain.Transaction
+	picked := make(map[string]bool)
+	skipped := make(map[string]bool)
+	size := 0
+
+	for {
+		var best []string
+		bestID, bestFee, bestSize := "", 0, 0
+
+		for _, id := range ids {
+			if picked[id] || skipped[id] || !valid[id] {
+				continue
+			}
+
+			var pkg []string
+			pkgFee, pkgSize := 0, 0
+			for ancestor, tx := range ancestors[id] {
+				if !picked[ancestor] {
+					pkg = append(pkg, ancestor)
+					pkgFee += fees[ancestor]
+					pkgSize += tx.Size()
+				}
+			}
+
+			if best == nil || pkgFee*bestSize > bestFee*pkgSize {
+				best, bestID, bestFee, bestSize = pkg, id, pkgFee, pkgSize
+			}
+		}
+
+		if best == nil {
+			break
+		}
+
+		if size+bestSize > maxBlockSize {
+			skipped[bestID] = true
+			continue
+		}
+
+		// Fewer ancestors come first, which puts every parent before its
+		// children.
+		sort.Slice(best, func(i, j int) bool {
+			if len(ancestors[best[i]]) != len(ancestors[best[j]]) {
+				return len(ancestors[best[i]]) < len(ancestors[best[j]])
+			}
+			return best[i] < best[j]
+		})
+
+		for _, id := range best {
+			tx := n.memoryPool[id]
+			picked[id] = true
+			txs = append(txs, &tx)
+		}
+		size += bestSize
+	}
+
+	return txs
+}
+
+// orderedMempool returns the pool transactions with every parent before
+// its children.
+func (n *Node) orderedMempool() []blockchain.Transaction {
+	var txs []blockchain.Transaction
+	counts := make(map[string]int)
+	for id, tx := range n.memoryPool {
+		pooled := tx
+		counts[id] = len(n.ancestors(&pooled))
+		txs = append(txs, tx)
+	}
+
+	sort.Slice(txs, func(i, j int) bool {
+		ci, cj := counts[hex.EncodeToString(txs[i].ID)], counts[hex.EncodeToString(txs[j].ID)]
+		if ci != cj {
+			return ci < cj
+		}
+		return bytes.Compare(txs[i].ID, txs[j].ID) < 0
+	})
+
+	return txs
+}
+
+// sortTxs orders txs by ID, which keeps the results of map walks stable.
+func sortTxs(txs []blockchain.Transaction) []blockchain.Transaction {
+	sort.Slice(txs, func(i, j int) bool {
+		return bytes.Compare(txs[i].ID, txs[j].ID) < 0
+	})
+
+	return txs
+}
@@ -0,0 +1,131 @@
+package network
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+)
+
+func TestBlockTemplateChildPaysForParent(t *testing.T) {
+	sim := newTestSimulator(t, 2, 1)
+	node, w := sim.Nodes[0], sim.Wallets[0]
+	sim.Mine(0)
+
+	parent := pay(t, node, w, spending(1, false, coinbase(t, node, 0)), *blockchain.NewTXOutput(5, sim.Address(1)))
+	other := pay(t, node, w, spending(10, false, coinbase(t, node, 1)), *blockchain.NewTXOutput(5, sim.Address(1)))
+	child := pay(t, node, w, spending(40, false, change(t, parent, w)), *blockchain.NewTXOutput(1, sim.Address(1)))
+
+	rate := func(tx *blockchain.Transaction) int {
+		return fee(t, node, tx) * 1000 / tx.Size()
+	}
+	if rate(parent) >= rate(other) {
+		t.Fatalf("the parent pays rate %d, not less than the %d of the other transaction", rate(parent), rate(other))
+	}
+
+	entry, _ := node.MempoolEntry(child.ID)
+	if entry.AncestorCount != 2 || entry.AncestorFees != fee(t, node, parent)+fee(t, node, child) {
+		t.Fatalf("the child has %d ancestors paying %d", entry.AncestorCount, entry.AncestorFees)
+	}
+
+	block := sim.Mine(0)
+
+	want := []*blockchain.Transaction{parent, child, other}
+	if len(block.Transactions) != len(want)+1 {
+		t.Fatalf("mined %d transactions, want %d and the coinbase", len(block.Transactions), len(want))
+	}
+	for i, tx := range want {
+		if !bytes.Equal(block.Transactions[i].ID, tx.ID) {
+			t.Errorf("transaction %d is %x, want %x", i, block.Transactions[i].ID, tx.ID)
+		}
+	}
+	if txs := node.MempoolTxs(); len(txs) != 0 {
+		t.Fatalf("%d transactions stayed in the memory pool", len(txs))
+	}
+}
+
+func TestAncestorLimit(t *testing.T) {
+	sim := newTestSimulator(t, 2, 1)
+	node, w := sim.Nodes[0], sim.Wallets[0]
+	sim.Mine(0)
+
+	// Each transaction pays everything but a fee of 1 back to w.
+	outpoints := []blockchain.UTXO{coinbase(t, node, 0), coinbase(t, node, 1)}
+	value := 2*blockchain.Subsidy - 1
+	for i := 0; i < maxAncestors; i++ {
+		tx := pay(t, node, w, spending(1, false, outpoints...), *blockchain.NewTXOutput(value, sim.Address(0)))
+		outpoints = []blockchain.UTXO{change(t, tx, w)}
+		value--
+	}
+
+	_, err := node.AddNewTx(func(UTXO *blockchain.UTXOSet) (*blockchain.Transaction, error) {
+		return blockchain.NewTransactionWith(w, []blockchain.TxOutput{*blockchain.NewTXOutput(value, sim.Address(0))}, UTXO, spending(1, false, outpoints...))
+	})
+	if err == nil || !strings.Contains(err.Error(), "unconfirmed ancestors") {
+		t.Fatalf("transaction %d of a chain got %v", maxAncestors+1, err)
+	}
+	if txs := node.MempoolTxs(); len(txs) != maxAncestors {
+		t.Fatalf("the memory pool holds %d transactions, want %d", len(txs), maxAncestors)
+	}
+}
+
+func TestBlockEvictsConflicts(t *testing.T) {
+	sim := newTestSimulator(t, 2, 1)
+	node, w := sim.Nodes[0], sim.Wallets[0]
+	genesis := coinbase(t, node, 0)
+
+	sim.Partition([]int{0}, []int{1})
+
+	tx := pay(t, node, w, spending(1, false, genesis), *blockchain.NewTXOutput(5, sim.Address(1)))
+	child := pay(t, node, w, spending(1, false, change(t, tx, w)), *blockchain.NewTXOutput(5, sim.Address(1)))
+	conflict := pay(t, sim.Nodes[1], w, spending(1, false, genesis), *blockchain.NewTXOutput(7, sim.Address(1)))
+
+	sim.Mine(1)
+	sim.RunUntilIdle()
+	sim.Heal()
+	sim.Sync()
+	sim.RunUntilIdle()
+
+	if !sim.Converged() {
+		t.Fatalf("nodes did not converge, heights %v", heights(sim))
+	}
+	if !inChain(t, node, conflict) {
+		t.Fatal("the conflicting transaction was not mined")
+	}
+	if inPool(node, tx) || inPool(node, child) {
+		t.Fatal("transactions spending outputs the block spends stayed in the memory pool")
+	}
+
+	// The template must not pick them up again either.
+	if block := sim.Mine(0); len(block.Transactions) != 1 {
+		t.Fatalf("mined %d transactions besides the coinbase", len(block.Transactions)-1)
+	}
+}
+
+func TestSpentInputsAreRejected(t *testing.T) {
+	sim := newTestSimulator(t, 2, 1)
+	node, w := sim.Nodes[0], sim.Wallets[0]
+	genesis := coinbase(t, node, 0)
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: node.Chain}
+	b, err := blockchain.NewTransactionWith(w, []blockchain.TxOutput{*blockchain.NewTXOutput(6, sim.Address(1))}, &UTXOSet, spending(1, false, genesis))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := pay(t, node, w, spending(1, false, genesis), *blockchain.NewTXOutput(5, sim.Address(1)))
+	sim.Mine(0)
+	if !inChain(t, node, a) {
+		t.Fatal("the first spend was not mined")
+	}
+
+	if err := node.AddTx(b); err == nil || !strings.Contains(err.Error(), "is spent") {
+		t.Fatalf("adding a transaction spending a mined output got %v", err)
+	}
+
+	deliverTx(node, "sim:1", b)
+	if inPool(node, b) {
+		t.Fatal("a relayed transaction spending a mined output entered the memory pool")
+	}
+}
@@ -0,0 +1,144 @@
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// Simulator runs several nodes in one process on top of a MemoryNetwork.
+// Node 0 plays the role of the well-known seed node and its wallet owns the
+// genesis reward. Blocks are stamped with the virtual clock of the network,
+// so a run depends on its seed only, not on the wall clock.
+type Simulator struct {
+	Network *MemoryNetwork
+	Nodes   []*Node
+	Wallets []*wallet.Wallet
+}
+
+func NewSimulator(dir string, size int, seed int64) (*Simulator, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("simulator needs at least one node")
+	}
+
+	sim := &Simulator{Network: NewMemoryNetwork(seed)}
+
+	for i := 0; i < size; i++ {
+		sim.Wallets = append(sim.Wallets, wallet.MakeWallet())
+	}
+	genesisAddress := string(sim.Wallets[0].Address())
+
+	for i := 0; i < size; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("node_%d", i))
+		chain := blockchain.InitBlockchainWith(path, genesisAddress, sim.Network.Clock.Now)
+		UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+		UTXOSet.Reindex()
+
+		address := fmt.Sprintf("sim:%d", i)
+		node := NewNode(address, string(sim.Wallets[i].Address()), chain, sim.Network.Transport())
+		node.KnownNodes = []string{"sim:0"}
+		sim.Nodes = append(sim.Nodes, node)
+	}
+
+	for _, node := range sim.Nodes {
+		if err := node.Start(); err != nil {
+			sim.Close()
+			return nil, err
+		}
+	}
+
+	return sim, nil
+}
+
+func (sim *Simulator) Address(i int) string {
+	return string(sim.Wallets[i].Address())
+}
+
+func (sim *Simulator) Send(from, to, amount int) (*blockchain.Transaction, error) {
+	node := sim.Nodes[from]
+	UTXOSet := blockchain.UTXOSet{Blockchain: node.Chain}
+
+	tx := blockchain.NewTransaction(sim.Wallets[from], sim.Address(to), amount, &UTXOSet)
+
+	return tx, node.AddTx(tx)
+}
+
+// SendMany pays every node in amounts from node from in one transaction, the
+// way a pool pays out its miners.
+func (sim *Simulator) SendMany(from int, amounts map[int]int) (*blockchain.Transaction, error) {
+	var to []int
+	for i := range amounts {
+		to = append(to, i)
+	}
+	sort.Ints(to)
+
+	var outputs []blockchain.TxOutput
+	for _, i := range to {
+		outputs = append(outputs, *blockchain.NewTXOutput(amounts[i], sim.Address(i)))
+	}
+
+	node := sim.Nodes[from]
+	UTXOSet := blockchain.UTXOSet{Blockchain: node.Chain}
+
+	tx, err := blockchain.NewTransactionWith(sim.Wallets[from], outputs, &UTXOSet, blockchain.DefaultSendOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return tx, node.AddTx(tx)
+}
+
+func (sim *Simulator) Mine(i int) *blockchain.Block {
+	return sim.Nodes[i].MineBlock(sim.Address(i))
+}
+
+func (sim *Simulator) Partition(groups ...[]int) {
+	var addressGroups [][]string
+
+	for _, group := range groups {
+		var addresses []string
+		for _, i := range group {
+			addresses = append(addresses, sim.Nodes[i].Address)
+		}
+		addressGroups = append(addressGroups, addresses)
+	}
+
+	sim.Network.Partition(addressGroups...)
+}
+
+func (sim *Simulator) Heal() {
+	sim.Network.Heal()
+}
+
+func (sim *Simulator) Sync() {
+	for _, node := range sim.Nodes {
+		node.Sync()
+	}
+}
+
+func (sim *Simulator) RunUntilIdle() int {
+	return sim.Network.RunUntilIdle(1000000)
+}
+
+func (sim *Simulator) Converged() bool {
+	tip := sim.Nodes[0].Chain.LastHash
+
+	for _, node := range sim.Nodes[1:] {
+		if !bytes.Equal(node.Chain.LastHash, tip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (sim *Simulator) Close() {
+	for _, node := range sim.Nodes {
+		node.Stop()
+		node.Chain.Database.Close()
+	}
+}
@@ -0,0 +1,316 @@
+package network
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+func newTestSimulator(t *testing.T, size int, seed int64) *Simulator {
+	sim, err := NewSimulator(t.TempDir(), size, seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sim.Close)
+
+	sim.RunUntilIdle()
+
+	return sim
+}
+
+func heights(sim *Simulator) []int {
+	var heights []int
+	for _, node := range sim.Nodes {
+		heights = append(heights, node.Chain.GetBestHeight())
+	}
+
+	return heights
+}
+
+// pay adds a transaction of node paying outputs from w to its memory pool.
+func pay(t *testing.T, node *Node, w *wallet.Wallet, opts blockchain.SendOptions, outputs ...blockchain.TxOutput) *blockchain.Transaction {
+	t.Helper()
+
+	tx, err := node.AddNewTx(func(UTXO *blockchain.UTXOSet) (*blockchain.Transaction, error) {
+		return blockchain.NewTransactionWith(w, outputs, UTXO, opts)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tx
+}
+
+func blocksOf(t *testing.T, node *Node) []*blockchain.Block {
+	var blocks []*blockchain.Block
+
+	iter := node.Chain.Iterator()
+	for {
+		block := iter.Next()
+		blocks = append(blocks, block)
+		if len(block.PrevHash) == 0 {
+			return blocks
+		}
+	}
+}
+
+func inChain(t *testing.T, node *Node, tx *blockchain.Transaction) bool {
+	for _, block := range blocksOf(t, node) {
+		for _, mined := range block.Transactions {
+			if bytes.Equal(mined.ID, tx.ID) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func TestSimulatorGenesis(t *testing.T) {
+	sim := newTestSimulator(t, 3, 1)
+
+	if !sim.Converged() {
+		t.Fatal("nodes start from different genesis blocks")
+	}
+
+	for i, node := range sim.Nodes {
+		genesis, err := node.Chain.GetBlock(node.Chain.LastHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if genesis.Timestamp != 0 {
+			t.Errorf("node %d: genesis is stamped %d, not with the virtual clock", i, genesis.Timestamp)
+		}
+	}
+}
+
+func TestSimulatorSync(t *testing.T) {
+	sim := newTestSimulator(t, 3, 1)
+
+	sim.Mine(1)
+	sim.RunUntilIdle()
+	sim.Mine(2)
+	sim.RunUntilIdle()
+
+	if !sim.Converged() {
+		t.Fatalf("nodes did not converge, heights %v", heights(sim))
+	}
+	if height := sim.Nodes[0].Chain.GetBestHeight(); height != 2 {
+		t.Fatalf("got height %d, want 2", height)
+	}
+}
+
+func TestSimulatorPartitionFork(t *testing.T) {
+	sim := newTestSimulator(t, 3, 1)
+
+	sim.Partition([]int{0, 1}, []int{2})
+
+	sim.Mine(1)
+	sim.RunUntilIdle()
+	sim.Mine(2)
+	sim.Mine(2)
+	sim.RunUntilIdle()
+
+	if sim.Converged() {
+		t.Fatal("a partitioned network converged")
+	}
+	if got := heights(sim); got[0] != 1 || got[1] != 1 || got[2] != 2 {
+		t.Fatalf("got heights %v, want [1 1 2]", got)
+	}
+
+	longest := sim.Nodes[2].Chain.LastHash
+
+	sim.Heal()
+	sim.Sync()
+	sim.RunUntilIdle()
+
+	if !sim.Converged() {
+		t.Fatalf("nodes did not converge after healing, heights %v", heights(sim))
+	}
+	if !bytes.Equal(sim.Nodes[0].Chain.LastHash, longest) {
+		t.Fatal("the network did not settle on the longest chain")
+	}
+}
+
+func TestSimulatorForkRemovesMinedTransactions(t *testing.T) {
+	sim := newTestSimulator(t, 3, 1)
+
+	sim.Mine(0)
+	sim.RunUntilIdle()
+
+	sim.Partition([]int{0, 1}, []int{2})
+
+	tx, err := sim.Send(0, 1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.RunUntilIdle()
+
+	if _, ok := sim.Nodes[1].MempoolTx(tx.ID); !ok {
+		t.Fatal("the transaction did not reach node 1")
+	}
+	if _, ok := sim.Nodes[2].MempoolTx(tx.ID); ok {
+		t.Fatal("the transaction crossed the partition")
+	}
+
+	sim.Mine(1)
+	sim.RunUntilIdle()
+	sim.Heal()
+	sim.Sync()
+	sim.RunUntilIdle()
+
+	if !sim.Converged() {
+		t.Fatalf("nodes did not converge, heights %v", heights(sim))
+	}
+	for i, node := range sim.Nodes {
+		if _, ok := node.MempoolTx(tx.ID); ok {
+			t.Errorf("node %d kept the mined transaction in its memory pool", i)
+		}
+	}
+}
+
+// TestSimulatorReproducible runs the same scenario twice. Keys and
+// signatures are random, so block hashes differ, but the messages delivered,
+// the virtual time and the chains the nodes end up with must not.
+func TestSimulatorReproducible(t *testing.T) {
+	run := func() ([]int, []int64, int) {
+		sim := newTestSimulator(t, 4, 7)
+		sim.Network.SetLatency(time.Second, 90*time.Second)
+
+		steps := 0
+		sim.Partition([]int{0, 1}, []int{2, 3})
+		for _, miner := range []int{1, 3, 3} {
+			sim.Network.Clock.Advance(10 * time.Minute)
+			sim.Mine(miner)
+			steps += sim.RunUntilIdle()
+		}
+		sim.Heal()
+		sim.Sync()
+		steps += sim.RunUntilIdle()
+
+		var timestamps []int64
+		iter := sim.Nodes[0].Chain.Iterator()
+		for {
+			block := iter.Next()
+			timestamps = append(timestamps, block.Timestamp)
+			if len(block.PrevHash) == 0 {
+				break
+			}
+		}
+
+		return heights(sim), timestamps, steps
+	}
+
+	if _, timestamps, _ := run(); timestamps[0] == 0 {
+		t.Fatal("the tip is stamped 0, the virtual clock did not advance")
+	}
+
+	heights1, timestamps1, steps1 := run()
+	heights2, timestamps2, steps2 := run()
+
+	if steps1 != steps2 {
+		t.Errorf("delivered %d and %d messages", steps1, steps2)
+	}
+	if len(heights1) != len(heights2) || len(timestamps1) != len(timestamps2) {
+		t.Fatalf("got heights %v and %v, timestamps %v and %v", heights1, heights2, timestamps1, timestamps2)
+	}
+	for i := range heights1 {
+		if heights1[i] != heights2[i] {
+			t.Errorf("got heights %v and %v", heights1, heights2)
+			break
+		}
+	}
+	for i := range timestamps1 {
+		if timestamps1[i] != timestamps2[i] {
+			t.Errorf("got timestamps %v and %v", timestamps1, timestamps2)
+			break
+		}
+	}
+}
+
+func TestSimulatorMultiSigSpend(t *testing.T) {
+	sim := newTestSimulator(t, 3, 1)
+	node := sim.Nodes[0]
+
+	redeemScript, err := blockchain.NewMultiSigScript(2, [][]byte{sim.Wallets[0].PublicKey, sim.Wallets[1].PublicKey, sim.Wallets[2].PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := string(wallet.ScriptHashToAddress(wallet.ScriptHash(redeemScript)))
+
+	pay(t, node, sim.Wallets[0], blockchain.DefaultSendOptions, *blockchain.NewTXOutput(10, address))
+	sim.Mine(0)
+
+	var ptx *blockchain.PartialTx
+	node.PendingView(func(UTXO *blockchain.UTXOSet) {
+		var tx *blockchain.Transaction
+		if tx, err = blockchain.NewScriptHashTransactionWith(address, []blockchain.TxOutput{*blockchain.NewTXOutput(8, sim.Address(2))}, UTXO, blockchain.DefaultSendOptions); err == nil {
+			ptx, err = blockchain.NewPartialTx(tx, UTXO)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ptx.AddRedeemScript(redeemScript); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cosigners sign copies passed around serialized, like a partially
+	// signed transaction travels between wallets.
+	cosigned, err := blockchain.DeserializePartialTx(ptx.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ptx.Sign(sim.Wallets[1])
+	cosigned.Sign(sim.Wallets[2])
+
+	if _, err := ptx.Finalize(); err == nil {
+		t.Fatal("finalized a 2 of 3 multisig spend with one signature")
+	}
+	if err := ptx.Combine(cosigned); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := ptx.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := node.AddTx(tx); err != nil {
+		t.Fatal(err)
+	}
+
+	sim.Mine(0)
+	sim.RunUntilIdle()
+
+	if !sim.Converged() {
+		t.Fatalf("nodes did not converge, heights %v", heights(sim))
+	}
+	if !inChain(t, sim.Nodes[2], tx) {
+		t.Fatal("the multisig spend was not mined")
+	}
+}
+
+func TestSimulatorSendMany(t *testing.T) {
+	sim := newTestSimulator(t, 3, 1)
+
+	tx, err := sim.SendMany(0, map[int]int{2: 7, 1: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.Outputs) != 3 || tx.Outputs[0].Address() != sim.Address(1) || tx.Outputs[1].Address() != sim.Address(2) {
+		t.Fatalf("got outputs %+v, want the payouts in node order and change", tx.Outputs)
+	}
+
+	sim.Mine(0)
+	sim.RunUntilIdle()
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: sim.Nodes[2].Chain}
+	for i, want := range map[int]int{1: 5, 2: 7} {
+		if got := blockchain.TokenBalance(UTXOSet.FindUTXO(wallet.PublicKeyHash(sim.Wallets[i].PublicKey)), nil); got != want {
+			t.Errorf("node %d has %d, want %d", i, got, want)
+		}
+	}
+}
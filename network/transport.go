@@ -0,0 +1,77 @@
+package network
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+)
+
+type Handler func(data []byte)
+
+type Transport interface {
+	Listen(address string, handler Handler) error
+	Send(address string, data []byte) error
+	Close() error
+}
+
+type TCPTransport struct {
+	listener net.Listener
+}
+
+func NewTCPTransport() *TCPTransport {
+	return &TCPTransport{}
+}
+
+func (t *TCPTransport) Listen(address string, handler Handler) error {
+	ln, err := net.Listen(protocol, address)
+	if err != nil {
+		return err
+	}
+	t.listener = ln
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				log.Println(err)
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				req, err := ioutil.ReadAll(conn)
+				if err != nil {
+					log.Println(err)
+					return
+				}
+
+				handler(req)
+			}(conn)
+		}
+	}()
+
+	return nil
+}
+
+func (t *TCPTransport) Send(address string, data []byte) error {
+	conn, err := net.Dial(protocol, address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = io.Copy(conn, bytes.NewReader(data))
+
+	return err
+}
+
+func (t *TCPTransport) Close() error {
+	if t.listener == nil {
+		return nil
+	}
+
+	return t.listener.Close()
+}
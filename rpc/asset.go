@@ -0,0 +1,165 @@
+package rpc
+
+import (
+	"encoding/hex"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// AssetResult describes an issued asset and how many of its tokens the
+// wallet holds.
+type AssetResult struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Supply    int    `json:"supply"`
+	IssueTxID string `json:"issuetxid"`
+	Balance   int    `json:"balance"`
+}
+
+func newAssetResult(asset *blockchain.Asset) AssetResult {
+	return AssetResult{
+		ID:        hex.EncodeToString(asset.ID),
+		Name:      asset.Name,
+		Supply:    asset.Supply,
+		IssueTxID: hex.EncodeToString(asset.IssueTx),
+	}
+}
+
+// IssueAsset issues supply tokens of a new asset called name to the wallet
+// address from.
+func IssueAsset(wallets *wallet.Wallets, from, name string, supply int, UTXO *blockchain.UTXOSet, opts blockchain.SendOptions) (*blockchain.Transaction, AssetResult, error) {
+	w, err := signingWallet(wallets, from)
+	if err != nil {
+		return nil, AssetResult{}, err
+	}
+
+	tx, err := blockchain.NewIssuanceTx(w, name, supply, UTXO, opts)
+	if err != nil {
+		return nil, AssetResult{}, err
+	}
+
+	asset, _ := tx.Issuance()
+	result := newAssetResult(asset)
+	result.Balance = supply
+
+	return tx, result, nil
+}
+
+// ListAssets returns the assets issued on the chain with the wallet's
+// balance of each.
+func ListAssets(wallets *wallet.Wallets, UTXO *blockchain.UTXOSet) []AssetResult {
+	results := []AssetResult{}
+
+	for _, asset := range UTXO.Blockchain.FindAssets() {
+		result := newAssetResult(asset)
+		result.Balance = WalletBalanceOf(wallets, UTXO, asset.ID).Mine
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// WalletBalanceOf adds up the tokens of asset the wallet holds, or its coins
+// if asset is nil.
+func WalletBalanceOf(wallets *wallet.Wallets, UTXO *blockchain.UTXOSet, asset []byte) WalletBalance {
+	var balance WalletBalance
+
+	for _, address := range wallets.GetAllAddresses() {
+		pubKeyHash := wallet.AddressToPubKeyHash(address)
+		balance.Mine += blockchain.TokenBalance(UTXO.FindUTXO(pubKeyHash), asset)
+		balance.Pending += UTXO.PendingBalance(pubKeyHash, asset)
+	}
+
+	for _, address := range wallets.GetWatchedAddresses() {
+		balance.WatchOnly += blockchain.TokenBalance(UTXO.FindUTXO(wallet.AddressToPubKeyHash(address)), asset)
+	}
+
+	return balance
+}
+
+// FindAssetID resolves the hex encoded ID or the name of an asset, where
+// empty stands for the native coin.
+func FindAssetID(chain *blockchain.BlockChain, idOrName string) ([]byte, error) {
+	if idOrName == "" {
+		return nil, nil
+	}
+
+	asset, err := chain.FindAsset(idOrName)
+	if err != nil {
+		return nil, err
+	}
+
+	return asset.ID, nil
+}
+
+// asset reads the optional asset parameter i.
+func (a args) asset(i int, chain *blockchain.BlockChain) ([]byte, error) {
+	var idOrName string
+	if err := a.optional(i, &idOrName); err != nil {
+		return nil, err
+	}
+
+	id, err := FindAssetID(chain, idOrName)
+	if err != nil {
+		return nil, &Error{InvalidParams, err.Error()}
+	}
+
+	return id, nil
+}
+
+func issueAsset(s *Server, params args) (interface{}, error) {
+	from, err := params.address(0)
+	if err != nil {
+		return nil, err
+	}
+
+	var name string
+	if err := params.get(1, &name); err != nil {
+		return nil, err
+	}
+
+	var supply int
+	if err := params.get(2, &supply); err != nil {
+		return nil, err
+	}
+
+	opts, err := s.sendOptions(params, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	var tx *blockchain.Transaction
+	var result AssetResult
+	tx, err = s.node.AddNewTx(func(UTXOSet *blockchain.UTXOSet) (*blockchain.Transaction, error) {
+		tx, result, err = IssueAsset(wallets, from, name, supply, UTXOSet, opts)
+		return tx, err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func listAssets(s *Server, params args) (interface{}, error) {
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []AssetResult
+	s.node.View(func(chain *blockchain.BlockChain) {
+		UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+		results = ListAssets(wallets, &UTXOSet)
+	})
+
+	return results, nil
+}
@@ -0,0 +1,121 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// BumpResult describes a transaction replaced by one paying a higher fee.
+type BumpResult struct {
+	TxID     string `json:"txid"`
+	OrigTxID string `json:"origtxid"`
+	OrigFee  int    `json:"origfee"`
+	Fee      int    `json:"fee"`
+}
+
+// BumpFee builds a replacement of tx paying fee rate rate, or the rate of
+// tx plus DefaultFeeRate if rate is 0. tx has to signal replace-by-fee and
+// spend key outputs of one wallet address, whose change output pays the
+// higher fee.
+func BumpFee(wallets *wallet.Wallets, UTXO *blockchain.UTXOSet, tx *blockchain.Transaction, rate blockchain.FeeRate) (*blockchain.Transaction, BumpResult, error) {
+	if !tx.SignalsReplacement() {
+		return nil, BumpResult{}, fmt.Errorf("transaction %x does not signal replace-by-fee", tx.ID)
+	}
+
+	from := ""
+	for _, in := range tx.Inputs {
+		prevTx, err := UTXO.Blockchain.FindTxWith(in.ID, UTXO.Pending)
+		if err != nil {
+			return nil, BumpResult{}, err
+		}
+
+		prevOut := prevTx.Outputs[in.Out]
+		if len(prevOut.Script) > 0 || (from != "" && prevOut.Address() != from) {
+			return nil, BumpResult{}, fmt.Errorf("transaction %x does not only spend outputs of one wallet address", tx.ID)
+		}
+		from = prevOut.Address()
+	}
+
+	w, err := signingWallet(wallets, from)
+	if err != nil {
+		return nil, BumpResult{}, err
+	}
+
+	change := -1
+	for i, out := range tx.Outputs {
+		if out.Address() == from && len(out.Script) == 0 && !out.HasAsset() {
+			change = i
+		}
+	}
+	if change < 0 {
+		return nil, BumpResult{}, fmt.Errorf("transaction %x has no change output to pay a higher fee from", tx.ID)
+	}
+
+	oldFee, err := UTXO.Blockchain.FeeWith(tx, UTXO.Pending)
+	if err != nil {
+		return nil, BumpResult{}, err
+	}
+
+	oldRate := blockchain.FeeRateOf(oldFee, tx.Size())
+	if rate == 0 {
+		rate = oldRate + blockchain.DefaultFeeRate
+	}
+	if rate <= oldRate {
+		return nil, BumpResult{}, fmt.Errorf("fee rate %d is not higher than the %d of transaction %x", rate, oldRate, tx.ID)
+	}
+
+	fee := rate.Fee(tx.Size())
+	if fee <= oldFee {
+		fee = oldFee + 1
+	}
+
+	bump, err := blockchain.NewFeeBumpTx(tx, w, change, fee, UTXO)
+	if err != nil {
+		return nil, BumpResult{}, err
+	}
+
+	return bump, BumpResult{hex.EncodeToString(bump.ID), hex.EncodeToString(tx.ID), oldFee, fee}, nil
+}
+
+// bumpFee replaces a wallet transaction in the memory pool, taking its ID
+// and an optional fee rate.
+func bumpFee(s *Server, params args) (interface{}, error) {
+	id, err := params.txID(0)
+	if err != nil {
+		return nil, err
+	}
+
+	var rate int
+	if err := params.optional(1, &rate); err != nil {
+		return nil, err
+	}
+	if rate < 0 {
+		return nil, &Error{InvalidParams, "fee rate must not be negative"}
+	}
+
+	tx, ok := s.node.MempoolTx(id)
+	if !ok {
+		return nil, fmt.Errorf("transaction %x is not in the memory pool", id)
+	}
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	var bump *blockchain.Transaction
+	var result BumpResult
+	bump, err = s.node.AddNewTx(func(UTXOSet *blockchain.UTXOSet) (*blockchain.Transaction, error) {
+		bump, result, err = BumpFee(wallets, UTXOSet, &tx, blockchain.FeeRate(rate))
+		return bump, err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
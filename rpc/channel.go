@@ -0,0 +1,409 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// ChannelTimeout is how long a new payment channel stays open by default.
+// The payee has to close it before, or the payer can take the funds back.
+const ChannelTimeout = 24 * time.Hour
+
+// ChannelResult describes a payment channel. Status is open or closed once
+// the chain has been looked at.
+type ChannelResult struct {
+	ID       string `json:"id"`
+	Out      int    `json:"vout"`
+	Payer    string `json:"payer"`
+	Payee    string `json:"payee"`
+	Capacity int    `json:"capacity"`
+	Paid     int    `json:"paid"`
+	LockTime int64  `json:"locktime"`
+	Status   string `json:"status,omitempty"`
+}
+
+// ChannelPayment is a channel after a payment together with the hex encoded
+// payment the payer hands to the payee.
+type ChannelPayment struct {
+	ChannelResult
+	Payment string `json:"payment"`
+}
+
+func newChannelResult(c *wallet.Channel) (ChannelResult, error) {
+	contract, err := blockchain.ParseChannelScript(c.Script)
+	if err != nil {
+		return ChannelResult{}, err
+	}
+
+	return ChannelResult{
+		ID:       c.ID(),
+		Out:      c.Out,
+		Payer:    contract.PayerAddress(),
+		Payee:    contract.PayeeAddress(),
+		Capacity: c.Capacity,
+		Paid:     c.Paid,
+		LockTime: contract.LockTime,
+	}, nil
+}
+
+func fundingOutput(c *wallet.Channel) blockchain.TxOutput {
+	return *blockchain.NewScriptOutput(c.Capacity, c.Script)
+}
+
+// OpenChannel locks amount from the wallet address from into a channel
+// paying to, whose public key the wallet has to know, and stores the
+// channel in the wallet.
+func OpenChannel(wallets *wallet.Wallets, from, to string, amount int, timeout time.Duration, UTXO *blockchain.UTXOSet, opts blockchain.SendOptions) (*blockchain.Transaction, ChannelResult, error) {
+	w, err := signingWallet(wallets, from)
+	if err != nil {
+		return nil, ChannelResult{}, err
+	}
+
+	payeeKey, ok := wallets.PublicKey(to)
+	if !ok {
+		return nil, ChannelResult{}, fmt.Errorf("the public key of %s is unknown, import it with importpubkey", to)
+	}
+	if amount <= 0 {
+		return nil, ChannelResult{}, errors.New("amount must be positive")
+	}
+	if timeout <= 0 {
+		return nil, ChannelResult{}, errors.New("timeout must be positive")
+	}
+
+	contract := &blockchain.PaymentChannel{
+		PayerPubKey: w.PublicKey,
+		PayeePubKey: payeeKey,
+		LockTime:    time.Now().Add(timeout).Unix(),
+	}
+	script := contract.Script()
+
+	tx, err := blockchain.NewTransactionWith(w, []blockchain.TxOutput{*blockchain.NewScriptOutput(amount, script)}, UTXO, opts)
+	if err != nil {
+		return nil, ChannelResult{}, err
+	}
+
+	c := &wallet.Channel{FundingTxID: tx.ID, Script: script, Capacity: amount}
+	for i, out := range tx.Outputs {
+		if bytes.Equal(out.Script, script) {
+			c.Out = i
+		}
+	}
+	wallets.AddChannel(c)
+
+	result, err := newChannelResult(c)
+
+	return tx, result, err
+}
+
+// PayChannel signs a commitment paying amount more to the payee of the
+// channel id and returns the payment to hand to the payee.
+func PayChannel(wallets *wallet.Wallets, id string, amount int) (ChannelPayment, error) {
+	c, ok := wallets.Channel(id)
+	if !ok {
+		return ChannelPayment{}, fmt.Errorf("unknown channel %s", id)
+	}
+	if amount <= 0 {
+		return ChannelPayment{}, errors.New("amount must be positive")
+	}
+
+	result, err := newChannelResult(c)
+	if err != nil {
+		return ChannelPayment{}, err
+	}
+
+	w, err := signingWallet(wallets, result.Payer)
+	if err != nil {
+		return ChannelPayment{}, err
+	}
+
+	tx, err := blockchain.NewCommitmentTx(c.FundingTxID, c.Out, fundingOutput(c), c.Paid+amount, blockchain.DefaultFeeRate)
+	if err != nil {
+		return ChannelPayment{}, err
+	}
+
+	c.Paid += amount
+	c.PayerSignature = tx.SignatureFor(0, w.PrivateKey, fundingOutput(c))
+	result.Paid = c.Paid
+
+	return ChannelPayment{result, hex.EncodeToString(c.Serialize())}, nil
+}
+
+// AcceptChannelPayment checks a payment for a channel whose payee is in the
+// wallet and keeps it if it pays more than the last one. The funding output
+// has to be unspent in the UTXO set.
+func AcceptChannelPayment(wallets *wallet.Wallets, payment []byte, UTXO *blockchain.UTXOSet) (ChannelResult, error) {
+	c, err := wallet.DeserializeChannel(payment)
+	if err != nil {
+		return ChannelResult{}, err
+	}
+
+	contract, err := blockchain.ParseChannelScript(c.Script)
+	if err != nil {
+		return ChannelResult{}, err
+	}
+
+	result, err := newChannelResult(c)
+	if err != nil {
+		return ChannelResult{}, err
+	}
+
+	if _, ok := wallets.Wallets[result.Payee]; !ok {
+		return ChannelResult{}, fmt.Errorf("payee %s is not in the wallet", result.Payee)
+	}
+
+	out, ok := UTXO.GetOutput(c.FundingTxID, c.Out)
+	if !ok || out.Value != c.Capacity || !bytes.Equal(out.Script, c.Script) {
+		return ChannelResult{}, fmt.Errorf("channel %s is not funded by an unspent confirmed output", c.ID())
+	}
+
+	if known, ok := wallets.Channel(c.ID()); ok && known.Paid > c.Paid {
+		return ChannelResult{}, fmt.Errorf("the payment pays %d, less than the %d already paid", c.Paid, known.Paid)
+	}
+
+	tx, err := blockchain.NewCommitmentTx(c.FundingTxID, c.Out, fundingOutput(c), c.Paid, blockchain.DefaultFeeRate)
+	if err != nil {
+		return ChannelResult{}, err
+	}
+	if !blockchain.CheckCommitmentSignature(tx, fundingOutput(c), c.PayerSignature, contract.PayerPubKey) {
+		return ChannelResult{}, errors.New("the payer's signature of the payment is not valid")
+	}
+
+	wallets.AddChannel(c)
+
+	return result, nil
+}
+
+// CloseChannel signs the latest commitment of the channel id as its payee,
+// paying out the channel.
+func CloseChannel(wallets *wallet.Wallets, id string) (*blockchain.Transaction, error) {
+	c, ok := wallets.Channel(id)
+	if !ok {
+		return nil, fmt.Errorf("unknown channel %s", id)
+	}
+
+	result, err := newChannelResult(c)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := signingWallet(wallets, result.Payee)
+	if err != nil {
+		return nil, err
+	}
+	if c.PayerSignature == nil {
+		return nil, fmt.Errorf("channel %s has not paid anything yet", id)
+	}
+
+	tx, err := blockchain.NewCommitmentTx(c.FundingTxID, c.Out, fundingOutput(c), c.Paid, blockchain.DefaultFeeRate)
+	if err != nil {
+		return nil, err
+	}
+
+	return blockchain.CloseCommitmentTx(tx, fundingOutput(c), c.PayerSignature, w)
+}
+
+// RefundChannel spends the channel id back to its payer, which is only
+// accepted once the channel's lock time has passed.
+func RefundChannel(wallets *wallet.Wallets, id string) (*blockchain.Transaction, error) {
+	c, ok := wallets.Channel(id)
+	if !ok {
+		return nil, fmt.Errorf("unknown channel %s", id)
+	}
+
+	result, err := newChannelResult(c)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := signingWallet(wallets, result.Payer)
+	if err != nil {
+		return nil, err
+	}
+
+	return blockchain.NewChannelRefundTx(c.FundingTxID, c.Out, fundingOutput(c), w, blockchain.DefaultFeeRate)
+}
+
+// ListChannels returns the channels of the wallet, open while their funding
+// output is unspent.
+func ListChannels(wallets *wallet.Wallets, UTXO *blockchain.UTXOSet) ([]ChannelResult, error) {
+	results := []ChannelResult{}
+
+	for _, id := range wallets.ChannelIDs() {
+		c, _ := wallets.Channel(id)
+
+		result, err := newChannelResult(c)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Status = "closed"
+		if _, ok := UTXO.GetOutput(c.FundingTxID, c.Out); ok {
+			result.Status = "open"
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func openChannel(s *Server, params args) (interface{}, error) {
+	s.walletFile.Lock()
+	defer s.walletFile.Unlock()
+
+	from, err := params.address(0)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := params.address(1)
+	if err != nil {
+		return nil, err
+	}
+
+	var amount int
+	if err := params.get(2, &amount); err != nil {
+		return nil, err
+	}
+
+	timeout, err := params.duration(3, ChannelTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := s.sendOptions(params, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	var tx *blockchain.Transaction
+	var result ChannelResult
+	tx, err = s.node.AddNewTx(func(UTXOSet *blockchain.UTXOSet) (*blockchain.Transaction, error) {
+		tx, result, err = OpenChannel(wallets, from, to, amount, timeout, UTXOSet, opts)
+		return tx, err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	wallets.SaveFile(s.NodeID)
+
+	return result, nil
+}
+
+func payChannel(s *Server, params args) (interface{}, error) {
+	s.walletFile.Lock()
+	defer s.walletFile.Unlock()
+
+	var id string
+	if err := params.get(0, &id); err != nil {
+		return nil, err
+	}
+
+	var amount int
+	if err := params.get(1, &amount); err != nil {
+		return nil, err
+	}
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	payment, err := PayChannel(wallets, id, amount)
+	if err != nil {
+		return nil, err
+	}
+	wallets.SaveFile(s.NodeID)
+
+	return payment, nil
+}
+
+func acceptChannelPayment(s *Server, params args) (interface{}, error) {
+	s.walletFile.Lock()
+	defer s.walletFile.Unlock()
+
+	payment, err := params.hex(0)
+	if err != nil {
+		return nil, err
+	}
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	var result ChannelResult
+	s.node.View(func(chain *blockchain.BlockChain) {
+		UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+		result, err = AcceptChannelPayment(wallets, payment, &UTXOSet)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	wallets.SaveFile(s.NodeID)
+
+	return result, nil
+}
+
+func closeChannel(s *Server, params args) (interface{}, error) {
+	return s.spendChannel(params, CloseChannel)
+}
+
+func refundChannel(s *Server, params args) (interface{}, error) {
+	return s.spendChannel(params, RefundChannel)
+}
+
+func (s *Server) spendChannel(params args, spend func(*wallet.Wallets, string) (*blockchain.Transaction, error)) (interface{}, error) {
+	var id string
+	if err := params.get(0, &id); err != nil {
+		return nil, err
+	}
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := spend(wallets, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.node.AddTx(tx); err != nil {
+		return nil, err
+	}
+
+	return hex.EncodeToString(tx.ID), nil
+}
+
+func listChannels(s *Server, params args) (interface{}, error) {
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []ChannelResult
+	s.node.View(func(chain *blockchain.BlockChain) {
+		UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+		results, err = ListChannels(wallets, &UTXOSet)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
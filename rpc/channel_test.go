@@ -0,0 +1,107 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// testWallets returns wallets holding the keys of ws.
+func testWallets(ws ...*wallet.Wallet) *wallet.Wallets {
+	wallets := &wallet.Wallets{
+		Wallets:  make(map[string]*wallet.Wallet),
+		Paths:    make(map[string]string),
+		Next:     make(map[string]uint32),
+		Watched:  make(map[string][]byte),
+		Scripts:  make(map[string][]byte),
+		Channels: make(map[string]*wallet.Channel),
+		Pending:  make(map[string][]byte),
+	}
+	for _, w := range ws {
+		wallets.Wallets[string(w.Address())] = w
+	}
+
+	return wallets
+}
+
+func TestChannelPayments(t *testing.T) {
+	payer, payee := wallet.MakeWallet(), wallet.MakeWallet()
+	payerAddress, payeeAddress := string(payer.Address()), string(payee.Address())
+
+	payerWallets, payeeWallets := testWallets(payer), testWallets(payee)
+	if _, err := payerWallets.ImportPublicKey(payee.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+
+	chain := blockchain.InitBlockchainAt(t.TempDir(), payerAddress)
+	defer chain.Database.Close()
+	UTXO := blockchain.UTXOSet{Blockchain: chain}
+	UTXO.Reindex()
+
+	funding, channel, err := OpenChannel(payerWallets, payerAddress, payeeAddress, 10, time.Hour, &UTXO, blockchain.DefaultSendOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fee, err := chain.Fee(funding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain.MineBlock([]*blockchain.Transaction{funding, blockchain.CoinBaseTx(payerAddress, "", fee)})
+	UTXO.Reindex()
+
+	pay := func(amount int) []byte {
+		payment, err := PayChannel(payerWallets, channel.ID, amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := hex.DecodeString(payment.Payment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	first, second := pay(3), pay(2)
+
+	if result, err := AcceptChannelPayment(payeeWallets, second, &UTXO); err != nil || result.Paid != 5 {
+		t.Fatalf("accepting the latest payment got %+v, %v", result, err)
+	}
+	if _, err := AcceptChannelPayment(payeeWallets, first, &UTXO); err == nil || !strings.Contains(err.Error(), "less than") {
+		t.Fatalf("accepting an older payment got %v", err)
+	}
+
+	forged, err := wallet.DeserializeChannel(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged.Paid = 8
+	if _, err := AcceptChannelPayment(payeeWallets, forged.Serialize(), &UTXO); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Fatalf("accepting a payment the payer did not sign got %v", err)
+	}
+
+	if c, _ := payeeWallets.Channel(channel.ID); c.Paid != 5 {
+		t.Fatalf("the payee kept a payment of %d", c.Paid)
+	}
+
+	closing, err := CloseChannel(payeeWallets, channel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !chain.VerifyTx(closing) {
+		t.Fatal("the closing transaction is invalid")
+	}
+	if out := closing.Outputs[0]; out.Value != 5 || out.Address() != payeeAddress {
+		t.Fatalf("the channel closes paying %d to %s", out.Value, out.Address())
+	}
+
+	refund, err := RefundChannel(payerWallets, channel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chain.VerifyTx(refund) {
+		t.Fatal("the payer can take the funds back before the lock time")
+	}
+}
@@ -0,0 +1,99 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+type Client struct {
+	Address  string
+	User     string
+	Password string
+
+	httpClient *http.Client
+	nextID     int
+}
+
+func NewClient(address, user, password string) *Client {
+	return &Client{
+		Address:    address,
+		User:       user,
+		Password:   password,
+		httpClient: &http.Client{},
+	}
+}
+
+func (c *Client) Ping() bool {
+	conn, err := net.DialTimeout("tcp", c.Address, 500*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+
+	return true
+}
+
+func (c *Client) Call(method string, result interface{}, params ...interface{}) error {
+	var rawParams []json.RawMessage
+
+	for _, param := range params {
+		raw, err := json.Marshal(param)
+		if err != nil {
+			return err
+		}
+		rawParams = append(rawParams, raw)
+	}
+
+	c.nextID++
+	id, _ := json.Marshal(c.nextID)
+	body, err := json.Marshal(Request{rpcVersion, method, rawParams, id})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "http://"+c.Address, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(c.User, c.Password)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("rpc server returned %s", resp.Status)
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *Error          `json:"error"`
+	}
+
+	if err := json.Unmarshal(content, &response); err != nil {
+		return err
+	}
+
+	if response.Error != nil {
+		return response.Error
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(response.Result, result)
+}
@@ -0,0 +1,123 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/network"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// testServer serves the JSON-RPC API of a node whose chain pays its genesis
+// reward to the returned miner and which is connected to no peers.
+func testServer(t *testing.T) (*Server, *httptest.Server, *wallet.Wallet) {
+	miner := wallet.MakeWallet()
+	address := string(miner.Address())
+
+	chain := blockchain.InitBlockchainAt(t.TempDir(), address)
+	t.Cleanup(func() { chain.Database.Close() })
+	UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+	UTXOSet.Reindex()
+
+	node := network.NewNode("node", address, chain, network.NewMemoryNetwork(1).Transport())
+	node.KnownNodes = nil
+
+	s := NewServer("1", "", "user", "password")
+	s.node = node
+
+	httpServer := httptest.NewServer(s.mux)
+	t.Cleanup(httpServer.Close)
+
+	return s, httpServer, miner
+}
+
+func testClient(httpServer *httptest.Server) *Client {
+	return NewClient(strings.TrimPrefix(httpServer.URL, "http://"), "user", "password")
+}
+
+func TestClientForwardsCalls(t *testing.T) {
+	_, httpServer, miner := testServer(t)
+	client := testClient(httpServer)
+
+	var genesis string
+	if err := client.Call("getbestblockhash", &genesis); err != nil {
+		t.Fatal(err)
+	}
+
+	var mined string
+	if err := client.Call("generate", &mined, string(miner.Address())); err != nil {
+		t.Fatal(err)
+	}
+
+	var height int
+	if err := client.Call("getblockcount", &height); err != nil || height != 1 {
+		t.Fatalf("got height %d, %v after mining a block", height, err)
+	}
+
+	var blockHex string
+	if err := client.Call("getblock", &blockHex, mined, false); err != nil {
+		t.Fatal(err)
+	}
+	data, err := hex.DecodeString(blockHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := blockchain.Deserialize(data)
+	if hex.EncodeToString(block.Hash) != mined || hex.EncodeToString(block.PrevHash) != genesis {
+		t.Fatalf("got block %x on %x, want %s on %s", block.Hash, block.PrevHash, mined, genesis)
+	}
+
+	var balance int
+	if err := client.Call("getbalance", &balance, string(miner.Address())); err != nil || balance != 2*blockchain.Subsidy {
+		t.Fatalf("got balance %d, %v, want %d", balance, err, 2*blockchain.Subsidy)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	_, httpServer, _ := testServer(t)
+	client := testClient(httpServer)
+
+	err := client.Call("getblockhash", nil, 5)
+	if rpcErr, ok := err.(*Error); !ok || rpcErr.Code != InvalidParams {
+		t.Errorf("a height out of range got %v", err)
+	}
+
+	err = client.Call("nosuchmethod", nil)
+	if rpcErr, ok := err.(*Error); !ok || rpcErr.Code != MethodNotFound {
+		t.Errorf("an unknown method got %v", err)
+	}
+
+	client.Password = "wrong"
+	if err := client.Call("getblockcount", nil); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("a wrong password got %v", err)
+	}
+}
+
+func TestClientPing(t *testing.T) {
+	_, httpServer, _ := testServer(t)
+	client := testClient(httpServer)
+
+	if !client.Ping() {
+		t.Fatal("a running server does not answer")
+	}
+
+	httpServer.Close()
+	if client.Ping() {
+		t.Fatal("a stopped server answers")
+	}
+}
+
+func TestGenerateWithoutAddress(t *testing.T) {
+	s, _, _ := testServer(t)
+	s.node.MinerAddress = ""
+
+	if _, err := s.Call("generate", nil); err == nil || !strings.Contains(err.Error(), "no address") {
+		t.Fatalf("generating without a reward address got %v", err)
+	}
+	if height, _ := s.Call("getblockcount", nil); height != 0 {
+		t.Fatalf("a block was mined, the height is %v", height)
+	}
+}
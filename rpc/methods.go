@@ -0,0 +1,947 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/network"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+type handler func(s *Server, params args) (interface{}, error)
+
+var methods = map[string]handler{
+	"getblockcount":        getBlockCount,
+	"getbestblockhash":     getBestBlockHash,
+	"getblockhash":         getBlockHash,
+	"getblock":             getBlock,
+	"getrawtransaction":    getRawTransaction,
+	"sendrawtransaction":   sendRawTransaction,
+	"getbalance":           getBalance,
+	"getpendingbalance":    getPendingBalance,
+	"listunspent":          listUnspent,
+	"getmempoolinfo":       getMempoolInfo,
+	"getrawmempool":        getRawMempool,
+	"getmempoolentry":      getMempoolEntry,
+	"estimatefee":          estimateFee,
+	"getpeerinfo":          getPeerInfo,
+	"reindexutxo":          reindexUTXO,
+	"getnewaddress":        getNewAddress,
+	"listaddresses":        listAddresses,
+	"discoveraddresses":    discoverAddresses,
+	"createmnemonic":       createMnemonic,
+	"restorewallet":        restoreWallet,
+	"encryptwallet":        encryptWallet,
+	"walletpassphrase":     walletPassphrase,
+	"walletlock":           walletLock,
+	"getwalletinfo":        getWalletInfo,
+	"importaddress":        importAddress,
+	"importpubkey":         importPubKey,
+	"listwatchonly":        listWatchOnly,
+	"getaddressinfo":       getAddressInfo,
+	"addmultisigaddress":   addMultiSigAddress,
+	"getwalletbalance":     getWalletBalance,
+	"listtransactions":     listTransactions,
+	"createrawtx":          createRawTransaction,
+	"createrawtransaction": createRawTransaction,
+	"decoderawtransaction": decodeRawTransaction,
+	"decodescript":         decodeScript,
+	"sendtoaddress":        sendToAddress,
+	"sendmany":             sendMany,
+	"generate":             generate,
+	"initiateswap":         initiateSwap,
+	"participateswap":      participateSwap,
+	"redeemswap":           redeemSwap,
+	"refundswap":           refundSwap,
+	"auditswap":            auditSwap,
+	"openchannel":          openChannel,
+	"paychannel":           payChannel,
+	"acceptchannelpayment": acceptChannelPayment,
+	"closechannel":         closeChannel,
+	"refundchannel":        refundChannel,
+	"listchannels":         listChannels,
+	"issueasset":           issueAsset,
+	"listassets":           listAssets,
+	"bumpfee":              bumpFee,
+	"timestamp":            timestamp,
+	"verifystamp":          verifyStamp,
+}
+
+type args []json.RawMessage
+
+func (a args) get(i int, v interface{}) error {
+	if i >= len(a) {
+		return &Error{InvalidParams, fmt.Sprintf("missing parameter %d", i)}
+	}
+
+	if err := json.Unmarshal(a[i], v); err != nil {
+		return &Error{InvalidParams, fmt.Sprintf("parameter %d: %s", i, err)}
+	}
+
+	return nil
+}
+
+func (a args) optional(i int, v interface{}) error {
+	if i >= len(a) {
+		return nil
+	}
+
+	return a.get(i, v)
+}
+
+func (a args) hex(i int) ([]byte, error) {
+	var s string
+	if err := a.get(i, &s); err != nil {
+		return nil, err
+	}
+
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, &Error{InvalidParams, fmt.Sprintf("parameter %d: %s", i, err)}
+	}
+
+	return data, nil
+}
+
+func (a args) address(i int) (string, error) {
+	var address string
+	if err := a.get(i, &address); err != nil {
+		return "", err
+	}
+
+	if !wallet.ValidateAddress(address) {
+		return "", &Error{InvalidParams, fmt.Sprintf("invalid address %s", address)}
+	}
+
+	return address, nil
+}
+
+// SendOptions are the optional coin selection parameters of sendtoaddress
+// and createrawtransaction. Given Blocks, a node pays the fee rate it estimates for
+// confirming within that many blocks instead of FeeRate, which is only used
+// while it has no estimate.
+type SendOptions struct {
+	Strategy    string   `json:"strategy,omitempty"`
+	FeeRate     int      `json:"feerate"`
+	Blocks      int      `json:"blocks,omitempty"`
+	Inputs      []string `json:"inputs,omitempty"`
+	Replaceable bool     `json:"replaceable,omitempty"`
+}
+
+func (o SendOptions) Build() (blockchain.SendOptions, error) {
+	if o.FeeRate < 0 {
+		return blockchain.SendOptions{}, errors.New("fee rate must not be negative")
+	}
+
+	selector, err := blockchain.CoinSelectorByName(o.Strategy, o.Inputs)
+	if err != nil {
+		return blockchain.SendOptions{}, err
+	}
+
+	return blockchain.SendOptions{Selector: selector, FeeRate: blockchain.FeeRate(o.FeeRate), Replaceable: o.Replaceable}, nil
+}
+
+// sendOptions reads the send options parameter i. Without a fee rate the
+// rate for confirming within network.DefaultConfTarget blocks is estimated.
+func (s *Server) sendOptions(params args, i int) (blockchain.SendOptions, error) {
+	opts := SendOptions{FeeRate: -1}
+	if err := params.optional(i, &opts); err != nil {
+		return blockchain.SendOptions{}, err
+	}
+
+	if opts.FeeRate < 0 || opts.Blocks != 0 {
+		if opts.FeeRate < 0 {
+			opts.FeeRate = int(blockchain.DefaultFeeRate)
+		}
+		if opts.Blocks == 0 {
+			opts.Blocks = network.DefaultConfTarget
+		}
+
+		rate, err := s.node.EstimateFee(opts.Blocks)
+		if err == nil {
+			opts.FeeRate = int(rate)
+		} else if err != network.ErrNoFeeEstimate {
+			return blockchain.SendOptions{}, &Error{InvalidParams, err.Error()}
+		}
+	}
+
+	sendOpts, err := opts.Build()
+	if err != nil {
+		return blockchain.SendOptions{}, &Error{InvalidParams, err.Error()}
+	}
+
+	return sendOpts, nil
+}
+
+// Payment is one recipient of sendmany.
+type Payment struct {
+	Address string `json:"address"`
+	Amount  int    `json:"amount"`
+}
+
+// PaymentOutputs checks all payments before any is made and turns them into
+// transaction outputs. Paying an address twice is most likely a mistake in a
+// payout list, so it is rejected too.
+func PaymentOutputs(payments []Payment) ([]blockchain.TxOutput, error) {
+	if len(payments) == 0 {
+		return nil, errors.New("no payments")
+	}
+
+	var outputs []blockchain.TxOutput
+	seen := make(map[string]bool)
+
+	for i, payment := range payments {
+		if !wallet.ValidateAddress(payment.Address) {
+			return nil, fmt.Errorf("payment %d: invalid address %s", i+1, payment.Address)
+		}
+		if payment.Amount <= 0 {
+			return nil, fmt.Errorf("payment %d: amount must be positive", i+1)
+		}
+		if seen[payment.Address] {
+			return nil, fmt.Errorf("payment %d: address %s is paid twice", i+1, payment.Address)
+		}
+		seen[payment.Address] = true
+
+		outputs = append(outputs, *blockchain.NewTXOutput(payment.Amount, payment.Address))
+	}
+
+	return outputs, nil
+}
+
+type BlockResult struct {
+	Hash         string   `json:"hash"`
+	PrevHash     string   `json:"previousblockhash"`
+	Height       int      `json:"height"`
+	Timestamp    int64    `json:"time"`
+	Nonce        int      `json:"nonce"`
+	Transactions []string `json:"tx"`
+}
+
+type TxInputResult struct {
+	TxID      string `json:"txid"`
+	Out       int    `json:"vout"`
+	Signature string `json:"signature"`
+	PubKey    string `json:"pubkey"`
+	Script    string `json:"script,omitempty"`
+	Sequence  int    `json:"sequence,omitempty"`
+}
+
+type TxOutputResult struct {
+	Value      int    `json:"value"`
+	PubKeyHash string `json:"pubkeyhash,omitempty"`
+	Address    string `json:"address,omitempty"`
+	Script     string `json:"script,omitempty"`
+	Type       string `json:"type,omitempty"`
+	Asset      string `json:"asset,omitempty"`
+	Tokens     int    `json:"tokens,omitempty"`
+}
+
+type TxResult struct {
+	TxID     string           `json:"txid"`
+	Hex      string           `json:"hex"`
+	Inputs   []TxInputResult  `json:"vin"`
+	Outputs  []TxOutputResult `json:"vout"`
+	LockTime int              `json:"locktime,omitempty"`
+}
+
+type UnspentResult struct {
+	TxID    string `json:"txid"`
+	Out     int    `json:"vout"`
+	Address string `json:"address"`
+	Amount  int    `json:"amount"`
+	Asset   string `json:"asset,omitempty"`
+	Tokens  int    `json:"tokens,omitempty"`
+}
+
+type MempoolInfo struct {
+	Size  int `json:"size"`
+	Bytes int `json:"bytes"`
+}
+
+// MempoolEntryResult describes a memory pool transaction and its package of
+// unconfirmed ancestors and descendants, both counting the transaction
+// itself.
+type MempoolEntryResult struct {
+	Size            int      `json:"size"`
+	Fee             int      `json:"fee"`
+	AncestorCount   int      `json:"ancestorcount"`
+	AncestorSize    int      `json:"ancestorsize"`
+	AncestorFees    int      `json:"ancestorfees"`
+	DescendantCount int      `json:"descendantcount"`
+	DescendantSize  int      `json:"descendantsize"`
+	DescendantFees  int      `json:"descendantfees"`
+	Depends         []string `json:"depends"`
+	SpentBy         []string `json:"spentby"`
+}
+
+type FeeEstimate struct {
+	FeeRate int `json:"feerate"`
+	Blocks  int `json:"blocks"`
+}
+
+type PeerInfo struct {
+	Address string `json:"addr"`
+}
+
+type MnemonicResult struct {
+	Mnemonic string `json:"mnemonic"`
+	Address  string `json:"address"`
+}
+
+type RestoredAddress struct {
+	Address string `json:"address"`
+	Balance int    `json:"balance"`
+}
+
+type RestoreResult struct {
+	Addresses []RestoredAddress `json:"addresses"`
+	Balance   int               `json:"balance"`
+}
+
+func newBlockResult(block *blockchain.Block) BlockResult {
+	result := BlockResult{
+		Hash:      hex.EncodeToString(block.Hash),
+		PrevHash:  hex.EncodeToString(block.PrevHash),
+		Height:    block.Height,
+		Timestamp: block.Timestamp,
+		Nonce:     block.Nonce,
+	}
+
+	for _, tx := range block.Transactions {
+		result.Transactions = append(result.Transactions, hex.EncodeToString(tx.ID))
+	}
+
+	return result
+}
+
+func newTxResult(tx *blockchain.Transaction) TxResult {
+	result := TxResult{
+		TxID:     hex.EncodeToString(tx.ID),
+		Hex:      hex.EncodeToString(tx.Serialize()),
+		LockTime: tx.LockTime,
+	}
+
+	for _, in := range tx.Inputs {
+		result.Inputs = append(result.Inputs, TxInputResult{
+			TxID:      hex.EncodeToString(in.ID),
+			Out:       in.Out,
+			Signature: hex.EncodeToString(in.Signature),
+			PubKey:    hex.EncodeToString(in.PubKey),
+			Script:    hex.EncodeToString(in.Script),
+			Sequence:  in.Sequence,
+		})
+	}
+
+	for _, out := range tx.Outputs {
+		output := TxOutputResult{
+			Value:      out.Value,
+			PubKeyHash: hex.EncodeToString(out.PubKeyHash),
+			Address:    out.Address(),
+			Script:     hex.EncodeToString(out.Script),
+			Asset:      hex.EncodeToString(out.Asset),
+			Tokens:     out.Tokens,
+		}
+		if len(out.Script) > 0 {
+			output.Type = blockchain.ScriptType(out.Script)
+		}
+
+		result.Outputs = append(result.Outputs, output)
+	}
+
+	return result
+}
+
+func getBlockCount(s *Server, params args) (interface{}, error) {
+	var height int
+
+	s.node.View(func(chain *blockchain.BlockChain) {
+		height = chain.GetBestHeight()
+	})
+
+	return height, nil
+}
+
+func getBestBlockHash(s *Server, params args) (interface{}, error) {
+	var hash []byte
+
+	s.node.View(func(chain *blockchain.BlockChain) {
+		hash = chain.LastHash
+	})
+
+	return hex.EncodeToString(hash), nil
+}
+
+func getBlockHash(s *Server, params args) (interface{}, error) {
+	var height int
+	if err := params.get(0, &height); err != nil {
+		return nil, err
+	}
+
+	var hash []byte
+
+	s.node.View(func(chain *blockchain.BlockChain) {
+		iter := chain.Iterator()
+
+		for {
+			block := iter.Next()
+
+			if block.Height == height {
+				hash = block.Hash
+				break
+			}
+
+			if len(block.PrevHash) == 0 || block.Height < height {
+				break
+			}
+		}
+	})
+
+	if hash == nil {
+		return nil, &Error{InvalidParams, "block height out of range"}
+	}
+
+	return hex.EncodeToString(hash), nil
+}
+
+func getBlock(s *Server, params args) (interface{}, error) {
+	hash, err := params.hex(0)
+	if err != nil {
+		return nil, err
+	}
+
+	verbose := true
+	if err := params.optional(1, &verbose); err != nil {
+		return nil, err
+	}
+
+	var block blockchain.Block
+
+	s.node.View(func(chain *blockchain.BlockChain) {
+		block, err = chain.GetBlock(hash)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !verbose {
+		return hex.EncodeToString(block.Serialize()), nil
+	}
+
+	return newBlockResult(&block), nil
+}
+
+func getRawTransaction(s *Server, params args) (interface{}, error) {
+	id, err := params.hex(0)
+	if err != nil {
+		return nil, err
+	}
+
+	verbose := false
+	if err := params.optional(1, &verbose); err != nil {
+		return nil, err
+	}
+
+	tx, ok := s.node.MempoolTx(id)
+	if !ok {
+		s.node.View(func(chain *blockchain.BlockChain) {
+			tx, err = chain.FindTx(id)
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !verbose {
+		return hex.EncodeToString(tx.Serialize()), nil
+	}
+
+	return newTxResult(&tx), nil
+}
+
+func sendRawTransaction(s *Server, params args) (interface{}, error) {
+	data, err := params.hex(0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := blockchain.DecodeTransaction(data)
+	if err != nil {
+		return nil, &Error{InvalidParams, err.Error()}
+	}
+
+	if !bytes.Equal(tx.ID, tx.UnsignedHash()) {
+		return nil, fmt.Errorf("transaction ID does not match its contents")
+	}
+
+	if err := s.node.AddTx(tx); err != nil {
+		return nil, err
+	}
+
+	return hex.EncodeToString(tx.ID), nil
+}
+
+func getBalance(s *Server, params args) (interface{}, error) {
+	address, err := params.address(0)
+	if err != nil {
+		return nil, err
+	}
+
+	balance := 0
+	pubKeyHash := wallet.AddressToPubKeyHash(address)
+
+	s.node.View(func(chain *blockchain.BlockChain) {
+		var asset []byte
+		if asset, err = params.asset(1, chain); err != nil {
+			return
+		}
+
+		UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+		balance = blockchain.TokenBalance(UTXOSet.FindUTXO(pubKeyHash), asset)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return balance, nil
+}
+
+// getPendingBalance returns what unconfirmed transactions add to the
+// balance of an address once they confirm.
+func getPendingBalance(s *Server, params args) (interface{}, error) {
+	address, err := params.address(0)
+	if err != nil {
+		return nil, err
+	}
+
+	balance := 0
+	pubKeyHash := wallet.AddressToPubKeyHash(address)
+
+	s.node.PendingView(func(UTXOSet *blockchain.UTXOSet) {
+		var asset []byte
+		if asset, err = params.asset(1, UTXOSet.Blockchain); err != nil {
+			return
+		}
+
+		balance = UTXOSet.PendingBalance(pubKeyHash, asset)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return balance, nil
+}
+
+func listUnspent(s *Server, params args) (interface{}, error) {
+	address, err := params.address(0)
+	if err != nil {
+		return nil, err
+	}
+
+	unspent := []UnspentResult{}
+	pubKeyHash := wallet.AddressToPubKeyHash(address)
+
+	s.node.View(func(chain *blockchain.BlockChain) {
+		UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+
+		for _, utxo := range UTXOSet.ListUnspent(pubKeyHash) {
+			unspent = append(unspent, UnspentResult{
+				TxID:    hex.EncodeToString(utxo.TxID),
+				Out:     utxo.Out,
+				Address: address,
+				Amount:  utxo.Output.Value,
+				Asset:   hex.EncodeToString(utxo.Output.Asset),
+				Tokens:  utxo.Output.Tokens,
+			})
+		}
+	})
+
+	return unspent, nil
+}
+
+func getMempoolInfo(s *Server, params args) (interface{}, error) {
+	info := MempoolInfo{}
+
+	for _, tx := range s.node.MempoolTxs() {
+		info.Size++
+		info.Bytes += len(tx.Serialize())
+	}
+
+	return info, nil
+}
+
+func getRawMempool(s *Server, params args) (interface{}, error) {
+	txIDs := []string{}
+
+	for _, tx := range s.node.MempoolTxs() {
+		txIDs = append(txIDs, hex.EncodeToString(tx.ID))
+	}
+
+	return txIDs, nil
+}
+
+func getMempoolEntry(s *Server, params args) (interface{}, error) {
+	id, err := params.txID(0)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := s.node.MempoolEntry(id)
+	if !ok {
+		return nil, fmt.Errorf("transaction %x is not in the memory pool", id)
+	}
+
+	result := MempoolEntryResult{
+		Size:            entry.Tx.Size(),
+		Fee:             entry.Fee,
+		AncestorCount:   entry.AncestorCount,
+		AncestorSize:    entry.AncestorSize,
+		AncestorFees:    entry.AncestorFees,
+		DescendantCount: entry.DescendantCount,
+		DescendantSize:  entry.DescendantSize,
+		DescendantFees:  entry.DescendantFees,
+		Depends:         []string{},
+		SpentBy:         []string{},
+	}
+	for _, parent := range entry.Depends {
+		result.Depends = append(result.Depends, hex.EncodeToString(parent))
+	}
+	for _, child := range entry.SpentBy {
+		result.SpentBy = append(result.SpentBy, hex.EncodeToString(child))
+	}
+
+	return result, nil
+}
+
+// estimateFee returns the fee rate to confirm within the number of blocks
+// given, network.DefaultConfTarget by default.
+func estimateFee(s *Server, params args) (interface{}, error) {
+	blocks := network.DefaultConfTarget
+	if err := params.optional(0, &blocks); err != nil {
+		return nil, err
+	}
+
+	rate, err := s.node.EstimateFee(blocks)
+	if err == network.ErrNoFeeEstimate {
+		return nil, err
+	}
+	if err != nil {
+		return nil, &Error{InvalidParams, err.Error()}
+	}
+
+	return FeeEstimate{int(rate), blocks}, nil
+}
+
+func getPeerInfo(s *Server, params args) (interface{}, error) {
+	peers := []PeerInfo{}
+
+	for _, peer := range s.node.Peers() {
+		peers = append(peers, PeerInfo{peer})
+	}
+
+	return peers, nil
+}
+
+func reindexUTXO(s *Server, params args) (interface{}, error) {
+	var count int
+
+	s.node.View(func(chain *blockchain.BlockChain) {
+		UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+		UTXOSet.Reindex()
+		count = UTXOSet.CountTransactions()
+	})
+
+	return count, nil
+}
+
+func getNewAddress(s *Server, params args) (interface{}, error) {
+	s.walletFile.Lock()
+	defer s.walletFile.Unlock()
+
+	path := wallet.DefaultPath
+	if err := params.optional(0, &path); err != nil {
+		return nil, err
+	}
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+	address, err := wallets.AddWalletAt(path)
+	if err != nil {
+		return nil, &Error{InvalidParams, err.Error()}
+	}
+	wallets.SaveFile(s.NodeID)
+
+	return address, nil
+}
+
+func discoverAddresses(s *Server, params args) (interface{}, error) {
+	s.walletFile.Lock()
+	defer s.walletFile.Unlock()
+
+	path, gap := wallet.DefaultPath, wallet.DefaultGap
+	if err := params.optional(0, &path); err != nil {
+		return nil, err
+	}
+	if err := params.optional(1, &gap); err != nil {
+		return nil, err
+	}
+
+	var used map[string]bool
+	s.node.View(func(chain *blockchain.BlockChain) {
+		used = chain.UsedPubKeyHashes()
+	})
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+	found, err := wallets.Discover(path, gap, wallet.UsedIn(used))
+	if err != nil {
+		return nil, err
+	}
+	wallets.SaveFile(s.NodeID)
+
+	if found == nil {
+		found = []string{}
+	}
+
+	return found, nil
+}
+
+func createMnemonic(s *Server, params args) (interface{}, error) {
+	s.walletFile.Lock()
+	defer s.walletFile.Unlock()
+
+	passphrase, path := "", wallet.DefaultPath
+	if err := params.optional(0, &passphrase); err != nil {
+		return nil, err
+	}
+	if err := params.optional(1, &path); err != nil {
+		return nil, err
+	}
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+	if wallets.Seed != nil {
+		return nil, fmt.Errorf("the wallet already has an HD seed")
+	}
+
+	mnemonic, err := wallet.NewMnemonic(wallet.DefaultEntropyBits)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := wallet.MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := wallets.SetSeed(seed); err != nil {
+		return nil, err
+	}
+
+	address, err := wallets.AddWalletAt(path)
+	if err != nil {
+		return nil, &Error{InvalidParams, err.Error()}
+	}
+	wallets.SaveFile(s.NodeID)
+
+	return MnemonicResult{mnemonic, address}, nil
+}
+
+func restoreWallet(s *Server, params args) (interface{}, error) {
+	s.walletFile.Lock()
+	defer s.walletFile.Unlock()
+
+	var mnemonic string
+	if err := params.get(0, &mnemonic); err != nil {
+		return nil, err
+	}
+
+	passphrase, path, gap := "", wallet.DefaultPath, wallet.DefaultGap
+	if err := params.optional(1, &passphrase); err != nil {
+		return nil, err
+	}
+	if err := params.optional(2, &path); err != nil {
+		return nil, err
+	}
+	if err := params.optional(3, &gap); err != nil {
+		return nil, err
+	}
+
+	seed, err := wallet.MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, &Error{InvalidParams, err.Error()}
+	}
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+	if err := wallets.SetSeed(seed); err != nil {
+		return nil, err
+	}
+
+	result := RestoreResult{Addresses: []RestoredAddress{}}
+
+	s.node.View(func(chain *blockchain.BlockChain) {
+		var found []string
+		found, err = wallets.Discover(path, gap, wallet.UsedIn(chain.UsedPubKeyHashes()))
+
+		UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+		for _, address := range found {
+			restored := RestoredAddress{Address: address}
+			for _, out := range UTXOSet.FindUTXO(wallet.AddressToPubKeyHash(address)) {
+				restored.Balance += out.Value
+			}
+
+			result.Addresses = append(result.Addresses, restored)
+			result.Balance += restored.Balance
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	wallets.SaveFile(s.NodeID)
+
+	return result, nil
+}
+
+func listAddresses(s *Server, params args) (interface{}, error) {
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := wallets.GetAllAddresses()
+	if addresses == nil {
+		addresses = []string{}
+	}
+
+	return addresses, nil
+}
+
+func sendToAddress(s *Server, params args) (interface{}, error) {
+	from, err := params.address(0)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := params.address(1)
+	if err != nil {
+		return nil, err
+	}
+
+	var amount int
+	if err := params.get(2, &amount); err != nil {
+		return nil, err
+	}
+
+	if amount <= 0 {
+		return nil, &Error{InvalidParams, "amount must be positive"}
+	}
+
+	opts, err := s.sendOptions(params, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	var asset []byte
+	s.node.View(func(chain *blockchain.BlockChain) {
+		asset, err = params.asset(4, chain)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if asset != nil {
+		return s.send(from, []blockchain.TxOutput{*blockchain.NewTokenOutput(asset, amount, to)}, opts)
+	}
+
+	return s.send(from, []blockchain.TxOutput{*blockchain.NewTXOutput(amount, to)}, opts)
+}
+
+// sendMany pays every recipient from one address in a single transaction
+// with one change output.
+func sendMany(s *Server, params args) (interface{}, error) {
+	from, err := params.address(0)
+	if err != nil {
+		return nil, err
+	}
+
+	var payments []Payment
+	if err := params.get(1, &payments); err != nil {
+		return nil, err
+	}
+
+	outputs, err := PaymentOutputs(payments)
+	if err != nil {
+		return nil, &Error{InvalidParams, err.Error()}
+	}
+
+	opts, err := s.sendOptions(params, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.send(from, outputs, opts)
+}
+
+func (s *Server) send(from string, outputs []blockchain.TxOutput, opts blockchain.SendOptions) (interface{}, error) {
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	if wallets.IsWatchOnly(from) {
+		return nil, fmt.Errorf("address %s is watch-only, build an unsigned transaction with createrawtx", from)
+	}
+	if _, ok := wallets.Wallets[from]; !ok {
+		return nil, fmt.Errorf("address %s is not in the wallet", from)
+	}
+	if wallets.Locked() {
+		return nil, wallet.ErrLocked
+	}
+	w := wallets.GetWallet(from)
+
+	tx, err := s.node.AddNewTx(func(UTXOSet *blockchain.UTXOSet) (*blockchain.Transaction, error) {
+		return blockchain.NewTransactionWith(&w, outputs, UTXOSet, opts)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return hex.EncodeToString(tx.ID), nil
+}
+
+func generate(s *Server, params args) (interface{}, error) {
+	address := s.node.MinerAddress
+	if len(params) > 0 {
+		var err error
+		if address, err = params.address(0); err != nil {
+			return nil, err
+		}
+	}
+
+	if address == "" {
+		return nil, &Error{InvalidParams, "no address to receive the block reward"}
+	}
+
+	block := s.node.MineBlock(address)
+
+	return hex.EncodeToString(block.Hash), nil
+}
@@ -0,0 +1,82 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+func TestPaymentOutputs(t *testing.T) {
+	a, b := string(wallet.MakeWallet().Address()), string(wallet.MakeWallet().Address())
+
+	outputs, err := PaymentOutputs([]Payment{{a, 5}, {b, 7}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 2 || outputs[0].Address() != a || outputs[0].Value != 5 || outputs[1].Address() != b || outputs[1].Value != 7 {
+		t.Fatalf("got %+v", outputs)
+	}
+
+	tests := []struct {
+		name     string
+		payments []Payment
+		err      string
+	}{
+		{"none", nil, "no payments"},
+		{"invalid address", []Payment{{a, 5}, {"bogus", 1}}, "payment 2: invalid address"},
+		{"zero amount", []Payment{{a, 0}}, "payment 1: amount must be positive"},
+		{"negative amount", []Payment{{a, 5}, {b, -1}}, "payment 2: amount must be positive"},
+		{"paid twice", []Payment{{a, 5}, {b, 1}, {a, 2}}, "payment 3: address " + a + " is paid twice"},
+	}
+
+	for _, test := range tests {
+		if _, err := PaymentOutputs(test.payments); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, want %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestSendMany(t *testing.T) {
+	inTempDir(t)
+	s, _, miner := testServer(t)
+	testWallets(miner).SaveFile(s.NodeID)
+
+	payees := []string{string(wallet.MakeWallet().Address()), string(wallet.MakeWallet().Address()), string(wallet.MakeWallet().Address())}
+	payments := []Payment{{payees[0], 3}, {payees[1], 4}, {payees[2], 5}}
+
+	if err := call(t, s, nil, "sendmany", string(miner.Address()), append(payments, Payment{"bogus", 1})); err == nil {
+		t.Fatal("paid a list with an invalid address")
+	}
+	if txs := s.node.MempoolTxs(); len(txs) != 0 {
+		t.Fatalf("a rejected list left %d transactions in the memory pool", len(txs))
+	}
+
+	var txID string
+	if err := call(t, s, &txID, "sendmany", string(miner.Address()), payments, SendOptions{FeeRate: 1}); err != nil {
+		t.Fatal(err)
+	}
+	txs := s.node.MempoolTxs()
+	if len(txs) != 1 || hex.EncodeToString(txs[0].ID) != txID {
+		t.Fatalf("the memory pool holds %d transactions", len(txs))
+	}
+	if outputs := txs[0].Outputs; len(outputs) != len(payments)+1 || outputs[len(payments)].Address() != string(miner.Address()) {
+		t.Fatalf("the transaction pays %+v, want the payments and one change output", outputs)
+	}
+
+	if err := call(t, s, nil, "generate"); err != nil {
+		t.Fatal(err)
+	}
+	for _, payment := range payments {
+		var balance int
+		if err := call(t, s, &balance, "getbalance", payment.Address); err != nil || balance != payment.Amount {
+			t.Errorf("%s has %d, %v, want %d", payment.Address, balance, err, payment.Amount)
+		}
+	}
+
+	if err := call(t, s, nil, "sendmany", string(miner.Address()), []Payment{{payees[0], 10 * blockchain.Subsidy}}); err == nil {
+		t.Fatal("paid more than the balance")
+	}
+}
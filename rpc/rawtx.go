@@ -0,0 +1,189 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// fundRawTransaction builds an unsigned transaction paying amount from a
+// wallet or watch-only address, choosing its inputs like sendtoaddress.
+func fundRawTransaction(s *Server, params args) (interface{}, error) {
+	from, err := params.address(0)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := params.address(1)
+	if err != nil {
+		return nil, err
+	}
+
+	var amount int
+	if err := params.get(2, &amount); err != nil {
+		return nil, err
+	}
+
+	if amount <= 0 {
+		return nil, &Error{InvalidParams, "amount must be positive"}
+	}
+
+	opts, err := s.sendOptions(params, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	var ptx *blockchain.PartialTx
+	s.node.PendingView(func(UTXOSet *blockchain.UTXOSet) {
+		ptx, err = blockchain.NewPartialTxFrom(wallets, from, []blockchain.TxOutput{*blockchain.NewTXOutput(amount, to)}, UTXOSet, opts)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return hex.EncodeToString(ptx.Serialize()), nil
+}
+
+type RawInput struct {
+	TxID     string `json:"txid"`
+	Out      int    `json:"vout"`
+	Sequence int    `json:"sequence,omitempty"`
+}
+
+// RawOutput pays Amount to Address, or locks it with the hex encoded Script.
+type RawOutput struct {
+	Address string `json:"address,omitempty"`
+	Script  string `json:"script,omitempty"`
+	Amount  int    `json:"amount"`
+}
+
+// NewRawTransaction builds the unsigned transaction createrawtransaction
+// returns, checking only that its fields decode.
+func NewRawTransaction(inputs []RawInput, outputs []RawOutput, lockTime int) (*blockchain.Transaction, error) {
+	tx := blockchain.Transaction{LockTime: lockTime}
+
+	for _, input := range inputs {
+		id, err := hex.DecodeString(input.TxID)
+		if err != nil {
+			return nil, fmt.Errorf("input %s: %s", input.TxID, err)
+		}
+
+		tx.Inputs = append(tx.Inputs, blockchain.TxInput{ID: id, Out: input.Out, Sequence: input.Sequence})
+	}
+
+	for _, output := range outputs {
+		if output.Script != "" {
+			script, err := hex.DecodeString(output.Script)
+			if err != nil {
+				return nil, fmt.Errorf("output script %s: %s", output.Script, err)
+			}
+
+			tx.Outputs = append(tx.Outputs, *blockchain.NewScriptOutput(output.Amount, script))
+			continue
+		}
+
+		if !wallet.ValidateAddress(output.Address) {
+			return nil, fmt.Errorf("invalid address %s", output.Address)
+		}
+
+		tx.Outputs = append(tx.Outputs, *blockchain.NewTXOutput(output.Amount, output.Address))
+	}
+
+	tx.ID = tx.UnsignedHash()
+
+	return &tx, nil
+}
+
+// createRawTransaction builds a transaction spending exactly the given
+// outpoints into the given outputs, returned as a hex encoded partially
+// signed transaction. Any difference between inputs and outputs is left as
+// fee. Given an address, amount and recipient instead, it selects the inputs
+// itself, see fundRawTransaction. createrawtx is an alias.
+func createRawTransaction(s *Server, params args) (interface{}, error) {
+	var from string
+	if params.get(0, &from) == nil {
+		return fundRawTransaction(s, params)
+	}
+
+	var inputs []RawInput
+	var outputs []RawOutput
+	if err := params.get(0, &inputs); err != nil {
+		return nil, err
+	}
+	if err := params.get(1, &outputs); err != nil {
+		return nil, err
+	}
+
+	var lockTime int
+	if err := params.optional(2, &lockTime); err != nil {
+		return nil, err
+	}
+
+	tx, err := NewRawTransaction(inputs, outputs, lockTime)
+	if err != nil {
+		return nil, &Error{InvalidParams, err.Error()}
+	}
+
+	var ptx *blockchain.PartialTx
+	s.node.View(func(chain *blockchain.BlockChain) {
+		UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+		ptx, err = blockchain.NewPartialTx(tx, &UTXOSet)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return hex.EncodeToString(ptx.Serialize()), nil
+}
+
+type ScriptResult struct {
+	Asm  string `json:"asm"`
+	Hex  string `json:"hex"`
+	Type string `json:"type"`
+}
+
+func NewScriptResult(script []byte) (ScriptResult, error) {
+	asm, err := blockchain.DisasmScript(script)
+	if err != nil {
+		return ScriptResult{}, err
+	}
+
+	return ScriptResult{asm, hex.EncodeToString(script), blockchain.ScriptType(script)}, nil
+}
+
+func decodeScript(s *Server, params args) (interface{}, error) {
+	script, err := params.hex(0)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := NewScriptResult(script)
+	if err != nil {
+		return nil, &Error{InvalidParams, err.Error()}
+	}
+
+	return result, nil
+}
+
+func decodeRawTransaction(s *Server, params args) (interface{}, error) {
+	data, err := params.hex(0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := blockchain.DecodeTransaction(data)
+	if err != nil {
+		return nil, &Error{InvalidParams, err.Error()}
+	}
+
+	return tx, nil
+}
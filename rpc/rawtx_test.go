@@ -0,0 +1,128 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+func decodePartialTx(t *testing.T, data string) *blockchain.PartialTx {
+	t.Helper()
+
+	raw, err := hex.DecodeString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ptx, err := blockchain.DeserializePartialTx(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ptx
+}
+
+func TestCreateRawTransaction(t *testing.T) {
+	inTempDir(t)
+	s, _, miner := testServer(t)
+	to := string(wallet.MakeWallet().Address())
+
+	var genesis []blockchain.UTXO
+	s.node.View(func(chain *blockchain.BlockChain) {
+		UTXOSet := blockchain.UTXOSet{Blockchain: chain}
+		genesis = UTXOSet.ListUnspent(wallet.PublicKeyHash(miner.PublicKey))
+	})
+
+	inputs := []RawInput{{TxID: hex.EncodeToString(genesis[0].TxID), Out: genesis[0].Out, Sequence: 3}}
+	outputs := []RawOutput{{Address: to, Amount: 15}, {Script: hex.EncodeToString(blockchain.HashLockScript(make([]byte, 32))), Amount: 4}}
+
+	var created, alias string
+	if err := call(t, s, &created, "createrawtransaction", inputs, outputs, 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := call(t, s, &alias, "createrawtx", inputs, outputs, 7); err != nil || alias != created {
+		t.Fatalf("createrawtx got %s, %v, want what createrawtransaction returns", alias, err)
+	}
+
+	ptx := decodePartialTx(t, created)
+	tx := ptx.Tx
+	if tx.LockTime != 7 || tx.Inputs[0].Sequence != 3 || len(tx.Inputs[0].PubKey) != 0 || tx.Outputs[0].Address() != to || len(tx.Outputs[1].Script) == 0 {
+		t.Fatalf("got %+v", tx)
+	}
+	if ptx.Spent[0].Value != blockchain.Subsidy || ptx.Fee() != blockchain.Subsidy-19 {
+		t.Fatalf("spends %+v with fee %d", ptx.Spent, ptx.Fee())
+	}
+
+	// Signing fills in the public key, and the result is accepted.
+	var final string
+	if err := call(t, s, &final, "createrawtransaction", []RawInput{{TxID: inputs[0].TxID, Out: inputs[0].Out}}, outputs); err != nil {
+		t.Fatal(err)
+	}
+	ptx = decodePartialTx(t, final)
+	ptx.Sign(miner)
+	signed, err := ptx.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := call(t, s, nil, "sendrawtransaction", hex.EncodeToString(signed.Serialize())); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		params  []interface{}
+		code    int
+		message string
+	}{
+		{"unknown output", []interface{}{[]RawInput{{TxID: "00", Out: 0}}, outputs}, ServerError, "not unspent"},
+		{"bad input hex", []interface{}{[]RawInput{{TxID: "zz", Out: 0}}, outputs}, InvalidParams, "input zz"},
+		{"invalid address", []interface{}{inputs, []RawOutput{{Address: "bogus", Amount: 1}}}, InvalidParams, "invalid address"},
+		{"bad script hex", []interface{}{inputs, []RawOutput{{Script: "0", Amount: 1}}}, InvalidParams, "output script"},
+		{"missing outputs", []interface{}{inputs}, InvalidParams, "missing parameter 1"},
+		{"address without a public key", []interface{}{string(miner.Address()), to, 5}, ServerError, "public key"},
+	}
+
+	for _, test := range tests {
+		err := call(t, s, nil, "createrawtransaction", test.params...)
+
+		code := ServerError
+		if rpcErr, ok := err.(*Error); ok {
+			code = rpcErr.Code
+		}
+		if err == nil || code != test.code || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("%s: got %v, want error %d containing %q", test.name, err, test.code, test.message)
+		}
+	}
+}
+
+func TestDecodeRawTransaction(t *testing.T) {
+	s, _, miner := testServer(t)
+
+	tx := blockchain.CoinBaseTx(string(miner.Address()), "decode me", 0)
+
+	result, err := s.Call("decoderawtransaction", []json.RawMessage{json.RawMessage(`"` + hex.EncodeToString(tx.Serialize()) + `"`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	for _, param := range []string{`"zz"`, `"00"`} {
+		_, err := s.Call("decoderawtransaction", []json.RawMessage{json.RawMessage(param)})
+		if rpcErr, ok := err.(*Error); !ok || rpcErr.Code != InvalidParams {
+			t.Errorf("decoding %s got %v", param, err)
+		}
+	}
+}
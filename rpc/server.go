@@ -0,0 +1,312 @@
+package rpc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gitferry/blockchain-go/network"
+)
+
+const (
+	cookieFile = "./tmp/rpc_%s.cookie"
+	cookieUser = "__cookie__"
+	rpcVersion = "2.0"
+
+	ParseError     = -32700
+	InvalidRequest = -32600
+	MethodNotFound = -32601
+	InvalidParams  = -32602
+	InternalError  = -32603
+	ServerError    = -32000
+)
+
+type Request struct {
+	JSONRPC string            `json:"jsonrpc"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage   `json:"id,omitempty"`
+}
+
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// MarshalJSON writes a result, null for methods returning nothing, unless
+// the response is an error, as JSON-RPC 2.0 wants exactly one of them.
+func (r Response) MarshalJSON() ([]byte, error) {
+	if r.Error != nil {
+		return json.Marshal(struct {
+			JSONRPC string          `json:"jsonrpc"`
+			Error   *Error          `json:"error"`
+			ID      json.RawMessage `json:"id"`
+		}{r.JSONRPC, r.Error, r.id()})
+	}
+
+	return json.Marshal(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		Result  interface{}     `json:"result"`
+		ID      json.RawMessage `json:"id"`
+	}{r.JSONRPC, r.Result, r.id()})
+}
+
+func (r Response) id() json.RawMessage {
+	if len(r.ID) == 0 {
+		return json.RawMessage("null")
+	}
+
+	return r.ID
+}
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+type Server struct {
+	NodeID   string
+	Address  string
+	User     string
+	Password string
+
+	node       *network.Node
+	httpServer *http.Server
+	mux        *http.ServeMux
+	cookie     string
+
+	walletMu      sync.Mutex
+	walletKey     []byte
+	unlockedUntil time.Time
+	lockTimer     *time.Timer
+
+	// walletFile is held by handlers changing the wallet file from loading it
+	// to saving it, so concurrent calls do not lose each other's changes.
+	walletFile sync.Mutex
+}
+
+func NewServer(nodeID, address, user, password string) *Server {
+	s := &Server{
+		NodeID:   nodeID,
+		Address:  address,
+		User:     user,
+		Password: password,
+		mux:      http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/", s.handleRPC)
+	s.mux.HandleFunc("/ws", s.handleWebSocket)
+
+	return s
+}
+
+func DefaultAddress(nodeID string) string {
+	port, err := strconv.Atoi(nodeID)
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("localhost:%d", port+1000)
+}
+
+func ReadCookie(nodeID string) (string, string, error) {
+	content, err := ioutil.ReadFile(fmt.Sprintf(cookieFile, nodeID))
+	if err != nil {
+		return "", "", err
+	}
+
+	parts := strings.SplitN(strings.TrimSpace(string(content)), ":", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("malformed cookie file")
+	}
+
+	return parts[0], parts[1], nil
+}
+
+func (s *Server) Start(node *network.Node) error {
+	s.node = node
+
+	if s.Password == "" {
+		if err := s.writeCookie(); err != nil {
+			return err
+		}
+	}
+
+	ln, err := net.Listen("tcp", s.Address)
+	if err != nil {
+		return err
+	}
+
+	s.httpServer = &http.Server{Handler: s.mux}
+	go func() {
+		if err := s.httpServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Println(err)
+		}
+	}()
+
+	fmt.Printf("RPC server listening on %s\n", s.Address)
+
+	return nil
+}
+
+func (s *Server) Stop() error {
+	s.lockWallet()
+
+	if s.cookie != "" {
+		os.Remove(s.cookie)
+	}
+
+	if s.httpServer == nil {
+		return nil
+	}
+
+	return s.httpServer.Close()
+}
+
+func (s *Server) writeCookie() error {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+
+	s.User = cookieUser
+	s.Password = hex.EncodeToString(secret)
+	s.cookie = fmt.Sprintf(cookieFile, s.NodeID)
+
+	return ioutil.WriteFile(s.cookie, []byte(s.User+":"+s.Password), 0600)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	userOk := subtle.ConstantTimeCompare([]byte(user), []byte(s.User)) == 1
+	passwordOk := subtle.ConstantTimeCompare([]byte(password), []byte(s.Password)) == 1
+
+	return userOk && passwordOk
+}
+
+func (s *Server) handleRPC(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="jsonrpc"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "JSON-RPC requests must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var result interface{}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var requests []json.RawMessage
+		if err := json.Unmarshal(body, &requests); err != nil {
+			result = errorResponse(nil, ParseError, err.Error())
+		} else if len(requests) == 0 {
+			result = errorResponse(nil, InvalidRequest, "empty batch")
+		} else {
+			var responses []Response
+			for _, raw := range requests {
+				if response, ok := s.handleRequest(raw); ok {
+					responses = append(responses, response)
+				}
+			}
+			if responses != nil {
+				result = responses
+			}
+		}
+	} else if response, ok := s.handleRequest(body); ok {
+		result = response
+	}
+
+	// Notifications are not answered.
+	if result == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Println(err)
+	}
+}
+
+// handleRequest runs a single request. It reports false for notifications,
+// requests without an id, which get no response.
+func (s *Server) handleRequest(raw []byte) (Response, bool) {
+	var req Request
+	var members map[string]json.RawMessage
+
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return errorResponse(nil, ParseError, err.Error()), true
+	}
+
+	if req.JSONRPC != rpcVersion || req.Method == "" {
+		return errorResponse(req.ID, InvalidRequest, "invalid JSON-RPC 2.0 request"), true
+	}
+
+	json.Unmarshal(raw, &members)
+	_, hasID := members["id"]
+
+	result, err := s.Call(req.Method, req.Params)
+	if !hasID {
+		return Response{}, false
+	}
+
+	if err != nil {
+		if rpcErr, ok := err.(*Error); ok {
+			return errorResponse(req.ID, rpcErr.Code, rpcErr.Message), true
+		}
+		return errorResponse(req.ID, ServerError, err.Error()), true
+	}
+
+	return Response{JSONRPC: rpcVersion, Result: result, ID: req.ID}, true
+}
+
+func (s *Server) Call(method string, params []json.RawMessage) (result interface{}, err error) {
+	handler, ok := methods[method]
+	if !ok {
+		return nil, &Error{MethodNotFound, fmt.Sprintf("method %s not found", method)}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			result = nil
+			err = &Error{InternalError, fmt.Sprint(r)}
+		}
+	}()
+
+	return handler(s, args(params))
+}
+
+func errorResponse(id json.RawMessage, code int, message string) Response {
+	return Response{JSONRPC: rpcVersion, Error: &Error{code, message}, ID: id}
+}
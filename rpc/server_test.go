@@ -0,0 +1,48 @@
+package rpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func post(t *testing.T, s *Server, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.SetBasicAuth(s.User, s.Password)
+
+	rec := httptest.NewRecorder()
+	s.handleRPC(rec, req)
+
+	return rec
+}
+
+func TestResponses(t *testing.T) {
+	s := NewServer("1", "", "user", "password")
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		want   string
+	}{
+		{"null result", `{"jsonrpc":"2.0","id":1,"method":"walletlock"}`, http.StatusOK, `{"jsonrpc":"2.0","result":null,"id":1}`},
+		{"error", `{"jsonrpc":"2.0","id":"a","method":"nosuchmethod"}`, http.StatusOK, `{"jsonrpc":"2.0","error":{"code":-32601,"message":"method nosuchmethod not found"},"id":"a"}`},
+		{"parse error", `{"jsonrpc"`, http.StatusOK, `{"jsonrpc":"2.0","error":{"code":-32700,"message":"unexpected end of JSON input"},"id":null}`},
+		{"notification", `{"jsonrpc":"2.0","method":"walletlock"}`, http.StatusNoContent, ``},
+		{"batch", `[{"jsonrpc":"2.0","method":"walletlock"},{"jsonrpc":"2.0","id":2,"method":"walletlock"}]`, http.StatusOK, `[{"jsonrpc":"2.0","result":null,"id":2}]`},
+		{"batch of notifications", `[{"jsonrpc":"2.0","method":"walletlock"}]`, http.StatusNoContent, ``},
+		{"empty batch", `[]`, http.StatusOK, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"empty batch"},"id":null}`},
+	}
+
+	for _, test := range tests {
+		rec := post(t, s, test.body)
+
+		if rec.Code != test.status {
+			t.Errorf("%s: got status %d, want %d", test.name, rec.Code, test.status)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
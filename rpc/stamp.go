@@ -0,0 +1,163 @@
+package rpc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// StampResult proves that a document hash was committed to the chain: data
+// output Out of transaction TxID carries it, and Proof links that
+// transaction to the Merkle root of the block it was mined in. Verified
+// tells whether the proof and the block's proof of work check out.
+type StampResult struct {
+	Hash          string   `json:"hash"`
+	TxID          string   `json:"txid"`
+	Out           int      `json:"vout"`
+	BlockHash     string   `json:"blockhash"`
+	Height        int      `json:"height"`
+	Time          int64    `json:"time"`
+	Confirmations int      `json:"confirmations"`
+	MerkleRoot    string   `json:"merkleroot"`
+	Index         int      `json:"index"`
+	Proof         []string `json:"proof"`
+	Verified      bool     `json:"verified"`
+}
+
+// NewStamp commits the SHA-256 hash of a document to the chain in a data
+// output paid for by the wallet address from.
+func NewStamp(wallets *wallet.Wallets, from string, hash []byte, UTXO *blockchain.UTXOSet, opts blockchain.SendOptions) (*blockchain.Transaction, error) {
+	if len(hash) != sha256.Size {
+		return nil, errors.New("not a SHA-256 hash")
+	}
+
+	w, err := signingWallet(wallets, from)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := blockchain.NewDataOutput(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return blockchain.NewTransactionWith(w, []blockchain.TxOutput{*out}, UTXO, opts)
+}
+
+// FindStamp looks for the earliest confirmed transaction committing hash
+// and proves its inclusion.
+func FindStamp(chain *blockchain.BlockChain, hash []byte) (StampResult, error) {
+	var stamp StampResult
+	found := false
+
+	iter := chain.Iterator()
+	for {
+		block := iter.Next()
+
+		if result, ok := findStampIn(block, hash); ok {
+			stamp, found = result, true
+		}
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	if !found {
+		return StampResult{}, fmt.Errorf("hash %x is not timestamped in the chain", hash)
+	}
+	stamp.Confirmations = chain.GetBestHeight() - stamp.Height + 1
+
+	return stamp, nil
+}
+
+func findStampIn(block *blockchain.Block, hash []byte) (StampResult, bool) {
+	for _, tx := range block.Transactions {
+		for i, out := range tx.Outputs {
+			if data, ok := out.Data(); !ok || !bytes.Equal(data, hash) {
+				continue
+			}
+
+			proof, _ := block.MerkleProof(tx.ID)
+			root := block.HashTransactions()
+
+			result := StampResult{
+				Hash:       hex.EncodeToString(hash),
+				TxID:       hex.EncodeToString(tx.ID),
+				Out:        i,
+				BlockHash:  hex.EncodeToString(block.Hash),
+				Height:     block.Height,
+				Time:       block.Timestamp,
+				MerkleRoot: hex.EncodeToString(root),
+				Index:      proof.Index,
+				Proof:      []string{},
+				Verified:   blockchain.VerifyMerkleProof(tx, proof, root) && blockchain.NewProof(block).Validate(),
+			}
+			for _, sibling := range proof.Hashes {
+				result.Proof = append(result.Proof, hex.EncodeToString(sibling))
+			}
+
+			return result, true
+		}
+	}
+
+	return StampResult{}, false
+}
+
+// timestamp takes from, the hex encoded document hash and optional send
+// options and returns the ID of the stamping transaction.
+func timestamp(s *Server, params args) (interface{}, error) {
+	from, err := params.address(0)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := params.hex(1)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := s.sendOptions(params, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	var tx *blockchain.Transaction
+	tx, err = s.node.AddNewTx(func(UTXOSet *blockchain.UTXOSet) (*blockchain.Transaction, error) {
+		return NewStamp(wallets, from, hash, UTXOSet, opts)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return hex.EncodeToString(tx.ID), nil
+}
+
+func verifyStamp(s *Server, params args) (interface{}, error) {
+	hash, err := params.hex(0)
+	if err != nil {
+		return nil, err
+	}
+
+	var stamp StampResult
+	s.node.View(func(chain *blockchain.BlockChain) {
+		stamp, err = FindStamp(chain, hash)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return stamp, nil
+}
@@ -0,0 +1,382 @@
+package rpc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// Default contract timeouts of an atomic swap. The initiator's contract
+// must outlive the participant's, so the participant can still redeem after
+// learning the secret late.
+const (
+	InitiatorTimeout   = 48 * time.Hour
+	ParticipantTimeout = 24 * time.Hour
+)
+
+// SwapContract describes the hash time-locked contract output of a swap.
+// Secret is only known to the initiator until the contract is redeemed.
+type SwapContract struct {
+	TxID       string `json:"txid"`
+	Out        int    `json:"vout"`
+	Amount     int    `json:"amount"`
+	Recipient  string `json:"recipient"`
+	Refund     string `json:"refund"`
+	SecretHash string `json:"secrethash"`
+	Secret     string `json:"secret,omitempty"`
+	LockTime   int64  `json:"locktime"`
+	Script     string `json:"script"`
+}
+
+// SwapAudit is the state of a swap contract on the chain: open, redeemed,
+// in which case Secret holds the revealed secret, or refunded.
+type SwapAudit struct {
+	SwapContract
+	Confirmed bool   `json:"confirmed"`
+	Status    string `json:"status"`
+	SpendTxID string `json:"spendtxid,omitempty"`
+}
+
+// NewSecret returns a random swap secret and its hash.
+func NewSecret() ([]byte, []byte, error) {
+	secret := make([]byte, blockchain.SecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, nil, err
+	}
+
+	hash := sha256.Sum256(secret)
+
+	return secret, hash[:], nil
+}
+
+// NewSwapContract locks amount from the wallet address from into a contract
+// that to can redeem with the preimage of secretHash until timeout has
+// passed, after which from can take it back.
+func NewSwapContract(wallets *wallet.Wallets, from, to string, amount int, secretHash []byte, timeout time.Duration, UTXO *blockchain.UTXOSet, opts blockchain.SendOptions) (*blockchain.Transaction, SwapContract, error) {
+	w, err := signingWallet(wallets, from)
+	if err != nil {
+		return nil, SwapContract{}, err
+	}
+
+	if !wallet.ValidateAddress(to) || wallet.IsScriptHashAddress(to) {
+		return nil, SwapContract{}, fmt.Errorf("%s is not a public key hash address", to)
+	}
+	if len(secretHash) != sha256.Size {
+		return nil, SwapContract{}, errors.New("secret hash must be a SHA-256 hash")
+	}
+	if amount <= 0 {
+		return nil, SwapContract{}, errors.New("amount must be positive")
+	}
+	if timeout <= 0 {
+		return nil, SwapContract{}, errors.New("timeout must be positive")
+	}
+
+	contract := &blockchain.HTLC{
+		SecretHash:          secretHash,
+		RecipientPubKeyHash: wallet.AddressToPubKeyHash(to),
+		RefundPubKeyHash:    wallet.PublicKeyHash(w.PublicKey),
+		LockTime:            time.Now().Add(timeout).Unix(),
+	}
+
+	tx, err := blockchain.NewTransactionWith(w, []blockchain.TxOutput{*blockchain.NewScriptOutput(amount, contract.Script())}, UTXO, opts)
+	if err != nil {
+		return nil, SwapContract{}, err
+	}
+
+	out, _, err := FindSwapContract(tx)
+	if err != nil {
+		return nil, SwapContract{}, err
+	}
+
+	return tx, newSwapContract(tx, out, contract), nil
+}
+
+// FindSwapContract returns the first hash time-locked contract output of tx.
+func FindSwapContract(tx *blockchain.Transaction) (int, *blockchain.HTLC, error) {
+	for i, out := range tx.Outputs {
+		if contract, err := blockchain.ParseHTLCScript(out.Script); err == nil {
+			return i, contract, nil
+		}
+	}
+
+	return 0, nil, fmt.Errorf("transaction %x has no swap contract", tx.ID)
+}
+
+func newSwapContract(tx *blockchain.Transaction, out int, contract *blockchain.HTLC) SwapContract {
+	return SwapContract{
+		TxID:       hex.EncodeToString(tx.ID),
+		Out:        out,
+		Amount:     tx.Outputs[out].Value,
+		Recipient:  string(wallet.PubKeyHashToAddress(contract.RecipientPubKeyHash)),
+		Refund:     string(wallet.PubKeyHashToAddress(contract.RefundPubKeyHash)),
+		SecretHash: hex.EncodeToString(contract.SecretHash),
+		LockTime:   contract.LockTime,
+		Script:     hex.EncodeToString(tx.Outputs[out].Script),
+	}
+}
+
+// RedeemSwap spends the contract of contractTx to its recipient, which has
+// to be an address of wallets, revealing secret.
+func RedeemSwap(wallets *wallet.Wallets, contractTx *blockchain.Transaction, secret []byte) (*blockchain.Transaction, error) {
+	out, contract, err := FindSwapContract(contractTx)
+	if err != nil {
+		return nil, err
+	}
+
+	recipient := string(wallet.PubKeyHashToAddress(contract.RecipientPubKeyHash))
+	w, err := signingWallet(wallets, recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	return blockchain.NewHTLCRedeemTx(contractTx, out, w, secret, recipient, blockchain.DefaultFeeRate)
+}
+
+// RefundSwap spends the contract of contractTx back to its refund address,
+// which has to be an address of wallets. The transaction is only accepted
+// once the contract's lock time has passed.
+func RefundSwap(wallets *wallet.Wallets, contractTx *blockchain.Transaction) (*blockchain.Transaction, error) {
+	out, contract, err := FindSwapContract(contractTx)
+	if err != nil {
+		return nil, err
+	}
+
+	refund := string(wallet.PubKeyHashToAddress(contract.RefundPubKeyHash))
+	w, err := signingWallet(wallets, refund)
+	if err != nil {
+		return nil, err
+	}
+
+	return blockchain.NewHTLCRefundTx(contractTx, out, w, refund, blockchain.DefaultFeeRate)
+}
+
+// AuditSwap looks up the contract of the transaction txID and whether it has
+// been spent, in the chain or by one of the pending transactions.
+func AuditSwap(chain *blockchain.BlockChain, txID []byte, pending []blockchain.Transaction) (SwapAudit, error) {
+	tx, err := chain.FindTx(txID)
+	confirmed := err == nil
+	if !confirmed {
+		found := false
+		for _, p := range pending {
+			if bytes.Equal(p.ID, txID) {
+				tx, found = p, true
+				break
+			}
+		}
+		if !found {
+			return SwapAudit{}, err
+		}
+	}
+
+	out, contract, err := FindSwapContract(&tx)
+	if err != nil {
+		return SwapAudit{}, err
+	}
+
+	audit := SwapAudit{SwapContract: newSwapContract(&tx, out, contract), Confirmed: confirmed, Status: "open"}
+
+	spender, ok := chain.FindSpendingTx(txID, out)
+	for i := 0; !ok && i < len(pending); i++ {
+		for _, in := range pending[i].Inputs {
+			if bytes.Equal(in.ID, txID) && in.Out == out {
+				spender, ok = &pending[i], true
+			}
+		}
+	}
+	if !ok {
+		return audit, nil
+	}
+
+	audit.SpendTxID = hex.EncodeToString(spender.ID)
+	audit.Status = "refunded"
+	for _, in := range spender.Inputs {
+		if !bytes.Equal(in.ID, txID) || in.Out != out {
+			continue
+		}
+		if secret, ok := blockchain.ExtractSecret(in.Script, contract.SecretHash); ok {
+			audit.Status = "redeemed"
+			audit.Secret = hex.EncodeToString(secret)
+		}
+	}
+
+	return audit, nil
+}
+
+// signingWallet returns the key of an address of wallets that is neither
+// watch-only nor locked.
+func signingWallet(wallets *wallet.Wallets, address string) (*wallet.Wallet, error) {
+	w, ok := wallets.Wallets[address]
+	if !ok {
+		return nil, fmt.Errorf("address %s is not in the wallet", address)
+	}
+	if wallets.Locked() {
+		return nil, wallet.ErrLocked
+	}
+
+	return w, nil
+}
+
+func (a args) duration(i int, def time.Duration) (time.Duration, error) {
+	seconds := int64(def / time.Second)
+	if err := a.optional(i, &seconds); err != nil {
+		return 0, err
+	}
+
+	return time.Duration(seconds) * time.Second, nil
+}
+
+func (a args) txID(i int) ([]byte, error) {
+	id, err := a.hex(i)
+	if err == nil && len(id) != sha256.Size {
+		err = &Error{InvalidParams, fmt.Sprintf("parameter %d: not a transaction ID", i)}
+	}
+
+	return id, err
+}
+
+// initiateSwap creates a secret and locks funds to the other party behind
+// its hash. The result carries the secret, which must not be shared.
+func initiateSwap(s *Server, params args) (interface{}, error) {
+	secret, secretHash, err := NewSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	contract, err := s.newSwap(params, 3, secretHash, InitiatorTimeout)
+	if err != nil {
+		return nil, err
+	}
+	contract.Secret = hex.EncodeToString(secret)
+
+	return contract, nil
+}
+
+// participateSwap locks funds to the initiator behind the secret hash of the
+// initiator's contract.
+func participateSwap(s *Server, params args) (interface{}, error) {
+	secretHash, err := params.hex(3)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.newSwap(params, 4, secretHash, ParticipantTimeout)
+}
+
+// newSwap takes from, to and amount as the first parameters and the optional
+// timeout in seconds and send options from parameter optional on.
+func (s *Server) newSwap(params args, optional int, secretHash []byte, timeout time.Duration) (SwapContract, error) {
+	from, err := params.address(0)
+	if err != nil {
+		return SwapContract{}, err
+	}
+
+	to, err := params.address(1)
+	if err != nil {
+		return SwapContract{}, err
+	}
+
+	var amount int
+	if err := params.get(2, &amount); err != nil {
+		return SwapContract{}, err
+	}
+
+	if timeout, err = params.duration(optional, timeout); err != nil {
+		return SwapContract{}, err
+	}
+
+	opts, err := s.sendOptions(params, optional+1)
+	if err != nil {
+		return SwapContract{}, err
+	}
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return SwapContract{}, err
+	}
+
+	var tx *blockchain.Transaction
+	var contract SwapContract
+	tx, err = s.node.AddNewTx(func(UTXOSet *blockchain.UTXOSet) (*blockchain.Transaction, error) {
+		tx, contract, err = NewSwapContract(wallets, from, to, amount, secretHash, timeout, UTXOSet, opts)
+		return tx, err
+	})
+
+	if err != nil {
+		return SwapContract{}, err
+	}
+
+	return contract, nil
+}
+
+func redeemSwap(s *Server, params args) (interface{}, error) {
+	secret, err := params.hex(1)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.spendSwap(params, func(wallets *wallet.Wallets, contractTx *blockchain.Transaction) (*blockchain.Transaction, error) {
+		return RedeemSwap(wallets, contractTx, secret)
+	})
+}
+
+func refundSwap(s *Server, params args) (interface{}, error) {
+	return s.spendSwap(params, RefundSwap)
+}
+
+func (s *Server) spendSwap(params args, spend func(*wallet.Wallets, *blockchain.Transaction) (*blockchain.Transaction, error)) (interface{}, error) {
+	id, err := params.txID(0)
+	if err != nil {
+		return nil, err
+	}
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	var contractTx blockchain.Transaction
+	s.node.View(func(chain *blockchain.BlockChain) {
+		contractTx, err = chain.FindTx(id)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("contract transaction %x is not confirmed", id)
+	}
+
+	tx, err := spend(wallets, &contractTx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.node.AddTx(tx); err != nil {
+		return nil, err
+	}
+
+	return hex.EncodeToString(tx.ID), nil
+}
+
+func auditSwap(s *Server, params args) (interface{}, error) {
+	id, err := params.txID(0)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := s.node.MempoolTxs()
+
+	var audit SwapAudit
+	s.node.View(func(chain *blockchain.BlockChain) {
+		audit, err = AuditSwap(chain, id, pending)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return audit, nil
+}
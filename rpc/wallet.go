@@ -0,0 +1,401 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+type WalletInfo struct {
+	Encrypted     bool  `json:"encrypted"`
+	Locked        bool  `json:"locked"`
+	UnlockedUntil int64 `json:"unlocked_until,omitempty"`
+}
+
+// readWallets reads the wallet file, or starts an empty wallet if there is
+// none yet. Any other error is returned, as saving the empty wallet in its
+// place would lose the keys.
+func (s *Server) readWallets() (*wallet.Wallets, error) {
+	wallets, err := wallet.CreateWalltes(s.NodeID)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("cannot read the wallet: %s", err)
+	}
+
+	return wallets, nil
+}
+
+// loadWallets reads the wallet file and, while a walletpassphrase unlock is
+// active, unlocks it with the key kept in memory.
+func (s *Server) loadWallets() (*wallet.Wallets, error) {
+	wallets, err := s.readWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	s.walletMu.Lock()
+	key := s.walletKey
+	s.walletMu.Unlock()
+
+	if wallets.Encrypted() && key != nil {
+		if err := wallets.UnlockWithKey(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return wallets, nil
+}
+
+func (s *Server) unlockWallet(key []byte, timeout time.Duration) {
+	s.walletMu.Lock()
+	defer s.walletMu.Unlock()
+
+	if s.lockTimer != nil {
+		s.lockTimer.Stop()
+	}
+
+	s.walletKey = key
+	s.unlockedUntil = time.Now().Add(timeout)
+	s.lockTimer = time.AfterFunc(timeout, s.lockWallet)
+}
+
+func (s *Server) lockWallet() {
+	s.walletMu.Lock()
+	defer s.walletMu.Unlock()
+
+	if s.lockTimer != nil {
+		s.lockTimer.Stop()
+		s.lockTimer = nil
+	}
+
+	s.walletKey = nil
+	s.unlockedUntil = time.Time{}
+}
+
+func encryptWallet(s *Server, params args) (interface{}, error) {
+	s.walletFile.Lock()
+	defer s.walletFile.Unlock()
+
+	var passphrase string
+	if err := params.get(0, &passphrase); err != nil {
+		return nil, err
+	}
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := wallets.Encrypt(passphrase); err != nil {
+		return nil, err
+	}
+	wallets.SaveFile(s.NodeID)
+	s.lockWallet()
+
+	return "wallet encrypted, unlock it with walletpassphrase to spend", nil
+}
+
+func walletPassphrase(s *Server, params args) (interface{}, error) {
+	var passphrase string
+	var timeout int
+	if err := params.get(0, &passphrase); err != nil {
+		return nil, err
+	}
+	if err := params.get(1, &timeout); err != nil {
+		return nil, err
+	}
+
+	if timeout <= 0 {
+		return nil, &Error{InvalidParams, "timeout must be positive"}
+	}
+
+	wallets, err := s.readWallets()
+	if err != nil {
+		return nil, err
+	}
+	if !wallets.Encrypted() {
+		return nil, &Error{ServerError, "the wallet is not encrypted"}
+	}
+
+	if err := wallets.Unlock(passphrase); err != nil {
+		return nil, err
+	}
+
+	s.unlockWallet(wallets.Key(), time.Duration(timeout)*time.Second)
+
+	return nil, nil
+}
+
+func walletLock(s *Server, params args) (interface{}, error) {
+	s.lockWallet()
+
+	return nil, nil
+}
+
+func getWalletInfo(s *Server, params args) (interface{}, error) {
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	info := WalletInfo{Encrypted: wallets.Encrypted(), Locked: wallets.Locked()}
+
+	s.walletMu.Lock()
+	if wallets.Encrypted() && !s.unlockedUntil.IsZero() {
+		info.UnlockedUntil = s.unlockedUntil.Unix()
+	}
+	s.walletMu.Unlock()
+
+	return info, nil
+}
+
+type AddressInfo struct {
+	Address     string `json:"address"`
+	PubKey      string `json:"pubkey,omitempty"`
+	IsMine      bool   `json:"ismine"`
+	IsWatchOnly bool   `json:"iswatchonly"`
+	HDPath      string `json:"hdkeypath,omitempty"`
+	Script      string `json:"redeemscript,omitempty"`
+}
+
+type MultiSigResult struct {
+	Address      string `json:"address"`
+	RedeemScript string `json:"redeemscript"`
+}
+
+// WalletBalance is the confirmed balance of the wallet. Pending is what
+// unconfirmed transactions add to Mine once they confirm.
+type WalletBalance struct {
+	Mine      int `json:"mine"`
+	WatchOnly int `json:"watchonly"`
+	Pending   int `json:"pending"`
+}
+
+type WalletTxResult struct {
+	TxID              string `json:"txid"`
+	BlockHash         string `json:"blockhash"`
+	Height            int    `json:"height"`
+	Received          int    `json:"received"`
+	Sent              int    `json:"sent"`
+	Amount            int    `json:"amount"`
+	InvolvesWatchOnly bool   `json:"involveswatchonly"`
+}
+
+func importAddress(s *Server, params args) (interface{}, error) {
+	s.walletFile.Lock()
+	defer s.walletFile.Unlock()
+
+	address, err := params.address(0)
+	if err != nil {
+		return nil, err
+	}
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := wallets.ImportAddress(address); err != nil {
+		return nil, err
+	}
+	wallets.SaveFile(s.NodeID)
+
+	return address, nil
+}
+
+func importPubKey(s *Server, params args) (interface{}, error) {
+	s.walletFile.Lock()
+	defer s.walletFile.Unlock()
+
+	pubKey, err := params.hex(0)
+	if err != nil {
+		return nil, err
+	}
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := wallets.ImportPublicKey(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	wallets.SaveFile(s.NodeID)
+
+	return address, nil
+}
+
+func listWatchOnly(s *Server, params args) (interface{}, error) {
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := wallets.GetWatchedAddresses()
+	if addresses == nil {
+		addresses = []string{}
+	}
+
+	return addresses, nil
+}
+
+func getAddressInfo(s *Server, params args) (interface{}, error) {
+	address, err := params.address(0)
+	if err != nil {
+		return nil, err
+	}
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	return newAddressInfo(wallets, address), nil
+}
+
+func newAddressInfo(wallets *wallet.Wallets, address string) AddressInfo {
+	_, mine := wallets.Wallets[address]
+	info := AddressInfo{
+		Address:     address,
+		IsMine:      mine,
+		IsWatchOnly: wallets.IsWatchOnly(address),
+		HDPath:      wallets.Paths[address],
+	}
+
+	if pubKey, ok := wallets.PublicKey(address); ok {
+		info.PubKey = hex.EncodeToString(pubKey)
+	}
+	if script, ok := wallets.RedeemScript(address); ok {
+		info.Script = hex.EncodeToString(script)
+	}
+
+	return info
+}
+
+// AddMultiSigAddress adds the address requiring m signatures of keys to the
+// wallet. Keys are hex encoded public keys or addresses whose public key the
+// wallet knows. The order of the keys is part of the address.
+func AddMultiSigAddress(wallets *wallet.Wallets, m int, keys []string) (MultiSigResult, error) {
+	var pubKeys [][]byte
+
+	for _, key := range keys {
+		if wallet.ValidateAddress(key) {
+			pubKey, ok := wallets.PublicKey(key)
+			if !ok {
+				return MultiSigResult{}, fmt.Errorf("the public key of %s is unknown, give it in hex instead", key)
+			}
+			pubKeys = append(pubKeys, pubKey)
+			continue
+		}
+
+		pubKey, err := hex.DecodeString(key)
+		if err != nil {
+			return MultiSigResult{}, fmt.Errorf("%s is neither an address nor a hex encoded public key", key)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	script, err := blockchain.NewMultiSigScript(m, pubKeys)
+	if err != nil {
+		return MultiSigResult{}, err
+	}
+
+	return MultiSigResult{wallets.AddScript(script), hex.EncodeToString(script)}, nil
+}
+
+func addMultiSigAddress(s *Server, params args) (interface{}, error) {
+	s.walletFile.Lock()
+	defer s.walletFile.Unlock()
+
+	var m int
+	if err := params.get(0, &m); err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	if err := params.get(1, &keys); err != nil {
+		return nil, err
+	}
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := AddMultiSigAddress(wallets, m, keys)
+	if err != nil {
+		return nil, &Error{InvalidParams, err.Error()}
+	}
+	wallets.SaveFile(s.NodeID)
+
+	return result, nil
+}
+
+func getWalletBalance(s *Server, params args) (interface{}, error) {
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	var balance WalletBalance
+
+	s.node.PendingView(func(UTXOSet *blockchain.UTXOSet) {
+		var asset []byte
+		if asset, err = params.asset(0, UTXOSet.Blockchain); err != nil {
+			return
+		}
+
+		balance = WalletBalanceOf(wallets, UTXOSet, asset)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return balance, nil
+}
+
+func listTransactions(s *Server, params args) (interface{}, error) {
+	watchOnly := true
+	if err := params.optional(0, &watchOnly); err != nil {
+		return nil, err
+	}
+
+	wallets, err := s.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	var txs []blockchain.WalletTx
+	s.node.View(func(chain *blockchain.BlockChain) {
+		txs = chain.FindWalletTxs(wallets.PubKeyHashes(watchOnly))
+	})
+
+	mine := wallets.PubKeyHashes(false)
+	results := []WalletTxResult{}
+
+	for _, wtx := range txs {
+		result := WalletTxResult{
+			TxID:      hex.EncodeToString(wtx.Tx.ID),
+			BlockHash: hex.EncodeToString(wtx.Block.Hash),
+			Height:    wtx.Block.Height,
+			Received:  wtx.Received,
+			Sent:      wtx.Sent,
+			Amount:    wtx.Received - wtx.Sent,
+		}
+
+		for pubKeyHash := range wtx.Matched {
+			if !mine[pubKeyHash] {
+				result.InvolvesWatchOnly = true
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,143 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/wallet"
+)
+
+// inTempDir runs the test in an empty directory, as wallet files live under
+// ./tmp.
+func inTempDir(t *testing.T) {
+	dir := t.TempDir()
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir("tmp", 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { os.Chdir(cwd) })
+}
+
+// call runs method with params encoded as JSON and decodes its result into
+// result.
+func call(t *testing.T, s *Server, result interface{}, method string, params ...interface{}) error {
+	t.Helper()
+
+	var raw []json.RawMessage
+	for _, param := range params {
+		data, err := json.Marshal(param)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw = append(raw, data)
+	}
+
+	value, err := s.Call(method, raw)
+	if err != nil || result == nil {
+		return err
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return json.Unmarshal(data, result)
+}
+
+func TestWatchOnlyAddresses(t *testing.T) {
+	inTempDir(t)
+	s, _, miner := testServer(t)
+	cold, bare := wallet.MakeWallet(), wallet.MakeWallet()
+	coldAddress, minerAddress := string(cold.Address()), string(miner.Address())
+
+	var address string
+	if err := call(t, s, &address, "importpubkey", hex.EncodeToString(cold.PublicKey)); err != nil || address != coldAddress {
+		t.Fatalf("importing the public key got %s, %v", address, err)
+	}
+	if err := call(t, s, nil, "importaddress", string(bare.Address())); err != nil {
+		t.Fatal(err)
+	}
+
+	var watched []string
+	if err := call(t, s, &watched, "listwatchonly"); err != nil || len(watched) != 2 {
+		t.Fatalf("watching %v, %v", watched, err)
+	}
+
+	var info AddressInfo
+	if err := call(t, s, &info, "getaddressinfo", coldAddress); err != nil {
+		t.Fatal(err)
+	}
+	if info.IsMine || !info.IsWatchOnly || info.PubKey != hex.EncodeToString(cold.PublicKey) {
+		t.Fatalf("got %+v", info)
+	}
+
+	if _, err := s.node.AddNewTx(func(UTXOSet *blockchain.UTXOSet) (*blockchain.Transaction, error) {
+		return blockchain.NewTransactionWith(miner, []blockchain.TxOutput{*blockchain.NewTXOutput(8, coldAddress)}, UTXOSet, blockchain.DefaultSendOptions)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := call(t, s, nil, "generate"); err != nil {
+		t.Fatal(err)
+	}
+
+	var balance WalletBalance
+	if err := call(t, s, &balance, "getwalletbalance"); err != nil || balance != (WalletBalance{WatchOnly: 8}) {
+		t.Fatalf("got balance %+v, %v", balance, err)
+	}
+
+	var txs []WalletTxResult
+	if err := call(t, s, &txs, "listtransactions"); err != nil || len(txs) != 1 || txs[0].Received != 8 || !txs[0].InvolvesWatchOnly {
+		t.Fatalf("got transactions %+v, %v", txs, err)
+	}
+	if err := call(t, s, &txs, "listtransactions", false); err != nil || len(txs) != 0 {
+		t.Fatalf("got transactions %+v, %v without watch-only addresses", txs, err)
+	}
+
+	if err := call(t, s, nil, "sendtoaddress", coldAddress, minerAddress, 3); err == nil || !strings.Contains(err.Error(), "watch-only") {
+		t.Fatalf("sending from a watch-only address got %v", err)
+	}
+	if err := call(t, s, nil, "createrawtx", string(bare.Address()), minerAddress, 3); err == nil || !strings.Contains(err.Error(), "public key") {
+		t.Fatalf("building a transaction from an address without a public key got %v", err)
+	}
+
+	// The unsigned transaction is signed where the key is kept.
+	var unsigned string
+	if err := call(t, s, &unsigned, "createrawtx", coldAddress, minerAddress, 3, SendOptions{FeeRate: 1}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := hex.DecodeString(unsigned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ptx, err := blockchain.DeserializePartialTx(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ptx.Complete() {
+		t.Fatal("the transaction is signed without the key")
+	}
+	if signed := ptx.Sign(cold); signed != 1 {
+		t.Fatalf("signed %d inputs, want 1", signed)
+	}
+	tx, err := ptx.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := call(t, s, nil, "sendrawtransaction", hex.EncodeToString(tx.Serialize())); err != nil {
+		t.Fatal(err)
+	}
+}
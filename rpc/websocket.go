@@ -0,0 +1,92 @@
+package rpc
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gitferry/blockchain-go/network"
+	"golang.org/x/net/websocket"
+)
+
+type EventResult struct {
+	Type   network.EventType `json:"type"`
+	Block  *BlockResult      `json:"block,omitempty"`
+	Tx     *TxResult         `json:"tx,omitempty"`
+	Peer   string            `json:"peer,omitempty"`
+	Reason string            `json:"reason,omitempty"`
+}
+
+func newEventResult(e network.Event) EventResult {
+	result := EventResult{Type: e.Type, Peer: e.Peer, Reason: e.Reason}
+
+	if e.Block != nil {
+		block := newBlockResult(e.Block)
+		result.Block = &block
+	}
+
+	if e.Tx != nil {
+		tx := newTxResult(e.Tx)
+		result.Tx = &tx
+	}
+
+	return result
+}
+
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="jsonrpc"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Subscribing before the handshake delivers every event published once
+	// the client is connected.
+	sub := s.node.Events.Subscribe(eventFilter(r.URL.Query()))
+	defer sub.Unsubscribe()
+
+	websocket.Server{Handler: func(ws *websocket.Conn) {
+		streamEvents(ws, sub)
+	}}.ServeHTTP(w, r)
+}
+
+func eventFilter(query url.Values) network.EventFilter {
+	filter := network.EventFilter{Addresses: query["address"]}
+
+	for _, types := range query["types"] {
+		for _, t := range strings.Split(types, ",") {
+			if t != "" {
+				filter.Types = append(filter.Types, network.EventType(t))
+			}
+		}
+	}
+
+	return filter
+}
+
+func streamEvents(ws *websocket.Conn, sub *network.Subscription) {
+	defer ws.Close()
+
+	closed := make(chan struct{})
+	go func() {
+		var discard []byte
+		for websocket.Message.Receive(ws, &discard) == nil {
+		}
+		close(closed)
+	}()
+
+	for {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+
+			if err := websocket.JSON.Send(ws, newEventResult(e)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
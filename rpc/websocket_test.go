@@ -0,0 +1,105 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gitferry/blockchain-go/blockchain"
+	"github.com/gitferry/blockchain-go/network"
+	"github.com/gitferry/blockchain-go/wallet"
+	"golang.org/x/net/websocket"
+)
+
+func dialEvents(url, query, password string) (*websocket.Conn, error) {
+	config, err := websocket.NewConfig("ws"+strings.TrimPrefix(url, "http")+"/ws?"+query, url)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth("user", password)
+	config.Header = req.Header
+
+	return websocket.DialConfig(config)
+}
+
+func receiveEvent(t *testing.T, ws *websocket.Conn) EventResult {
+	t.Helper()
+
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var e EventResult
+	if err := websocket.JSON.Receive(ws, &e); err != nil {
+		t.Fatal(err)
+	}
+
+	return e
+}
+
+func TestEventStream(t *testing.T) {
+	s, httpServer, miner := testServer(t)
+	watched := string(wallet.MakeWallet().Address())
+
+	all, err := dialEvents(httpServer.URL, "types=blockconnected,txaccepted", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer all.Close()
+
+	watching, err := dialEvents(httpServer.URL, "address="+watched, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watching.Close()
+
+	s.node.Events.Publish(network.Event{Type: network.PeerConnected, Peer: "localhost:3001"})
+
+	first, err := s.Call("generate", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := receiveEvent(t, all); e.Type != network.BlockConnected || e.Block.Hash != first {
+		t.Fatalf("got %+v, want the first mined block", e)
+	}
+
+	tx, err := s.node.AddNewTx(func(UTXOSet *blockchain.UTXOSet) (*blockchain.Transaction, error) {
+		return blockchain.NewTransactionWith(miner, []blockchain.TxOutput{*blockchain.NewTXOutput(5, watched)}, UTXOSet, blockchain.DefaultSendOptions)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ws := range []*websocket.Conn{all, watching} {
+		if e := receiveEvent(t, ws); e.Type != network.TxAccepted || e.Tx.TxID != hex.EncodeToString(tx.ID) || e.Tx.Outputs[0].Address != watched {
+			t.Fatalf("got %+v, want the payment to the watched address", e)
+		}
+	}
+
+	second, err := s.Call("generate", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := receiveEvent(t, all); e.Type != network.BlockConnected || e.Block.Hash != second {
+		t.Fatalf("got %+v, want the second mined block", e)
+	}
+	if e := receiveEvent(t, watching); e.Type != network.TxRemoved || e.Reason != "block" {
+		t.Fatalf("got %+v, want the payment leaving the memory pool", e)
+	}
+	if e := receiveEvent(t, watching); e.Type != network.BlockConnected || e.Block.Hash != second {
+		t.Fatalf("got %+v, want the block paying the watched address", e)
+	}
+}
+
+func TestEventStreamRequiresAuth(t *testing.T) {
+	_, httpServer, _ := testServer(t)
+
+	if ws, err := dialEvents(httpServer.URL, "", "wrong"); err == nil {
+		ws.Close()
+		t.Fatal("connected with a wrong password")
+	}
+}
@@ -0,0 +1,74 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"log"
+	"sort"
+)
+
+// Channel is a payment channel the wallet is the payer or payee of. The
+// funding output FundingTxID:Out holds Capacity locked by Script, and the
+// latest commitment pays Paid of it to the payee. PayerSignature is the
+// payer's signature of that commitment, which is all the payee needs to
+// close the channel.
+type Channel struct {
+	FundingTxID    []byte
+	Out            int
+	Script         []byte
+	Capacity       int
+	Paid           int
+	PayerSignature []byte
+}
+
+// ID names a channel after its funding transaction.
+func (c *Channel) ID() string {
+	return hex.EncodeToString(c.FundingTxID)
+}
+
+// Serialize encodes the channel state, which is also how the payer hands a
+// payment to the payee.
+func (c *Channel) Serialize() []byte {
+	var encoded bytes.Buffer
+
+	if err := gob.NewEncoder(&encoded).Encode(c); err != nil {
+		log.Panic(err)
+	}
+
+	return encoded.Bytes()
+}
+
+func DeserializeChannel(data []byte) (*Channel, error) {
+	var c Channel
+
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&c); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// AddChannel stores a new channel or replaces the state of a known one.
+func (ws *Wallets) AddChannel(c *Channel) string {
+	ws.Channels[c.ID()] = c
+
+	return c.ID()
+}
+
+func (ws *Wallets) Channel(id string) (*Channel, bool) {
+	c, ok := ws.Channels[id]
+
+	return c, ok
+}
+
+func (ws *Wallets) ChannelIDs() []string {
+	var ids []string
+
+	for id := range ws.Channels {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
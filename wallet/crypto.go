@@ -0,0 +1,185 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/gob"
+	"errors"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+	saltLength   = 16
+)
+
+var (
+	ErrLocked           = errors.New("the wallet is locked, unlock it with the wallet passphrase first")
+	ErrWrongPassphrase  = errors.New("the wallet passphrase is incorrect")
+	ErrAlreadyEncrypted = errors.New("the wallet is already encrypted")
+	ErrEmptyPassphrase  = errors.New("the wallet passphrase must not be empty")
+	errNothingToDecrypt = errors.New("the wallet is not encrypted")
+)
+
+// Encryption holds the sealed private key material of an encrypted wallet
+// file together with the scrypt parameters used to derive its key.
+type Encryption struct {
+	Salt       []byte
+	N, R, P    int
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+type walletSecrets struct {
+	Seed []byte
+	Keys map[string][]byte
+}
+
+func (ws *Wallets) Encrypted() bool {
+	return ws.Encryption != nil
+}
+
+func (ws *Wallets) Locked() bool {
+	return ws.Encrypted() && ws.key == nil
+}
+
+// Encrypt seals the wallet with a key derived from passphrase. The wallet
+// stays unlocked until Lock is called.
+func (ws *Wallets) Encrypt(passphrase string) error {
+	if ws.Encrypted() {
+		return ErrAlreadyEncrypted
+	}
+	if passphrase == "" {
+		return ErrEmptyPassphrase
+	}
+
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		return err
+	}
+
+	ws.Encryption = &Encryption{Salt: salt, N: scryptN, R: scryptR, P: scryptP}
+	ws.key = key
+
+	return ws.seal()
+}
+
+func (ws *Wallets) Unlock(passphrase string) error {
+	if !ws.Encrypted() {
+		return errNothingToDecrypt
+	}
+
+	e := ws.Encryption
+	key, err := scrypt.Key([]byte(passphrase), e.Salt, e.N, e.R, e.P, scryptKeyLen)
+	if err != nil {
+		return err
+	}
+
+	return ws.UnlockWithKey(key)
+}
+
+// UnlockWithKey unlocks the wallet with a key previously returned by Key,
+// which lets a long running process skip the deliberately slow scrypt step.
+func (ws *Wallets) UnlockWithKey(key []byte) error {
+	if !ws.Encrypted() {
+		return errNothingToDecrypt
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := gcm.Open(nil, ws.Encryption.Nonce, ws.Encryption.Ciphertext, nil)
+	if err != nil {
+		return ErrWrongPassphrase
+	}
+
+	var secrets walletSecrets
+	if err := gob.NewDecoder(bytes.NewReader(plaintext)).Decode(&secrets); err != nil {
+		return err
+	}
+
+	ws.Seed = secrets.Seed
+	for address, d := range secrets.Keys {
+		if w, ok := ws.Wallets[address]; ok {
+			w.setPrivateKey(d)
+		}
+	}
+	ws.key = key
+
+	return nil
+}
+
+func (ws *Wallets) Key() []byte {
+	return ws.key
+}
+
+// Lock forgets the decrypted seed and private keys.
+func (ws *Wallets) Lock() {
+	if !ws.Encrypted() {
+		return
+	}
+
+	ws.Seed = nil
+	for _, w := range ws.Wallets {
+		w.PrivateKey.D = nil
+	}
+	ws.key = nil
+}
+
+func (ws *Wallets) seal() error {
+	secrets := walletSecrets{Seed: ws.Seed, Keys: make(map[string][]byte)}
+	for address, w := range ws.Wallets {
+		if w.PrivateKey.D != nil {
+			secrets.Keys[address] = w.PrivateKey.D.Bytes()
+		}
+	}
+
+	var plaintext bytes.Buffer
+	if err := gob.NewEncoder(&plaintext).Encode(secrets); err != nil {
+		return err
+	}
+
+	gcm, err := newGCM(ws.key)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	ws.Encryption.Nonce = nonce
+	ws.Encryption.Ciphertext = gcm.Seal(nil, nonce, plaintext.Bytes(), nil)
+
+	return nil
+}
+
+// public returns a copy of the wallet without its private key, which is what
+// an encrypted wallet file stores in the clear.
+func (w *Wallet) public() *Wallet {
+	return &Wallet{PrivateKey: ecdsa.PrivateKey{PublicKey: w.PrivateKey.PublicKey}, PublicKey: w.PublicKey}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,96 @@
+package wallet
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestEncryptedWalletRoundTrip(t *testing.T) {
+	ws := emptyWallets(t)
+	if err := ws.SetSeed(testSeed()); err != nil {
+		t.Fatal(err)
+	}
+	derived := ws.AddWallet()
+	legacy := MakeWallet()
+	ws.Wallets[string(legacy.Address())] = legacy
+
+	if err := ws.Encrypt(""); err != ErrEmptyPassphrase {
+		t.Fatalf("encrypting with an empty passphrase got %v", err)
+	}
+	if err := ws.Encrypt("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Encrypt("other"); err != ErrAlreadyEncrypted {
+		t.Fatalf("encrypting twice got %v", err)
+	}
+	ws.SaveFile("1")
+
+	content, err := ioutil.ReadFile("tmp/wallets_1.data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(content, testSeed()) || bytes.Contains(content, legacy.PrivateKey.D.Bytes()) {
+		t.Fatal("the wallet file holds secrets in the clear")
+	}
+
+	loaded, err := CreateWalltes("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Locked() || loaded.Seed != nil || loaded.Wallets[derived].PrivateKey.D != nil {
+		t.Fatal("a loaded encrypted wallet is not locked")
+	}
+
+	if err := loaded.Unlock("wrong"); err != ErrWrongPassphrase {
+		t.Fatalf("unlocking with the wrong passphrase got %v", err)
+	}
+	if !loaded.Locked() {
+		t.Fatal("the wrong passphrase unlocked the wallet")
+	}
+
+	if err := loaded.Unlock("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(loaded.Seed, testSeed()) {
+		t.Error("the seed changed")
+	}
+	for address, w := range ws.Wallets {
+		if got := loaded.Wallets[address].PrivateKey.D; got == nil || got.Cmp(w.PrivateKey.D) != 0 {
+			t.Errorf("the private key of %s changed", address)
+		}
+	}
+
+	key := loaded.Key()
+	loaded.Lock()
+	if !loaded.Locked() || loaded.Seed != nil {
+		t.Fatal("locking kept the secrets")
+	}
+	if err := loaded.UnlockWithKey(key); err != nil || loaded.Locked() {
+		t.Fatalf("unlocking with the key got %v", err)
+	}
+}
+
+func TestLockedWalletRefusesSecrets(t *testing.T) {
+	ws := emptyWallets(t)
+	if err := ws.SetSeed(testSeed()); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Encrypt("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	ws.Lock()
+
+	if _, err := ws.AddWalletAt(DefaultPath); err != ErrLocked {
+		t.Errorf("AddWalletAt got %v", err)
+	}
+	if err := ws.SetSeed(testSeed()); err != ErrLocked {
+		t.Errorf("SetSeed got %v", err)
+	}
+	if _, err := ws.Discover(DefaultPath, DefaultGap, func(string) bool { return false }); err != ErrLocked {
+		t.Errorf("Discover got %v", err)
+	}
+	if len(ws.Wallets) != 0 {
+		t.Errorf("a locked wallet derived %d addresses", len(ws.Wallets))
+	}
+}
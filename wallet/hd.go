@@ -0,0 +1,182 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	HardenedOffset = uint32(0x80000000)
+	DefaultPath    = "m/44'/0'/0'/0"
+	DefaultGap     = 20
+	seedLength     = 32
+)
+
+var masterKeySalt = []byte("blockchain-go seed")
+
+var ErrInvalidChild = errors.New("derived key is invalid, use the next index")
+
+// childKey is the derivation Derive uses. Invalid children come up about
+// once in 2^32 indexes, so tests replace it to produce them.
+var childKey = (*ExtendedKey).Child
+
+// ExtendedKey is a private key together with the chain code needed to derive
+// its children, following BIP32 but on the P256 curve used by our wallets.
+type ExtendedKey struct {
+	Key       []byte
+	ChainCode []byte
+	Depth     uint8
+	Index     uint32
+}
+
+func NewSeed() []byte {
+	seed := make([]byte, seedLength)
+	if _, err := rand.Read(seed); err != nil {
+		log.Panic(err)
+	}
+
+	return seed
+}
+
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	mac := hmac.New(sha512.New, masterKeySalt)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	if !validScalar(sum[:32]) {
+		return nil, errors.New("seed produces an invalid master key")
+	}
+
+	return &ExtendedKey{Key: sum[:32], ChainCode: sum[32:]}, nil
+}
+
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	var data []byte
+
+	if index >= HardenedOffset {
+		data = append([]byte{0x00}, k.Key...)
+	} else {
+		curve := elliptic.P256()
+		x, y := curve.ScalarBaseMult(k.Key)
+		data = elliptic.MarshalCompressed(curve, x, y)
+	}
+
+	indexBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(indexBytes, index)
+	data = append(data, indexBytes...)
+
+	mac := hmac.New(sha512.New, k.ChainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	if !validScalar(sum[:32]) {
+		return nil, ErrInvalidChild
+	}
+
+	n := elliptic.P256().Params().N
+	child := new(big.Int).SetBytes(sum[:32])
+	child.Add(child, new(big.Int).SetBytes(k.Key))
+	child.Mod(child, n)
+
+	if child.Sign() == 0 {
+		return nil, ErrInvalidChild
+	}
+
+	return &ExtendedKey{
+		Key:       paddedBytes(child, 32),
+		ChainCode: sum[32:],
+		Depth:     k.Depth + 1,
+		Index:     index,
+	}, nil
+}
+
+func (k *ExtendedKey) Derive(path []uint32) (*ExtendedKey, error) {
+	key := k
+	for _, index := range path {
+		var err error
+		if key, err = childKey(key, index); err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+func (k *ExtendedKey) Wallet() *Wallet {
+	curve := elliptic.P256()
+
+	private := ecdsa.PrivateKey{D: new(big.Int).SetBytes(k.Key)}
+	private.PublicKey.Curve = curve
+	private.PublicKey.X, private.PublicKey.Y = curve.ScalarBaseMult(k.Key)
+
+	return &Wallet{private, publicKeyBytes(&private.PublicKey)}
+}
+
+// ParsePath parses derivation paths such as m/44'/0'/0'/0, where a trailing
+// ' or h marks a hardened index.
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("derivation path %q must start with m", path)
+	}
+
+	var indexes []uint32
+	for _, part := range parts[1:] {
+		offset := uint32(0)
+		if strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h") {
+			offset = HardenedOffset
+			part = part[:len(part)-1]
+		}
+
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(index) >= HardenedOffset {
+			return nil, fmt.Errorf("invalid index %q in derivation path %q", part, path)
+		}
+
+		indexes = append(indexes, uint32(index)+offset)
+	}
+
+	return indexes, nil
+}
+
+func FormatPath(indexes []uint32) string {
+	path := "m"
+	for _, index := range indexes {
+		if index >= HardenedOffset {
+			path += fmt.Sprintf("/%d'", index-HardenedOffset)
+		} else {
+			path += fmt.Sprintf("/%d", index)
+		}
+	}
+
+	return path
+}
+
+func validScalar(b []byte) bool {
+	k := new(big.Int).SetBytes(b)
+
+	return k.Sign() > 0 && k.Cmp(elliptic.P256().Params().N) < 0
+}
+
+func paddedBytes(n *big.Int, size int) []byte {
+	b := n.Bytes()
+	if len(b) >= size {
+		return b
+	}
+
+	return append(make([]byte, size-len(b)), b...)
+}
+
+func publicKeyBytes(pub *ecdsa.PublicKey) []byte {
+	return append(paddedBytes(pub.X, 32), paddedBytes(pub.Y, 32)...)
+}
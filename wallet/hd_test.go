@@ -0,0 +1,217 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"testing"
+)
+
+// testSeed is the seed 00 01 02 ... 1f.
+func testSeed() []byte {
+	seed := make([]byte, 32)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+
+	return seed
+}
+
+// emptyWallets returns the wallets of a node without a wallet file yet.
+func emptyWallets(t *testing.T) *Wallets {
+	t.Helper()
+	inTempDir(t)
+
+	ws, err := CreateWalltes("1")
+	if !os.IsNotExist(err) {
+		t.Fatalf("got %v, want no wallet file", err)
+	}
+
+	return ws
+}
+
+// addressAt is the address at index below path of seed.
+func addressAt(t *testing.T, seed []byte, path string, index uint32) string {
+	t.Helper()
+
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexes, err := ParsePath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := master.Derive(append(indexes, index))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(key.Wallet().Address())
+}
+
+func TestDerive(t *testing.T) {
+	master, err := NewMasterKey(testSeed())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hex.EncodeToString(master.Key), "b0d10442257f3f22d53efd8c77515c335c803df325d46a5472427b9b7d9b8c4d"; got != want {
+		t.Fatalf("master key is %s, want %s", got, want)
+	}
+
+	path, err := ParsePath("m/44'/0'/0'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := master.Derive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := hex.EncodeToString(key.Key), "bb5098a176632c8a2a7c7626f0579e69f067b295ebc27e49adddb68ff5a047d1"; got != want {
+		t.Errorf("key is %s, want %s", got, want)
+	}
+	if got, want := hex.EncodeToString(key.ChainCode), "59c4f5e4acb08ab2757a2727bd20a952942a0d67811f31553d3c034f89a76d20"; got != want {
+		t.Errorf("chain code is %s, want %s", got, want)
+	}
+	if key.Depth != 3 || key.Index != HardenedOffset {
+		t.Errorf("depth %d and index %d, want 3 and %d", key.Depth, key.Index, HardenedOffset)
+	}
+	if got := FormatPath(path); got != "m/44'/0'/0'" {
+		t.Errorf("path formats as %s", got)
+	}
+}
+
+func TestHardenedAndNormalChildren(t *testing.T) {
+	parent, err := NewMasterKey(testSeed())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	normal, err := parent.Child(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hardened, err := parent.Child(HardenedOffset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(normal.Key, hardened.Key) {
+		t.Fatal("the hardened child is the normal child")
+	}
+
+	// The public key of a normal child follows from the public key and
+	// chain code of its parent, that of a hardened child does not.
+	curve := elliptic.P256()
+	x, y := curve.ScalarBaseMult(parent.Key)
+	for _, child := range []*ExtendedKey{normal, hardened} {
+		data := elliptic.MarshalCompressed(curve, x, y)
+		data = append(data, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(data[len(data)-4:], child.Index)
+
+		mac := hmac.New(sha512.New, parent.ChainCode)
+		mac.Write(data)
+		tweakX, tweakY := curve.ScalarBaseMult(mac.Sum(nil)[:32])
+		publicX, publicY := curve.Add(x, y, tweakX, tweakY)
+
+		childX, childY := curve.ScalarBaseMult(child.Key)
+		public := childX.Cmp(publicX) == 0 && childY.Cmp(publicY) == 0
+		if public != (child.Index < HardenedOffset) {
+			t.Errorf("the public key of child %d follows from the parent's: %t", child.Index, public)
+		}
+	}
+}
+
+func TestInvalidChildrenAreSkipped(t *testing.T) {
+	const path = "m/44'/0'/0'/0"
+
+	defer func(original func(*ExtendedKey, uint32) (*ExtendedKey, error)) { childKey = original }(childKey)
+	childKey = func(k *ExtendedKey, index uint32) (*ExtendedKey, error) {
+		if k.Depth == 4 && index == 1 {
+			return nil, ErrInvalidChild
+		}
+		return k.Child(index)
+	}
+
+	ws := emptyWallets(t)
+	if err := ws.SetSeed(testSeed()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, index := range []uint32{0, 2} {
+		address, err := ws.AddWalletAt(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := addressAt(t, testSeed(), path, index); address != want {
+			t.Fatalf("derived %s, want the address at index %d", address, index)
+		}
+	}
+
+	restored := emptyWallets(t)
+	if err := restored.SetSeed(testSeed()); err != nil {
+		t.Fatal(err)
+	}
+	used := addressAt(t, testSeed(), path, 2)
+	found, err := restored.Discover(path, 3, func(address string) bool { return address == used })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0] != used || restored.Next[path] != 3 {
+		t.Fatalf("discovered %v with next index %d", found, restored.Next[path])
+	}
+}
+
+func TestDiscoverGapLimit(t *testing.T) {
+	const path = "m/44'/0'/0'/0"
+	seed := testSeed()
+
+	used := map[string]bool{
+		addressAt(t, seed, path, 0):  true,
+		addressAt(t, seed, path, 20): true,
+		addressAt(t, seed, path, 41): true,
+	}
+	isUsed := func(address string) bool { return used[address] }
+
+	tests := []struct {
+		gap   int
+		found []uint32
+	}{
+		{DefaultGap, []uint32{0, 20}},
+		{19, []uint32{0}},
+		{21, []uint32{0, 20, 41}},
+	}
+
+	for _, test := range tests {
+		ws := emptyWallets(t)
+		if err := ws.SetSeed(seed); err != nil {
+			t.Fatal(err)
+		}
+
+		found, err := ws.Discover(path, test.gap, isUsed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(found) != len(test.found) {
+			t.Fatalf("gap %d: found %d addresses, want %d", test.gap, len(found), len(test.found))
+		}
+		for i, index := range test.found {
+			if found[i] != addressAt(t, seed, path, index) {
+				t.Errorf("gap %d: address %d is not the one at index %d", test.gap, i, index)
+			}
+		}
+
+		next := test.found[len(test.found)-1] + 1
+		if ws.Next[path] != next {
+			t.Errorf("gap %d: next index is %d, want %d", test.gap, ws.Next[path], next)
+		}
+		if len(ws.Wallets) != int(next) {
+			t.Errorf("gap %d: kept %d addresses, want %d", test.gap, len(ws.Wallets), next)
+		}
+	}
+}
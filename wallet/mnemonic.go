@@ -0,0 +1,113 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	DefaultEntropyBits = 128
+	seedIterations     = 2048
+)
+
+// english.txt is the BIP39 english wordlist, sha256
+// 2f5eed53a4727b4bf8880d8f3f199efc90e58503646d9ff8eff3a2ed3b24dbda.
+//
+//go:embed english.txt
+var englishList string
+
+var (
+	wordList  = strings.Fields(englishList)
+	wordIndex = make(map[string]int, len(wordList))
+)
+
+func init() {
+	for i, word := range wordList {
+		wordIndex[word] = i
+	}
+}
+
+func NewMnemonic(bits int) (string, error) {
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", fmt.Errorf("entropy must be 128 to 256 bits in steps of 32, not %d", bits)
+	}
+
+	entropy := make([]byte, bits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+
+	return EntropyToMnemonic(entropy), nil
+}
+
+// EntropyToMnemonic appends a checksum of len(entropy)/4 bits to the entropy
+// and encodes every 11 bits as one word.
+func EntropyToMnemonic(entropy []byte) string {
+	checksumBits := len(entropy) * 8 / 32
+	hash := sha256.Sum256(entropy)
+
+	data := new(big.Int).SetBytes(entropy)
+	data.Lsh(data, uint(checksumBits))
+	data.Or(data, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+
+	words := make([]string, (len(entropy)*8+checksumBits)/11)
+	mask := big.NewInt(2047)
+	for i := len(words) - 1; i >= 0; i-- {
+		index := new(big.Int).And(data, mask)
+		words[i] = wordList[index.Int64()]
+		data.Rsh(data, 11)
+	}
+
+	return strings.Join(words, " ")
+}
+
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, fmt.Errorf("mnemonic must have 12, 15, 18, 21 or 24 words, not %d", len(words))
+	}
+
+	data := new(big.Int)
+	for _, word := range words {
+		index, ok := wordIndex[word]
+		if !ok {
+			return nil, fmt.Errorf("%q is not in the wordlist", word)
+		}
+		data.Lsh(data, 11)
+		data.Or(data, big.NewInt(int64(index)))
+	}
+
+	checksumBits := len(words) * 11 / 33
+	checksum := new(big.Int).And(data, big.NewInt(int64(1<<uint(checksumBits)-1)))
+	data.Rsh(data, uint(checksumBits))
+
+	entropy := paddedBytes(data, checksumBits*4)
+	hash := sha256.Sum256(entropy)
+	if checksum.Int64() != int64(hash[0]>>uint(8-checksumBits)) {
+		return nil, errors.New("mnemonic checksum is invalid")
+	}
+
+	return entropy, nil
+}
+
+// MnemonicToSeed checks the mnemonic and stretches it, together with the
+// optional passphrase, into a 64 byte wallet seed. Passphrases are used as
+// given rather than NFKD normalized, which only matters outside ASCII.
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	if _, err := MnemonicToEntropy(mnemonic); err != nil {
+		return nil, err
+	}
+
+	password := strings.Join(strings.Fields(mnemonic), " ")
+	salt := "mnemonic" + passphrase
+
+	return pbkdf2.Key([]byte(password), []byte(salt), seedIterations, 64, sha512.New), nil
+}
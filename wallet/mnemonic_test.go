@@ -0,0 +1,106 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// TestMnemonicVectors checks the reference vectors of BIP39, which use the
+// passphrase TREZOR.
+func TestMnemonicVectors(t *testing.T) {
+	tests := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			"00000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			"legal winner thank year wave sausage worth useful legal winner thank yellow",
+			"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			"80808080808080808080808080808080",
+			"letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+			"d71de856f81a8acc65e6fc851a38d4d7ec216fd0796d0a6827a3ad6ed5511a30fa280f12eb2e47ed2ac03b5c462a0358d18d69fe4f985ec81778c1b370b652a8",
+		},
+		{
+			"ffffffffffffffffffffffffffffffff",
+			"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+			"ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+		},
+		{
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+			"bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8",
+		},
+	}
+
+	for _, test := range tests {
+		entropy, err := hex.DecodeString(test.entropy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if mnemonic := EntropyToMnemonic(entropy); mnemonic != test.mnemonic {
+			t.Errorf("%s: mnemonic is %q, want %q", test.entropy, mnemonic, test.mnemonic)
+		}
+
+		decoded, err := MnemonicToEntropy(test.mnemonic)
+		if err != nil || hex.EncodeToString(decoded) != test.entropy {
+			t.Errorf("%s: entropy is %x, %v", test.entropy, decoded, err)
+		}
+
+		seed, err := MnemonicToSeed(test.mnemonic, "TREZOR")
+		if err != nil || hex.EncodeToString(seed) != test.seed {
+			t.Errorf("%s: seed is %x, %v", test.entropy, seed, err)
+		}
+	}
+}
+
+func TestMnemonicRejectsBadInput(t *testing.T) {
+	abandon := strings.Repeat("abandon ", 11)
+
+	tests := []struct {
+		name     string
+		mnemonic string
+		err      string
+	}{
+		{"checksum", abandon + "abandon", "checksum is invalid"},
+		{"swapped words", "legal winner thank year wave sausage worth useful legal winner yellow thank", "checksum is invalid"},
+		{"unknown word", abandon + "bitcoin", "not in the wordlist"},
+		{"word count", abandon, "must have 12"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := MnemonicToSeed(test.mnemonic, ""); err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("got %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestNewMnemonic(t *testing.T) {
+	for _, bits := range []int{128, 160, 192, 224, 256} {
+		mnemonic, err := NewMnemonic(bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if words := len(strings.Fields(mnemonic)); words != (bits+bits/32)/11 {
+			t.Errorf("%d bits make %d words", bits, words)
+		}
+		if _, err := MnemonicToEntropy(mnemonic); err != nil {
+			t.Errorf("%d bits: %s", bits, err)
+		}
+	}
+
+	if _, err := NewMnemonic(100); err == nil {
+		t.Error("made a mnemonic of 100 bits")
+	}
+}
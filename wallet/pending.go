@@ -0,0 +1,30 @@
+package wallet
+
+import "sort"
+
+// AddPending remembers a serialized transaction the wallet sent that has
+// not been mined yet, under its hex encoded ID.
+func (ws *Wallets) AddPending(id string, tx []byte) {
+	ws.Pending[id] = tx
+}
+
+// RemovePending forgets a transaction that was mined or can no longer be.
+func (ws *Wallets) RemovePending(id string) {
+	delete(ws.Pending, id)
+}
+
+// PendingTxs returns the serialized unmined transactions, ordered by ID.
+func (ws *Wallets) PendingTxs() [][]byte {
+	var ids []string
+	for id := range ws.Pending {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var txs [][]byte
+	for _, id := range ids {
+		txs = append(txs, ws.Pending[id])
+	}
+
+	return txs
+}
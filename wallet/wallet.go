@@ -6,8 +6,11 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/gob"
 	"log"
+	"math/big"
 
+	"github.com/mr-tron/base58"
 	"golang.org/x/crypto/ripemd160"
 )
 
@@ -17,8 +20,9 @@ type Wallet struct {
 }
 
 const (
-	checksumLength = 4
-	version        = byte(0x00)
+	checksumLength    = 4
+	version           = byte(0x00)
+	scriptHashVersion = byte(0x05)
 )
 
 func NewKeyPair() (ecdsa.PrivateKey, []byte) {
@@ -29,11 +33,57 @@ func NewKeyPair() (ecdsa.PrivateKey, []byte) {
 		log.Panic(err)
 	}
 
-	pub := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
+	pub := publicKeyBytes(&private.PublicKey)
 
 	return *private, pub
 }
 
+type walletData struct {
+	D         []byte
+	PublicKey []byte
+}
+
+func (w Wallet) GobEncode() ([]byte, error) {
+	var content bytes.Buffer
+
+	data := walletData{PublicKey: w.PublicKey}
+	if w.PrivateKey.D != nil {
+		data.D = w.PrivateKey.D.Bytes()
+	}
+	err := gob.NewEncoder(&content).Encode(data)
+
+	return content.Bytes(), err
+}
+
+func (w *Wallet) GobDecode(content []byte) error {
+	var data walletData
+
+	if err := gob.NewDecoder(bytes.NewReader(content)).Decode(&data); err != nil {
+		return err
+	}
+
+	w.PublicKey = data.PublicKey
+	w.PrivateKey.PublicKey.Curve = elliptic.P256()
+
+	if len(data.D) == 0 {
+		half := len(data.PublicKey) / 2
+		w.PrivateKey.PublicKey.X = new(big.Int).SetBytes(data.PublicKey[:half])
+		w.PrivateKey.PublicKey.Y = new(big.Int).SetBytes(data.PublicKey[half:])
+		return nil
+	}
+
+	w.setPrivateKey(data.D)
+
+	return nil
+}
+
+func (w *Wallet) setPrivateKey(d []byte) {
+	curve := elliptic.P256()
+	w.PrivateKey.PublicKey.Curve = curve
+	w.PrivateKey.D = new(big.Int).SetBytes(d)
+	w.PrivateKey.PublicKey.X, w.PrivateKey.PublicKey.Y = curve.ScalarBaseMult(d)
+}
+
 func MakeWallet() *Wallet {
 	privateKey, publicKey := NewKeyPair()
 
@@ -65,24 +115,64 @@ func CheckSum(pubHash []byte) []byte {
 
 func (w Wallet) Address() []byte {
 	pubKeyHash := PublicKeyHash(w.PublicKey)
+	address := PubKeyHashToAddress(pubKeyHash)
+
+	// fmt.Printf("pub key: %x\n", w.PublicKey)
+	// fmt.Printf("pubkey hash: %x\n", pubKeyHash)
+	// fmt.Printf("address: %x\n", address)
 
-	versionedHash := append([]byte{version}, pubKeyHash...)
+	return address
+}
+
+func PubKeyHashToAddress(pubKeyHash []byte) []byte {
+	return encodeAddress(version, pubKeyHash)
+}
+
+// ScriptHash is the hash a pay to script hash address is made of.
+func ScriptHash(script []byte) []byte {
+	return PublicKeyHash(script)
+}
+
+// ScriptHashToAddress encodes the address paying to the script hashed to
+// scriptHash. These addresses start with a 3 instead of a 1.
+func ScriptHashToAddress(scriptHash []byte) []byte {
+	return encodeAddress(scriptHashVersion, scriptHash)
+}
+
+// IsScriptHashAddress reports whether address pays to a script hash rather
+// than a public key hash.
+func IsScriptHashAddress(address string) bool {
+	decoded, err := base58.Decode(address)
+
+	return err == nil && ValidateAddress(address) && decoded[0] == scriptHashVersion
+}
+
+func encodeAddress(version byte, hash []byte) []byte {
+	versionedHash := append([]byte{version}, hash...)
 	checkSum := CheckSum(versionedHash)
 
 	fullHash := append(versionedHash, checkSum...)
 	address := Base58Encode(fullHash)
 
-	// fmt.Printf("pub key: %x\n", w.PublicKey)
-	// fmt.Printf("pubkey hash: %x\n", pubKeyHash)
-	// fmt.Printf("address: %x\n", address)
-
 	return address
 }
 
-func ValidateAddress(address string) bool {
+func AddressToPubKeyHash(address string) []byte {
 	pubKeyHash := Base58Decode([]byte(address))
+
+	return pubKeyHash[1 : len(pubKeyHash)-checksumLength]
+}
+
+func ValidateAddress(address string) bool {
+	pubKeyHash, err := base58.Decode(address)
+	if err != nil || len(pubKeyHash) <= checksumLength {
+		return false
+	}
 	actualChecksum := pubKeyHash[len(pubKeyHash)-checksumLength:]
 	version := pubKeyHash[0]
+	if version != byte(0x00) && version != scriptHashVersion {
+		return false
+	}
 	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-checksumLength]
 	targetChecksum := CheckSum(append([]byte{version}, pubKeyHash...))
 
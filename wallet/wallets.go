@@ -2,18 +2,43 @@ package wallet
 
 import (
 	"bytes"
+	"crypto/ecdsa"
 	"crypto/elliptic"
 	"encoding/gob"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 )
 
 const walletFile = "./tmp/wallets_%s.data"
 
+// Wallets holds the keys of a node. Keys derived from Seed can be recreated
+// from the seed alone, Wallets only caches them; keys without an entry in
+// Paths are legacy random keys that have to be backed up individually.
+// When Encryption is set the file only stores public keys in the clear and
+// Seed and the private keys are available after Unlock. Watched holds
+// watch-only addresses with their public key, if it was imported. Scripts
+// holds the redeem scripts of pay to script hash addresses such as multisig
+// addresses, which are watched as well. Channels holds the payment channels
+// the wallet is a party of. Pending holds the serialized transactions the
+// wallet sent without a node that are not mined yet.
 type Wallets struct {
-	Wallets map[string]*Wallet
+	Wallets    map[string]*Wallet
+	Seed       []byte
+	Paths      map[string]string
+	Next       map[string]uint32
+	Encryption *Encryption
+	Watched    map[string][]byte
+	Scripts    map[string][]byte
+	Channels   map[string]*Channel
+	Pending    map[string][]byte
+
+	key []byte
 }
 
 func (ws *Wallets) LoadFile(nodeId string) error {
@@ -33,17 +58,88 @@ func (ws *Wallets) LoadFile(nodeId string) error {
 	err = decoder.Decode(&wallets)
 
 	if err != nil {
-		return err
+		legacy, legacyErr := decodeLegacyWallets(fileContent)
+		if legacyErr != nil {
+			return err
+		}
+		wallets.Wallets = legacy
 	}
 
 	ws.Wallets = wallets.Wallets
+	ws.Seed = wallets.Seed
+	ws.Encryption = wallets.Encryption
+	if wallets.Paths != nil {
+		ws.Paths = wallets.Paths
+	}
+	if wallets.Next != nil {
+		ws.Next = wallets.Next
+	}
+	if wallets.Watched != nil {
+		ws.Watched = wallets.Watched
+	}
+	if wallets.Scripts != nil {
+		ws.Scripts = wallets.Scripts
+	}
+	if wallets.Channels != nil {
+		ws.Channels = wallets.Channels
+	}
+	if wallets.Pending != nil {
+		ws.Pending = wallets.Pending
+	}
 
 	return nil
 }
 
+// legacyWallet is a key pair as the original wallet files stored it, with
+// the ecdsa key encoded field by field.
+type legacyWallet struct {
+	PrivateKey ecdsa.PrivateKey
+	PublicKey  []byte
+}
+
+// legacyCurve decodes the curve of a legacyWallet, which older Go versions
+// registered with gob as crypto/elliptic.p256Curve. Newer ones cannot encode
+// their curve at all, hence the encoding of Wallet as walletData.
+type legacyCurve struct {
+	*elliptic.CurveParams
+}
+
+// decodeLegacyWallets reads a wallet file of the original format, which
+// held nothing but key pairs. Saving the wallet again migrates it.
+func decodeLegacyWallets(content []byte) (map[string]*Wallet, error) {
+	var legacy struct {
+		Wallets map[string]*legacyWallet
+	}
+
+	gob.RegisterName("crypto/elliptic.p256Curve", legacyCurve{})
+
+	if err := gob.NewDecoder(bytes.NewReader(content)).Decode(&legacy); err != nil {
+		return nil, err
+	}
+
+	wallets := make(map[string]*Wallet)
+	for address, lw := range legacy.Wallets {
+		if lw.PrivateKey.D == nil {
+			return nil, fmt.Errorf("the key of %s is missing", address)
+		}
+
+		w := &Wallet{PublicKey: lw.PublicKey}
+		w.setPrivateKey(lw.PrivateKey.D.Bytes())
+		wallets[address] = w
+	}
+
+	return wallets, nil
+}
+
 func CreateWalltes(nodeId string) (*Wallets, error) {
 	wallets := Wallets{}
 	wallets.Wallets = make(map[string]*Wallet)
+	wallets.Paths = make(map[string]string)
+	wallets.Next = make(map[string]uint32)
+	wallets.Watched = make(map[string][]byte)
+	wallets.Scripts = make(map[string][]byte)
+	wallets.Channels = make(map[string]*Channel)
+	wallets.Pending = make(map[string][]byte)
 
 	err := wallets.LoadFile(nodeId)
 
@@ -65,12 +161,151 @@ func (ws *Wallets) GetAllAddresses() []string {
 }
 
 func (ws *Wallets) AddWallet() string {
-	wallet := MakeWallet()
+	address, err := ws.AddWalletAt(DefaultPath)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return address
+}
+
+// SetSeed installs the HD seed of a new or restored wallet. Legacy keys are
+// kept, but a wallet never changes its seed once it has one.
+func (ws *Wallets) SetSeed(seed []byte) error {
+	if ws.Locked() {
+		return ErrLocked
+	}
+	if ws.Seed != nil && !bytes.Equal(ws.Seed, seed) {
+		return errors.New("the wallet already has a different HD seed")
+	}
+
+	ws.Seed = seed
+
+	return nil
+}
+
+// AddWalletAt derives the next unused key below path, creating the master
+// seed on first use.
+func (ws *Wallets) AddWalletAt(path string) (string, error) {
+	indexes, err := ParsePath(path)
+	if err != nil {
+		return "", err
+	}
+	path = FormatPath(indexes)
+
+	if ws.Locked() {
+		return "", ErrLocked
+	}
+
+	if ws.Seed == nil {
+		ws.Seed = NewSeed()
+	}
+
+	for {
+		index := ws.Next[path]
+		ws.Next[path] = index + 1
+
+		address, err := ws.derive(indexes, index)
+		if err == ErrInvalidChild {
+			continue
+		}
+
+		return address, err
+	}
+}
+
+// Discover derives the addresses below path in order until gap consecutive
+// addresses are reported unused, keeping every address up to the last used
+// one. It returns the addresses that were found in use.
+func (ws *Wallets) Discover(path string, gap int, used func(address string) bool) ([]string, error) {
+	if ws.Locked() {
+		return nil, ErrLocked
+	}
+
+	if ws.Seed == nil {
+		return nil, errors.New("the wallet has no HD seed")
+	}
+
+	indexes, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	path = FormatPath(indexes)
+
+	var found []string
+	unused := 0
+
+	for index := uint32(0); unused < gap; index++ {
+		address, err := ws.derive(indexes, index)
+		if err == ErrInvalidChild {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if !used(address) {
+			unused++
+			continue
+		}
+
+		unused = 0
+		found = append(found, address)
+		if ws.Next[path] <= index {
+			ws.Next[path] = index + 1
+		}
+	}
+
+	for address, addressPath := range ws.Paths {
+		if index, ok := childIndex(addressPath, path); ok && index >= ws.Next[path] {
+			delete(ws.Wallets, address)
+			delete(ws.Paths, address)
+		}
+	}
+
+	return found, nil
+}
+
+// UsedIn adapts a set of hex encoded public key hashes, as returned by
+// BlockChain.UsedPubKeyHashes, to the callback Discover expects.
+func UsedIn(pubKeyHashes map[string]bool) func(address string) bool {
+	return func(address string) bool {
+		return pubKeyHashes[hex.EncodeToString(AddressToPubKeyHash(address))]
+	}
+}
+
+func (ws *Wallets) derive(indexes []uint32, index uint32) (string, error) {
+	master, err := NewMasterKey(ws.Seed)
+	if err != nil {
+		return "", err
+	}
+
+	full := append(append([]uint32{}, indexes...), index)
+	key, err := master.Derive(full)
+	if err != nil {
+		return "", err
+	}
+
+	wallet := key.Wallet()
 	address := fmt.Sprintf("%s", wallet.Address())
 
 	ws.Wallets[address] = wallet
+	ws.Paths[address] = FormatPath(full)
 
-	return address
+	return address, nil
+}
+
+func childIndex(addressPath, path string) (uint32, bool) {
+	if !strings.HasPrefix(addressPath, path+"/") {
+		return 0, false
+	}
+
+	index, err := strconv.ParseUint(strings.TrimPrefix(addressPath, path+"/"), 10, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	return uint32(index), true
 }
 
 func (ws *Wallets) SaveFile(nodeId string) {
@@ -79,14 +314,43 @@ func (ws *Wallets) SaveFile(nodeId string) {
 
 	gob.Register(elliptic.P256())
 
+	file := ws
+	if ws.Encrypted() {
+		if !ws.Locked() {
+			if err := ws.seal(); err != nil {
+				log.Panic(err)
+			}
+		}
+
+		file = &Wallets{Wallets: make(map[string]*Wallet), Paths: ws.Paths, Next: ws.Next, Encryption: ws.Encryption, Watched: ws.Watched, Scripts: ws.Scripts, Channels: ws.Channels, Pending: ws.Pending}
+		for address, w := range ws.Wallets {
+			file.Wallets[address] = w.public()
+		}
+	}
+
 	encoder := gob.NewEncoder(&content)
-	err := encoder.Encode(ws)
+	err := encoder.Encode(file)
+
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// The file is replaced in one step, so a concurrent LoadFile never reads
+	// it half written.
+	tmpFile := walletFile + ".tmp"
+	err = ioutil.WriteFile(tmpFile, content.Bytes(), 0600)
+	if err != nil {
+		log.Panic(err)
+	}
 
+	// WriteFile keeps the mode of an existing file, so tighten files left
+	// behind with another mode as well.
+	err = os.Chmod(tmpFile, 0600)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	err = ioutil.WriteFile(walletFile, content.Bytes(), 0644)
+	err = os.Rename(tmpFile, walletFile)
 	if err != nil {
 		log.Panic(err)
 	}
@@ -0,0 +1,89 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"encoding/gob"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// inTempDir runs the test in an empty directory, as wallet files live under
+// ./tmp.
+func inTempDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallets")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir("tmp", 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		os.Chdir(cwd)
+		os.RemoveAll(dir)
+	})
+}
+
+func TestLoadLegacyWalletFile(t *testing.T) {
+	inTempDir(t)
+
+	w := MakeWallet()
+	address := string(w.Address())
+
+	// The original format: the key pairs with the ecdsa key encoded field by
+	// field and its curve registered as crypto/elliptic.p256Curve.
+	gob.RegisterName("crypto/elliptic.p256Curve", legacyCurve{})
+	key := w.PrivateKey
+	key.PublicKey.Curve = legacyCurve{elliptic.P256().Params()}
+	legacy := struct {
+		Wallets map[string]*legacyWallet
+	}{map[string]*legacyWallet{address: {key, w.PublicKey}}}
+
+	var content bytes.Buffer
+	if err := gob.NewEncoder(&content).Encode(legacy); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile("tmp/wallets_1.data", content.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range []string{"legacy", "migrated"} {
+		wallets, err := CreateWalltes("1")
+		if err != nil {
+			t.Fatalf("%s: %s", step, err)
+		}
+
+		got, ok := wallets.Wallets[address]
+		if !ok {
+			t.Fatalf("%s: wallet %s is missing", step, address)
+		}
+		if got.PrivateKey.D.Cmp(w.PrivateKey.D) != 0 || got.PrivateKey.X.Cmp(w.PrivateKey.X) != 0 || !bytes.Equal(got.PublicKey, w.PublicKey) {
+			t.Fatalf("%s: the key pair changed", step)
+		}
+
+		wallets.SaveFile("1")
+	}
+}
+
+func TestLoadFileRejectsCorruptFile(t *testing.T) {
+	inTempDir(t)
+
+	if err := ioutil.WriteFile("tmp/wallets_1.data", []byte("not a wallet"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CreateWalltes("1"); err == nil || os.IsNotExist(err) {
+		t.Fatalf("got %v, want a decoding error", err)
+	}
+}
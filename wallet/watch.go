@@ -0,0 +1,137 @@
+package wallet
+
+import (
+	"crypto/elliptic"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ImportAddress starts watching an address whose private key is kept
+// elsewhere.
+func (ws *Wallets) ImportAddress(address string) error {
+	if !ValidateAddress(address) {
+		return fmt.Errorf("invalid address %s", address)
+	}
+
+	if _, ok := ws.Wallets[address]; ok {
+		return fmt.Errorf("the wallet already holds the private key of %s", address)
+	}
+
+	if _, ok := ws.Watched[address]; !ok {
+		ws.Watched[address] = nil
+	}
+
+	return nil
+}
+
+// ImportPublicKey watches the address of pubKey. Unlike a bare address this
+// allows building unsigned transactions spending its outputs.
+func (ws *Wallets) ImportPublicKey(pubKey []byte) (string, error) {
+	pubKey, err := ParsePublicKey(pubKey)
+	if err != nil {
+		return "", err
+	}
+
+	address := string(PubKeyHashToAddress(PublicKeyHash(pubKey)))
+	if err := ws.ImportAddress(address); err != nil {
+		return "", err
+	}
+	ws.Watched[address] = pubKey
+
+	return address, nil
+}
+
+// AddScript stores the redeem script of the pay to script hash address it
+// returns. The wallet can sign for that address only together with others,
+// so it is watch-only.
+func (ws *Wallets) AddScript(script []byte) string {
+	address := string(ScriptHashToAddress(ScriptHash(script)))
+	ws.Scripts[address] = script
+
+	return address
+}
+
+// RedeemScript returns the script a pay to script hash address of the wallet
+// pays to.
+func (ws *Wallets) RedeemScript(address string) ([]byte, bool) {
+	script, ok := ws.Scripts[address]
+
+	return script, ok
+}
+
+func (ws *Wallets) IsWatchOnly(address string) bool {
+	_, watched := ws.Watched[address]
+	_, script := ws.Scripts[address]
+
+	return watched || script
+}
+
+func (ws *Wallets) GetWatchedAddresses() []string {
+	var addresses []string
+
+	for address := range ws.Watched {
+		addresses = append(addresses, address)
+	}
+	for address := range ws.Scripts {
+		if _, ok := ws.Watched[address]; !ok {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+
+	return addresses
+}
+
+// PublicKey returns the public key of an address of the wallet, watch-only
+// or not, if it is known.
+func (ws *Wallets) PublicKey(address string) ([]byte, bool) {
+	if w, ok := ws.Wallets[address]; ok {
+		return w.PublicKey, true
+	}
+
+	pubKey := ws.Watched[address]
+
+	return pubKey, pubKey != nil
+}
+
+// ParsePublicKey accepts P256 public keys as raw X||Y, uncompressed or
+// compressed points and returns them in the raw form used by transactions.
+func ParsePublicKey(pubKey []byte) ([]byte, error) {
+	curve := elliptic.P256()
+
+	switch len(pubKey) {
+	case 64:
+		pubKey = append([]byte{4}, pubKey...)
+		fallthrough
+	case 65:
+		if x, y := elliptic.Unmarshal(curve, pubKey); x != nil {
+			return append(paddedBytes(x, 32), paddedBytes(y, 32)...), nil
+		}
+	case 33:
+		if x, y := elliptic.UnmarshalCompressed(curve, pubKey); x != nil {
+			return append(paddedBytes(x, 32), paddedBytes(y, 32)...), nil
+		}
+	}
+
+	return nil, errors.New("not a valid P256 public key")
+}
+
+// PubKeyHashes returns the hex encoded public key hashes of the wallet's own
+// addresses and, if requested, of its watch-only addresses.
+func (ws *Wallets) PubKeyHashes(watchOnly bool) map[string]bool {
+	hashes := make(map[string]bool)
+
+	for address := range ws.Wallets {
+		hashes[hex.EncodeToString(AddressToPubKeyHash(address))] = true
+	}
+
+	if watchOnly {
+		for _, address := range ws.GetWatchedAddresses() {
+			hashes[hex.EncodeToString(AddressToPubKeyHash(address))] = true
+		}
+	}
+
+	return hashes
+}
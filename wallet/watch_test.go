@@ -0,0 +1,122 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"encoding/hex"
+	"testing"
+)
+
+func TestImportAddress(t *testing.T) {
+	ws := emptyWallets(t)
+	own, cold := MakeWallet(), MakeWallet()
+	ws.Wallets[string(own.Address())] = own
+
+	if err := ws.ImportAddress("bogus"); err == nil {
+		t.Error("imported an invalid address")
+	}
+	if err := ws.ImportAddress(string(own.Address())); err == nil {
+		t.Error("imported an address the wallet holds the key of")
+	}
+
+	address := string(cold.Address())
+	if err := ws.ImportAddress(address); err != nil {
+		t.Fatal(err)
+	}
+	if !ws.IsWatchOnly(address) || ws.IsWatchOnly(string(own.Address())) {
+		t.Fatal("the watch-only addresses are wrong")
+	}
+	if _, ok := ws.PublicKey(address); ok {
+		t.Fatal("a bare address has a public key")
+	}
+	if got := ws.GetWatchedAddresses(); len(got) != 1 || got[0] != address {
+		t.Fatalf("watching %v", got)
+	}
+
+	// Importing the public key later makes the address spendable externally.
+	if _, err := ws.ImportPublicKey(cold.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	if pubKey, ok := ws.PublicKey(address); !ok || !bytes.Equal(pubKey, cold.PublicKey) {
+		t.Fatal("the imported public key is not known")
+	}
+	if err := ws.ImportAddress(address); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ws.PublicKey(address); !ok {
+		t.Fatal("importing the address again forgot its public key")
+	}
+}
+
+func TestImportPublicKeyFormats(t *testing.T) {
+	cold := MakeWallet()
+	x, y := cold.PrivateKey.PublicKey.X, cold.PrivateKey.PublicKey.Y
+
+	formats := map[string][]byte{
+		"raw":          cold.PublicKey,
+		"uncompressed": elliptic.Marshal(elliptic.P256(), x, y),
+		"compressed":   elliptic.MarshalCompressed(elliptic.P256(), x, y),
+	}
+
+	for name, pubKey := range formats {
+		ws := emptyWallets(t)
+
+		address, err := ws.ImportPublicKey(pubKey)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if address != string(cold.Address()) {
+			t.Errorf("%s: imported %s, want %s", name, address, cold.Address())
+		}
+		if got, _ := ws.PublicKey(address); !bytes.Equal(got, cold.PublicKey) {
+			t.Errorf("%s: stored %x, want the raw key", name, got)
+		}
+	}
+
+	notOnCurve, _ := hex.DecodeString("04" + "01" + hex.EncodeToString(make([]byte, 63)))
+	for _, pubKey := range [][]byte{nil, cold.PublicKey[:40], notOnCurve} {
+		if _, err := ParsePublicKey(pubKey); err == nil {
+			t.Errorf("parsed the invalid public key %x", pubKey)
+		}
+	}
+}
+
+func TestWatchOnlySurvivesLockedWallet(t *testing.T) {
+	ws := emptyWallets(t)
+	own, cold := MakeWallet(), MakeWallet()
+	ws.Wallets[string(own.Address())] = own
+
+	address, err := ws.ImportPublicKey(cold.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := []byte("redeem script")
+	scriptAddress := ws.AddScript(script)
+
+	if err := ws.Encrypt("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	ws.SaveFile("1")
+
+	loaded, err := CreateWalltes("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Locked() {
+		t.Fatal("the loaded wallet is not locked")
+	}
+	if pubKey, ok := loaded.PublicKey(address); !ok || !bytes.Equal(pubKey, cold.PublicKey) {
+		t.Fatal("the watch-only public key was lost")
+	}
+	if got, ok := loaded.RedeemScript(scriptAddress); !ok || !bytes.Equal(got, script) {
+		t.Fatal("the redeem script was lost")
+	}
+
+	hashes := loaded.PubKeyHashes(false)
+	if len(hashes) != 1 || !hashes[hex.EncodeToString(PublicKeyHash(own.PublicKey))] {
+		t.Fatalf("the wallet's own key hashes are %v", hashes)
+	}
+	if hashes := loaded.PubKeyHashes(true); len(hashes) != 3 || !hashes[hex.EncodeToString(PublicKeyHash(cold.PublicKey))] {
+		t.Fatalf("the key hashes with watch-only addresses are %v", hashes)
+	}
+}